@@ -21,6 +21,10 @@ limitations under the License.
 // hardware/kernel/device specific programming implementation, if any.
 package core
 
+import (
+	"time"
+)
+
 // Address is a string representation of a network address (mac, ip, dns-name, url etc)
 type Address struct {
 	addr string
@@ -75,18 +79,23 @@ type Plugin interface {
 // InstanceInfo encapsulates data that is specific to a running instance of
 // netplugin like label of host on which it is started.
 type InstanceInfo struct {
-	StateDriver  StateDriver `json:"-"`
-	HostLabel    string      `json:"host-label"`
-	CtrlIP       string      `json:"ctrl-ip"`
-	VtepIP       string      `json:"vtep-ip"`
-	UplinkIntf   []string    `json:"uplink-if"`
-	RouterIP     string      `json:"router-ip"`
-	FwdMode      string      `json:"fwd-mode"`
-	ArpMode      string      `json:"arp-mode"`
-	DbURL        string      `json:"db-url"`
-	PluginMode   string      `json:"plugin-mode"`
-	HostPvtNW    int         `json:"host-pvt-nw"`
-	VxlanUDPPort int         `json:"vxlan-port"`
+	StateDriver       StateDriver `json:"-"`
+	HostLabel         string      `json:"host-label"`
+	CtrlIP            string      `json:"ctrl-ip"`
+	VtepIP            string      `json:"vtep-ip"`
+	UplinkIntf        []string    `json:"uplink-if"`
+	RouterIP          string      `json:"router-ip"`
+	FwdMode           string      `json:"fwd-mode"`
+	ArpMode           string      `json:"arp-mode"`
+	DbURL             string      `json:"db-url"`
+	DbCACert          string      `json:"db-ca-cert"`
+	DbCert            string      `json:"db-cert"`
+	DbKey             string      `json:"db-key"`
+	PluginMode        string      `json:"plugin-mode"`
+	HostPvtNW         int         `json:"host-pvt-nw"`
+	VxlanUDPPort      int         `json:"vxlan-port"`
+	PolicyLog         string      `json:"policy-log"`
+	LocalhostPortMaps bool        `json:"localhost-port-maps"`
 }
 
 // PortSpec defines protocol/port info required to host the service
@@ -104,6 +113,37 @@ type ServiceSpec struct {
 	ExternalIPs []string // externally visible IPs
 }
 
+// PortMapSpec defines a host port published to an endpoint port
+type PortMapSpec struct {
+	Protocol string `json:"protocol"` // TCP or UDP
+	HostIP   string `json:"hostIP"`   // host address, all addresses if empty
+	HostPort uint16 `json:"hostPort"` // port on the node
+	EpPort   uint16 `json:"epPort"`   // port of the endpoint
+}
+
+// PolicyRuleStats has the hit counters of a policy rule on a node
+type PolicyRuleStats struct {
+	Packets uint64 `json:"packets"` // packets matching the rule
+	Bytes   uint64 `json:"bytes"`   // bytes matching the rule
+}
+
+// PolicyLogRecord is a packet logged by a policy rule with logging enabled
+type PolicyLogRecord struct {
+	Time        time.Time `json:"time"`                  // time the packet was seen
+	Host        string    `json:"host"`                  // host logging the packet
+	RuleID      string    `json:"ruleId"`                // rule id of the datapath rule
+	Action      string    `json:"action"`                // allow, deny or reject
+	Protocol    uint8     `json:"protocol"`              // ip protocol
+	SrcIP       string    `json:"srcIP"`                 // source address
+	DstIP       string    `json:"dstIP"`                 // destination address
+	SrcPort     uint16    `json:"srcPort,omitempty"`     // source port
+	DstPort     uint16    `json:"dstPort,omitempty"`     // destination port
+	SrcEpg      string    `json:"srcEpg,omitempty"`      // source endpoint group
+	DstEpg      string    `json:"dstEpg,omitempty"`      // destination endpoint group
+	SrcEndpoint string    `json:"srcEndpoint,omitempty"` // source endpoint name
+	DstEndpoint string    `json:"dstEndpoint,omitempty"` // destination endpoint name
+}
+
 // Driver implements the programming logic
 type Driver interface{}
 
@@ -113,6 +153,9 @@ type NetworkDriver interface {
 	Init(instInfo *InstanceInfo) error
 	Deinit()
 	CreateNetwork(id string) error
+	// Move a network and its local endpoints from the old pkt tags to the
+	// ones in its current config
+	UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error
 	DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error
 	CreateEndpoint(id string) error
 	UpdateEndpointGroup(id string) error
@@ -144,6 +187,16 @@ type NetworkDriver interface {
 	InspectNameserver() ([]byte, error)
 	AddPolicyRule(id string) error
 	DelPolicyRule(id string) error
+	// Publish host ports to an endpoint
+	AddPortMaps(id string, maps []PortMapSpec) error
+	// Remove the host ports published to an endpoint
+	DelPortMaps(id string) error
+	// Get policy rule hit counters keyed by rule id
+	GetPolicyRuleStats() (map[string]PolicyRuleStats, error)
+	// Get the recent packets logged by policy rules
+	GetPolicyLogs() ([]PolicyLogRecord, error)
+	// Get the number of endpoints homed on this node
+	GetLocalEndpointCount() (int, error)
 }
 
 // WatchState is used to provide a difference between core.State structs by
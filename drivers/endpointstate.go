@@ -31,11 +31,14 @@ type OperEndpointState struct {
 	ServiceName string `json:"serviceName"`
 	ContUUID    string `json:"contUUID"`
 	IPAddress   string `json:"ipAddress"`
+	IPv6Address string `json:"ipv6Address"`
 	MacAddress  string `json:"macAddress"`
 	HomingHost  string `json:"homingHost"`
 	IntfName    string `json:"intfName"`
 	PortName    string `json:"portName"`
 	VtepIP      string `json:"vtepIP"`
+
+	PortMaps []core.PortMapSpec `json:"portMaps,omitempty"` // published host ports
 }
 
 // Matches matches the fields updated from configuration state
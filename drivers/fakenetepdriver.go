@@ -114,6 +114,26 @@ func (d *FakeNetEpDriver) GetEndpointStats() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
 }
 
+// GetPolicyRuleStats is not implemented
+func (d *FakeNetEpDriver) GetPolicyRuleStats() (map[string]core.PolicyRuleStats, error) {
+	return nil, core.Errorf("Not implemented")
+}
+
+// UpdateNetworkPktTag is not implemented
+func (d *FakeNetEpDriver) UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error {
+	return core.Errorf("Not implemented")
+}
+
+// GetPolicyLogs is not implemented
+func (d *FakeNetEpDriver) GetPolicyLogs() ([]core.PolicyLogRecord, error) {
+	return nil, core.Errorf("Not implemented")
+}
+
+// GetLocalEndpointCount is not implemented
+func (d *FakeNetEpDriver) GetLocalEndpointCount() (int, error) {
+	return 0, core.Errorf("Not implemented")
+}
+
 // InspectState is not implemented
 func (d *FakeNetEpDriver) InspectState() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
@@ -143,3 +163,13 @@ func (d *FakeNetEpDriver) AddPolicyRule(id string) error {
 func (d *FakeNetEpDriver) DelPolicyRule(id string) error {
 	return core.Errorf("Not implemented")
 }
+
+// AddPortMaps is not implemented
+func (d *FakeNetEpDriver) AddPortMaps(id string, maps []core.PortMapSpec) error {
+	return core.Errorf("Not implemented")
+}
+
+// DelPortMaps is not implemented
+func (d *FakeNetEpDriver) DelPortMaps(id string) error {
+	return core.Errorf("Not implemented")
+}
@@ -0,0 +1,253 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+const (
+	fqdnMinTTL         = 60 * time.Second // min lifetime of a learned address
+	fqdnExpiryInterval = 5 * time.Second  // interval between expiry runs
+)
+
+// policyRuleInstaller installs policy rules on a switch
+type policyRuleInstaller interface {
+	AddLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error
+	DelLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error
+}
+
+// fqdnName is a domain name resolved in a tenant
+type fqdnName struct {
+	tenant string
+	name   string
+}
+
+// fqdnAddrRule is a copy of a rule on a domain name installed for one of
+// the addresses of the name
+type fqdnAddrRule struct {
+	rule   *ofnet.OfnetPolicyRule
+	parent string // id of the rule on the domain name
+	expiry time.Time
+}
+
+// FqdnPolicy installs the policy rules on a domain name for each address the
+// name resolves to, the addresses are learned from the nameserver and removed
+// when their dns records expire
+type FqdnPolicy struct {
+	mutex     sync.Mutex
+	switches  []policyRuleInstaller
+	rules     map[string]*mastercfg.CfgPolicyRule // rules on a domain name by id
+	resolved  map[fqdnName]map[string]time.Time   // expiry of the resolved addresses
+	addrRules map[string]*fqdnAddrRule            // installed copies by id
+}
+
+// NewFqdnPolicy creates the fqdn policy of a set of switches
+func NewFqdnPolicy(switches ...policyRuleInstaller) *FqdnPolicy {
+	return &FqdnPolicy{
+		switches:  switches,
+		rules:     make(map[string]*mastercfg.CfgPolicyRule),
+		resolved:  make(map[fqdnName]map[string]time.Time),
+		addrRules: make(map[string]*fqdnAddrRule),
+	}
+}
+
+// AddRule adds a rule on a domain name and installs it for the addresses the
+// name is already known to resolve to
+func (fp *FqdnPolicy) AddRule(ruleCfg *mastercfg.CfgPolicyRule) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	if _, found := fp.rules[ruleCfg.RuleId]; found {
+		fp.delRule(ruleCfg.RuleId)
+	}
+	fp.rules[ruleCfg.RuleId] = ruleCfg
+	log.Infof("Added policy rule %s on %s", ruleCfg.RuleId, ruleCfg.FqdnRule.Fqdn)
+
+	for fn, addrs := range fp.resolved {
+		if !ruleMatches(ruleCfg, fn) {
+			continue
+		}
+		for ip, expiry := range addrs {
+			fp.installAddrRule(ruleCfg, ip, expiry)
+		}
+	}
+}
+
+// DelRule removes a rule on a domain name along with its installed copies
+func (fp *FqdnPolicy) DelRule(ruleID string) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	fp.delRule(ruleID)
+}
+
+func (fp *FqdnPolicy) delRule(ruleID string) {
+	ruleCfg, found := fp.rules[ruleID]
+	if !found {
+		return
+	}
+
+	for id, addrRule := range fp.addrRules {
+		if addrRule.parent == ruleID {
+			fp.uninstallAddrRule(id, addrRule)
+		}
+	}
+	delete(fp.rules, ruleID)
+	log.Infof("Deleted policy rule %s on %s", ruleID, ruleCfg.FqdnRule.Fqdn)
+}
+
+// FqdnMatch checks if a name is matched by a policy rule of the tenant
+func (fp *FqdnPolicy) FqdnMatch(tenant string, name string) bool {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	fn := fqdnName{tenant: tenant, name: name}
+	for _, ruleCfg := range fp.rules {
+		if ruleMatches(ruleCfg, fn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FqdnResolved installs the rules matching a name for the addresses it
+// resolved to. The addresses are kept for the ttl of their records, with a
+// minimum of fqdnMinTTL so that short lived records don't cut connections.
+func (fp *FqdnPolicy) FqdnResolved(tenant string, name string, ips []net.IP, ttl uint32) {
+	lifetime := time.Duration(ttl) * time.Second
+	if lifetime < fqdnMinTTL {
+		lifetime = fqdnMinTTL
+	}
+	expiry := time.Now().Add(lifetime)
+
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	fn := fqdnName{tenant: tenant, name: name}
+	addrs := fp.resolved[fn]
+	if addrs == nil {
+		addrs = make(map[string]time.Time)
+		fp.resolved[fn] = addrs
+	}
+
+	for _, ip := range ips {
+		// policy rules are ipv4 only
+		if ip.To4() == nil {
+			continue
+		}
+		addr := ip.String()
+		if expiry.After(addrs[addr]) {
+			addrs[addr] = expiry
+		}
+
+		for _, ruleCfg := range fp.rules {
+			if ruleMatches(ruleCfg, fn) {
+				fp.installAddrRule(ruleCfg, addr, addrs[addr])
+			}
+		}
+	}
+}
+
+// installAddrRule installs the copy of a rule for an address or extends
+// the lifetime of an installed copy
+func (fp *FqdnPolicy) installAddrRule(ruleCfg *mastercfg.CfgPolicyRule, addr string, expiry time.Time) {
+	id := ruleCfg.RuleId + ":" + addr
+	if addrRule, found := fp.addrRules[id]; found {
+		if expiry.After(addrRule.expiry) {
+			addrRule.expiry = expiry
+		}
+		return
+	}
+
+	rule := ruleCfg.OfnetPolicyRule
+	rule.RuleId = id
+	if ruleCfg.FqdnRule.MatchSrc {
+		rule.SrcIpAddr = addr
+	} else {
+		rule.DstIpAddr = addr
+	}
+
+	for _, sw := range fp.switches {
+		if err := sw.AddLocalPolicyRule(&rule); err != nil {
+			log.Errorf("Error installing policy rule {%+v}. Err: %v", rule, err)
+		}
+	}
+	fp.addrRules[id] = &fqdnAddrRule{rule: &rule, parent: ruleCfg.RuleId, expiry: expiry}
+}
+
+func (fp *FqdnPolicy) uninstallAddrRule(id string, addrRule *fqdnAddrRule) {
+	for _, sw := range fp.switches {
+		if err := sw.DelLocalPolicyRule(addrRule.rule); err != nil {
+			log.Errorf("Error removing policy rule {%+v}. Err: %v", addrRule.rule, err)
+		}
+	}
+	delete(fp.addrRules, id)
+}
+
+// expire removes the addresses whose records expired
+func (fp *FqdnPolicy) expire(now time.Time) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	for id, addrRule := range fp.addrRules {
+		if now.After(addrRule.expiry) {
+			log.Infof("Policy rule %s expired", id)
+			fp.uninstallAddrRule(id, addrRule)
+		}
+	}
+
+	for fn, addrs := range fp.resolved {
+		for addr, expiry := range addrs {
+			if now.After(expiry) {
+				delete(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			delete(fp.resolved, fn)
+		}
+	}
+}
+
+// run expires the learned addresses periodically
+func (fp *FqdnPolicy) run() {
+	for {
+		time.Sleep(fqdnExpiryInterval)
+		fp.expire(time.Now())
+	}
+}
+
+// ruleMatches checks if a rule matches a name resolved in a tenant,
+// *.domain matches the subdomains of the domain
+func ruleMatches(ruleCfg *mastercfg.CfgPolicyRule, fn fqdnName) bool {
+	if ruleCfg.FqdnRule.TenantName != fn.tenant {
+		return false
+	}
+
+	fqdn := ruleCfg.FqdnRule.Fqdn
+	if strings.HasPrefix(fqdn, "*.") {
+		return strings.HasSuffix(fn.name, fqdn[1:])
+	}
+	return fqdn == fn.name
+}
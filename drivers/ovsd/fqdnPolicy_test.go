@@ -0,0 +1,111 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+// fakeRuleInstaller records the installed policy rules
+type fakeRuleInstaller struct {
+	rules map[string]ofnet.OfnetPolicyRule
+}
+
+func (f *fakeRuleInstaller) AddLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error {
+	f.rules[rule.RuleId] = *rule
+	return nil
+}
+
+func (f *fakeRuleInstaller) DelLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error {
+	delete(f.rules, rule.RuleId)
+	return nil
+}
+
+func newFqdnRule(id, tenant, fqdn string, matchSrc bool) *mastercfg.CfgPolicyRule {
+	ruleCfg := &mastercfg.CfgPolicyRule{
+		FqdnRule: &mastercfg.FqdnRule{TenantName: tenant, Fqdn: fqdn, MatchSrc: matchSrc},
+	}
+	ruleCfg.RuleId = id
+	ruleCfg.Priority = 10
+	ruleCfg.IpProtocol = 6
+	ruleCfg.DstPort = 443
+	ruleCfg.Action = "allow"
+	return ruleCfg
+}
+
+func TestFqdnPolicy(t *testing.T) {
+	sw := &fakeRuleInstaller{rules: make(map[string]ofnet.OfnetPolicyRule)}
+	fp := NewFqdnPolicy(sw)
+
+	fp.AddRule(newFqdnRule("r1", "default", "api.example.com", false))
+	fp.AddRule(newFqdnRule("r2", "default", "*.cdn.example.com", true))
+
+	if !fp.FqdnMatch("default", "api.example.com") || !fp.FqdnMatch("default", "a.cdn.example.com") {
+		t.Fatalf("names of the rules were not matched")
+	}
+	if fp.FqdnMatch("blue", "api.example.com") || fp.FqdnMatch("default", "cdn.example.com") ||
+		fp.FqdnMatch("default", "www.example.com") {
+		t.Fatalf("unexpected name matched")
+	}
+
+	fp.FqdnResolved("default", "api.example.com", []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}, 30)
+	fp.FqdnResolved("default", "a.cdn.example.com", []net.IP{net.ParseIP("192.0.2.2")}, 600)
+	fp.FqdnResolved("blue", "api.example.com", []net.IP{net.ParseIP("192.0.2.3")}, 600)
+
+	if len(sw.rules) != 2 {
+		t.Fatalf("expected 2 installed rules, got %+v", sw.rules)
+	}
+	if rule, found := sw.rules["r1:192.0.2.1"]; !found || rule.DstIpAddr != "192.0.2.1" ||
+		rule.SrcIpAddr != "" || rule.DstPort != 443 {
+		t.Fatalf("rule for the destination address not installed. Got %+v", sw.rules)
+	}
+	if rule, found := sw.rules["r2:192.0.2.2"]; !found || rule.SrcIpAddr != "192.0.2.2" {
+		t.Fatalf("rule for the source address not installed. Got %+v", sw.rules)
+	}
+
+	// a new rule applies to the names already resolved
+	fp.AddRule(newFqdnRule("r3", "default", "*.example.com", false))
+	if _, found := sw.rules["r3:192.0.2.1"]; !found {
+		t.Fatalf("new rule was not installed for resolved addresses. Got %+v", sw.rules)
+	}
+	if _, found := sw.rules["r3:192.0.2.2"]; !found {
+		t.Fatalf("new rule was not installed for resolved addresses. Got %+v", sw.rules)
+	}
+
+	fp.DelRule("r3")
+	if len(sw.rules) != 2 {
+		t.Fatalf("rule copies were not removed. Got %+v", sw.rules)
+	}
+
+	// short ttls are kept for the minimum lifetime
+	fp.expire(time.Now().Add(fqdnMinTTL / 2))
+	if len(sw.rules) != 2 {
+		t.Fatalf("rules expired before the minimum lifetime. Got %+v", sw.rules)
+	}
+	fp.expire(time.Now().Add(fqdnMinTTL + time.Second))
+	if _, found := sw.rules["r1:192.0.2.1"]; found || len(sw.rules) != 1 {
+		t.Fatalf("expired rule was not removed. Got %+v", sw.rules)
+	}
+	fp.expire(time.Now().Add(time.Hour))
+	if len(sw.rules) != 0 {
+		t.Fatalf("expired rules were not removed. Got %+v", sw.rules)
+	}
+}
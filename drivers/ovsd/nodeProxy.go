@@ -17,16 +17,27 @@ package ovsd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	osexec "os/exec"
 	"strings"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/core"
+	"github.com/vishvananda/netlink"
 )
 
 const (
 	contivNPChain = "CONTIV-NODEPORT"
+	contivPMChain = "CONTIV-PORTMAP"
+	contivLNChain = "CONTIV-LOCALNET"
+
+	loopbackNet = "127.0.0.0/8"
+
+	// routeLocalnetPath allows routing of localhost traffic DNATed to
+	// published ports out of an interface
+	routeLocalnetPath = "/proc/sys/net/ipv4/conf/%s/route_localnet"
 )
 
 // Presence indicates presence of an item
@@ -36,57 +47,191 @@ type Presence struct {
 
 // NodeSvcProxy holds service proxy info
 type NodeSvcProxy struct {
-	Mutex        sync.Mutex
-	SvcMap       map[string]core.ServiceSpec // service name as key
-	ProvMap      map[string]Presence         // service name as key
-	LocalIP      map[string]string           // globalIP as key
-	ipTablesPath string
-	natRules     map[string][]string // natRule for the service
+	Mutex         sync.Mutex
+	SvcMap        map[string]core.ServiceSpec // service name as key
+	ProvMap       map[string]Presence         // service name as key
+	LocalIP       map[string]string           // globalIP as key
+	ipTablesPath  string
+	natRules      map[string][]string // natRule for the service
+	PortMaps      map[string][]string // portmap natRules, endpoint id as key
+	localhost     bool                // published ports are reached on localhost
+	LocalnetIntfs map[string]string   // route_localnet interface, endpoint id as key
 }
 
-// NewNodeProxy creates an instance of the node proxy
-func NewNodeProxy() (*NodeSvcProxy, error) {
-	ipTablesPath, err := osexec.LookPath("iptables")
+// ensureRule adds an iptables rule unless it exists
+func ensureRule(ipTablesPath, table, act string, rule ...string) error {
+	_, err := osexec.Command(ipTablesPath, append([]string{"-t", table, "-C"},
+		rule...)...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	out, err := osexec.Command(ipTablesPath, append([]string{"-t", table, act},
+		rule...)...).CombinedOutput()
 	if err != nil {
-		return nil, err
+		log.Errorf("Failed to add rule %v to %s %v out: %s", rule, table, err, out)
+	}
+	return err
+}
+
+// deleteRule removes an iptables rule if it exists
+func deleteRule(ipTablesPath, table string, rule ...string) {
+	_, err := osexec.Command(ipTablesPath, append([]string{"-t", table, "-C"},
+		rule...)...).CombinedOutput()
+	if err != nil {
+		return
 	}
 
-	// Install contiv chain and jump
-	out, err := osexec.Command(ipTablesPath, "-t", "nat", "-N",
-		contivNPChain).CombinedOutput()
+	out, err := osexec.Command(ipTablesPath, append([]string{"-t", table, "-D"},
+		rule...)...).CombinedOutput()
+	if err != nil {
+		log.Errorf("Failed to delete rule %v from %s %v out: %s", rule, table, err, out)
+	}
+}
+
+// setupChain installs a contiv chain in a table, jumped to by the jump
+// rules, and flushes it
+func setupChain(ipTablesPath, table, chain string, jumps ...[]string) error {
+	out, err := osexec.Command(ipTablesPath, "-t", table, "-N",
+		chain).CombinedOutput()
 	if err != nil {
 		if !strings.Contains(string(out), "Chain already exists") {
-			log.Errorf("Failed to setup contiv nodeport chain %v out: %s",
-				err, out)
-			return nil, err
+			log.Errorf("Failed to setup contiv chain %s %v out: %s",
+				chain, err, out)
+			return err
 		}
 	}
 
-	_, err = osexec.Command(ipTablesPath, "-t", "nat", "-C",
-		"PREROUTING", "-m", "addrtype", "--dst-type", "LOCAL", "-j",
-		contivNPChain).CombinedOutput()
+	for _, jump := range jumps {
+		if err := ensureRule(ipTablesPath, table, "-I", jump...); err != nil {
+			log.Errorf("Failed to setup contiv chain %s jump from %s", chain, jump[0])
+			return err
+		}
+	}
+
+	// Flush any old rules we might have added. They will get re-added
+	// if the service or endpoint is still active
+	osexec.Command(ipTablesPath, "-t", table, "-F",
+		chain).CombinedOutput()
+	return nil
+}
+
+// localJump is the rule jumping from a hook chain to a contiv chain for
+// traffic to local addresses, loopback addresses are left out when
+// excludeLoopback is set
+func localJump(hook, chain string, excludeLoopback bool) []string {
+	jump := []string{hook, "-m", "addrtype", "--dst-type", "LOCAL"}
+	if excludeLoopback {
+		jump = append(jump, "!", "-d", loopbackNet)
+	}
+	return append(jump, "-j", chain)
+}
+
+// loopbackJump is the rule jumping to the portmap chain for traffic the host
+// sends to localhost
+func loopbackJump() []string {
+	return []string{"OUTPUT", "-d", loopbackNet, "-j", contivPMChain}
+}
+
+// localnetRules returns the nat rule masquerading localhost traffic DNATed
+// out of an interface and the filter rule dropping other traffic to
+// localhost received on it
+func localnetRules(intf string) ([]string, []string) {
+	return []string{contivLNChain, "-o", intf, "-s", loopbackNet, "-m", "conntrack",
+			"--ctstate", "DNAT", "-j", "MASQUERADE"},
+		[]string{contivLNChain, "-i", intf, "-d", loopbackNet, "-m", "conntrack",
+			"!", "--ctstate", "RELATED,ESTABLISHED,DNAT", "-j", "DROP"}
+}
+
+// setRouteLocalnet enables or disables route_localnet on an interface
+func setRouteLocalnet(intf string, enable bool) error {
+	val := "0"
+	if enable {
+		val = "1"
+	}
+	err := ioutil.WriteFile(fmt.Sprintf(routeLocalnetPath, intf), []byte(val), 0644)
+	if err != nil {
+		log.Errorf("Failed to set route_localnet of %s to %s: %v", intf, val, err)
+	}
+	return err
+}
+
+// resetLocalnet disables route_localnet on the interfaces it was enabled on
+// for published ports, they are found from the masquerade rules, and flushes
+// the localnet chains
+func resetLocalnet(ipTablesPath string) {
+	out, err := osexec.Command(ipTablesPath, "-t", "nat", "-S",
+		contivLNChain).CombinedOutput()
 	if err != nil {
-		out, err = osexec.Command(ipTablesPath, "-t", "nat", "-I",
-			"PREROUTING", "-m", "addrtype", "--dst-type", "LOCAL", "-j",
-			contivNPChain).CombinedOutput()
+		return
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == "-o" {
+				setRouteLocalnet(fields[i+1], false)
+			}
+		}
+	}
+
+	for _, table := range []string{"nat", "filter"} {
+		osexec.Command(ipTablesPath, "-t", table, "-F",
+			contivLNChain).CombinedOutput()
+	}
+}
+
+// NewNodeProxy creates an instance of the node proxy, published ports are
+// also reached on localhost when localhost is set
+func NewNodeProxy(localhost bool) (*NodeSvcProxy, error) {
+	ipTablesPath, err := osexec.LookPath("iptables")
+	if err != nil {
+		return nil, err
+	}
+
+	// Install contiv chains and jumps, published ports are also reached
+	// from the host itself
+	err = setupChain(ipTablesPath, "nat", contivNPChain,
+		localJump("PREROUTING", contivNPChain, false))
+	if err != nil {
+		return nil, err
+	}
+	err = setupChain(ipTablesPath, "nat", contivPMChain,
+		localJump("PREROUTING", contivPMChain, true),
+		localJump("OUTPUT", contivPMChain, true))
+	if err != nil {
+		return nil, err
+	}
+
+	// Localhost needs route_localnet on the interfaces the published
+	// endpoints are reached through, revert what a previous run enabled
+	resetLocalnet(ipTablesPath)
+	if localhost {
+		err = setupChain(ipTablesPath, "nat", contivLNChain,
+			[]string{"POSTROUTING", "-j", contivLNChain})
+		if err == nil {
+			err = setupChain(ipTablesPath, "filter", contivLNChain,
+				[]string{"INPUT", "-j", contivLNChain})
+		}
+		if err == nil {
+			err = ensureRule(ipTablesPath, "nat", "-I", loopbackJump()...)
+		}
 		if err != nil {
-			log.Errorf("Failed to setup contiv nodeport chain jump %v out: %s",
-				err, out)
 			return nil, err
 		}
+	} else {
+		deleteRule(ipTablesPath, "nat", loopbackJump()...)
 	}
 
-	// Flush any old rules we might have added. They will get re-added
-	// if the service is still active
-	osexec.Command(ipTablesPath, "-t", "nat", "-F",
-		contivNPChain).CombinedOutput()
-
 	proxy := NodeSvcProxy{}
 	proxy.SvcMap = make(map[string]core.ServiceSpec)
 	proxy.ProvMap = make(map[string]Presence)
 	proxy.LocalIP = make(map[string]string)
 	proxy.ipTablesPath = ipTablesPath
 	proxy.natRules = make(map[string][]string)
+	proxy.PortMaps = make(map[string][]string)
+	proxy.localhost = localhost
+	proxy.LocalnetIntfs = make(map[string]string)
 	return &proxy, nil
 }
 
@@ -125,6 +270,18 @@ func (p *NodeSvcProxy) detectClash(svcName string, nodePort uint16) bool {
 		}
 	}
 
+	// verify if there is a clashing published port
+	dport := fmt.Sprintf("tcp/%d/", nodePort)
+	for epID, rules := range p.PortMaps {
+		for _, rule := range rules {
+			if strings.HasPrefix(rule, dport) {
+				log.Errorf("CONTIV-NODEPORT: %s/%d clashes with endpoint %s",
+					svcName, nodePort, epID)
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
@@ -200,12 +357,242 @@ func (p *NodeSvcProxy) SvcProviderUpdate(svcName string, providers []string) {
 }
 
 func (p *NodeSvcProxy) execNATRule(act, dport, dest string) (string, error) {
-	out, err := osexec.Command(p.ipTablesPath, "-t", "nat", act,
-		contivNPChain, "-p", "tcp", "-m", "tcp", "--dport",
-		dport, "-j", "DNAT", "--to-destination",
-		dest).CombinedOutput()
+	return p.execChainNATRule(act, contivNPChain, "tcp", "", dport, dest)
+}
+
+func (p *NodeSvcProxy) execChainNATRule(act, chain, proto, hostIP, dport, dest string) (string, error) {
+	args := []string{"-t", "nat", act, chain, "-p", proto, "-m", proto}
+	if hostIP != "" {
+		args = append(args, "-d", hostIP)
+	}
+	args = append(args, "--dport", dport, "-j", "DNAT", "--to-destination", dest)
+	out, err := osexec.Command(p.ipTablesPath, args...).CombinedOutput()
 	return string(out), err
 }
+
+// portMapRule returns the natRule of a published port,
+// proto/hostPort/hostIP/dest
+func portMapRule(pm core.PortMapSpec, dest string) string {
+	return fmt.Sprintf("%s/%d/%s/%s:%d", strings.ToLower(pm.Protocol),
+		pm.HostPort, pm.HostIP, dest, pm.EpPort)
+}
+
+// detectPortMapClash verifies if a published port is already in use
+func (p *NodeSvcProxy) detectPortMapClash(epID string, pm core.PortMapSpec) error {
+	proto := strings.ToLower(pm.Protocol)
+	for e, rules := range p.PortMaps {
+		if e == epID {
+			continue
+		}
+		for _, rule := range rules {
+			r := strings.Split(rule, "/")
+			if r[0] == proto && r[1] == fmt.Sprintf("%d", pm.HostPort) &&
+				(r[2] == "" || pm.HostIP == "" || r[2] == pm.HostIP) {
+				return fmt.Errorf("host port %s/%d is in use by endpoint %s",
+					proto, pm.HostPort, e)
+			}
+		}
+	}
+
+	if proto != "tcp" {
+		return nil
+	}
+	for svc, s := range p.SvcMap {
+		for _, port := range s.Ports {
+			if port.NodePort == pm.HostPort && port.Protocol == "TCP" {
+				return fmt.Errorf("host port %s/%d is in use by service %s",
+					proto, pm.HostPort, svc)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddPortMaps publishes host ports to an endpoint, traffic is sent to the
+// local address of the endpoint if it has a host access port
+func (p *NodeSvcProxy) AddPortMaps(epID, ipAddr string, maps []core.PortMapSpec) error {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+	log.Infof("Node proxy AddPortMaps: %s %v", epID, maps)
+
+	for _, pm := range maps {
+		if err := p.detectPortMapClash(epID, pm); err != nil {
+			return err
+		}
+	}
+
+	dest := strings.Split(ipAddr, "/")[0]
+	if localIP, found := p.LocalIP[dest]; found {
+		dest = localIP
+	}
+
+	// Remove all previous rules and install new ones
+	p.deletePortMapRules(epID)
+
+	natRules := make([]string, 0, len(maps))
+	for _, pm := range maps {
+		rule := portMapRule(pm, dest)
+		r := strings.Split(rule, "/")
+		out, err := p.execChainNATRule("-A", contivPMChain, r[0], r[2], r[1], r[3])
+		if err != nil {
+			p.PortMaps[epID] = natRules
+			p.deletePortMapRules(epID)
+			return fmt.Errorf("failed to add rule: %s, err: %v - %s", rule, err, out)
+		}
+		natRules = append(natRules, rule)
+		log.Infof("Added %s", rule)
+	}
+
+	p.PortMaps[epID] = natRules
+
+	if onLoopback(maps) {
+		if !p.localhost {
+			log.Warnf("Published ports of endpoint %s are not reachable on localhost, "+
+				"it is not enabled", epID)
+		} else if err := p.addLocalnet(epID, dest); err != nil {
+			log.Warnf("Published ports of endpoint %s are not reachable on localhost: %v",
+				epID, err)
+		}
+	}
+	return nil
+}
+
+// onLoopback verifies if some published ports are reached on localhost
+func onLoopback(maps []core.PortMapSpec) bool {
+	for _, pm := range maps {
+		if pm.HostIP == "" || net.ParseIP(pm.HostIP).IsLoopback() {
+			return true
+		}
+	}
+	return false
+}
+
+// routeIntf returns the interface traffic to an address is routed out of
+func routeIntf(ipAddr string) (string, error) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid address %s", ipAddr)
+	}
+	routes, err := netlink.RouteGet(ip)
+	if err != nil {
+		return "", err
+	}
+	if len(routes) == 0 {
+		return "", fmt.Errorf("no route to %s", ipAddr)
+	}
+	link, err := netlink.LinkByIndex(routes[0].LinkIndex)
+	if err != nil {
+		return "", err
+	}
+	return link.Attrs().Name, nil
+}
+
+// localnetInUse verifies if an endpoint is reached on localhost through an
+// interface
+func (p *NodeSvcProxy) localnetInUse(intf string) bool {
+	for _, i := range p.LocalnetIntfs {
+		if i == intf {
+			return true
+		}
+	}
+	return false
+}
+
+// addLocalnet lets the localhost traffic DNATed to the published ports of an
+// endpoint out of the interface the endpoint is reached through. The other
+// interfaces keep dropping loopback sources.
+func (p *NodeSvcProxy) addLocalnet(epID, dest string) error {
+	intf, err := routeIntf(dest)
+	if err != nil {
+		return err
+	}
+	if intf == "lo" {
+		return nil
+	}
+
+	if !p.localnetInUse(intf) {
+		nat, filter := localnetRules(intf)
+		err = ensureRule(p.ipTablesPath, "nat", "-A", nat...)
+		if err == nil {
+			err = ensureRule(p.ipTablesPath, "filter", "-A", filter...)
+		}
+		if err == nil {
+			err = setRouteLocalnet(intf, true)
+		}
+		if err != nil {
+			p.revokeLocalnet(intf)
+			return err
+		}
+		log.Infof("Enabled localhost port maps on %s", intf)
+	}
+
+	p.LocalnetIntfs[epID] = intf
+	return nil
+}
+
+// delLocalnet stops reaching the published ports of an endpoint on
+// localhost, the interface is reverted once no endpoint uses it
+func (p *NodeSvcProxy) delLocalnet(epID string) {
+	intf, found := p.LocalnetIntfs[epID]
+	if !found {
+		return
+	}
+
+	delete(p.LocalnetIntfs, epID)
+	if !p.localnetInUse(intf) {
+		p.revokeLocalnet(intf)
+	}
+}
+
+// revokeLocalnet disables route_localnet on an interface and removes its
+// localnet rules
+func (p *NodeSvcProxy) revokeLocalnet(intf string) {
+	setRouteLocalnet(intf, false)
+	nat, filter := localnetRules(intf)
+	deleteRule(p.ipTablesPath, "nat", nat...)
+	deleteRule(p.ipTablesPath, "filter", filter...)
+	log.Infof("Disabled localhost port maps on %s", intf)
+}
+
+// ResetLocalnet reverts the interfaces published ports are reached through
+// from localhost, the port maps are kept
+func (p *NodeSvcProxy) ResetLocalnet() {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+	for epID := range p.LocalnetIntfs {
+		p.delLocalnet(epID)
+	}
+}
+
+// DelPortMaps removes the host ports published to an endpoint
+func (p *NodeSvcProxy) DelPortMaps(epID string) {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+	p.deletePortMapRules(epID)
+}
+
+func (p *NodeSvcProxy) deletePortMapRules(epID string) {
+	p.delLocalnet(epID)
+
+	natRules, found := p.PortMaps[epID]
+	if !found {
+		return
+	}
+	// Remove all rules
+	for _, rule := range natRules {
+		r := strings.Split(rule, "/")
+		out, err := p.execChainNATRule("-D", contivPMChain, r[0], r[2], r[1], r[3])
+		if err != nil {
+			log.Errorf("Failed to delete rule: %s, err: %v - %s",
+				rule, err, out)
+		} else {
+			log.Infof("Deleted %s", rule)
+		}
+	}
+
+	delete(p.PortMaps, epID)
+}
 func (p *NodeSvcProxy) syncSvc(svcName string) {
 	// check if the service is active
 	_, found := p.SvcMap[svcName]
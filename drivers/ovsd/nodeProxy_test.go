@@ -17,11 +17,12 @@ package ovsd
 
 import (
 	"fmt"
+	"io/ioutil"
 	osexec "os/exec"
+	"strings"
 	"testing"
 
 	"github.com/contiv/netplugin/core"
-	"github.com/contiv/netplugin/state"
 )
 
 var ipTablesPath string
@@ -173,3 +174,168 @@ func TestNodeProxy(t *testing.T) {
 		t.Errorf("NAT rule still exists for 19201=>172.20.0.2:9601")
 	}
 }
+
+func verifyPortMapRule(proto string, hostPort uint16, destIP string, destPort uint16) error {
+	dport := fmt.Sprintf("%d", hostPort)
+	dest := fmt.Sprintf("%s:%d", destIP, destPort)
+	_, err := osexec.Command(ipTablesPath, "-t", "nat", "-C", contivPMChain,
+		"-p", proto, "-m", proto, "--dport", dport, "-j",
+		"DNAT", "--to-destination", dest).CombinedOutput()
+	return err
+}
+
+func TestNodeProxyPortMaps(t *testing.T) {
+	driver := initOvsDriver(t, bridgeMode, defPvtNW)
+	defer func() { driver.Deinit() }()
+	var err error
+	ipTablesPath, err = osexec.LookPath("iptables")
+	if err != nil {
+		t.Errorf("iptables not found %v", err)
+	}
+
+	// published ports are reached from other hosts and the host itself,
+	// but not on localhost unless it is enabled
+	for _, hook := range []string{"PREROUTING", "OUTPUT"} {
+		_, err = osexec.Command(ipTablesPath, append([]string{"-t", "nat", "-C"},
+			localJump(hook, contivPMChain, true)...)...).CombinedOutput()
+		if err != nil {
+			t.Errorf("%s jump to %s not found -- err: %v", hook, contivPMChain, err)
+		}
+	}
+	_, err = osexec.Command(ipTablesPath, append([]string{"-t", "nat", "-C"},
+		loopbackJump()...)...).CombinedOutput()
+	if err == nil {
+		t.Errorf("localhost jump to %s found", contivPMChain)
+	}
+
+	maps := []core.PortMapSpec{
+		{Protocol: "TCP", HostPort: 18080, EpPort: 80},
+		{Protocol: "UDP", HostPort: 18053, EpPort: 53},
+	}
+
+	// endpoint without a host access port
+	err = driver.HostProxy.AddPortMaps("ep1", "23.4.5.10", maps)
+	if err != nil {
+		t.Fatalf("Error adding port maps: %v", err)
+	}
+	if err = verifyPortMapRule("tcp", 18080, "23.4.5.10", 80); err != nil {
+		t.Errorf("NAT rule not found for 18080=>23.4.5.10:80 -- err: %v", err)
+	}
+	if err = verifyPortMapRule("udp", 18053, "23.4.5.10", 53); err != nil {
+		t.Errorf("NAT rule not found for 18053=>23.4.5.10:53 -- err: %v", err)
+	}
+
+	// the host port is already published
+	err = driver.HostProxy.AddPortMaps("ep2", "23.4.5.11", maps[:1])
+	if err == nil {
+		t.Errorf("Clashing host port not detected")
+	}
+
+	// endpoint with a host access port
+	driver.HostProxy.AddLocalIP("23.4.5.11", "172.20.0.5")
+	err = driver.HostProxy.AddPortMaps("ep2", "23.4.5.11",
+		[]core.PortMapSpec{{Protocol: "TCP", HostPort: 18081, EpPort: 80}})
+	if err != nil {
+		t.Fatalf("Error adding port maps: %v", err)
+	}
+	if err = verifyPortMapRule("tcp", 18081, "172.20.0.5", 80); err != nil {
+		t.Errorf("NAT rule not found for 18081=>172.20.0.5:80 -- err: %v", err)
+	}
+
+	driver.HostProxy.DelPortMaps("ep1")
+	if err = verifyPortMapRule("tcp", 18080, "23.4.5.10", 80); err == nil {
+		t.Errorf("NAT rule still exists for 18080=>23.4.5.10:80")
+	}
+	if err = verifyPortMapRule("udp", 18053, "23.4.5.10", 53); err == nil {
+		t.Errorf("NAT rule still exists for 18053=>23.4.5.10:53")
+	}
+
+	driver.HostProxy.DelPortMaps("ep2")
+	if err = verifyPortMapRule("tcp", 18081, "172.20.0.5", 80); err == nil {
+		t.Errorf("NAT rule still exists for 18081=>172.20.0.5:80")
+	}
+}
+
+func readRouteLocalnet(t *testing.T, intf string) string {
+	val, err := ioutil.ReadFile(fmt.Sprintf(routeLocalnetPath, intf))
+	if err != nil {
+		t.Fatalf("Error reading route_localnet of %s: %v", intf, err)
+	}
+	return strings.TrimSpace(string(val))
+}
+
+func TestNodeProxyLocalhostPortMaps(t *testing.T) {
+	var err error
+	ipTablesPath, err = osexec.LookPath("iptables")
+	if err != nil {
+		t.Errorf("iptables not found %v", err)
+	}
+
+	proxy, err := NewNodeProxy(true)
+	if err != nil {
+		t.Fatalf("Error creating node proxy: %v", err)
+	}
+	defer NewNodeProxy(false)
+
+	_, err = osexec.Command(ipTablesPath, append([]string{"-t", "nat", "-C"},
+		loopbackJump()...)...).CombinedOutput()
+	if err != nil {
+		t.Errorf("localhost jump to %s not found -- err: %v", contivPMChain, err)
+	}
+
+	intf, err := routeIntf("23.4.5.10")
+	if err != nil {
+		t.Skipf("No route to the endpoint: %v", err)
+	}
+	nat, filter := localnetRules(intf)
+
+	// ports published on a host address don't need localhost
+	err = proxy.AddPortMaps("ep1", "23.4.5.10",
+		[]core.PortMapSpec{{Protocol: "TCP", HostIP: "192.0.2.1", HostPort: 18082, EpPort: 80}})
+	if err != nil {
+		t.Fatalf("Error adding port maps: %v", err)
+	}
+	if _, found := proxy.LocalnetIntfs["ep1"]; found {
+		t.Errorf("route_localnet enabled for a host address")
+	}
+
+	// ports published on all addresses and localhost need it
+	err = proxy.AddPortMaps("ep1", "23.4.5.10",
+		[]core.PortMapSpec{{Protocol: "TCP", HostPort: 18083, EpPort: 80}})
+	if err != nil {
+		t.Fatalf("Error adding port maps: %v", err)
+	}
+	err = proxy.AddPortMaps("ep2", "23.4.5.10",
+		[]core.PortMapSpec{{Protocol: "UDP", HostIP: "127.0.0.1", HostPort: 18084, EpPort: 53}})
+	if err != nil {
+		t.Fatalf("Error adding port maps: %v", err)
+	}
+
+	if val := readRouteLocalnet(t, intf); val != "1" {
+		t.Errorf("route_localnet of %s is %s", intf, val)
+	}
+	for table, rule := range map[string][]string{"nat": nat, "filter": filter} {
+		_, err = osexec.Command(ipTablesPath, append([]string{"-t", table, "-C"},
+			rule...)...).CombinedOutput()
+		if err != nil {
+			t.Errorf("Localnet rule %v not found -- err: %v", rule, err)
+		}
+	}
+
+	// the interface is reverted with its last endpoint
+	proxy.DelPortMaps("ep1")
+	if val := readRouteLocalnet(t, intf); val != "1" {
+		t.Errorf("route_localnet of %s reverted while in use", intf)
+	}
+	proxy.DelPortMaps("ep2")
+	if val := readRouteLocalnet(t, intf); val != "0" {
+		t.Errorf("route_localnet of %s not reverted", intf)
+	}
+	for table, rule := range map[string][]string{"nat": nat, "filter": filter} {
+		_, err = osexec.Command(ipTablesPath, append([]string{"-t", table, "-C"},
+			rule...)...).CombinedOutput()
+		if err == nil {
+			t.Errorf("Localnet rule %v still exists", rule)
+		}
+	}
+}
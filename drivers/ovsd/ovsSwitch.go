@@ -482,6 +482,34 @@ func (sw *OvsSwitch) UpdatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 	return nil
 }
 
+// UpdatePortTag moves a port to new pkt tags, the port is removed from
+// ofnet and added back with the new tags
+func (sw *OvsSwitch) UpdatePortTag(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, dscp int, skipVethPair bool) error {
+	// Get OVS port name
+	ovsPortName := getOvsPortName(intfName, skipVethPair)
+
+	err := sw.ovsdbDriver.UpdatePortTag(ovsPortName, pktTag)
+	if err != nil {
+		log.Errorf("Error setting tag %d on port %s. Err: %v", pktTag, ovsPortName, err)
+		return err
+	}
+
+	if sw.ofnetAgent != nil {
+		ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(ovsPortName)
+		if err != nil {
+			log.Errorf("Could not find the OVS port %s. Err: %v", ovsPortName, err)
+			return err
+		}
+		err = sw.ofnetAgent.RemoveLocalEndpoint(ofpPort)
+		if err != nil {
+			log.Errorf("Error removing port %s from ofnet. Err: %v", ovsPortName, err)
+			return err
+		}
+	}
+
+	return sw.UpdatePort(intfName, cfgEp, pktTag, nwPktTag, dscp, skipVethPair)
+}
+
 // DeletePort removes a port from OVS
 func (sw *OvsSwitch) DeletePort(epOper *drivers.OperEndpointState, skipVethPair bool) error {
 
@@ -1035,6 +1063,33 @@ func (sw *OvsSwitch) GetEndpointStats() (map[string]*ofnet.OfnetEndpointStats, e
 	return stats, nil
 }
 
+// GetPolicyRuleStats invokes ofnetAgent api
+func (sw *OvsSwitch) GetPolicyRuleStats() (map[string]*ofnet.OfnetPolicyRuleStats, error) {
+	if sw.ofnetAgent == nil {
+		return nil, errors.New("No ofnet agent")
+	}
+
+	return sw.ofnetAgent.GetPolicyRuleStats()
+}
+
+// AddLocalPolicyRule installs a policy rule on this switch only
+func (sw *OvsSwitch) AddLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error {
+	if sw.ofnetAgent == nil {
+		return errors.New("No ofnet agent")
+	}
+
+	return sw.ofnetAgent.AddLocalRule(rule)
+}
+
+// DelLocalPolicyRule removes a policy rule installed by AddLocalPolicyRule
+func (sw *OvsSwitch) DelLocalPolicyRule(rule *ofnet.OfnetPolicyRule) error {
+	if sw.ofnetAgent == nil {
+		return errors.New("No ofnet agent")
+	}
+
+	return sw.ofnetAgent.DelLocalRule(rule)
+}
+
 // InspectState ireturns ofnet state in json form
 func (sw *OvsSwitch) InspectState() (interface{}, error) {
 	if sw.ofnetAgent == nil {
@@ -1065,3 +1120,10 @@ func (sw *OvsSwitch) AddNameServer(ns ofnet.NameServer) {
 		sw.ofnetAgent.AddNameServer(ns)
 	}
 }
+
+// AddPolicyLogger adds the logger of packets matching policy rules
+func (sw *OvsSwitch) AddPolicyLogger(pl ofnet.PolicyLogger) {
+	if sw.ofnetAgent != nil {
+		sw.ofnetAgent.AddPolicyLogger(pl)
+	}
+}
@@ -579,6 +579,26 @@ func (d *OvsdbDriver) UpdatePolicingRate(intfName string, burst int, bandwidth i
 
 }
 
+// UpdatePortTag changes the access vlan of a port
+func (d *OvsdbDriver) UpdatePortTag(intfName string, tag int) error {
+	port := make(map[string]interface{})
+	port["tag"] = tag
+
+	condition := libovsdb.NewCondition("name", "==", intfName)
+	if condition == nil {
+		return errors.New("Error getting the new condition")
+	}
+	updateOp := libovsdb.Operation{
+		Op:    "update",
+		Table: portTable,
+		Row:   port,
+		Where: []interface{}{condition},
+	}
+
+	operations := []libovsdb.Operation{updateOp}
+	return d.performOvsdbOps(operations)
+}
+
 // DeletePort deletes a port from OVS
 func (d *OvsdbDriver) DeletePort(intfName string) error {
 	portUUIDStr := intfName
@@ -84,12 +84,14 @@ func (s *OvsDriverOperState) Clear() error {
 // OvsDriver implements the Layer 2 Network and Endpoint Driver interfaces
 // specific to vlan based open-vswitch.
 type OvsDriver struct {
-	oper       OvsDriverOperState    // Oper state of the driver
-	localIP    string                // Local IP address
-	switchDb   map[string]*OvsSwitch // OVS switch instances
-	lock       sync.Mutex            // lock for modifying shared state
-	HostProxy  *NodeSvcProxy
-	nameServer *nameserver.NetpluginNameServer
+	oper         OvsDriverOperState    // Oper state of the driver
+	localIP      string                // Local IP address
+	switchDb     map[string]*OvsSwitch // OVS switch instances
+	lock         sync.Mutex            // lock for modifying shared state
+	HostProxy    *NodeSvcProxy
+	nameServer   *nameserver.NetpluginNameServer
+	policyLogger *PolicyLogger
+	fqdnPolicy   *FqdnPolicy
 }
 
 func (d *OvsDriver) getIntfName() (string, error) {
@@ -189,6 +191,19 @@ func (d *OvsDriver) Init(info *core.InstanceInfo) error {
 	d.switchDb["vlan"].AddNameServer(d.nameServer)
 	log.Infof("initialized nameserver")
 
+	// Add policy logger
+	d.policyLogger, err = NewPolicyLogger(info)
+	if err != nil {
+		return err
+	}
+	d.switchDb["vxlan"].AddPolicyLogger(d.policyLogger)
+	d.switchDb["vlan"].AddPolicyLogger(d.policyLogger)
+
+	// Learn the addresses of the policy rules on domain names
+	d.fqdnPolicy = NewFqdnPolicy(d.switchDb["vxlan"], d.switchDb["vlan"])
+	d.nameServer.AddFqdnHandler(d.fqdnPolicy)
+	go d.fqdnPolicy.run()
+
 	// Add uplink to VLAN switch
 	if len(info.UplinkIntf) != 0 {
 		err = d.switchDb["vlan"].AddUplink("uplinkPort", info.UplinkIntf)
@@ -212,9 +227,40 @@ func (d *OvsDriver) Init(info *core.InstanceInfo) error {
 	netutils.SetIPMasquerade(hostPortName, netmask)
 
 	// Initialize the node proxy
-	d.HostProxy, err = NewNodeProxy()
+	d.HostProxy, err = NewNodeProxy(info.LocalhostPortMaps)
+	if err != nil {
+		return err
+	}
 
-	return err
+	d.restorePortMaps()
+	return nil
+}
+
+// restorePortMaps re-publishes the host ports of local endpoints
+func (d *OvsDriver) restorePortMaps() {
+	readEp := &drivers.OperEndpointState{}
+	readEp.StateDriver = d.oper.StateDriver
+	epList, err := readEp.ReadAll()
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error reading endpoints to restore port maps. Err: %v", err)
+		}
+		return
+	}
+
+	for _, ep := range epList {
+		operEp := ep.(*drivers.OperEndpointState)
+		d.oper.localEpInfoMutex.Lock()
+		_, isLocal := d.oper.LocalEpInfo[operEp.ID]
+		d.oper.localEpInfoMutex.Unlock()
+		if !isLocal || len(operEp.PortMaps) == 0 {
+			continue
+		}
+		err = d.HostProxy.AddPortMaps(operEp.ID, operEp.IPAddress, operEp.PortMaps)
+		if err != nil {
+			log.Errorf("Error restoring port maps of endpoint %s. Err: %v", operEp.ID, err)
+		}
+	}
 }
 
 //DeleteHostAccPort deletes the access port
@@ -260,6 +306,10 @@ func (d *OvsDriver) CreateHostAccPort(portName, globalIP string, net int) (strin
 func (d *OvsDriver) Deinit() {
 	log.Infof("Cleaning up ovsdriver")
 
+	if d.HostProxy != nil {
+		d.HostProxy.ResetLocalnet()
+	}
+
 	// cleanup both vlan and vxlan OVS instances
 	if d.switchDb["vlan"] != nil {
 		d.switchDb["vlan"].RemoveUplinks()
@@ -293,6 +343,79 @@ func (d *OvsDriver) CreateNetwork(id string) error {
 	return sw.CreateNetwork(uint16(cfgNw.PktTag), uint32(cfgNw.ExtPktTag), cfgNw.Gateway, cfgNw.Tenant)
 }
 
+// UpdateNetworkPktTag moves a network from its old pkt tags to the ones in
+// its config. The network is created with the new tags, the local endpoints
+// are moved to the tags of their group and the old tags are removed.
+func (d *OvsDriver) UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error {
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	err := cfgNw.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read net %s \n", id)
+		return err
+	}
+	log.Infof("update net %s tags: %d/%d -> %d/%d", id, oldPktTag, oldExtPktTag,
+		cfgNw.PktTag, cfgNw.ExtPktTag)
+
+	// Find the switch based on network type
+	var sw *OvsSwitch
+	if cfgNw.PktTagType == "vxlan" {
+		sw = d.switchDb["vxlan"]
+	} else {
+		sw = d.switchDb["vlan"]
+	}
+
+	err = sw.CreateNetwork(uint16(cfgNw.PktTag), uint32(cfgNw.ExtPktTag), cfgNw.Gateway, cfgNw.Tenant)
+	if err != nil {
+		log.Errorf("Error creating net %s with tags %d/%d. Err: %v", id, cfgNw.PktTag, cfgNw.ExtPktTag, err)
+		return err
+	}
+
+	skipVethPair := (cfgNw.NwType == "infra")
+	d.oper.localEpInfoMutex.Lock()
+	epIDs := []string{}
+	for epID := range d.oper.LocalEpInfo {
+		epIDs = append(epIDs, epID)
+	}
+	d.oper.localEpInfoMutex.Unlock()
+	for _, epID := range epIDs {
+		operEp := &drivers.OperEndpointState{}
+		operEp.StateDriver = d.oper.StateDriver
+		if err := operEp.Read(epID); err != nil || operEp.NetID != id {
+			continue
+		}
+
+		cfgEp := &mastercfg.CfgEndpointState{}
+		cfgEp.StateDriver = d.oper.StateDriver
+		if err := cfgEp.Read(epID); err != nil {
+			log.Errorf("Unable to get endpoint %s. Err: %v", epID, err)
+			return err
+		}
+
+		pktTag := cfgNw.PktTag
+		dscp := 0
+		if cfgEp.EndpointGroupKey != "" {
+			cfgEpGroup := &mastercfg.EndpointGroupState{}
+			cfgEpGroup.StateDriver = d.oper.StateDriver
+			err = cfgEpGroup.Read(cfgEp.EndpointGroupKey)
+			if err == nil {
+				pktTag = cfgEpGroup.PktTag
+				dscp = cfgEpGroup.DSCP
+			} else if core.ErrIfKeyExists(err) != nil {
+				return err
+			}
+		}
+
+		err = sw.UpdatePortTag(operEp.PortName, cfgEp, pktTag, cfgNw.PktTag, dscp, skipVethPair)
+		if err != nil {
+			log.Errorf("Error moving endpoint %s to tag %d. Err: %v", epID, pktTag, err)
+			return err
+		}
+	}
+
+	return sw.DeleteNetwork(uint16(oldPktTag), uint32(oldExtPktTag), cfgNw.Gateway, cfgNw.Tenant)
+}
+
 // DeleteNetwork deletes a network by named identifier
 func (d *OvsDriver) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error {
 	log.Infof("delete net %s, nwType %s, encap %s, tags: %d/%d", id, nwType, encap, pktTag, extPktTag)
@@ -451,6 +574,7 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 		EndpointID:  cfgEp.EndpointID,
 		ServiceName: cfgEp.ServiceName,
 		IPAddress:   cfgEp.IPAddress,
+		IPv6Address: cfgEp.IPv6Address,
 		MacAddress:  cfgEp.MacAddress,
 		IntfName:    cfgEp.IntfName,
 		PortName:    intfName,
@@ -554,6 +678,8 @@ func (d *OvsDriver) DeleteEndpoint(id string) error {
 	delete(d.oper.LocalEpInfo, id)
 	d.oper.localEpInfoMutex.Unlock()
 
+	d.HostProxy.DelPortMaps(id)
+
 	return nil
 }
 
@@ -768,6 +894,42 @@ func (d *OvsDriver) GetEndpointStats() ([]byte, error) {
 	return jsonStats, nil
 }
 
+// GetPolicyRuleStats sums the policy rule counters of all ovs instances
+func (d *OvsDriver) GetPolicyRuleStats() (map[string]core.PolicyRuleStats, error) {
+	ruleStats := make(map[string]core.PolicyRuleStats)
+	for _, swType := range []string{"vlan", "vxlan"} {
+		swStats, err := d.switchDb[swType].GetPolicyRuleStats()
+		if err != nil {
+			log.Errorf("Error getting %s policy stats. Err: %v", swType, err)
+			return nil, err
+		}
+
+		for ruleID, stats := range swStats {
+			rs := ruleStats[ruleID]
+			rs.Packets += stats.Packets
+			rs.Bytes += stats.Bytes
+			ruleStats[ruleID] = rs
+		}
+	}
+
+	return ruleStats, nil
+}
+
+// GetPolicyLogs returns the recent packets logged by policy rules
+func (d *OvsDriver) GetPolicyLogs() ([]core.PolicyLogRecord, error) {
+	if d.policyLogger == nil {
+		return []core.PolicyLogRecord{}, nil
+	}
+	return d.policyLogger.Records(), nil
+}
+
+// GetLocalEndpointCount returns the number of endpoints homed on this node
+func (d *OvsDriver) GetLocalEndpointCount() (int, error) {
+	d.oper.localEpInfoMutex.Lock()
+	defer d.oper.localEpInfoMutex.Unlock()
+	return len(d.oper.LocalEpInfo), nil
+}
+
 // InspectState returns driver state as json string
 func (d *OvsDriver) InspectState() ([]byte, error) {
 	driverState := make(map[string]interface{})
@@ -860,14 +1022,70 @@ func (d *OvsDriver) InspectNameserver() ([]byte, error) {
 	return jsonState, nil
 }
 
-// AddPolicyRule creates a policy rule
+// AddPolicyRule creates a policy rule. Rules are installed through ofnet sync
+// except the rules on a domain name, installed for each resolved address.
 func (d *OvsDriver) AddPolicyRule(id string) error {
-	log.Debug("OVS driver ignoring PolicyRule create as it uses ofnet sync")
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.StateDriver = d.oper.StateDriver
+	err := ruleCfg.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read policy rule %s. Err: %v", id, err)
+		return err
+	}
+
+	if ruleCfg.FqdnRule == nil {
+		log.Debug("OVS driver ignoring PolicyRule create as it uses ofnet sync")
+		return nil
+	}
+
+	d.fqdnPolicy.AddRule(ruleCfg)
 	return nil
 }
 
 // DelPolicyRule deletes a policy rule
 func (d *OvsDriver) DelPolicyRule(id string) error {
-	log.Debug("OVS driver ignoring PolicyRule delete as it uses ofnet sync")
+	// no-op for rules installed through ofnet sync
+	d.fqdnPolicy.DelRule(id)
 	return nil
 }
+
+// AddPortMaps publishes host ports to an endpoint
+func (d *OvsDriver) AddPortMaps(id string, maps []core.PortMapSpec) error {
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = d.oper.StateDriver
+	err := operEp.Read(id)
+	if err != nil {
+		return err
+	}
+
+	err = d.HostProxy.AddPortMaps(id, operEp.IPAddress, maps)
+	if err != nil {
+		log.Errorf("Error publishing ports of endpoint %s. Err: %v", id, err)
+		return err
+	}
+
+	// save the port maps so that they are restored on restart
+	operEp.PortMaps = maps
+	return operEp.Write()
+}
+
+// DelPortMaps removes the host ports published to an endpoint
+func (d *OvsDriver) DelPortMaps(id string) error {
+	d.HostProxy.DelPortMaps(id)
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = d.oper.StateDriver
+	err := operEp.Read(id)
+	if err != nil {
+		if core.ErrIfKeyExists(err) == nil {
+			return nil
+		}
+		return err
+	}
+
+	if len(operEp.PortMaps) == 0 {
+		return nil
+	}
+	operEp.PortMaps = nil
+	return operEp.Write()
+}
@@ -0,0 +1,186 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"encoding/json"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+const (
+	policyLogBufSize  = 1000             // number of recent records kept
+	policyLogCacheAge = 10 * time.Second // min interval between endpoint reloads
+)
+
+// PolicyLogger records the packets logged by policy rules, the records are
+// kept in a ring buffer and optionally written to a file or syslog
+type PolicyLogger struct {
+	mutex       sync.Mutex
+	stateDriver core.StateDriver
+	hostLabel   string
+	out         io.Writer                              // log destination
+	records     []core.PolicyLogRecord                 // ring buffer
+	next        int                                    // next slot in the ring
+	epCache     map[string]*mastercfg.CfgEndpointState // endpoints by ip
+	epCacheTime time.Time                              // last endpoint reload
+}
+
+// NewPolicyLogger creates a policy logger writing to the destination given in
+// the instance info, a file path or "syslog"
+func NewPolicyLogger(info *core.InstanceInfo) (*PolicyLogger, error) {
+	pl := &PolicyLogger{
+		stateDriver: info.StateDriver,
+		hostLabel:   info.HostLabel,
+		records:     make([]core.PolicyLogRecord, 0, policyLogBufSize),
+		epCache:     make(map[string]*mastercfg.CfgEndpointState),
+	}
+
+	switch info.PolicyLog {
+	case "":
+	case "syslog":
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "netplugin-policy")
+		if err != nil {
+			log.Errorf("Could not connect to syslog. Err: %v", err)
+			return nil, err
+		}
+		pl.out = w
+	default:
+		f, err := os.OpenFile(info.PolicyLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Errorf("Could not open policy log %s. Err: %v", info.PolicyLog, err)
+			return nil, err
+		}
+		pl.out = f
+	}
+
+	return pl, nil
+}
+
+// LogPolicyPkt records a packet logged by ofnet
+func (pl *PolicyLogger) LogPolicyPkt(policyLog *ofnet.OfnetPolicyLog) {
+	record := core.PolicyLogRecord{
+		Time:     policyLog.Time,
+		Host:     pl.hostLabel,
+		RuleID:   policyLog.RuleId,
+		Action:   policyLog.Action,
+		Protocol: policyLog.IpProtocol,
+		SrcIP:    policyLog.SrcIp,
+		DstIP:    policyLog.DstIp,
+		SrcPort:  policyLog.SrcPort,
+		DstPort:  policyLog.DstPort,
+	}
+
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+
+	if ep := pl.lookupEndpoint(policyLog.SrcIp, policyLog.SrcEndpointGroup); ep != nil {
+		record.SrcEpg = ep.EndpointGroupKey
+		record.SrcEndpoint = endpointName(ep)
+	}
+	if ep := pl.lookupEndpoint(policyLog.DstIp, policyLog.DstEndpointGroup); ep != nil {
+		record.DstEpg = ep.EndpointGroupKey
+		record.DstEndpoint = endpointName(ep)
+	}
+
+	if len(pl.records) < policyLogBufSize {
+		pl.records = append(pl.records, record)
+	} else {
+		pl.records[pl.next] = record
+	}
+	pl.next = (pl.next + 1) % policyLogBufSize
+
+	if pl.out != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			log.Errorf("Error encoding policy log %+v. Err: %v", record, err)
+			return
+		}
+		if _, err := pl.out.Write(append(line, '\n')); err != nil {
+			log.Errorf("Error writing policy log. Err: %v", err)
+		}
+	}
+}
+
+// Records returns the recent records, oldest first
+func (pl *PolicyLogger) Records() []core.PolicyLogRecord {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+
+	records := make([]core.PolicyLogRecord, 0, len(pl.records))
+	if len(pl.records) == policyLogBufSize {
+		records = append(records, pl.records[pl.next:]...)
+		records = append(records, pl.records[:pl.next]...)
+	} else {
+		records = append(records, pl.records...)
+	}
+
+	return records
+}
+
+// lookupEndpoint finds the endpoint with the ip in the endpoint group,
+// reloading the endpoints from the state store on a cache miss
+func (pl *PolicyLogger) lookupEndpoint(ip string, epgID int) *mastercfg.CfgEndpointState {
+	ep, found := pl.epCache[ip]
+	if found && (epgID == 0 || ep.EndpointGroupID == epgID) {
+		return ep
+	}
+	if pl.stateDriver == nil || time.Since(pl.epCacheTime) < policyLogCacheAge {
+		return nil
+	}
+
+	pl.epCacheTime = time.Now()
+	readEp := &mastercfg.CfgEndpointState{}
+	readEp.StateDriver = pl.stateDriver
+	epList, err := readEp.ReadAll()
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error reading endpoints for policy log. Err: %v", err)
+		}
+		return nil
+	}
+
+	pl.epCache = make(map[string]*mastercfg.CfgEndpointState)
+	for _, epState := range epList {
+		cfgEp := epState.(*mastercfg.CfgEndpointState)
+		if prev, found := pl.epCache[cfgEp.IPAddress]; found && prev.EndpointGroupID == epgID {
+			continue // same ip in another vrf, keep the one in the group
+		}
+		pl.epCache[cfgEp.IPAddress] = cfgEp
+	}
+
+	ep, found = pl.epCache[ip]
+	if !found {
+		return nil
+	}
+	return ep
+}
+
+// endpointName returns the name of the container or pod of an endpoint
+func endpointName(ep *mastercfg.CfgEndpointState) string {
+	if ep.EPCommonName != "" {
+		return ep.EPCommonName
+	}
+	return ep.ContainerID
+}
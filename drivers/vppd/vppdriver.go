@@ -181,6 +181,29 @@ func (d *VppDriver) DelSvcSpec(svcName string, spec *core.ServiceSpec) error {
 func (d *VppDriver) SvcProviderUpdate(svcName string, providers []string) {
 }
 
+// GetPolicyRuleStats is not implemented
+func (d *VppDriver) GetPolicyRuleStats() (map[string]core.PolicyRuleStats, error) {
+	log.Infof("Not implemented")
+	return nil, nil
+}
+
+// UpdateNetworkPktTag is not implemented
+func (d *VppDriver) UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// GetPolicyLogs is not implemented
+func (d *VppDriver) GetPolicyLogs() ([]core.PolicyLogRecord, error) {
+	log.Infof("Not implemented")
+	return nil, nil
+}
+
+// GetLocalEndpointCount is not implemented
+func (d *VppDriver) GetLocalEndpointCount() (int, error) {
+	return 0, core.Errorf("Not implemented")
+}
+
 // GetEndpointStats is not implemented
 func (d *VppDriver) GetEndpointStats() ([]byte, error) {
 	log.Infof("Not implemented")
@@ -222,3 +245,14 @@ func (d *VppDriver) DelPolicyRule(id string) error {
 	log.Infof("Not implemented")
 	return nil
 }
+
+// AddPortMaps is not implemented
+func (d *VppDriver) AddPortMaps(id string, maps []core.PortMapSpec) error {
+	return core.Errorf("Not implemented")
+}
+
+// DelPortMaps is not implemented
+func (d *VppDriver) DelPortMaps(id string) error {
+	log.Infof("Not implemented")
+	return nil
+}
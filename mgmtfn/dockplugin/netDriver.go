@@ -22,9 +22,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/contivmodel/client"
+	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/drivers"
 	"github.com/contiv/netplugin/netmaster/docknet"
 	"github.com/contiv/netplugin/netmaster/intent"
@@ -32,11 +34,14 @@ import (
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/cluster"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/metrics"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/docker/libnetwork/driverapi"
 	"github.com/docker/libnetwork/drivers/remote/api"
+	"github.com/docker/libnetwork/netlabel"
+	lntypes "github.com/docker/libnetwork/types"
 	"golang.org/x/net/context"
 )
 
@@ -115,6 +120,10 @@ func deleteEndpoint(hostname string) func(http.ResponseWriter, *http.Request) {
 			dereq   = api.DeleteEndpointRequest{}
 		)
 
+		defer func(start time.Time) {
+			metrics.ObserveEndpointOp("docker", "delete", start, err)
+		}(time.Now())
+
 		logEvent("delete endpoint")
 
 		err = decoder.Decode(&dereq)
@@ -182,6 +191,10 @@ func createEndpoint(hostname string) func(http.ResponseWriter, *http.Request) {
 			cereq   = api.CreateEndpointRequest{}
 		)
 
+		defer func(start time.Time) {
+			metrics.ObserveEndpointOp("docker", "create", start, err)
+		}(time.Now())
+
 		logEvent("create endpoint")
 
 		err = decoder.Decode(&cereq)
@@ -460,12 +473,106 @@ func freeNetwork(w http.ResponseWriter, r *http.Request) {
 	w.Write(content)
 }
 
+// getPortMaps returns the published ports of a program external
+// connectivity request
+func getPortMaps(options map[string]interface{}) ([]core.PortMapSpec, error) {
+	var (
+		bindings []lntypes.PortBinding
+		exposed  []lntypes.TransportPort
+	)
+
+	// options are generic json, decode them again into libnetwork types
+	for label, value := range map[string]interface{}{
+		netlabel.PortMap:      &bindings,
+		netlabel.ExposedPorts: &exposed,
+	} {
+		opt, found := options[label]
+		if !found {
+			continue
+		}
+		content, err := json.Marshal(opt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, value); err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", label, err)
+		}
+	}
+
+	log.Infof("Exposed ports: %v, port bindings: %v", exposed, bindings)
+
+	maps := []core.PortMapSpec{}
+	for _, pb := range bindings {
+		if pb.Proto != lntypes.TCP && pb.Proto != lntypes.UDP {
+			return nil, fmt.Errorf("unsupported protocol in port binding %s", pb.String())
+		}
+		if pb.HostPort == 0 {
+			return nil, fmt.Errorf("dynamic host port in port binding %s is not supported", pb.String())
+		}
+
+		// expand host port ranges, they map to a range of endpoint ports
+		hostPortEnd := pb.HostPortEnd
+		if hostPortEnd < pb.HostPort {
+			hostPortEnd = pb.HostPort
+		}
+		for port := pb.HostPort; port <= hostPortEnd; port++ {
+			pm := core.PortMapSpec{
+				Protocol: strings.ToUpper(pb.Proto.String()),
+				HostPort: port,
+				EpPort:   pb.Port + (port - pb.HostPort),
+			}
+			if pb.HostIP != nil && !pb.HostIP.IsUnspecified() {
+				pm.HostIP = pb.HostIP.String()
+			}
+			maps = append(maps, pm)
+			if port == hostPortEnd {
+				break
+			}
+		}
+	}
+
+	return maps, nil
+}
+
 func programExternalConnectivity(w http.ResponseWriter, r *http.Request) {
-	resp := api.ProgramExternalConnectivityResponse{}
+	var (
+		decoder = json.NewDecoder(r.Body)
+		pr      = api.ProgramExternalConnectivityRequest{}
+	)
 
 	logEvent("program externalConnectivity")
 
-	content, err := json.Marshal(resp)
+	err := decoder.Decode(&pr)
+	if err != nil {
+		httpError(w, "Could not read and parse the externalConnectivity request", err)
+		return
+	}
+
+	log.Infof("ProgramExternalConnectivityRequest: %+v", pr)
+
+	maps, err := getPortMaps(pr.Options)
+	if err != nil {
+		httpError(w, "Could not get the port mappings", err)
+		return
+	}
+
+	if len(maps) > 0 {
+		tenantName, netName, _, err := GetDockerNetworkName(pr.NetworkID)
+		if err != nil {
+			log.Errorf("Error getting network name for UUID: %s. Err: %v", pr.NetworkID, err)
+			httpError(w, "Could not get network name", err)
+			return
+		}
+
+		netID := netName + "." + tenantName
+		err = netPlugin.AddPortMaps(netID+"-"+pr.EndpointID, maps)
+		if err != nil {
+			httpError(w, "Could not publish ports", err)
+			return
+		}
+	}
+
+	content, err := json.Marshal(api.ProgramExternalConnectivityResponse{})
 	if err != nil {
 		httpError(w, "failed to marshal JSON for externalConnectivity request", err)
 		return
@@ -475,11 +582,36 @@ func programExternalConnectivity(w http.ResponseWriter, r *http.Request) {
 }
 
 func revokeExternalConnectivity(w http.ResponseWriter, r *http.Request) {
-	resp := api.RevokeExternalConnectivityResponse{}
+	var (
+		decoder = json.NewDecoder(r.Body)
+		rr      = api.RevokeExternalConnectivityRequest{}
+	)
 
 	logEvent("revoke externalConnectivity")
 
-	content, err := json.Marshal(resp)
+	err := decoder.Decode(&rr)
+	if err != nil {
+		httpError(w, "Could not read and parse the externalConnectivity request", err)
+		return
+	}
+
+	log.Infof("RevokeExternalConnectivityRequest: %+v", rr)
+
+	tenantName, netName, _, err := GetDockerNetworkName(rr.NetworkID)
+	if err != nil {
+		log.Errorf("Error getting network name for UUID: %s. Err: %v", rr.NetworkID, err)
+		httpError(w, "Could not get network name", err)
+		return
+	}
+
+	netID := netName + "." + tenantName
+	err = netPlugin.DelPortMaps(netID + "-" + rr.EndpointID)
+	if err != nil {
+		httpError(w, "Could not revoke published ports", err)
+		return
+	}
+
+	content, err := json.Marshal(api.RevokeExternalConnectivityResponse{})
 	if err != nil {
 		httpError(w, "failed to marshal JSON for externalConnectivity response", err)
 		return
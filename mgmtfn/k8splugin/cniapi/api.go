@@ -29,6 +29,31 @@ const EPAddURL = "/ContivCNI.AddPod"
 // EPDelURL is the rest point for deleting an endpoint
 const EPDelURL = "/ContivCNI.DelPod"
 
+// EPCheckURL is the rest point for checking an endpoint
+const EPCheckURL = "/ContivCNI.CheckPod"
+
+// CNIVersion is the CNI spec version of the results returned by contivk8s
+const CNIVersion = "0.4.0"
+
+// CNISupportedVersions lists the CNI spec versions understood by contivk8s
+var CNISupportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0"}
+
+// contiv labels that select the target of a pod, either as pod labels or
+// as CNI network config args
+const (
+	// LabelTenantName is the contiv tenant label
+	LabelTenantName = "io.contiv.tenant"
+	// LabelNetworkName is the contiv network label
+	LabelNetworkName = "io.contiv.network"
+	// LabelNetworkGroup is the contiv group label
+	LabelNetworkGroup = "io.contiv.net-group"
+)
+
+// AnnotationNetworks is the pod annotation listing the contiv networks a pod
+// is attached to in addition to its primary network, as a json list of
+// {"tenant": "t", "network": "n", "group": "g", "interface": "net1"}
+const AnnotationNetworks = "io.contiv.networks"
+
 // CNIPodAttr holds attributes of the pod to be attached or detached
 type CNIPodAttr struct {
 	Name             string `json:"K8S_POD_NAME,omitempty"`
@@ -36,13 +61,56 @@ type CNIPodAttr struct {
 	InfraContainerID string `json:"K8S_POD_INFRA_CONTAINER_ID,omitempty"`
 	NwNameSpace      string `json:"CNI_NETNS,omitempty"`
 	IntfName         string `json:"CNI_IFNAME,omitempty"`
+	// Labels carries the contiv labels found in the CNI network config args
+	Labels map[string]string `json:"CNI_CONFIG_ARGS,omitempty"`
+	// PrevResult is the result of the pod add that a CHECK verifies
+	PrevResult *CNIResult `json:"CNI_PREV_RESULT,omitempty"`
+}
+
+// CNIInterface describes an interface in a CNI result
+type CNIInterface struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+// CNIIPConfig describes an address in a CNI result
+type CNIIPConfig struct {
+	Version   string `json:"version"`
+	Interface *int   `json:"interface,omitempty"`
+	Address   string `json:"address"`
+	Gateway   string `json:"gateway,omitempty"`
+}
+
+// CNIRoute describes a route in a CNI result
+type CNIRoute struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+// CNIDNS describes the dns settings in a CNI result
+type CNIDNS struct {
+	Nameservers []string `json:"nameservers,omitempty"`
+	Domain      string   `json:"domain,omitempty"`
+	Search      []string `json:"search,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// CNIResult is the CNI 0.3.x and 0.4.0 result of a pod add
+type CNIResult struct {
+	CNIVersion string         `json:"cniVersion"`
+	Interfaces []CNIInterface `json:"interfaces,omitempty"`
+	IPs        []CNIIPConfig  `json:"ips,omitempty"`
+	Routes     []CNIRoute     `json:"routes,omitempty"`
+	DNS        CNIDNS         `json:"dns"`
 }
 
 // RspAddPod contains the response to the AddPod
 type RspAddPod struct {
-	Result     uint   `json:"result,omitempty"`
-	EndpointID string `json:"endpointid,omitempty"`
-	IPAddress  string `json:"ipaddress,omitempty"`
-	ErrMsg     string `json:"errmsg,omitempty"`
-	ErrInfo    string `json:"errinfo,omitempty"`
+	Result     uint       `json:"result,omitempty"`
+	EndpointID string     `json:"endpointid,omitempty"`
+	IPAddress  string     `json:"ipaddress,omitempty"`
+	CNIResult  *CNIResult `json:"cniresult,omitempty"`
+	ErrMsg     string     `json:"errmsg,omitempty"`
+	ErrInfo    string     `json:"errinfo,omitempty"`
 }
@@ -21,11 +21,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/mgmtfn/k8splugin/cniapi"
 	"github.com/contiv/netplugin/netplugin/plugin"
 	"github.com/contiv/netplugin/utils/k8sutils"
+	"github.com/contiv/netplugin/utils/metrics"
 	"github.com/gorilla/mux"
 )
 
@@ -91,60 +93,32 @@ func makeHTTPHandler(handlerFunc restAPIFunc) http.HandlerFunc {
 	}
 }
 
+// meterEndpointOp records the metrics of a pod add or delete request
+func meterEndpointOp(op string, handlerFunc restAPIFunc) restAPIFunc {
+	return func(r *http.Request) (interface{}, error) {
+		start := time.Now()
+		resp, err := handlerFunc(r)
+		metrics.ObserveEndpointOp("k8s", op, start, err)
+		return resp, err
+	}
+}
+
 // InitKubServiceWatch initializes the k8s service watch
 func InitKubServiceWatch(np *plugin.NetPlugin) {
 
-	watchClient := setUpAPIClient()
-	if watchClient == nil {
-		log.Fatalf("Could not init kubernetes API client")
+	err := k8sutils.GetK8SConfig(&contivK8Config)
+	if err != nil {
+		log.Fatalf("Could not read kubernetes config: %v", err)
 	}
 
-	svcCh := make(chan SvcWatchResp, 1)
-	epCh := make(chan EpWatchResp, 1)
-	go func() {
-		for {
-			select {
-			case svcEvent := <-svcCh:
-				switch svcEvent.opcode {
-				case "WARN":
-					log.Debugf("svcWatch : %s", svcEvent.errStr)
-					break
-				case "FATAL":
-					log.Errorf("svcWatch : %s", svcEvent.errStr)
-					break
-				case "ERROR":
-					log.Warnf("svcWatch : %s", svcEvent.errStr)
-					watchClient.WatchServices(svcCh)
-					break
-
-				case "DELETED":
-					np.DelSvcSpec(svcEvent.svcName, &svcEvent.svcSpec)
-					break
-				default:
-					np.AddSvcSpec(svcEvent.svcName, &svcEvent.svcSpec)
-				}
-			case epEvent := <-epCh:
-				switch epEvent.opcode {
-				case "WARN":
-					log.Debugf("epWatch : %s", epEvent.errStr)
-					break
-				case "FATAL":
-					log.Errorf("epWatch : %s", epEvent.errStr)
-					break
-				case "ERROR":
-					log.Warnf("epWatch : %s", epEvent.errStr)
-					watchClient.WatchSvcEps(epCh)
-					break
-
-				default:
-					np.SvcProviderUpdate(epEvent.svcName, epEvent.providers)
-				}
-			}
-		}
-	}()
+	clientSet, err := k8sutils.SetUpK8SClient()
+	if err != nil {
+		log.Fatalf("Could not init kubernetes API client: %v", err)
+	}
 
-	watchClient.WatchServices(svcCh)
-	watchClient.WatchSvcEps(epCh)
+	svcWatch := newSvcWatcher(clientSet, np,
+		getAPIServerPort(contivK8Config.K8sAPIServer))
+	svcWatch.run(make(chan struct{}))
 }
 
 // InitCNIServer initializes the k8s cni server
@@ -170,8 +144,9 @@ func InitCNIServer(netplugin *plugin.NetPlugin) error {
 
 	// register handlers for cni
 	t := router.Headers("Content-Type", "application/json").Methods("POST").Subrouter()
-	t.HandleFunc(cniapi.EPAddURL, makeHTTPHandler(addPod))
-	t.HandleFunc(cniapi.EPDelURL, makeHTTPHandler(deletePod))
+	t.HandleFunc(cniapi.EPAddURL, makeHTTPHandler(meterEndpointOp("create", addPod)))
+	t.HandleFunc(cniapi.EPDelURL, makeHTTPHandler(meterEndpointOp("delete", deletePod)))
+	t.HandleFunc(cniapi.EPCheckURL, makeHTTPHandler(checkPod))
 	t.HandleFunc("/ContivCNI.{*}", unknownAction)
 
 	driverPath := cniapi.ContivCniSocket
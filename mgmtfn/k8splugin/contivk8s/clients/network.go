@@ -134,3 +134,56 @@ func (c *NWClient) DelPod(podInfo interface{}) error {
 
 	return nil
 }
+
+// CheckPod checks a pod's attachment to contiv using the cni api
+func (c *NWClient) CheckPod(podInfo interface{}) (*cniapi.RspAddPod, error) {
+
+	data := cniapi.RspAddPod{}
+	buf, err := json.Marshal(podInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	body := bytes.NewBuffer(buf)
+	url := c.baseURL + cniapi.EPCheckURL
+	r, err := c.client.Post(url, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	switch {
+	case r.StatusCode == int(404):
+		return nil, fmt.Errorf("page not found")
+
+	case r.StatusCode == int(403):
+		return nil, fmt.Errorf("access denied")
+
+	case r.StatusCode == int(500):
+		info, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(info, &data)
+		if err != nil {
+			return nil, err
+		}
+		return &data, fmt.Errorf("Internal Server Error")
+
+	case r.StatusCode != int(200):
+		log.Errorf("POST Status '%s' status code %d \n", r.Status, r.StatusCode)
+		return nil, fmt.Errorf("%s", r.Status)
+	}
+
+	response, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(response, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
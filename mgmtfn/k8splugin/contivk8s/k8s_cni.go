@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -29,7 +31,7 @@ import (
 	logger "github.com/Sirupsen/logrus"
 )
 
-//CNIError : return format from CNI plugin
+// CNIError : return format from CNI plugin
 type CNIError struct {
 	CNIVersion string `json:"cniVersion"`
 	Code       uint   `json:"code"`
@@ -37,8 +39,160 @@ type CNIError struct {
 	Details    string `json:"details,omitempty"`
 }
 
+// netConf : the fields of the CNI network config used by contivk8s
+type netConf struct {
+	CNIVersion string            `json:"cniVersion,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Args       confArgs          `json:"args,omitempty"`
+	DNS        cniapi.CNIDNS     `json:"dns,omitempty"`
+	PrevResult *cniapi.CNIResult `json:"prevResult,omitempty"`
+}
+
+// confArgs : the args of the CNI network config, plugin args are under the
+// "cni" key by convention
+type confArgs struct {
+	CNI map[string]interface{} `json:"cni,omitempty"`
+}
+
+// ipConfig : ip settings of a pre 0.3.0 CNI result
+type ipConfig struct {
+	IP      string `json:"ip"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// legacyResult : pre 0.3.0 CNI result
+type legacyResult struct {
+	CNIVersion string        `json:"cniVersion"`
+	IP4        *ipConfig     `json:"ip4,omitempty"`
+	IP6        *ipConfig     `json:"ip6,omitempty"`
+	DNS        cniapi.CNIDNS `json:"dns"`
+}
+
+// versionResult : response to the CNI VERSION command
+type versionResult struct {
+	CNIVersion        string   `json:"cniVersion"`
+	SupportedVersions []string `json:"supportedVersions"`
+}
+
+// legacyCNIVersion is assumed when the network config does not set one
+const legacyCNIVersion = "0.1.0"
+
 var log *logger.Entry
 
+// netConfReader is where the CNI network config is read from
+var netConfReader io.Reader = os.Stdin
+
+// getNetConf reads the CNI network config and copies the contiv labels found
+// in its args to the pod attributes
+func getNetConf(ppInfo *cniapi.CNIPodAttr) (*netConf, error) {
+	conf := &netConf{}
+
+	confBytes, err := ioutil.ReadAll(netConfReader)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading network config: %s", err)
+	}
+
+	if len(strings.TrimSpace(string(confBytes))) != 0 {
+		if err := json.Unmarshal(confBytes, conf); err != nil {
+			return nil, fmt.Errorf("Error parsing network config: %s", err)
+		}
+	}
+
+	if conf.CNIVersion == "" {
+		conf.CNIVersion = legacyCNIVersion
+	}
+
+	for _, label := range []string{cniapi.LabelTenantName,
+		cniapi.LabelNetworkName, cniapi.LabelNetworkGroup} {
+		if val, ok := conf.Args.CNI[label].(string); ok {
+			if ppInfo.Labels == nil {
+				ppInfo.Labels = make(map[string]string)
+			}
+			ppInfo.Labels[label] = val
+		}
+	}
+
+	return conf, nil
+}
+
+// isLegacyVersion checks if the CNI version predates the 0.3.0 result format
+func isLegacyVersion(cniVersion string) bool {
+	return cniVersion == "" || strings.HasPrefix(cniVersion, "0.1.") ||
+		strings.HasPrefix(cniVersion, "0.2.")
+}
+
+// isCheckVersion checks if the CNI version has the CHECK command, added in
+// CNI 0.4.0
+func isCheckVersion(cniVersion string) bool {
+	return !isLegacyVersion(cniVersion) && !strings.HasPrefix(cniVersion, "0.3.")
+}
+
+// formatAddResult converts the add pod response to the result format of the
+// requested CNI version
+func formatAddResult(conf *netConf, pInfo *cniapi.CNIPodAttr,
+	result *cniapi.RspAddPod) ([]byte, error) {
+
+	if isLegacyVersion(conf.CNIVersion) {
+		lr := legacyResult{CNIVersion: conf.CNIVersion, DNS: conf.DNS}
+		lr.IP4 = &ipConfig{IP: result.IPAddress}
+		if result.CNIResult != nil {
+			for _, ip := range result.CNIResult.IPs {
+				switch {
+				case ip.Version == "4" && ip.Address == result.IPAddress:
+					lr.IP4.Gateway = ip.Gateway
+				case ip.Version == "6" && lr.IP6 == nil:
+					lr.IP6 = &ipConfig{IP: ip.Address, Gateway: ip.Gateway}
+				}
+			}
+		}
+		return json.Marshal(&lr)
+	}
+
+	cr := result.CNIResult
+	if cr == nil {
+		// older netplugin, build the result from the ip address
+		intfIdx := 0
+		cr = &cniapi.CNIResult{
+			Interfaces: []cniapi.CNIInterface{{Name: pInfo.IntfName, Sandbox: pInfo.NwNameSpace}},
+			IPs:        []cniapi.CNIIPConfig{{Version: "4", Interface: &intfIdx, Address: result.IPAddress}},
+		}
+	}
+	cr.CNIVersion = conf.CNIVersion
+	if len(cr.DNS.Nameservers) == 0 && cr.DNS.Domain == "" && len(cr.DNS.Search) == 0 {
+		cr.DNS = conf.DNS
+	}
+
+	return json.Marshal(cr)
+}
+
+// writeCNIError writes the CNI error to stdout and exits
+func writeCNIError(conf *netConf, result *cniapi.RspAddPod, err error) {
+	cerr := CNIError{}
+	cerr.CNIVersion = legacyCNIVersion
+	if conf != nil {
+		cerr.CNIVersion = conf.CNIVersion
+	}
+
+	if result != nil {
+		cerr.Code = result.Result
+		cerr.Msg = "Contiv:" + result.ErrMsg
+		cerr.Details = result.ErrInfo
+	} else {
+		cerr.Code = 1
+		cerr.Msg = "Contiv:" + err.Error()
+	}
+
+	eOut, err := json.Marshal(&cerr)
+	if err == nil {
+		log.Infof("cniErr: %s", eOut)
+		fmt.Printf("%s", eOut)
+	} else {
+		log.Errorf("JSON error: %v", err)
+	}
+	os.Exit(1)
+}
+
 func getPodInfo(ppInfo *cniapi.CNIPodAttr) error {
 	cniArgs := os.Getenv("CNI_ARGS")
 	if cniArgs == "" {
@@ -60,40 +214,58 @@ func getPodInfo(ppInfo *cniapi.CNIPodAttr) error {
 	return nil
 }
 
-func addPodToContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr) {
+func addPodToContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr, conf *netConf) {
 
 	// Add to contiv network
 	result, err := nc.AddPod(pInfo)
 	if err != nil || result.Result != 0 {
 		log.Errorf("EP create failed for pod: %s/%s",
 			pInfo.K8sNameSpace, pInfo.Name)
-		cerr := CNIError{}
-		cerr.CNIVersion = "0.1.0"
-
-		if result != nil {
-			cerr.Code = result.Result
-			cerr.Msg = "Contiv:" + result.ErrMsg
-			cerr.Details = result.ErrInfo
-		} else {
-			cerr.Code = 1
-			cerr.Msg = "Contiv:" + err.Error()
-		}
-
-		eOut, err := json.Marshal(&cerr)
-		if err == nil {
-			log.Infof("cniErr: %s", eOut)
-			fmt.Printf("%s", eOut)
-		} else {
-			log.Errorf("JSON error: %v", err)
-		}
-		os.Exit(1)
+		writeCNIError(conf, result, err)
 	}
 
 	log.Infof("EP created IP: %s\n", result.IPAddress)
-	// Write the ip address of the created endpoint to stdout
-	fmt.Printf("{\n\"cniVersion\": \"0.1.0\",\n")
-	fmt.Printf("\"ip4\": {\n")
-	fmt.Printf("\"ip\": \"%s\"\n}\n}\n", result.IPAddress)
+	// Write the result of the created endpoint to stdout
+	out, err := formatAddResult(conf, pInfo, result)
+	if err != nil {
+		log.Errorf("JSON error: %v", err)
+		writeCNIError(conf, nil, err)
+	}
+	fmt.Printf("%s\n", out)
+}
+
+func checkPodInContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr, conf *netConf) {
+
+	if !isCheckVersion(conf.CNIVersion) {
+		writeCNIError(conf, nil,
+			fmt.Errorf("CHECK is not supported by cniVersion %s", conf.CNIVersion))
+	}
+
+	// the runtime passes the result of the add, which the check verifies
+	if conf.PrevResult == nil {
+		writeCNIError(conf, nil, fmt.Errorf("CHECK requires a prevResult"))
+	}
+	pInfo.PrevResult = conf.PrevResult
+
+	result, err := nc.CheckPod(pInfo)
+	if err != nil || result.Result != 0 {
+		log.Errorf("EP check failed for pod: %s/%s",
+			pInfo.K8sNameSpace, pInfo.Name)
+		writeCNIError(conf, result, err)
+	}
+
+	log.Infof("EP checked pod: %s\n", pInfo.Name)
+}
+
+func writeVersion() {
+	out, err := json.Marshal(&versionResult{
+		CNIVersion:        cniapi.CNIVersion,
+		SupportedVersions: cniapi.CNISupportedVersions,
+	})
+	if err != nil {
+		log.Fatalf("JSON error: %v", err)
+	}
+	fmt.Printf("%s\n", out)
 }
 
 func deletePodFromContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr) {
@@ -140,7 +312,7 @@ func main() {
 		logger.Fatalf("Failed to parse command. Error: %s", err)
 	}
 	if showVersion {
-		fmt.Printf(version.String())
+		fmt.Print(version.String())
 		os.Exit(0)
 	}
 
@@ -164,17 +336,31 @@ func mainfunc() {
 	log.Infof("==> Start New Log <==\n")
 	log.Infof("command: %s, cni_args: %s", cniCmd, os.Getenv("CNI_ARGS"))
 
+	if cniCmd == "VERSION" {
+		writeVersion()
+		return
+	}
+
 	// Collect information passed by CNI
 	err = getPodInfo(&pInfo)
 	if err != nil {
 		log.Fatalf("Error parsing environment. Err: %v", err)
 	}
 
+	conf, err := getNetConf(&pInfo)
+	if err != nil {
+		log.Errorf("Error parsing network config. Err: %v", err)
+		writeCNIError(nil, nil, err)
+	}
+
 	nc := clients.NewNWClient()
-	if cniCmd == "ADD" {
-		addPodToContiv(nc, &pInfo)
-	} else if cniCmd == "DEL" {
+	switch cniCmd {
+	case "ADD":
+		addPodToContiv(nc, &pInfo, conf)
+	case "DEL":
 		deletePodFromContiv(nc, &pInfo)
+	case "CHECK":
+		checkPodInContiv(nc, &pInfo, conf)
 	}
 
 }
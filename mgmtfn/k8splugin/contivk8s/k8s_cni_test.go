@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +30,9 @@ import (
 	"time"
 
 	logger "github.com/Sirupsen/logrus"
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+	types040 "github.com/containernetworking/cni/pkg/types/040"
 	"github.com/contiv/netplugin/mgmtfn/k8splugin/cniapi"
 	"github.com/gorilla/mux"
 )
@@ -39,6 +43,30 @@ const (
 	utCNIARG2  = "K8S_POD_NAME=utPod"
 	utCNIARG3  = "K8S_POD_INFRA_CONTAINER_ID=8ec72deca647bfa60a4b815aa735c87de859b47e872828586749b9d852af1f49"
 	utCNINETNS = "/proc/98765/ns/net"
+	utPodMac   = "02:02:2c:37:42:4d"
+	utNetConf  = `{
+	"cniVersion": "0.4.0",
+	"name": "contiv",
+	"type": "contivk8s",
+	"args": {
+		"cni": {
+			"io.contiv.tenant": "utTenant",
+			"io.contiv.network": "utNet",
+			"unrelated": {"key": "value"}
+		},
+		"io.contiv.net-group": "ignored"
+	},
+	"dns": {"domain": "cluster.local"}
+}`
+	utPrevResult = `{
+	"cniVersion": "0.4.0",
+	"interfaces": [{"name": "eth0", "mac": "02:02:2c:37:42:4d", "sandbox": "/proc/98765/ns/net"}],
+	"ips": [{"version": "4", "interface": 0, "address": "44.55.66.77/22", "gateway": "44.55.64.1"}],
+	"routes": [{"dst": "0.0.0.0/0", "gw": "44.55.64.1"}],
+	"dns": {}
+}`
+	// testPluginEnv makes the test binary run as the contivk8s plugin
+	testPluginEnv = "CONTIVK8S_TEST_PLUGIN"
 )
 
 type restAPIFunc func(r *http.Request) (interface{}, error)
@@ -80,8 +108,16 @@ func stubAddPod(r *http.Request) (interface{}, error) {
 				err)
 		} else {
 			// respond with success
+			intfIdx := 0
 			resp.IPAddress = utPodIP
 			resp.EndpointID = pInfo.InfraContainerID
+			resp.CNIResult = &cniapi.CNIResult{
+				Interfaces: []cniapi.CNIInterface{{Name: pInfo.IntfName, Mac: utPodMac,
+					Sandbox: pInfo.NwNameSpace}},
+				IPs: []cniapi.CNIIPConfig{{Version: "4", Interface: &intfIdx, Address: utPodIP,
+					Gateway: "44.55.64.1"}},
+				Routes: []cniapi.CNIRoute{{Dst: "0.0.0.0/0", GW: "44.55.64.1"}},
+			}
 			return resp, nil
 		}
 	}
@@ -89,6 +125,39 @@ func stubAddPod(r *http.Request) (interface{}, error) {
 	return resp, fmt.Errorf("Failed to add pod")
 }
 
+// stubCheckPod is the handler for testing pod checks
+func stubCheckPod(r *http.Request) (interface{}, error) {
+
+	resp := cniapi.RspAddPod{}
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Failed to read request: %v", err)
+		return resp, err
+	}
+
+	pInfo := cniapi.CNIPodAttr{}
+	if err := json.Unmarshal(content, &pInfo); err != nil {
+		return resp, err
+	}
+
+	// verify pod attributes, config args and the result of the add are as
+	// expected.
+	prev := pInfo.PrevResult
+	if pInfo.Name == "utPod" && pInfo.K8sNameSpace == "utK8sNS" &&
+		pInfo.Labels[cniapi.LabelNetworkName] == "utNet" && prev != nil &&
+		len(prev.Interfaces) == 1 && prev.Interfaces[0].Mac == utPodMac &&
+		prev.Interfaces[0].Sandbox == pInfo.NwNameSpace &&
+		len(prev.IPs) == 1 && prev.IPs[0].Address == utPodIP &&
+		len(prev.Routes) == 1 && prev.Routes[0].GW == "44.55.64.1" {
+		resp.IPAddress = utPodIP
+		resp.EndpointID = pInfo.InfraContainerID
+		return resp, nil
+	}
+	logger.Errorf("Failed pod %v", pInfo)
+	return resp, fmt.Errorf("Failed to check pod")
+}
+
 // stubDeletePod is the handler for testing pod additions
 func stubDeletePod(r *http.Request) (interface{}, error) {
 
@@ -148,6 +217,7 @@ func setupTestServer() {
 	t := router.Headers("Content-Type", "application/json").Methods("POST").Subrouter()
 	t.HandleFunc(cniapi.EPAddURL, httpWrapper(stubAddPod))
 	t.HandleFunc(cniapi.EPDelURL, httpWrapper(stubDeletePod))
+	t.HandleFunc(cniapi.EPCheckURL, httpWrapper(stubCheckPod))
 
 	driverPath := cniapi.ContivCniSocket
 	os.Remove(driverPath)
@@ -180,6 +250,12 @@ func setupTestServer() {
 
 // TestMain sets up an http server for testing k8s plugin REST interface
 func TestMain(m *testing.M) {
+	// the test binary is the plugin run by libcni
+	if os.Getenv(testPluginEnv) != "" {
+		mainfunc()
+		os.Exit(0)
+	}
+
 	setupTestServer()
 	os.Exit(m.Run())
 }
@@ -189,6 +265,7 @@ func setupTestEnv() {
 	os.Setenv("CNI_ARGS", testCNIARGS)
 	os.Setenv("CNI_NETNS", utCNINETNS)
 	os.Setenv("CNI_IFNAME", "eth0")
+	netConfReader = strings.NewReader("")
 }
 
 // TestAddpod tests the AddPod interface
@@ -204,3 +281,219 @@ func TestDelpod(m *testing.T) {
 	os.Setenv("CNI_COMMAND", "DEL")
 	mainfunc()
 }
+
+// TestCheckpod tests the CheckPod interface
+func TestCheckpod(m *testing.T) {
+	setupTestEnv()
+	netConfReader = strings.NewReader(strings.Replace(utNetConf, `"dns"`,
+		`"prevResult": `+utPrevResult+`, "dns"`, 1))
+	os.Setenv("CNI_COMMAND", "CHECK")
+	mainfunc()
+}
+
+// TestNetConf tests parsing of the CNI network config
+func TestNetConf(t *testing.T) {
+	pInfo := cniapi.CNIPodAttr{}
+	netConfReader = strings.NewReader(utNetConf)
+	conf, err := getNetConf(&pInfo)
+	if err != nil {
+		t.Fatalf("getNetConf failed: %v", err)
+	}
+
+	if conf.CNIVersion != "0.4.0" || conf.DNS.Domain != "cluster.local" {
+		t.Fatalf("unexpected network config: %+v", conf)
+	}
+	if pInfo.Labels[cniapi.LabelNetworkName] != "utNet" ||
+		pInfo.Labels[cniapi.LabelTenantName] != "utTenant" {
+		t.Fatalf("unexpected labels from config args: %+v", pInfo.Labels)
+	}
+	if _, found := pInfo.Labels["unrelated"]; found {
+		t.Fatalf("unexpected label from config args: %+v", pInfo.Labels)
+	}
+	if _, found := pInfo.Labels[cniapi.LabelNetworkGroup]; found {
+		t.Fatalf("unexpected label from top-level args: %+v", pInfo.Labels)
+	}
+
+	netConfReader = strings.NewReader("")
+	conf, err = getNetConf(&cniapi.CNIPodAttr{})
+	if err != nil || conf.CNIVersion != legacyCNIVersion {
+		t.Fatalf("unexpected default network config: %+v, err: %v", conf, err)
+	}
+}
+
+// TestAddResult tests formatting of the CNI results
+func TestAddResult(t *testing.T) {
+	intfIdx := 0
+	pInfo := &cniapi.CNIPodAttr{IntfName: "eth0", NwNameSpace: utCNINETNS}
+	rsp := &cniapi.RspAddPod{
+		IPAddress: utPodIP,
+		CNIResult: &cniapi.CNIResult{
+			Interfaces: []cniapi.CNIInterface{{Name: "eth0", Mac: "02:02:2c:37:42:4d", Sandbox: utCNINETNS}},
+			IPs: []cniapi.CNIIPConfig{
+				{Version: "4", Interface: &intfIdx, Address: utPodIP, Gateway: "44.55.64.1"},
+				{Version: "6", Interface: &intfIdx, Address: "2001::5/100", Gateway: "2001::1"},
+			},
+			Routes: []cniapi.CNIRoute{{Dst: "0.0.0.0/0", GW: "44.55.64.1"}},
+		},
+	}
+
+	conf := &netConf{CNIVersion: "0.3.1", DNS: cniapi.CNIDNS{Nameservers: []string{"10.254.0.10"}}}
+	out, err := formatAddResult(conf, pInfo, rsp)
+	if err != nil {
+		t.Fatalf("formatAddResult failed: %v", err)
+	}
+
+	result := cniapi.CNIResult{}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("failed to parse result %s: %v", out, err)
+	}
+	if result.CNIVersion != "0.3.1" || len(result.Interfaces) != 1 ||
+		len(result.IPs) != 2 || len(result.Routes) != 1 ||
+		*result.IPs[1].Interface != 0 || result.DNS.Nameservers[0] != "10.254.0.10" {
+		t.Fatalf("unexpected 0.3.1 result: %s", out)
+	}
+
+	conf.CNIVersion = "0.2.0"
+	out, err = formatAddResult(conf, pInfo, rsp)
+	if err != nil {
+		t.Fatalf("formatAddResult failed: %v", err)
+	}
+
+	lr := legacyResult{}
+	if err := json.Unmarshal(out, &lr); err != nil {
+		t.Fatalf("failed to parse result %s: %v", out, err)
+	}
+	if lr.CNIVersion != "0.2.0" || lr.IP4.IP != utPodIP || lr.IP4.Gateway != "44.55.64.1" ||
+		lr.IP6.IP != "2001::5/100" {
+		t.Fatalf("unexpected 0.2.0 result: %s", out)
+	}
+
+	// responses without a cni result still produce 0.3.x output
+	conf.CNIVersion = "0.3.0"
+	out, err = formatAddResult(conf, pInfo, &cniapi.RspAddPod{IPAddress: utPodIP})
+	if err != nil {
+		t.Fatalf("formatAddResult failed: %v", err)
+	}
+	result = cniapi.CNIResult{}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("failed to parse result %s: %v", out, err)
+	}
+	if len(result.IPs) != 1 || result.IPs[0].Address != utPodIP ||
+		result.Interfaces[0].Sandbox != utCNINETNS {
+		t.Fatalf("unexpected 0.3.0 result: %s", out)
+	}
+}
+
+// testPluginExec runs the test binary as the contivk8s plugin
+type testPluginExec struct {
+	*invoke.DefaultExec
+}
+
+// FindInPath returns the test binary for any plugin
+func (e *testPluginExec) FindInPath(plugin string, paths []string) (string, error) {
+	return os.Args[0], nil
+}
+
+// TestCNIConformance runs the plugin the way container runtimes do, through
+// the upstream libcni
+func TestCNIConformance(t *testing.T) {
+	os.Setenv(testPluginEnv, "1")
+	defer os.Unsetenv(testPluginEnv)
+
+	cacheDir, err := ioutil.TempDir("", "contivk8s")
+	if err != nil {
+		t.Fatalf("error creating cache dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	exec := &testPluginExec{&invoke.DefaultExec{RawExec: &invoke.RawExec{Stderr: os.Stderr}}}
+	cniConfig := libcni.NewCNIConfigWithCacheDir([]string{"/opt/cni/bin"}, cacheDir, exec)
+	netConfig, err := libcni.ConfFromBytes([]byte(utNetConf))
+	if err != nil {
+		t.Fatalf("error parsing network config: %v", err)
+	}
+	rt := &libcni.RuntimeConf{
+		ContainerID: "8ec72deca647bfa60a4b815aa735c87de859b47e872828586749b9d852af1f49",
+		NetNS:       utCNINETNS,
+		IfName:      "eth0",
+		Args: [][2]string{
+			{"K8S_POD_NAMESPACE", "utK8sNS"},
+			{"K8S_POD_NAME", "utPod"},
+			{"K8S_POD_INFRA_CONTAINER_ID", "8ec72deca647bfa60a4b815aa735c87de859b47e872828586749b9d852af1f49"},
+		},
+	}
+	ctx := context.Background()
+
+	versions, err := cniConfig.ValidateNetwork(ctx, netConfig)
+	if err != nil {
+		t.Fatalf("error validating network config: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("unexpected capabilities: %v", versions)
+	}
+
+	versionInfo, err := cniConfig.GetVersionInfo(ctx, "contivk8s")
+	if err != nil {
+		t.Fatalf("error getting version info: %v", err)
+	}
+	if strings.Join(versionInfo.SupportedVersions(), ",") != strings.Join(cniapi.CNISupportedVersions, ",") {
+		t.Fatalf("unexpected supported versions: %v", versionInfo.SupportedVersions())
+	}
+
+	result, err := cniConfig.AddNetwork(ctx, netConfig, rt)
+	if err != nil {
+		t.Fatalf("error adding network: %v", err)
+	}
+	addResult, err := types040.GetResult(result)
+	if err != nil {
+		t.Fatalf("error converting result %v: %v", result, err)
+	}
+	if addResult.CNIVersion != "0.4.0" || len(addResult.Interfaces) != 1 ||
+		addResult.Interfaces[0].Mac != utPodMac || len(addResult.IPs) != 1 ||
+		addResult.IPs[0].Address.String() != utPodIP || len(addResult.Routes) != 1 ||
+		addResult.DNS.Domain != "cluster.local" {
+		t.Fatalf("unexpected add result: %+v", addResult)
+	}
+
+	// check gets the cached result of the add as prevResult
+	if err := cniConfig.CheckNetwork(ctx, netConfig, rt); err != nil {
+		t.Fatalf("error checking network: %v", err)
+	}
+
+	if err := cniConfig.DelNetwork(ctx, netConfig, rt); err != nil {
+		t.Fatalf("error deleting network: %v", err)
+	}
+
+	// results are converted to the version of the network config
+	legacyConfig, err := libcni.ConfFromBytes([]byte(strings.Replace(utNetConf,
+		`"cniVersion": "0.4.0"`, `"cniVersion": "0.2.0"`, 1)))
+	if err != nil {
+		t.Fatalf("error parsing network config: %v", err)
+	}
+	result, err = cniConfig.AddNetwork(ctx, legacyConfig, rt)
+	if err != nil {
+		t.Fatalf("error adding network: %v", err)
+	}
+	if result.Version() != "0.2.0" {
+		t.Fatalf("unexpected add result version %s", result.Version())
+	}
+
+	// CHECK is rejected before 0.4.0, and without a prevResult
+	for _, conf := range []string{
+		strings.Replace(utNetConf, `"cniVersion": "0.4.0"`, `"cniVersion": "0.3.1"`, 1),
+		utNetConf,
+	} {
+		args := &invoke.Args{
+			Command:     "CHECK",
+			ContainerID: rt.ContainerID,
+			NetNS:       rt.NetNS,
+			IfName:      rt.IfName,
+			PluginArgs:  rt.Args,
+			Path:        "/opt/cni/bin",
+		}
+		err = invoke.ExecPluginWithoutResult(ctx, os.Args[0], []byte(conf), args, exec)
+		if err == nil {
+			t.Fatalf("CHECK succeeded for config %s", conf)
+		}
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/cluster"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/k8sutils"
 	"github.com/contiv/netplugin/utils/netutils"
 	"github.com/vishvananda/netlink"
 )
@@ -44,13 +45,25 @@ type epSpec struct {
 	Group      string `json:"group,omitempty"`
 	EndpointID string `json:"endpointid,omitempty"`
 	Name       string `json:"name,omitempty"`
+	IntfName   string `json:"intfname,omitempty"`
+}
+
+// epAttachment is an entry of the networks annotation of a pod
+type epAttachment struct {
+	Tenant    string `json:"tenant,omitempty"`
+	Network   string `json:"network,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Interface string `json:"interface,omitempty"`
 }
 
 // epAttr contains the assigned attributes of the created ep
 type epAttr struct {
-	IPAddress string
-	PortName  string
-	Gateway   string
+	IPAddress   string
+	IPv6Address string
+	PortName    string
+	Gateway     string
+	IPv6Gateway string
+	MacAddress  string
 }
 
 // netdGetEndpoint is a utility that reads the EP oper state
@@ -176,12 +189,23 @@ func createEP(req *epSpec) (*epAttr, error) {
 		return nil, err
 	}
 
+	return buildEPAttr(ep, nw), nil
+}
+
+// buildEPAttr builds the attributes of an EP from its oper state and the
+// state of its network
+func buildEPAttr(ep *drivers.OperEndpointState, nw *mastercfg.CfgNetworkState) *epAttr {
 	epResponse := epAttr{}
 	epResponse.PortName = ep.PortName
 	epResponse.IPAddress = ep.IPAddress + "/" + strconv.Itoa(int(nw.SubnetLen))
 	epResponse.Gateway = nw.Gateway
+	epResponse.MacAddress = ep.MacAddress
+	if ep.IPv6Address != "" {
+		epResponse.IPv6Address = ep.IPv6Address + "/" + strconv.Itoa(int(nw.IPv6SubnetLen))
+		epResponse.IPv6Gateway = nw.IPv6Gateway
+	}
 
-	return &epResponse, nil
+	return &epResponse
 }
 
 // getLink is a wrapper that fetches the netlink corresponding to the ifname
@@ -345,39 +369,445 @@ func setDefGw(pid int, gw, intfName string) error {
 	return nil
 }
 
+// setIPv6Attrs assigns the ipv6 address to the container interface
+func setIPv6Attrs(pid int, cidr, intfName string) error {
+	nsenterPath, err := osexec.LookPath("nsenter")
+	if err != nil {
+		return err
+	}
+	ipPath, err := osexec.LookPath("ip")
+	if err != nil {
+		return err
+	}
+
+	nsPid := fmt.Sprintf("%d", pid)
+	out, err := osexec.Command(nsenterPath, "-t", nsPid, "-n", "-F", "--", ipPath, "-6",
+		"address", "add", cidr, "dev", intfName).CombinedOutput()
+	if err != nil {
+		log.Errorf("unable to assign ipv6 %s to %s. Error: %s - %s",
+			cidr, intfName, err, out)
+		return err
+	}
+
+	return nil
+}
+
+// setIPv6DefGw sets the ipv6 default gateway for the container namespace
+func setIPv6DefGw(pid int, gw, intfName string) error {
+	nsenterPath, err := osexec.LookPath("nsenter")
+	if err != nil {
+		return err
+	}
+	ipPath, err := osexec.LookPath("ip")
+	if err != nil {
+		return err
+	}
+
+	nsPid := fmt.Sprintf("%d", pid)
+	out, err := osexec.Command(nsenterPath, "-t", nsPid, "-n", "-F", "--", ipPath, "-6",
+		"route", "add", "default", "via", gw, "dev", intfName).CombinedOutput()
+	if err != nil {
+		log.Errorf("unable to set ipv6 default gw %s. Error: %s - %s",
+			gw, err, out)
+		return err
+	}
+
+	return nil
+}
+
+// nsIPShow runs an ip show command in the netns of the pid
+func nsIPShow(pid int, args ...string) (string, error) {
+	nsenterPath, err := osexec.LookPath("nsenter")
+	if err != nil {
+		return "", err
+	}
+	ipPath, err := osexec.LookPath("ip")
+	if err != nil {
+		return "", err
+	}
+
+	nsPid := fmt.Sprintf("%d", pid)
+	cmdArgs := append([]string{"-t", nsPid, "-n", "-F", "--", ipPath, "-o"}, args...)
+	out, err := osexec.Command(nsenterPath, cmdArgs...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s - %s", err, out)
+	}
+
+	return string(out), nil
+}
+
+// checkIfLink verifies that the container interface exists with the mac
+func checkIfLink(pid int, intfName, mac string) error {
+	out, err := nsIPShow(pid, "link", "show", "dev", intfName)
+	if err != nil {
+		return fmt.Errorf("interface %s not found: %s", intfName, err)
+	}
+
+	if mac != "" && !strings.Contains(out, " link/ether "+strings.ToLower(mac)+" ") {
+		return fmt.Errorf("interface %s does not have mac %s", intfName, mac)
+	}
+
+	return nil
+}
+
+// checkIfAddr verifies that the container interface carries the address,
+// given with its prefix length
+func checkIfAddr(pid int, intfName, cidr string) error {
+	out, err := nsIPShow(pid, "address", "show", "dev", intfName)
+	if err != nil {
+		return fmt.Errorf("interface %s not found: %s", intfName, err)
+	}
+
+	if !strings.Contains(out, " "+cidr+" ") {
+		return fmt.Errorf("address %s not found on interface %s", cidr, intfName)
+	}
+
+	return nil
+}
+
+// checkRoute verifies that the container has the route
+func checkRoute(pid int, route cniapi.CNIRoute) error {
+	family := "-4"
+	if strings.Contains(route.Dst, ":") {
+		family = "-6"
+	}
+
+	out, err := nsIPShow(pid, family, "route", "show", "exact", route.Dst)
+	if err != nil {
+		return fmt.Errorf("error reading route %s: %s", route.Dst, err)
+	}
+
+	if strings.TrimSpace(out) == "" {
+		return fmt.Errorf("route %s not found", route.Dst)
+	}
+	if route.GW != "" && !strings.Contains(out, " via "+route.GW+" ") {
+		return fmt.Errorf("route %s is not via %s", route.Dst, route.GW)
+	}
+
+	return nil
+}
+
+// checkPrevResult verifies that the result of a pod add still describes
+// the EP attached on the interface
+func checkPrevResult(prev *cniapi.CNIResult, sandbox, intfName string, ep *epAttr) error {
+	intfIdx := -1
+	for idx, intf := range prev.Interfaces {
+		if intf.Name == intfName && intf.Sandbox == sandbox {
+			intfIdx = idx
+			break
+		}
+	}
+	if intfIdx < 0 {
+		return fmt.Errorf("interface %s not found in prevResult", intfName)
+	}
+
+	mac := prev.Interfaces[intfIdx].Mac
+	if mac != "" && !strings.EqualFold(mac, ep.MacAddress) {
+		return fmt.Errorf("interface %s has mac %s in prevResult, EP has %s",
+			intfName, mac, ep.MacAddress)
+	}
+
+	for _, addr := range []string{ep.IPAddress, ep.IPv6Address} {
+		if addr == "" {
+			continue
+		}
+		found := false
+		for _, ip := range prev.IPs {
+			if ip.Address == addr && ip.Interface != nil && *ip.Interface == intfIdx {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("address %s of interface %s not found in prevResult",
+				addr, intfName)
+		}
+	}
+
+	return nil
+}
+
+// checkNetNS verifies that the interfaces, addresses and routes of the
+// result of a pod add are in the container
+func checkNetNS(pid int, prev *cniapi.CNIResult, sandbox string) error {
+	for _, intf := range prev.Interfaces {
+		if intf.Sandbox != sandbox {
+			continue
+		}
+		if err := checkIfLink(pid, intf.Name, intf.Mac); err != nil {
+			return err
+		}
+	}
+
+	for _, ip := range prev.IPs {
+		if ip.Interface == nil || *ip.Interface < 0 || *ip.Interface >= len(prev.Interfaces) {
+			return fmt.Errorf("address %s has an invalid interface in prevResult", ip.Address)
+		}
+		intf := prev.Interfaces[*ip.Interface]
+		if intf.Sandbox != sandbox {
+			continue
+		}
+		if err := checkIfAddr(pid, intf.Name, ip.Address); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range prev.Routes {
+		if err := checkRoute(pid, route); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupContivLabel returns the value of a contiv label for the pod and
+// whether it was set. Labels set on the pod take precedence over the args of
+// the CNI network config.
+func lookupContivLabel(pInfo *cniapi.CNIPodAttr, label string) (string, bool, error) {
+	val, found, err := kubeAPIClient.LookupPodLabel(pInfo.K8sNameSpace, pInfo.Name, label)
+	if err != nil || found {
+		return val, found, err
+	}
+
+	val, found = pInfo.Labels[label]
+	return val, found, nil
+}
+
 // getEPSpec gets the EP spec using the pod attributes
 func getEPSpec(pInfo *cniapi.CNIPodAttr) (*epSpec, error) {
 	resp := epSpec{}
 
 	// Get labels from the kube api server
-	epg, err := kubeAPIClient.GetPodLabel(pInfo.K8sNameSpace, pInfo.Name,
-		"io.contiv.net-group")
+	epg, epgFound, err := lookupContivLabel(pInfo, cniapi.LabelNetworkGroup)
 	if err != nil {
 		log.Errorf("Error getting epg. Err: %v", err)
 		return &resp, err
 	}
 
-	// Safe to ignore the error return for subsequent invocations of GetPodLabel
-	netw, _ := kubeAPIClient.GetPodLabel(pInfo.K8sNameSpace, pInfo.Name,
-		"io.contiv.network")
-	tenant, _ := kubeAPIClient.GetPodLabel(pInfo.K8sNameSpace, pInfo.Name,
-		"io.contiv.tenant")
+	// Safe to ignore the error return for subsequent label lookups
+	netw, netwFound, _ := lookupContivLabel(pInfo, cniapi.LabelNetworkName)
+	tenant, tenantFound, _ := lookupContivLabel(pInfo, cniapi.LabelTenantName)
+
+	if !epgFound && !netwFound && !tenantFound {
+		// no labels, use the epg of the namespace when namespaces are mapped
+		nsTenant, nsNetw, nsEpg, ok := k8sutils.GetNamespaceTarget(&contivK8Config,
+			pInfo.K8sNameSpace)
+		if ok {
+			tenant, netw, epg = nsTenant, nsNetw, nsEpg
+			epgFound, netwFound, tenantFound = true, true, true
+		}
+	}
+
+	if !epgFound {
+		epg = defaultPodLabels[cniapi.LabelNetworkGroup]
+	}
+	if !netwFound {
+		netw = defaultPodLabels[cniapi.LabelNetworkName]
+	}
+	if !tenantFound {
+		tenant = defaultPodLabels[cniapi.LabelTenantName]
+	}
 	log.Infof("labels is %s/%s/%s for pod %s\n", tenant, netw, epg, pInfo.Name)
 	resp.Tenant = tenant
 	resp.Network = netw
 	resp.Group = epg
 	resp.EndpointID = pInfo.InfraContainerID
 	resp.Name = pInfo.Name
+	resp.IntfName = pInfo.IntfName
 
 	return &resp, nil
 }
 
+// parseAttachments builds the specs of the additional endpoints listed in
+// the networks annotation of a pod
+func parseAttachments(primary *epSpec, annotation string) ([]*epSpec, error) {
+	if strings.TrimSpace(annotation) == "" {
+		return nil, nil
+	}
+
+	attachments := []epAttachment{}
+	if err := json.Unmarshal([]byte(annotation), &attachments); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", cniapi.AnnotationNetworks, err)
+	}
+
+	intfNames := map[string]bool{primary.IntfName: true}
+	specs := []*epSpec{}
+	for idx, att := range attachments {
+		if att.Network == "" {
+			return nil, fmt.Errorf("network missing in attachment %d of %s annotation",
+				idx, cniapi.AnnotationNetworks)
+		}
+
+		if att.Tenant == "" {
+			att.Tenant = primary.Tenant
+		}
+		if att.Interface == "" {
+			att.Interface = fmt.Sprintf("net%d", idx+1)
+		}
+		if intfNames[att.Interface] {
+			return nil, fmt.Errorf("duplicate interface %s in %s annotation",
+				att.Interface, cniapi.AnnotationNetworks)
+		}
+		intfNames[att.Interface] = true
+
+		specs = append(specs, &epSpec{
+			Tenant:     att.Tenant,
+			Network:    att.Network,
+			Group:      att.Group,
+			EndpointID: primary.EndpointID + "-" + att.Interface,
+			Name:       primary.Name,
+			IntfName:   att.Interface,
+		})
+	}
+
+	return specs, nil
+}
+
+// getEPSpecs gets the specs of all the EPs of a pod, the primary EP comes
+// first and is followed by the ones listed in the networks annotation
+func getEPSpecs(pInfo *cniapi.CNIPodAttr) ([]*epSpec, error) {
+	primary, err := getEPSpec(pInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	annotation, err := kubeAPIClient.GetPodAnnotation(pInfo.K8sNameSpace, pInfo.Name,
+		cniapi.AnnotationNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := parseAttachments(primary, annotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*epSpec{primary}, attachments...), nil
+}
+
+// getLocalEPSpecs gets the specs of the EPs of a pod that exist on this host,
+// which does not rely on the pod being known to the api server anymore
+func getLocalEPSpecs(infraContainerID string) ([]*epSpec, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	readEp := &drivers.OperEndpointState{}
+	readEp.StateDriver = stateDriver
+	epList, err := readEp.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	specs := []*epSpec{}
+	for _, state := range epList {
+		ep := state.(*drivers.OperEndpointState)
+		if ep.EndpointID != infraContainerID &&
+			!strings.HasPrefix(ep.EndpointID, infraContainerID+"-") {
+			continue
+		}
+
+		sep := strings.LastIndex(ep.NetID, ".")
+		if sep < 0 {
+			log.Warnf("ignoring ep %s with invalid network %s", ep.ID, ep.NetID)
+			continue
+		}
+
+		specs = append(specs, &epSpec{
+			Tenant:     ep.NetID[sep+1:],
+			Network:    ep.NetID[:sep],
+			Group:      ep.ServiceName,
+			EndpointID: ep.EndpointID,
+		})
+	}
+
+	return specs, nil
+}
+
+// addAttachment creates an additional EP of a pod and moves it to the pod
+func addAttachment(pid int, pInfo *cniapi.CNIPodAttr, req *epSpec,
+	result *cniapi.CNIResult) error {
+
+	ep, err := createEP(req)
+	if err != nil {
+		return err
+	}
+
+	err = setIfAttrs(pid, ep.PortName, ep.IPAddress, req.IntfName)
+	if err == nil && ep.IPv6Address != "" {
+		err = setIPv6Attrs(pid, ep.IPv6Address, req.IntfName)
+	}
+	if err != nil {
+		epCleanUp(req)
+		return err
+	}
+
+	// additional networks only get their connected routes, the default
+	// route stays on the primary interface
+	intfIdx := len(result.Interfaces)
+	result.Interfaces = append(result.Interfaces, cniapi.CNIInterface{
+		Name:    req.IntfName,
+		Mac:     ep.MacAddress,
+		Sandbox: pInfo.NwNameSpace,
+	})
+	result.IPs = append(result.IPs, cniapi.CNIIPConfig{
+		Version:   "4",
+		Interface: &intfIdx,
+		Address:   ep.IPAddress,
+	})
+	if ep.IPv6Address != "" {
+		result.IPs = append(result.IPs, cniapi.CNIIPConfig{
+			Version:   "6",
+			Interface: &intfIdx,
+			Address:   ep.IPv6Address,
+		})
+	}
+
+	return nil
+}
+
 func setErrorResp(resp *cniapi.RspAddPod, msg string, err error) {
 	resp.Result = 1
 	resp.ErrMsg = msg
 	resp.ErrInfo = fmt.Sprintf("Err: %v", err)
 }
 
+// buildCNIResult builds the CNI 0.3.x result for a pod from the attributes
+// of its endpoint
+func buildCNIResult(pInfo *cniapi.CNIPodAttr, ep *epAttr) *cniapi.CNIResult {
+	intfIdx := 0
+	result := &cniapi.CNIResult{
+		CNIVersion: cniapi.CNIVersion,
+		Interfaces: []cniapi.CNIInterface{{
+			Name:    pInfo.IntfName,
+			Mac:     ep.MacAddress,
+			Sandbox: pInfo.NwNameSpace,
+		}},
+		IPs: []cniapi.CNIIPConfig{{
+			Version:   "4",
+			Interface: &intfIdx,
+			Address:   ep.IPAddress,
+			Gateway:   ep.Gateway,
+		}},
+	}
+
+	if ep.IPv6Address != "" {
+		result.IPs = append(result.IPs, cniapi.CNIIPConfig{
+			Version:   "6",
+			Interface: &intfIdx,
+			Address:   ep.IPv6Address,
+			Gateway:   ep.IPv6Gateway,
+		})
+		if ep.IPv6Gateway != "" {
+			result.Routes = append(result.Routes, cniapi.CNIRoute{Dst: "::/0", GW: ep.IPv6Gateway})
+		}
+	}
+
+	return result
+}
+
 // addPod is the handler for pod additions
 func addPod(r *http.Request) (interface{}, error) {
 
@@ -396,15 +826,15 @@ func addPod(r *http.Request) (interface{}, error) {
 		return resp, err
 	}
 
-	// Get labels from the kube api server
-	epReq, err := getEPSpec(&pInfo)
+	// Get labels and annotations from the kube api server
+	epReqs, err := getEPSpecs(&pInfo)
 	if err != nil {
 		log.Errorf("Error getting labels. Err: %v", err)
 		setErrorResp(&resp, "Error getting labels", err)
 		return resp, err
 	}
 
-	ep, err := createEP(epReq)
+	ep, err := createEP(epReqs[0])
 	if err != nil {
 		log.Errorf("Error creating ep. Err: %v", err)
 		setErrorResp(&resp, "Error creating EP", err)
@@ -427,6 +857,17 @@ func addPod(r *http.Request) (interface{}, error) {
 		return resp, err
 	}
 
+	if ep.IPv6Address != "" {
+		err = setIPv6Attrs(pid, ep.IPv6Address, pInfo.IntfName)
+		if err != nil {
+			log.Errorf("Error setting ipv6 address. Err: %v", err)
+			setErrorResp(&resp, "Error setting ipv6 address", err)
+			return resp, err
+		}
+	}
+
+	result := buildCNIResult(&pInfo, ep)
+
 	// if Gateway is not specified on the nw, use the host gateway
 	gwIntf := pInfo.IntfName
 	gw := ep.Gateway
@@ -448,6 +889,18 @@ func addPod(r *http.Request) (interface{}, error) {
 					// make sure service subnet points to eth0
 					svcSubnet := contivK8Config.SvcSubnet
 					addStaticRoute(pid, svcSubnet, pInfo.IntfName)
+
+					hostIdx := len(result.Interfaces)
+					result.Interfaces = append(result.Interfaces, cniapi.CNIInterface{
+						Name:    gwIntf,
+						Sandbox: pInfo.NwNameSpace,
+					})
+					result.IPs = append(result.IPs, cniapi.CNIIPConfig{
+						Version:   "4",
+						Interface: &hostIdx,
+						Address:   hostIP,
+					})
+					result.Routes = append(result.Routes, cniapi.CNIRoute{Dst: svcSubnet})
 				}
 			}
 		}
@@ -461,10 +914,34 @@ func addPod(r *http.Request) (interface{}, error) {
 		setErrorResp(&resp, "Error setting default gateway", err)
 		return resp, err
 	}
+	if gw != "" {
+		result.Routes = append(result.Routes, cniapi.CNIRoute{Dst: "0.0.0.0/0", GW: gw})
+	}
+
+	if ep.IPv6Gateway != "" {
+		err = setIPv6DefGw(pid, ep.IPv6Gateway, pInfo.IntfName)
+		if err != nil {
+			log.Errorf("Error setting ipv6 default gateway. Err: %v", err)
+			setErrorResp(&resp, "Error setting ipv6 default gateway", err)
+			return resp, err
+		}
+	}
+
+	// Attach the additional networks, endpoints that were created before a
+	// failure are removed by the delete that follows a failed add
+	for _, attReq := range epReqs[1:] {
+		err = addAttachment(pid, &pInfo, attReq, result)
+		if err != nil {
+			log.Errorf("Error attaching network %s. Err: %v", attReq.Network, err)
+			setErrorResp(&resp, "Error attaching network "+attReq.Network, err)
+			return resp, err
+		}
+	}
 
 	resp.Result = 0
 	resp.IPAddress = ep.IPAddress
 	resp.EndpointID = pInfo.InfraContainerID
+	resp.CNIResult = result
 	return resp, nil
 }
 
@@ -486,18 +963,119 @@ func deletePod(r *http.Request) (interface{}, error) {
 		return resp, err
 	}
 
-	// Get labels from the kube api server
-	epReq, err := getEPSpec(&pInfo)
+	// Get labels and annotations from the kube api server, and add the EPs
+	// found on this host in case the pod is gone or was partially created
+	epReqs, specErr := getEPSpecs(&pInfo)
+	if specErr != nil {
+		log.Warnf("Error getting labels. Err: %v", specErr)
+	}
+
+	localReqs, err := getLocalEPSpecs(pInfo.InfraContainerID)
+	if err != nil {
+		log.Errorf("Error reading local endpoints. Err: %v", err)
+	}
+
+	if specErr != nil && len(localReqs) == 0 {
+		setErrorResp(&resp, "Error getting labels", specErr)
+		return resp, specErr
+	}
+
+	epSeen := make(map[string]bool)
+	for _, epReq := range append(epReqs, localReqs...) {
+		epKey := epReq.Network + "." + epReq.Tenant + "-" + epReq.EndpointID
+		if epSeen[epKey] {
+			continue
+		}
+		epSeen[epKey] = true
+
+		if epReq.EndpointID == pInfo.InfraContainerID {
+			netPlugin.DeleteHostAccPort(epReq.EndpointID)
+		}
+		if err = epCleanUp(epReq); err != nil {
+			log.Errorf("failed to delete pod endpoint %s, error: %s", epKey, err)
+		}
+	}
+	resp.Result = 0
+	resp.EndpointID = pInfo.InfraContainerID
+	return resp, nil
+}
+
+// checkPod is the handler for pod checks
+func checkPod(r *http.Request) (interface{}, error) {
+
+	resp := cniapi.RspAddPod{}
+
+	logEvent("check pod")
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Errorf("Failed to read request: %v", err)
+		return resp, err
+	}
+
+	pInfo := cniapi.CNIPodAttr{}
+	if err := json.Unmarshal(content, &pInfo); err != nil {
+		return resp, err
+	}
+
+	// Get labels and annotations from the kube api server
+	epReqs, err := getEPSpecs(&pInfo)
 	if err != nil {
 		log.Errorf("Error getting labels. Err: %v", err)
 		setErrorResp(&resp, "Error getting labels", err)
 		return resp, err
 	}
 
-	netPlugin.DeleteHostAccPort(epReq.EndpointID)
-	if err = epCleanUp(epReq); err != nil {
-		log.Errorf("failed to delete pod, error: %s", err)
+	if pInfo.PrevResult == nil {
+		err = fmt.Errorf("no prevResult for pod %s", pInfo.Name)
+		log.Errorf("Error checking pod. Err: %v", err)
+		setErrorResp(&resp, "Error checking pod", err)
+		return resp, err
+	}
+
+	// the prevResult must match the EPs of the pod
+	for _, epReq := range epReqs {
+		netID := epReq.Network + "." + epReq.Tenant
+		ep, err := netdGetEndpoint(netID + "-" + epReq.EndpointID)
+		if err != nil {
+			log.Errorf("Error reading ep. Err: %v", err)
+			setErrorResp(&resp, "Error reading EP", err)
+			return resp, err
+		}
+		nw, err := netdGetNetwork(netID)
+		if err != nil {
+			log.Errorf("Error reading nw. Err: %v", err)
+			setErrorResp(&resp, "Error reading network", err)
+			return resp, err
+		}
+
+		epResponse := buildEPAttr(ep, nw)
+		err = checkPrevResult(pInfo.PrevResult, pInfo.NwNameSpace, epReq.IntfName, epResponse)
+		if err != nil {
+			log.Errorf("Error checking prevResult. Err: %v", err)
+			setErrorResp(&resp, "Error checking prevResult", err)
+			return resp, err
+		}
+
+		if epReq.EndpointID == pInfo.InfraContainerID {
+			resp.IPAddress = epResponse.IPAddress
+		}
+	}
+
+	// and the container must still have what the prevResult lists
+	pid, err := nsToPID(pInfo.NwNameSpace)
+	if err != nil {
+		log.Errorf("Error moving to netns. Err: %v", err)
+		setErrorResp(&resp, "Error moving to netns", err)
+		return resp, err
+	}
+
+	if err = checkNetNS(pid, pInfo.PrevResult, pInfo.NwNameSpace); err != nil {
+		log.Errorf("Error checking netns. Err: %v", err)
+		setErrorResp(&resp, "Error checking netns", err)
+		return resp, err
 	}
+
 	resp.Result = 0
 	resp.EndpointID = pInfo.InfraContainerID
 	return resp, nil
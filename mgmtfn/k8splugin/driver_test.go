@@ -22,6 +22,7 @@ import (
 	"runtime"
 
 	. "github.com/contiv/check"
+	"github.com/contiv/netplugin/mgmtfn/k8splugin/cniapi"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 )
@@ -213,3 +214,86 @@ func (s *NetSetup) TestNetSetup(c *C) {
 		c.Errorf("expected interface to be up, but it's down")
 	}
 }
+
+type AttachSuite struct{}
+
+var _ = Suite(&AttachSuite{})
+
+func (s *AttachSuite) TestParseAttachments(c *C) {
+	primary := &epSpec{
+		Tenant:     "t1",
+		Network:    "n1",
+		EndpointID: "infra1",
+		Name:       "pod1",
+		IntfName:   "eth0",
+	}
+
+	specs, err := parseAttachments(primary, "")
+	c.Assert(err, IsNil)
+	c.Assert(specs, HasLen, 0)
+
+	specs, err = parseAttachments(primary,
+		`[{"network": "data"}, {"tenant": "t2", "network": "storage", "group": "g1", "interface": "stor0"}]`)
+	c.Assert(err, IsNil)
+	c.Assert(specs, HasLen, 2)
+	c.Assert(*specs[0], DeepEquals, epSpec{Tenant: "t1", Network: "data",
+		EndpointID: "infra1-net1", Name: "pod1", IntfName: "net1"})
+	c.Assert(*specs[1], DeepEquals, epSpec{Tenant: "t2", Network: "storage", Group: "g1",
+		EndpointID: "infra1-stor0", Name: "pod1", IntfName: "stor0"})
+
+	_, err = parseAttachments(primary, `[{"network": "data", "interface": "eth0"}]`)
+	c.Assert(err, NotNil)
+
+	_, err = parseAttachments(primary, `[{"tenant": "t2"}]`)
+	c.Assert(err, NotNil)
+
+	_, err = parseAttachments(primary, `{"network": "data"}`)
+	c.Assert(err, NotNil)
+}
+
+func (s *AttachSuite) TestCheckPrevResult(c *C) {
+	eth0, net1 := 0, 1
+	prev := &cniapi.CNIResult{
+		Interfaces: []cniapi.CNIInterface{
+			{Name: "eth0", Mac: "02:02:0A:01:01:02", Sandbox: "/proc/100/ns/net"},
+			{Name: "net1", Mac: "02:02:0a:02:02:02", Sandbox: "/proc/100/ns/net"},
+		},
+		IPs: []cniapi.CNIIPConfig{
+			{Version: "4", Interface: &eth0, Address: "10.1.1.2/24", Gateway: "10.1.1.1"},
+			{Version: "6", Interface: &eth0, Address: "2001::2/100"},
+			{Version: "4", Interface: &net1, Address: "10.2.2.2/24"},
+		},
+		Routes: []cniapi.CNIRoute{{Dst: "0.0.0.0/0", GW: "10.1.1.1"}},
+	}
+
+	ep := &epAttr{IPAddress: "10.1.1.2/24", IPv6Address: "2001::2/100", MacAddress: "02:02:0a:01:01:02"}
+	c.Assert(checkPrevResult(prev, "/proc/100/ns/net", "eth0", ep), IsNil)
+
+	net1EP := &epAttr{IPAddress: "10.2.2.2/24", MacAddress: "02:02:0a:02:02:02"}
+	c.Assert(checkPrevResult(prev, "/proc/100/ns/net", "net1", net1EP), IsNil)
+
+	for _, tc := range []struct {
+		sandbox  string
+		intfName string
+		ep       *epAttr
+	}{
+		// interface of another netns
+		{"/proc/200/ns/net", "eth0", ep},
+		// interface missing
+		{"/proc/100/ns/net", "net2", ep},
+		// mac changed
+		{"/proc/100/ns/net", "eth0", &epAttr{IPAddress: "10.1.1.2/24", MacAddress: "02:02:0a:01:01:03"}},
+		// address changed
+		{"/proc/100/ns/net", "eth0", &epAttr{IPAddress: "10.1.1.3/24", MacAddress: "02:02:0a:01:01:02"}},
+		// prefix length changed
+		{"/proc/100/ns/net", "eth0", &epAttr{IPAddress: "10.1.1.2/16", MacAddress: "02:02:0a:01:01:02"}},
+		// address on another interface
+		{"/proc/100/ns/net", "net1", &epAttr{IPAddress: "10.1.1.2/24", MacAddress: "02:02:0a:02:02:02"}},
+		// ipv6 address missing
+		{"/proc/100/ns/net", "net1", &epAttr{IPAddress: "10.2.2.2/24", IPv6Address: "2002::2/100",
+			MacAddress: "02:02:0a:02:02:02"}},
+	} {
+		c.Assert(checkPrevResult(prev, tc.sandbox, tc.intfName, tc.ep), NotNil,
+			Commentf("sandbox %s, interface %s, ep %+v", tc.sandbox, tc.intfName, tc.ep))
+	}
+}
@@ -16,7 +16,6 @@ limitations under the License.
 package k8splugin
 
 import (
-	"bufio"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -27,9 +26,6 @@ import (
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/contiv/netplugin/core"
-	"golang.org/x/net/context"
-	"golang.org/x/net/context/ctxhttp"
 	"strconv"
 )
 
@@ -47,57 +43,34 @@ type APIClient struct {
 	authToken     string
 }
 
-// SvcWatchResp is the response to a service watch
-type SvcWatchResp struct {
-	opcode  string
-	errStr  string
-	svcName string
-	svcSpec core.ServiceSpec
-}
-
-// EpWatchResp is the response to service endpoints watch
-type EpWatchResp struct {
-	opcode    string
-	errStr    string
-	svcName   string
-	providers []string
-}
-
-type watchSvcStatus struct {
-	// The type of watch update contained in the message
-	Type string `json:"type"`
-	// Pod details
-	Object Service `json:"object"`
-}
-
-type watchSvcEpStatus struct {
-	// The type of watch update contained in the message
-	Type string `json:"type"`
-	// Pod details
-	Object Endpoints `json:"object"`
-}
-
 type podInfo struct {
 	nameSpace   string
 	name        string
 	labels      map[string]string
+	annotations map[string]string
 	labelsMutex sync.Mutex
 }
 
-// NewAPIClient creates an instance of the k8s api client
-func NewAPIClient(serverURL, caFile, keyFile, certFile, authToken string) *APIClient {
-	useClientCerts := true
-	c := APIClient{}
-
-	c.apiServerPort = 6443 // default
+// getAPIServerPort returns the port of the api server url
+func getAPIServerPort(serverURL string) uint16 {
+	apiServerPort := uint16(6443) // default
 	port := strings.Split(serverURL, ":")
 	if len(port) > 0 {
 		if v, err := strconv.ParseUint(port[len(port)-1], 10, 16); err == nil {
-			c.apiServerPort = uint16(v)
+			apiServerPort = uint16(v)
 		} else {
-			log.Warnf("parse failed: %s, use default api server port: %d", err, c.apiServerPort)
+			log.Warnf("parse failed: %s, use default api server port: %d", err, apiServerPort)
 		}
 	}
+	return apiServerPort
+}
+
+// NewAPIClient creates an instance of the k8s api client
+func NewAPIClient(serverURL, caFile, keyFile, certFile, authToken string) *APIClient {
+	useClientCerts := true
+	c := APIClient{}
+
+	c.apiServerPort = getAPIServerPort(serverURL)
 
 	c.baseURL = serverURL + "/api/v1/namespaces/"
 	c.watchBase = serverURL + "/api/v1/watch/"
@@ -139,18 +112,25 @@ func NewAPIClient(serverURL, caFile, keyFile, certFile, authToken string) *APICl
 
 	p := &c.podCache
 	p.labels = make(map[string]string)
+	p.annotations = make(map[string]string)
 	p.nameSpace = ""
 	p.name = ""
 
 	return &c
 }
 
+// defaultPodLabels are used for contiv labels that are not set on a pod
+var defaultPodLabels = map[string]string{
+	"io.contiv.tenant":    "default",
+	"io.contiv.network":   "default-net",
+	"io.contiv.net-group": "",
+}
+
 func (p *podInfo) setDefaults(ns, name string) {
 	p.nameSpace = ns
 	p.name = name
-	p.labels["io.contiv.tenant"] = "default"
-	p.labels["io.contiv.network"] = "default-net"
-	p.labels["io.contiv.net-group"] = ""
+	p.labels = make(map[string]string)
+	p.annotations = make(map[string]string)
 }
 
 // fetchPodLabels retrieves the labels from the podspec metadata
@@ -223,182 +203,65 @@ func (c *APIClient) fetchPodLabels(ns, name string) error {
 		log.Infof("labels not found in podSpec metadata, using defaults")
 	}
 
+	if a, ok := meta["annotations"]; ok {
+		annotations := a.(map[string]interface{})
+		for key, val := range annotations {
+			if str, ok := val.(string); ok {
+				p.annotations[key] = str
+			}
+		}
+	}
+
 	return nil
 }
 
-// GetPodLabel retrieves the specified label
+// GetPodLabel retrieves the specified label, falling back to the contiv
+// default when the pod does not carry it
 func (c *APIClient) GetPodLabel(ns, name, label string) (string, error) {
-
-	// If cache does not match, fetch
-	if c.podCache.nameSpace != ns || c.podCache.name != name {
-		err := c.fetchPodLabels(ns, name)
-		if err != nil {
-			return "", err
-		}
+	res, found, err := c.LookupPodLabel(ns, name, label)
+	if err != nil {
+		return "", err
 	}
 
-	res, found := c.podCache.labels[label]
 	if found {
 		return res, nil
 	}
 
 	log.Infof("label %s not found in podSpec for %s.%s", label, ns, name)
-	return "", nil
+	return defaultPodLabels[label], nil
 }
 
-// WatchServices watches the services object on the api server
-func (c *APIClient) WatchServices(respCh chan SvcWatchResp) {
-	ctx, _ := context.WithCancel(context.Background())
+// GetPodAnnotation retrieves the specified annotation, an empty string is
+// returned when the pod does not carry it
+func (c *APIClient) GetPodAnnotation(ns, name, annotation string) (string, error) {
 
-	go func() {
-		// Make request to Kubernetes API
-		getURL := c.watchBase + "services"
-		req, err := http.NewRequest("GET", getURL, nil)
-		if err != nil {
-			respCh <- SvcWatchResp{opcode: "FATAL", errStr: fmt.Sprintf("Req %v", err)}
-			return
-		}
-		if len(strings.TrimSpace(c.authToken)) > 0 {
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
-		}
-		res, err := ctxhttp.Do(ctx, c.client, req)
+	// If cache does not match, fetch
+	if c.podCache.nameSpace != ns || c.podCache.name != name {
+		err := c.fetchPodLabels(ns, name)
 		if err != nil {
-			log.Errorf("Watch error: %v", err)
-			respCh <- SvcWatchResp{opcode: "FATAL", errStr: fmt.Sprintf("Do %v", err)}
-			return
+			return "", err
 		}
-		defer res.Body.Close()
-
-		var wss watchSvcStatus
-		reader := bufio.NewReader(res.Body)
-
-		// bufio.Reader.ReadBytes is blocking, so we watch for
-		// context timeout or cancellation in a goroutine
-		// and close the response body when see see it. The
-		// response body is also closed via defer when the
-		// request is made, but closing twice is OK.
-		go func() {
-			<-ctx.Done()
-			res.Body.Close()
-		}()
-
-		for {
-			line, err := reader.ReadBytes('\n')
-			if ctx.Err() != nil {
-				respCh <- SvcWatchResp{opcode: "ERROR", errStr: fmt.Sprintf("ctx %v", err)}
-				return
-			}
-			if err != nil {
-				respCh <- SvcWatchResp{opcode: "ERROR", errStr: fmt.Sprintf("read %v", err)}
-				return
-			}
-			if err := json.Unmarshal(line, &wss); err != nil {
-				respCh <- SvcWatchResp{opcode: "WARN", errStr: fmt.Sprintf("unmarshal %v", err)}
-				continue
-			}
-
-			if wss.Object.ObjectMeta.Namespace == "kube-system" && (wss.Object.ObjectMeta.Name == "kube-scheduler" || wss.Object.ObjectMeta.Name == "kube-controller-manager") {
-				// Ignoring these frequent updates
-				continue
-			}
-
-			resp := SvcWatchResp{opcode: wss.Type}
-			resp.svcName = wss.Object.ObjectMeta.Name
-			sSpec := core.ServiceSpec{}
-			sSpec.Ports = make([]core.PortSpec, 0, 1)
-			sSpec.IPAddress = wss.Object.Spec.ClusterIP
-			sSpec.ExternalIPs = wss.Object.Spec.ExternalIPs
-			for _, port := range wss.Object.Spec.Ports {
-				ps := core.PortSpec{Protocol: string(port.Protocol),
-					SvcPort:  uint16(port.Port),
-					NodePort: uint16(port.NodePort),
-				}
-
-				// handle 'kubernetes' service
-				// Use port from configuration till named ports are supported.
-				if resp.svcName == "kubernetes" && (len(wss.Object.ObjectMeta.Namespace) == 0 ||
-					wss.Object.ObjectMeta.Namespace == "default") {
-					ps.ProvPort = c.apiServerPort
-				} else {
-					ps.ProvPort = uint16(port.TargetPort)
-				}
-				sSpec.Ports = append(sSpec.Ports, ps)
-			}
-
-			resp.svcSpec = sSpec
-			log.Infof("resp: %+v", resp)
+	}
 
-			respCh <- resp
-		}
-	}()
+	c.podCache.labelsMutex.Lock()
+	defer c.podCache.labelsMutex.Unlock()
+	return c.podCache.annotations[annotation], nil
 }
 
-// WatchSvcEps watches the service endpoints object
-func (c *APIClient) WatchSvcEps(respCh chan EpWatchResp) {
-	ctx, _ := context.WithCancel(context.Background())
+// LookupPodLabel retrieves the specified label and reports whether the pod
+// carries it
+func (c *APIClient) LookupPodLabel(ns, name, label string) (string, bool, error) {
 
-	go func() {
-		// Make request to Kubernetes API
-		getURL := c.watchBase + "endpoints"
-		req, err := http.NewRequest("GET", getURL, nil)
-		if err != nil {
-			respCh <- EpWatchResp{opcode: "FATAL", errStr: fmt.Sprintf("Req %v", err)}
-			return
-		}
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
-		res, err := ctxhttp.Do(ctx, c.client, req)
+	// If cache does not match, fetch
+	if c.podCache.nameSpace != ns || c.podCache.name != name {
+		err := c.fetchPodLabels(ns, name)
 		if err != nil {
-			log.Errorf("EP Watch error: %v", err)
-			respCh <- EpWatchResp{opcode: "FATAL", errStr: fmt.Sprintf("Do %v", err)}
-			return
+			return "", false, err
 		}
-		defer res.Body.Close()
-
-		var weps watchSvcEpStatus
-		reader := bufio.NewReader(res.Body)
-
-		// bufio.Reader.ReadBytes is blocking, so we watch for
-		// context timeout or cancellation in a goroutine
-		// and close the response body when see see it. The
-		// response body is also closed via defer when the
-		// request is made, but closing twice is OK.
-		go func() {
-			<-ctx.Done()
-			res.Body.Close()
-		}()
-
-		for {
-			line, err := reader.ReadBytes('\n')
-			if ctx.Err() != nil {
-				respCh <- EpWatchResp{opcode: "ERROR", errStr: fmt.Sprintf("ctx %v", err)}
-				return
-			}
-			if err != nil {
-				respCh <- EpWatchResp{opcode: "ERROR", errStr: fmt.Sprintf("read %v", err)}
-				return
-			}
-			if err := json.Unmarshal(line, &weps); err != nil {
-				respCh <- EpWatchResp{opcode: "WARN", errStr: fmt.Sprintf("unmarshal %v", err)}
-				continue
-			}
-
-			if weps.Object.ObjectMeta.Namespace == "kube-system" && (weps.Object.ObjectMeta.Name == "kube-scheduler" || weps.Object.ObjectMeta.Name == "kube-controller-manager") {
-				// Ignoring these frequent updates
-				continue
-			}
-
-			resp := EpWatchResp{opcode: weps.Type}
-			resp.svcName = weps.Object.ObjectMeta.Name
-			resp.providers = make([]string, 0, 1)
-			for _, subset := range weps.Object.Subsets {
-				// TODO: handle partially ready providers
-				for _, addr := range subset.Addresses {
-					resp.providers = append(resp.providers, addr.IP)
-				}
-			}
+	}
 
-			log.Infof("kube ep watch: %v", resp)
-			respCh <- resp
-		}
-	}()
+	c.podCache.labelsMutex.Lock()
+	defer c.podCache.labelsMutex.Unlock()
+	res, found := c.podCache.labels[label]
+	return res, found, nil
 }
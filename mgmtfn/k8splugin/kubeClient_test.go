@@ -29,16 +29,14 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	. "github.com/contiv/check"
+	"github.com/contiv/client-go/pkg/api/v1"
 	"github.com/contiv/netplugin/core"
-	"github.com/contiv/netplugin/netplugin/plugin"
 	"github.com/contiv/netplugin/utils/k8sutils"
 	"github.com/gorilla/mux"
 )
 
 const (
 	testPodURL       = "/api/v1/namespaces/default/pods/test-pod"
-	svcWatchURL      = "/api/v1/watch/services"
-	epWatchURL       = "/api/v1/watch/endpoints"
 	contivKubeCfgDir = "/opt/contiv/config"
 	testCfgFile      = "/tmp/certs/contiv.json"
 	testServerURL    = "0.0.0.0:443"
@@ -46,19 +44,14 @@ const (
 	testTgtPort      = 9364
 	testClusterIP    = "10.254.111.222"
 	tstSvcName       = "LipService"
-	testMaxSvcs      = 3
 	testEPIPAddr1    = "123.45.67.89"
 	testEPIPAddr2    = "123.45.67.88"
 )
 
-var totalSvcResp int
-var totalEPResp int
-var maxSvcResp int
-var maxEPResp int
 var token string
 
 type podStruct struct {
-	ObjectMeta `json:"metadata,omitempty"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
 }
 
 // KubeTestNetDrvConfig represents the configuration of the fakedriver,
@@ -167,6 +160,26 @@ func (d *KubeTestNetDrv) InspectBgp() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
 }
 
+// GetPolicyRuleStats is not implemented
+func (d *KubeTestNetDrv) GetPolicyRuleStats() (map[string]core.PolicyRuleStats, error) {
+	return nil, core.Errorf("Not implemented")
+}
+
+// UpdateNetworkPktTag is not implemented
+func (d *KubeTestNetDrv) UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error {
+	return core.Errorf("Not implemented")
+}
+
+// GetPolicyLogs is not implemented
+func (d *KubeTestNetDrv) GetPolicyLogs() ([]core.PolicyLogRecord, error) {
+	return nil, core.Errorf("Not implemented")
+}
+
+// GetLocalEndpointCount is not implemented
+func (d *KubeTestNetDrv) GetLocalEndpointCount() (int, error) {
+	return 0, core.Errorf("Not implemented")
+}
+
 // GetEndpointStats is not implemented
 func (d *KubeTestNetDrv) GetEndpointStats() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
@@ -197,6 +210,16 @@ func (d *KubeTestNetDrv) DelPolicyRule(id string) error {
 	return core.Errorf("Not implemented")
 }
 
+// AddPortMaps is not implemented
+func (d *KubeTestNetDrv) AddPortMaps(id string, maps []core.PortMapSpec) error {
+	return core.Errorf("Not implemented")
+}
+
+// DelPortMaps is not implemented
+func (d *KubeTestNetDrv) DelPortMaps(id string) error {
+	return core.Errorf("Not implemented")
+}
+
 // AddSvcSpec is implemented.
 func (d *KubeTestNetDrv) AddSvcSpec(svcName string, spec *core.ServiceSpec) error {
 	d.services[svcName] = spec
@@ -275,7 +298,7 @@ func testPodGet(r *http.Request, iter int) (interface{}, bool, error) {
 
 	lMap := make(map[string]string)
 	lMap["io.contiv.network"] = "ut-net"
-	meta := ObjectMeta{Name: "test-pod",
+	meta := v1.ObjectMeta{Name: "test-pod",
 		Namespace: "default",
 		Labels:    lMap,
 	}
@@ -286,105 +309,6 @@ func testPodGet(r *http.Request, iter int) (interface{}, bool, error) {
 
 }
 
-func serviceWatch(r *http.Request, iter int) (interface{}, bool, error) {
-	for totalSvcResp >= maxSvcResp {
-		time.Sleep(time.Second)
-	}
-
-	totalSvcResp++
-
-	sPort := ServicePort{
-		Protocol:   ProtocolTCP,
-		Port:       testSvcPort,
-		TargetPort: testTgtPort,
-	}
-	ports := make([]ServicePort, 1)
-	ports[0] = sPort
-	sSpec := ServiceSpec{
-		ClusterIP: testClusterIP,
-		Ports:     ports,
-	}
-	meta := ObjectMeta{Name: tstSvcName}
-	svc := Service{
-		ObjectMeta: meta,
-		Spec:       sSpec,
-	}
-	typeStr := ""
-	if iter == 1 {
-		typeStr = "DELETED"
-	} else {
-		typeStr = "ADDED"
-	}
-	resp := watchSvcStatus{
-		Type:   typeStr,
-		Object: svc,
-	}
-
-	log.Debugf("### inside service watch req: %+v", r)
-	if iter == testMaxSvcs {
-		return resp, true, nil
-	}
-	time.Sleep(time.Second)
-	return resp, false, nil
-}
-
-func epWatch(r *http.Request, iter int) (interface{}, bool, error) {
-
-	for totalEPResp >= maxEPResp {
-		time.Sleep(time.Second)
-	}
-
-	totalEPResp++
-
-	epPort := EndpointPort{
-		Port:     testTgtPort,
-		Protocol: ProtocolTCP,
-	}
-	ports := make([]EndpointPort, 1)
-	ports[0] = epPort
-
-	epAddr1 := EndpointAddress{
-		IP: testEPIPAddr1,
-	}
-	epAddr2 := EndpointAddress{
-		IP: testEPIPAddr2,
-	}
-	addrs := make([]EndpointAddress, 2)
-	addrs[0] = epAddr1
-	addrs[1] = epAddr2
-	var availAddr []EndpointAddress
-	switch iter {
-	case 0:
-		availAddr = addrs
-	case 1:
-		availAddr = addrs[1:1]
-	case 2:
-		availAddr = addrs[0:0]
-	}
-	epSubset := EndpointSubset{
-		Addresses: availAddr,
-		Ports:     ports,
-	}
-
-	epSS := make([]EndpointSubset, 1)
-	epSS[0] = epSubset
-	meta := ObjectMeta{Name: tstSvcName}
-	eps := Endpoints{
-		ObjectMeta: meta,
-		Subsets:    epSS,
-	}
-	resp := watchSvcEpStatus{
-		Type:   "ADDED",
-		Object: eps,
-	}
-
-	if iter == testMaxSvcs {
-		return resp, true, nil
-	}
-	time.Sleep(time.Second)
-	return resp, false, nil
-}
-
 // setupTestServer creates a listener for the rest requests.
 func setupTestServer(c *C) {
 
@@ -424,8 +348,6 @@ func setupTestServer(c *C) {
 	// register handlers
 	t := router.Headers().Methods("GET").Subrouter()
 	t.HandleFunc(testPodURL, restWrapper(testPodGet))
-	t.HandleFunc(svcWatchURL, restWrapper(serviceWatch))
-	t.HandleFunc(epWatchURL, restWrapper(epWatch))
 
 	server := &http.Server{Addr: testServerURL, Handler: router, TLSConfig: tlsCfg}
 
@@ -511,75 +433,24 @@ func (s *TestKube) SetUpSuite(c *C) {
 	setupTestServer(c)
 }
 
-// TestKubeWatch tests the watch interface
-func (s *TestKube) TestKubeWatch(c *C) {
-	drv := &KubeTestNetDrv{}
-	np := &plugin.NetPlugin{
-		NetworkDriver: drv,
-	}
-	drv.Init(nil)
-	totalSvcResp = 0
-	totalEPResp = 0
-
-	InitKubServiceWatch(np)
-	c.Logf("--ADD--")
-	maxEPResp = 0
-	maxSvcResp = 1
-	for ix := 0; ix < 2; ix++ {
-		time.Sleep(time.Second)
-	}
-	c.Logf("Drv: %+v", drv)
-	if drv.numAddSvc != 1 {
-		c.Errorf("Add service was not seen by client")
-	} else {
-		c.Logf("Add service seen by client, as expected")
-	}
-
-	verifySvc(c, drv)
+// TestPodLabels tests the pod label lookup
+func (s *TestKube) TestPodLabels(c *C) {
+	var cfg k8sutils.ContivConfig
+	c.Assert(k8sutils.GetK8SConfig(&cfg), IsNil)
 
-	if len(drv.providers) != 0 {
-		c.Errorf("Provider list is incorrect")
+	kc := NewAPIClient(cfg.K8sAPIServer, cfg.K8sCa, cfg.K8sKey, cfg.K8sCert, cfg.K8sToken)
+	c.Assert(kc, NotNil)
 
-	}
+	val, found, err := kc.LookupPodLabel("default", "test-pod", "io.contiv.network")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(val, Equals, "ut-net")
 
-	c.Logf("--DEL--")
-	maxEPResp = 0
-	maxSvcResp = 2
-	for ix := 0; ix < 3; ix++ {
-		time.Sleep(time.Second)
-	}
-	c.Logf("Drv: %+v", drv)
-	if drv.numDelSvc != 1 {
-		c.Errorf("Del service was not seen by client")
-	} else {
-		c.Logf("Del service seen by client, as expected")
-	}
-
-	_, ok := drv.services["LipService"]
-	if ok {
-		c.Errorf("Service was not deleted on client")
-	}
+	_, found, err = kc.LookupPodLabel("default", "test-pod", "io.contiv.tenant")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
 
-	c.Logf("--CLOSE--")
-	maxEPResp = 6
-	maxSvcResp = 4
-	for ix := 0; ix < 8; ix++ {
-		time.Sleep(time.Second)
-	}
-	c.Logf("Drv: %+v", drv)
-	c.Logf("services: %+v", drv.services["LipService"])
-	if (drv.numAddSvc != 3) || (drv.numDelSvc != 1) || (drv.numProvUpd != 6) {
-		c.Errorf("All updates were not seen by client")
-	}
-
-	verifySvc(c, drv)
-
-	provs, ok := drv.providers["LipService"]
-	if !ok {
-		c.Errorf("Providers were not updated on client")
-	} else {
-		if len(provs) != 2 {
-			c.Errorf("Providers were not updated correctly on client")
-		}
-	}
+	val, err = kc.GetPodLabel("default", "test-pod", "io.contiv.tenant")
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "default")
 }
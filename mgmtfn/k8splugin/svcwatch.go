@@ -0,0 +1,241 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8splugin
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/client-go/kubernetes"
+	"github.com/contiv/client-go/pkg/api/v1"
+	"github.com/contiv/client-go/pkg/runtime"
+	"github.com/contiv/client-go/pkg/watch"
+	"github.com/contiv/client-go/tools/cache"
+	"github.com/contiv/netplugin/core"
+)
+
+// svcResyncPeriod is how often the informers replay all the services and
+// endpoints to the watcher
+const svcResyncPeriod = 5 * time.Minute
+
+// svcProxy is the service proxy programmed by the watcher
+type svcProxy interface {
+	AddSvcSpec(svcName string, spec *core.ServiceSpec)
+	DelSvcSpec(svcName string, spec *core.ServiceSpec)
+	SvcProviderUpdate(svcName string, providers []string)
+}
+
+// svcWatcher keeps the service proxy in sync with the k8s services and
+// endpoints. Shared informers list and watch both resources, relist them
+// whenever a watch breaks so that deletes missed while disconnected are
+// delivered, and periodically replay their whole cache. Every event is
+// diffed against what was already installed, so replays of unchanged
+// objects do not reprogram the proxy.
+type svcWatcher struct {
+	clientSet     kubernetes.Interface
+	proxy         svcProxy
+	apiServerPort uint16
+	resyncPeriod  time.Duration
+
+	mutex     sync.Mutex
+	services  map[string]*core.ServiceSpec // installed service specs
+	providers map[string][]string          // installed service providers
+}
+
+// newSvcWatcher creates a watcher programming the proxy from the k8s api
+func newSvcWatcher(clientSet kubernetes.Interface, proxy svcProxy, apiServerPort uint16) *svcWatcher {
+	return &svcWatcher{
+		clientSet:     clientSet,
+		proxy:         proxy,
+		apiServerPort: apiServerPort,
+		resyncPeriod:  svcResyncPeriod,
+		services:      make(map[string]*core.ServiceSpec),
+		providers:     make(map[string][]string),
+	}
+}
+
+// run starts the service and endpoints informers until stopCh is closed
+func (w *svcWatcher) run(stopCh <-chan struct{}) {
+	services := w.clientSet.CoreV1().Services(v1.NamespaceAll)
+	svcInformer := cache.NewSharedInformer(&cache.ListWatch{
+		ListFunc: func(opts v1.ListOptions) (runtime.Object, error) {
+			return services.List(opts)
+		},
+		WatchFunc: func(opts v1.ListOptions) (watch.Interface, error) {
+			return services.Watch(opts)
+		},
+	}, &v1.Service{}, w.resyncPeriod)
+	svcInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.updateSvc,
+		UpdateFunc: func(oldObj, newObj interface{}) { w.updateSvc(newObj) },
+		DeleteFunc: w.deleteSvc,
+	})
+
+	endpoints := w.clientSet.CoreV1().Endpoints(v1.NamespaceAll)
+	epInformer := cache.NewSharedInformer(&cache.ListWatch{
+		ListFunc: func(opts v1.ListOptions) (runtime.Object, error) {
+			return endpoints.List(opts)
+		},
+		WatchFunc: func(opts v1.ListOptions) (watch.Interface, error) {
+			return endpoints.Watch(opts)
+		},
+	}, &v1.Endpoints{}, w.resyncPeriod)
+	epInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.updateEps,
+		UpdateFunc: func(oldObj, newObj interface{}) { w.updateEps(newObj) },
+		DeleteFunc: w.deleteEps,
+	})
+
+	go svcInformer.Run(stopCh)
+	go epInformer.Run(stopCh)
+}
+
+// isIgnoredObject filters the objects that are updated too often to matter
+func isIgnoredObject(meta *v1.ObjectMeta) bool {
+	return meta.Namespace == "kube-system" &&
+		(meta.Name == "kube-scheduler" || meta.Name == "kube-controller-manager")
+}
+
+// deletedObject returns the last known state of a deleted object, which
+// the informer wraps when the delete was missed while disconnected
+func deletedObject(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// svcToSpec converts a k8s service to the spec of the service proxy, nil is
+// returned for services that are not proxied
+func (w *svcWatcher) svcToSpec(svc *v1.Service) *core.ServiceSpec {
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == v1.ClusterIPNone {
+		return nil
+	}
+
+	sSpec := &core.ServiceSpec{
+		IPAddress:   svc.Spec.ClusterIP,
+		ExternalIPs: svc.Spec.ExternalIPs,
+		Ports:       make([]core.PortSpec, 0, len(svc.Spec.Ports)),
+	}
+	for _, port := range svc.Spec.Ports {
+		ps := core.PortSpec{Protocol: string(port.Protocol),
+			SvcPort:  uint16(port.Port),
+			NodePort: uint16(port.NodePort),
+		}
+
+		// handle 'kubernetes' service
+		// Use port from configuration till named ports are supported.
+		if svc.Name == "kubernetes" && (len(svc.Namespace) == 0 ||
+			svc.Namespace == "default") {
+			ps.ProvPort = w.apiServerPort
+		} else {
+			ps.ProvPort = uint16(port.TargetPort.IntValue())
+		}
+		sSpec.Ports = append(sSpec.Ports, ps)
+	}
+
+	return sSpec
+}
+
+// epsToProviders returns the sorted provider addresses of service endpoints
+func epsToProviders(eps *v1.Endpoints) []string {
+	providers := []string{}
+	for _, subset := range eps.Subsets {
+		// TODO: handle partially ready providers
+		for _, addr := range subset.Addresses {
+			providers = append(providers, addr.IP)
+		}
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// setService installs or removes a service spec if it differs from the
+// installed one, a nil spec removes the service
+func (w *svcWatcher) setService(svcName string, spec *core.ServiceSpec) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	installed, found := w.services[svcName]
+	switch {
+	case spec == nil && found:
+		log.Infof("service %s deleted", svcName)
+		w.proxy.DelSvcSpec(svcName, installed)
+		delete(w.services, svcName)
+	case spec != nil && (!found || !reflect.DeepEqual(installed, spec)):
+		log.Infof("service %s updated: %+v", svcName, spec)
+		w.proxy.AddSvcSpec(svcName, spec)
+		w.services[svcName] = spec
+	}
+}
+
+// setProviders updates the providers of a service if they differ from the
+// installed ones, nil providers remove the service's providers
+func (w *svcWatcher) setProviders(svcName string, providers []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	installed, found := w.providers[svcName]
+	switch {
+	case providers == nil && found:
+		log.Infof("providers of service %s deleted", svcName)
+		w.proxy.SvcProviderUpdate(svcName, []string{})
+		delete(w.providers, svcName)
+	case providers != nil && (!found || !reflect.DeepEqual(installed, providers)):
+		log.Infof("providers of service %s updated: %v", svcName, providers)
+		w.proxy.SvcProviderUpdate(svcName, providers)
+		w.providers[svcName] = providers
+	}
+}
+
+// updateSvc installs an added or updated service
+func (w *svcWatcher) updateSvc(obj interface{}) {
+	svc, ok := obj.(*v1.Service)
+	if !ok || isIgnoredObject(&svc.ObjectMeta) {
+		return
+	}
+	w.setService(svc.Name, w.svcToSpec(svc))
+}
+
+// deleteSvc removes a deleted service
+func (w *svcWatcher) deleteSvc(obj interface{}) {
+	svc, ok := deletedObject(obj).(*v1.Service)
+	if !ok || isIgnoredObject(&svc.ObjectMeta) {
+		return
+	}
+	w.setService(svc.Name, nil)
+}
+
+// updateEps updates the providers of added or updated service endpoints
+func (w *svcWatcher) updateEps(obj interface{}) {
+	eps, ok := obj.(*v1.Endpoints)
+	if !ok || isIgnoredObject(&eps.ObjectMeta) {
+		return
+	}
+	w.setProviders(eps.Name, epsToProviders(eps))
+}
+
+// deleteEps removes the providers of deleted service endpoints
+func (w *svcWatcher) deleteEps(obj interface{}) {
+	eps, ok := deletedObject(obj).(*v1.Endpoints)
+	if !ok || isIgnoredObject(&eps.ObjectMeta) {
+		return
+	}
+	w.setProviders(eps.Name, nil)
+}
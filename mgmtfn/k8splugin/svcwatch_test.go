@@ -0,0 +1,220 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8splugin
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/contiv/check"
+	"github.com/contiv/client-go/kubernetes/fake"
+	"github.com/contiv/client-go/pkg/api/v1"
+	"github.com/contiv/client-go/pkg/util/intstr"
+	"github.com/contiv/client-go/pkg/watch"
+	clienttesting "github.com/contiv/client-go/testing"
+	"github.com/contiv/netplugin/netplugin/plugin"
+)
+
+// fakeWatches hands out a new fake watcher for each watch of a resource so
+// that tests can push events and break the watch
+type fakeWatches struct {
+	mutex    sync.Mutex
+	watchers map[string]*watch.FakeWatcher
+}
+
+func (f *fakeWatches) reactor(action clienttesting.Action) (bool, watch.Interface, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	watcher := watch.NewFakeWithChanSize(10, false)
+	f.watchers[action.GetResource().Resource] = watcher
+	return true, watcher, nil
+}
+
+// get waits for the current watcher of a resource
+func (f *fakeWatches) get(c *C, resource string) *watch.FakeWatcher {
+	for count := 0; count < 500; count++ {
+		f.mutex.Lock()
+		watcher := f.watchers[resource]
+		f.mutex.Unlock()
+		if watcher != nil {
+			return watcher
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("timed out waiting for the %s watch", resource)
+	return nil
+}
+
+// reset forgets the current watchers so that the next ones can be waited for
+func (f *fakeWatches) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.watchers = make(map[string]*watch.FakeWatcher)
+}
+
+func testService() v1.Service {
+	return v1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: tstSvcName, Namespace: "default"},
+		Spec: v1.ServiceSpec{
+			ClusterIP: testClusterIP,
+			Ports: []v1.ServicePort{{
+				Protocol:   v1.ProtocolTCP,
+				Port:       testSvcPort,
+				TargetPort: intstr.FromInt(testTgtPort),
+			}},
+		},
+	}
+}
+
+func testEndpoints(addrs ...string) v1.Endpoints {
+	subset := v1.EndpointSubset{
+		Ports: []v1.EndpointPort{{Port: testTgtPort, Protocol: v1.ProtocolTCP}},
+	}
+	for _, addr := range addrs {
+		subset.Addresses = append(subset.Addresses, v1.EndpointAddress{IP: addr})
+	}
+	return v1.Endpoints{
+		ObjectMeta: v1.ObjectMeta{Name: tstSvcName, Namespace: "default"},
+		Subsets:    []v1.EndpointSubset{subset},
+	}
+}
+
+type SvcWatchSuite struct {
+	clientSet *fake.Clientset
+	watches   *fakeWatches
+	drv       *KubeTestNetDrv
+	np        *plugin.NetPlugin
+	stopCh    chan struct{}
+}
+
+var _ = Suite(&SvcWatchSuite{})
+
+func (s *SvcWatchSuite) SetUpTest(c *C) {
+	svc := testService()
+	eps := testEndpoints(testEPIPAddr1, testEPIPAddr2)
+	s.clientSet = fake.NewSimpleClientset(&svc, &eps)
+	s.watches = &fakeWatches{watchers: make(map[string]*watch.FakeWatcher)}
+	s.clientSet.PrependWatchReactor("*", s.watches.reactor)
+	s.drv = &KubeTestNetDrv{}
+	s.drv.Init(nil)
+	s.np = &plugin.NetPlugin{NetworkDriver: s.drv}
+	s.stopCh = make(chan struct{})
+}
+
+func (s *SvcWatchSuite) TearDownTest(c *C) {
+	close(s.stopCh)
+}
+
+func (s *SvcWatchSuite) startWatcher(resyncPeriod time.Duration) *svcWatcher {
+	w := newSvcWatcher(s.clientSet, s.np, 6443)
+	w.resyncPeriod = resyncPeriod
+	w.run(s.stopCh)
+	return w
+}
+
+// waitFor polls the driver until the condition holds
+func (s *SvcWatchSuite) waitFor(c *C, msg string, cond func(drv *KubeTestNetDrv) bool) {
+	for count := 0; count < 500; count++ {
+		s.np.Lock()
+		done := cond(s.drv)
+		s.np.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("timed out waiting for %s", msg)
+}
+
+func (s *SvcWatchSuite) TestSvcWatchEvents(c *C) {
+	s.startWatcher(time.Hour)
+
+	s.waitFor(c, "initial list", func(drv *KubeTestNetDrv) bool {
+		return len(drv.services) == 1 && len(drv.providers[tstSvcName]) == 2
+	})
+	s.np.Lock()
+	verifySvc(c, s.drv)
+	s.np.Unlock()
+
+	svcWatcher := s.watches.get(c, "services")
+	epWatcher := s.watches.get(c, "endpoints")
+
+	eps := testEndpoints(testEPIPAddr2)
+	eps.ResourceVersion = "2"
+	epWatcher.Modify(&eps)
+	s.waitFor(c, "provider update", func(drv *KubeTestNetDrv) bool {
+		provs := drv.providers[tstSvcName]
+		return len(provs) == 1 && provs[0] == testEPIPAddr2
+	})
+
+	// an unchanged service is not reinstalled
+	svc := testService()
+	svc.ResourceVersion = "2"
+	svcWatcher.Modify(&svc)
+	svcWatcher.Delete(&svc)
+	s.waitFor(c, "service delete", func(drv *KubeTestNetDrv) bool {
+		return drv.numDelSvc == 1 && len(drv.services) == 0
+	})
+	s.np.Lock()
+	c.Assert(s.drv.numAddSvc, Equals, 1)
+	s.np.Unlock()
+}
+
+func (s *SvcWatchSuite) TestSvcWatchReconnect(c *C) {
+	s.startWatcher(time.Hour)
+
+	s.waitFor(c, "initial list", func(drv *KubeTestNetDrv) bool {
+		return len(drv.services) == 1 && len(drv.providers[tstSvcName]) == 2
+	})
+
+	svcWatcher := s.watches.get(c, "services")
+	epWatcher := s.watches.get(c, "endpoints")
+
+	// the service is deleted while the watches are disconnected
+	services := s.clientSet.CoreV1().Services("default")
+	c.Assert(services.Delete(tstSvcName, nil), IsNil)
+	endpoints := s.clientSet.CoreV1().Endpoints("default")
+	c.Assert(endpoints.Delete(tstSvcName, nil), IsNil)
+	svcWatcher.Stop()
+	epWatcher.Stop()
+
+	s.waitFor(c, "delete after relist", func(drv *KubeTestNetDrv) bool {
+		return drv.numDelSvc == 1 && len(drv.services) == 0 &&
+			len(drv.providers[tstSvcName]) == 0
+	})
+}
+
+func (s *SvcWatchSuite) TestSvcWatchResync(c *C) {
+	w := s.startWatcher(50 * time.Millisecond)
+
+	s.waitFor(c, "initial list", func(drv *KubeTestNetDrv) bool {
+		return len(drv.services) == 1
+	})
+
+	// resync of unchanged objects does not reprogram the proxy
+	time.Sleep(300 * time.Millisecond)
+	s.np.Lock()
+	c.Assert(s.drv.numAddSvc, Equals, 1)
+	s.np.Unlock()
+
+	// a service missing from the installed specs is reinstalled by the resync
+	w.mutex.Lock()
+	delete(w.services, tstSvcName)
+	w.mutex.Unlock()
+	s.waitFor(c, "resync", func(drv *KubeTestNetDrv) bool {
+		return drv.numAddSvc == 2
+	})
+}
@@ -21,11 +21,13 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/mgmtfn/mesosplugin/cniapi"
 	"github.com/contiv/netplugin/netplugin/plugin"
+	"github.com/contiv/netplugin/utils/metrics"
 	"github.com/gorilla/mux"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"time"
 )
 
 type cniServer struct {
@@ -66,6 +68,16 @@ func httpWrapper(handlerFunc httpAPIFunc) http.HandlerFunc {
 	}
 }
 
+// meterEndpointOp records the metrics of an interface add or delete request
+func meterEndpointOp(op string, handlerFunc httpAPIFunc) httpAPIFunc {
+	return func(httpBody []byte) ([]byte, error) {
+		start := time.Now()
+		resp, err := handlerFunc(httpBody)
+		metrics.ObserveEndpointOp("mesos", op, start, err)
+		return resp, err
+	}
+}
+
 func createCniErrorResponse(errmsg error) ([]byte, error) {
 	// CNI_ERROR_UNSUPPORTED is sent for all errors
 	cniResp := cniapi.CniCmdErrorResp{CniVersion: cniapi.CniDefaultVersion,
@@ -196,8 +208,8 @@ func InitPlugin(netPlugin *plugin.NetPlugin) {
 
 	// register handlers for cni plugin
 	subRtr := router.Headers("Content-Type", "application/json").Subrouter()
-	subRtr.HandleFunc(cniapi.MesosNwIntfAdd, httpWrapper(mesosNwIntfAdd)).Methods("POST")
-	subRtr.HandleFunc(cniapi.MesosNwIntfDel, httpWrapper(mesosNwIntfDel)).Methods("POST")
+	subRtr.HandleFunc(cniapi.MesosNwIntfAdd, httpWrapper(meterEndpointOp("create", mesosNwIntfAdd))).Methods("POST")
+	subRtr.HandleFunc(cniapi.MesosNwIntfDel, httpWrapper(meterEndpointOp("delete", mesosNwIntfDel))).Methods("POST")
 	router.HandleFunc("/{*}", unknownReq)
 
 	sockFile := cniapi.ContivMesosSocket
@@ -0,0 +1,546 @@
+package netctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	contivClient "github.com/contiv/contivmodel/client"
+	"github.com/ghodss/yaml"
+)
+
+// apply labels the objects it creates or updates, prune only deletes
+// objects with this label
+const (
+	managedLabel = "managed-by"
+	managedValue = "netctl"
+)
+
+// manifestObject is a contivmodel object of a manifest
+type manifestObject struct {
+	Kind string                 `json:"kind"`
+	Spec map[string]interface{} `json:"spec"`
+}
+
+// manifest is a manifest document, a single object or a list of objects
+type manifest struct {
+	manifestObject
+	Items []manifestObject `json:"items"`
+}
+
+// objectLabels are the labels of a contivmodel object kept by netmaster
+type objectLabels struct {
+	ObjType string            `json:"objType"`
+	ObjKey  string            `json:"objKey"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// applyKind is a kind of contivmodel object apply manages
+type applyKind struct {
+	name      string   // manifest kind and contivmodel type
+	keyFields []string // fields the key of the objects is built from
+	recreate  bool     // objects can't be updated, they are deleted and created again
+	list      func(cl *contivClient.ContivClient) (interface{}, error)
+	post      func(cl *contivClient.ContivClient, data []byte) error
+	del       func(cl *contivClient.ContivClient, fields []string) error
+}
+
+// postDecoded decodes an object and posts it
+func postDecoded(data []byte, obj interface{}, post func() error) error {
+	if err := json.Unmarshal(data, obj); err != nil {
+		return err
+	}
+	return post()
+}
+
+// applyKinds are the kinds apply manages, in dependency order
+var applyKinds = []*applyKind{
+	{
+		name:      "global",
+		keyFields: []string{"name"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.GlobalList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Global{}
+			return postDecoded(data, obj, func() error { return cl.GlobalPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.GlobalDelete(f[0]) },
+	},
+	{
+		name:      "aciGw",
+		keyFields: []string{"name"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.AciGwList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.AciGw{}
+			return postDecoded(data, obj, func() error { return cl.AciGwPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.AciGwDelete(f[0]) },
+	},
+	{
+		name:      "tenant",
+		keyFields: []string{"tenantName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.TenantList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Tenant{}
+			return postDecoded(data, obj, func() error { return cl.TenantPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.TenantDelete(f[0]) },
+	},
+	{
+		name:      "netprofile",
+		keyFields: []string{"tenantName", "profileName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.NetprofileList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Netprofile{}
+			return postDecoded(data, obj, func() error { return cl.NetprofilePost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.NetprofileDelete(f[0], f[1]) },
+	},
+	{
+		name:      "network",
+		keyFields: []string{"tenantName", "networkName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.NetworkList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Network{}
+			return postDecoded(data, obj, func() error { return cl.NetworkPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.NetworkDelete(f[0], f[1]) },
+	},
+	{
+		name:      "policy",
+		keyFields: []string{"tenantName", "policyName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.PolicyList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Policy{}
+			return postDecoded(data, obj, func() error { return cl.PolicyPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.PolicyDelete(f[0], f[1]) },
+	},
+	{
+		name:      "rule",
+		keyFields: []string{"tenantName", "policyName", "ruleId"},
+		recreate:  true,
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.RuleList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Rule{}
+			return postDecoded(data, obj, func() error { return cl.RulePost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.RuleDelete(f[0], f[1], f[2]) },
+	},
+	{
+		name:      "extContractsGroup",
+		keyFields: []string{"tenantName", "contractsGroupName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.ExtContractsGroupList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.ExtContractsGroup{}
+			return postDecoded(data, obj, func() error { return cl.ExtContractsGroupPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.ExtContractsGroupDelete(f[0], f[1]) },
+	},
+	{
+		name:      "endpointGroup",
+		keyFields: []string{"tenantName", "groupName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.EndpointGroupList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.EndpointGroup{}
+			return postDecoded(data, obj, func() error { return cl.EndpointGroupPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.EndpointGroupDelete(f[0], f[1]) },
+	},
+	{
+		name:      "appProfile",
+		keyFields: []string{"tenantName", "appProfileName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.AppProfileList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.AppProfile{}
+			return postDecoded(data, obj, func() error { return cl.AppProfilePost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.AppProfileDelete(f[0], f[1]) },
+	},
+	{
+		name:      "serviceLB",
+		keyFields: []string{"tenantName", "serviceName"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.ServiceLBList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.ServiceLB{}
+			return postDecoded(data, obj, func() error { return cl.ServiceLBPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.ServiceLBDelete(f[0], f[1]) },
+	},
+	{
+		name:      "Bgp",
+		keyFields: []string{"hostname"},
+		list:      func(cl *contivClient.ContivClient) (interface{}, error) { return cl.BgpList() },
+		post: func(cl *contivClient.ContivClient, data []byte) error {
+			obj := &contivClient.Bgp{}
+			return postDecoded(data, obj, func() error { return cl.BgpPost(obj) })
+		},
+		del: func(cl *contivClient.ContivClient, f []string) error { return cl.BgpDelete(f[0]) },
+	},
+}
+
+// findApplyKind returns the kind of a manifest object
+func findApplyKind(name string) *applyKind {
+	for _, kind := range applyKinds {
+		if strings.EqualFold(kind.name, name) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// tenanted returns true if the objects of the kind belong to a tenant
+func (k *applyKind) tenanted() bool {
+	return k.name != "tenant" && k.keyFields[0] == "tenantName"
+}
+
+// keyValues returns the key fields of an object, an error if one is missing
+func (k *applyKind) keyValues(spec map[string]interface{}) ([]string, error) {
+	values := []string{}
+	for _, field := range k.keyFields {
+		value, ok := spec[field].(string)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%s has no %s", k.name, field)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// objectKey returns the contivmodel key of an object
+func (k *applyKind) objectKey(spec map[string]interface{}) (string, error) {
+	values, err := k.keyValues(spec)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(values, ":"), nil
+}
+
+// liveObjects returns the objects of the kind in netmaster by key
+func (k *applyKind) liveObjects(cl *contivClient.ContivClient) (map[string]map[string]interface{}, error) {
+	list, err := k.list(cl)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	objs := []map[string]interface{}{}
+	if err := json.Unmarshal(content, &objs); err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]map[string]interface{})
+	for _, obj := range objs {
+		key, err := k.objectKey(obj)
+		if err != nil {
+			return nil, err
+		}
+		live[key] = obj
+	}
+
+	return live, nil
+}
+
+// manifestFiles returns the manifest files of a path, a directory is not
+// walked recursively
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// readManifests reads the objects of the manifests of a path. A manifest
+// file is YAML or JSON, it may have several YAML documents.
+func readManifests(path string) ([]manifestObject, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := []manifestObject{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		docs := [][]byte{}
+		if filepath.Ext(file) == ".json" {
+			docs = append(docs, content)
+		} else {
+			docs = bytes.Split(content, []byte("\n---"))
+		}
+
+		for _, doc := range docs {
+			jsonDoc, err := yaml.YAMLToJSON(doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			if len(bytes.TrimSpace(jsonDoc)) == 0 || string(bytes.TrimSpace(jsonDoc)) == "null" {
+				continue
+			}
+
+			m := manifest{}
+			if err := json.Unmarshal(jsonDoc, &m); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			if m.Items != nil {
+				objs = append(objs, m.Items...)
+			} else {
+				objs = append(objs, m.manifestObject)
+			}
+		}
+	}
+
+	return objs, nil
+}
+
+// change is a change apply makes to an object
+type change struct {
+	op     string // create, update or delete
+	kind   *applyKind
+	key    string
+	spec   map[string]interface{} // object posted on create and update
+	live   map[string]interface{} // object in netmaster on update and delete
+	fields []string               // fields changed by an update
+}
+
+// sign returns the diff sign of the change
+func (c *change) sign() string {
+	switch c.op {
+	case "create":
+		return "+"
+	case "delete":
+		return "-"
+	}
+	return "~"
+}
+
+// diffManifestObjects returns the changes needed to bring netmaster to the
+// manifests, creates and updates in dependency order followed by deletes in
+// reverse order. With prune the managed objects missing from the manifests
+// are deleted.
+func diffManifestObjects(cl *contivClient.ContivClient, objs []manifestObject,
+	managed map[string]bool, prune bool) ([]*change, error) {
+	desired := make(map[*applyKind]map[string]map[string]interface{})
+	for _, obj := range objs {
+		kind := findApplyKind(obj.Kind)
+		if kind == nil {
+			return nil, fmt.Errorf("unsupported kind %q", obj.Kind)
+		}
+		if obj.Spec == nil {
+			obj.Spec = make(map[string]interface{})
+		}
+		if _, found := obj.Spec["tenantName"]; !found && kind.tenanted() {
+			obj.Spec["tenantName"] = "default"
+		}
+
+		key, err := kind.objectKey(obj.Spec)
+		if err != nil {
+			return nil, err
+		}
+		if desired[kind] == nil {
+			desired[kind] = make(map[string]map[string]interface{})
+		}
+		if _, found := desired[kind][key]; found {
+			return nil, fmt.Errorf("%s %s is in the manifests more than once", kind.name, key)
+		}
+		desired[kind][key] = obj.Spec
+	}
+
+	changes := []*change{}
+	deletes := []*change{}
+	for _, kind := range applyKinds {
+		if len(desired[kind]) == 0 && !prune {
+			continue
+		}
+
+		live, err := kind.liveObjects(cl)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := []string{}
+		for key := range desired[kind] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			spec := desired[kind][key]
+			liveObj, found := live[key]
+			if !found {
+				changes = append(changes, &change{op: "create", kind: kind, key: key, spec: spec})
+				continue
+			}
+
+			// only the fields of the manifest are compared, the others
+			// keep their value
+			fields := []string{}
+			merged := make(map[string]interface{})
+			for field, value := range liveObj {
+				merged[field] = value
+			}
+			for field, value := range spec {
+				if !reflect.DeepEqual(liveObj[field], value) {
+					fields = append(fields, field)
+				}
+				merged[field] = value
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			sort.Strings(fields)
+			delete(merged, "link-sets")
+			delete(merged, "links")
+
+			changes = append(changes, &change{op: "update", kind: kind, key: key,
+				spec: merged, live: liveObj, fields: fields})
+		}
+
+		if !prune {
+			continue
+		}
+
+		keys = []string{}
+		for key := range live {
+			if _, found := desired[kind][key]; !found && managed[kind.name+":"+key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		kindDeletes := []*change{}
+		for _, key := range keys {
+			kindDeletes = append(kindDeletes, &change{op: "delete", kind: kind, key: key, live: live[key]})
+		}
+		deletes = append(kindDeletes, deletes...)
+	}
+
+	return append(changes, deletes...), nil
+}
+
+// managedObjects returns the objects labelled as managed by apply
+func managedObjects(ctx *cli.Context) map[string]bool {
+	labels := []objectLabels{}
+	getObject(ctx, objectLabelsURL(ctx), &labels)
+
+	managed := make(map[string]bool)
+	for _, label := range labels {
+		if label.Labels[managedLabel] == managedValue {
+			managed[label.ObjType+":"+label.ObjKey] = true
+		}
+	}
+	return managed
+}
+
+// manifestChanges reads the manifests of the command and returns the
+// changes they make
+func manifestChanges(ctx *cli.Context) []*change {
+	path := ctx.String("file")
+	if path == "" {
+		errExit(ctx, exitHelp, "Manifest file or directory required", true)
+	}
+
+	objs, err := readManifests(path)
+	errCheck(ctx, err)
+
+	changes, err := diffManifestObjects(getClient(ctx), objs, managedObjects(ctx), ctx.Bool("prune"))
+	errCheck(ctx, err)
+
+	return changes
+}
+
+// printChanges prints the changes in diff format
+func printChanges(changes []*change) {
+	for _, c := range changes {
+		fmt.Printf("%s %s %s\n", c.sign(), c.kind.name, c.key)
+		for _, field := range c.fields {
+			from, _ := json.Marshal(c.live[field])
+			to, _ := json.Marshal(c.spec[field])
+			fmt.Printf("    %s: %s -> %s\n", field, from, to)
+		}
+	}
+}
+
+func diffManifests(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	changes := manifestChanges(ctx)
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	printChanges(changes)
+}
+
+// labelObject sets or removes the managed label of an object
+func labelObject(ctx *cli.Context, c *change) {
+	label := objectLabels{ObjType: c.kind.name, ObjKey: c.key}
+	if c.op != "delete" {
+		label.Labels = map[string]string{managedLabel: managedValue}
+	}
+	postJSON(ctx, objectLabelsURL(ctx), label, &objectLabels{})
+}
+
+func applyManifests(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	changes := manifestChanges(ctx)
+	cl := getClient(ctx)
+
+	for _, c := range changes {
+		fmt.Printf("%s %s %s\n", c.sign(), c.kind.name, c.key)
+
+		if c.op == "delete" || (c.op == "update" && c.kind.recreate) {
+			values, err := c.kind.keyValues(c.live)
+			errCheck(ctx, err)
+			errCheck(ctx, c.kind.del(cl, values))
+		}
+
+		if c.op != "delete" {
+			data, err := json.Marshal(c.spec)
+			errCheck(ctx, err)
+			errCheck(ctx, c.kind.post(cl, data))
+		}
+
+		labelObject(ctx, c)
+	}
+
+	fmt.Printf("%d changes applied\n", len(changes))
+}
@@ -0,0 +1,312 @@
+package netctl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	contivClient "github.com/contiv/contivmodel/client"
+)
+
+// fakeNetmaster serves the object lists of netmaster, objects are indexed
+// by the collection name of their url
+func fakeNetmaster(t *testing.T, objs map[string][]map[string]interface{}) (*httptest.Server, *contivClient.ContivClient) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		list := objs[strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")]
+		if list == nil {
+			list = []map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+
+	cl, err := contivClient.NewContivClient(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatalf("Error creating the client. Err: %v", err)
+	}
+	return server, cl
+}
+
+// changeKeys returns the changes as "op kind key" strings
+func changeKeys(changes []*change) []string {
+	keys := []string{}
+	for _, c := range changes {
+		keys = append(keys, c.op+" "+c.kind.name+" "+c.key)
+	}
+	return keys
+}
+
+func checkChanges(t *testing.T, changes []*change, expected []string) {
+	if keys := changeKeys(changes); !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Unexpected changes. Expected: %v, got: %v", expected, keys)
+	}
+}
+
+func TestDiffManifestCreate(t *testing.T) {
+	server, cl := fakeNetmaster(t, map[string][]map[string]interface{}{
+		"tenants": {{"key": "default", "tenantName": "default"}},
+	})
+	defer server.Close()
+
+	objs := []manifestObject{
+		{Kind: "network", Spec: map[string]interface{}{"networkName": "n1", "subnet": "10.1.1.0/24"}},
+		{Kind: "Tenant", Spec: map[string]interface{}{"tenantName": "default"}},
+		{Kind: "tenant", Spec: map[string]interface{}{"tenantName": "t1"}},
+		{Kind: "endpointGroup", Spec: map[string]interface{}{"tenantName": "t1", "groupName": "g1", "networkName": "n2"}},
+		{Kind: "network", Spec: map[string]interface{}{"tenantName": "t1", "networkName": "n2"}},
+	}
+
+	changes, err := diffManifestObjects(cl, objs, map[string]bool{}, false)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+
+	// the existing tenant is unchanged, the others are created in
+	// dependency order with the default tenant filled in
+	checkChanges(t, changes, []string{
+		"create tenant t1",
+		"create network default:n1",
+		"create network t1:n2",
+		"create endpointGroup t1:g1",
+	})
+	if changes[1].spec["tenantName"] != "default" {
+		t.Fatalf("Default tenant not set on %+v", changes[1].spec)
+	}
+}
+
+func TestDiffManifestClusterKinds(t *testing.T) {
+	server, cl := fakeNetmaster(t, map[string][]map[string]interface{}{
+		"globals": {{"key": "global", "name": "global", "fwdMode": "bridge", "vlans": "1-4094"}},
+		"Bgps":    {{"key": "host1", "hostname": "host1", "as": "65002"}},
+	})
+	defer server.Close()
+
+	objs := []manifestObject{
+		{Kind: "bgp", Spec: map[string]interface{}{"hostname": "host1", "as": "65002"}},
+		{Kind: "serviceLB", Spec: map[string]interface{}{"serviceName": "svc1", "networkName": "n1",
+			"selectors": []interface{}{"app=web"}}},
+		{Kind: "endpointGroup", Spec: map[string]interface{}{"groupName": "g1", "networkName": "n1",
+			"extContractsGrps": []interface{}{"c1"}}},
+		{Kind: "extContractsGroup", Spec: map[string]interface{}{"contractsGroupName": "c1",
+			"contractsType": "provided", "contracts": []interface{}{"uni/tn-common/brc-default"}}},
+		{Kind: "network", Spec: map[string]interface{}{"networkName": "n1", "subnet": "10.1.1.0/24"}},
+		{Kind: "aciGw", Spec: map[string]interface{}{"name": "aciGw", "enforcePolicies": "yes"}},
+		{Kind: "global", Spec: map[string]interface{}{"name": "global", "vlans": "100-200"}},
+	}
+
+	changes, err := diffManifestObjects(cl, objs, map[string]bool{}, false)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+
+	// the cluster settings come first, contracts groups before the groups
+	// using them and services after their network, the unchanged bgp is
+	// left alone
+	checkChanges(t, changes, []string{
+		"update global global",
+		"create aciGw aciGw",
+		"create network default:n1",
+		"create extContractsGroup default:c1",
+		"create endpointGroup default:g1",
+		"create serviceLB default:svc1",
+	})
+	if !reflect.DeepEqual(changes[0].fields, []string{"vlans"}) || changes[0].spec["fwdMode"] != "bridge" {
+		t.Fatalf("Unexpected global update %v: %+v", changes[0].fields, changes[0].spec)
+	}
+	for _, c := range changes[:2] {
+		if _, found := c.spec["tenantName"]; found {
+			t.Fatalf("Tenant set on %s %+v", c.kind.name, c.spec)
+		}
+	}
+	if changes[5].spec["tenantName"] != "default" {
+		t.Fatalf("Default tenant not set on %+v", changes[5].spec)
+	}
+
+	// pruned in reverse order
+	managed := map[string]bool{
+		"global:global":                true,
+		"Bgp:host1":                    true,
+		"extContractsGroup:default:c1": true,
+		"serviceLB:default:svc1":       true,
+		"endpointGroup:default:g1":     true,
+	}
+	server, cl = fakeNetmaster(t, map[string][]map[string]interface{}{
+		"globals":            {{"key": "global", "name": "global", "vlans": "100-200"}},
+		"Bgps":               {{"key": "host1", "hostname": "host1"}},
+		"extContractsGroups": {{"key": "default:c1", "tenantName": "default", "contractsGroupName": "c1"}},
+		"serviceLBs":         {{"key": "default:svc1", "tenantName": "default", "serviceName": "svc1"}},
+		"endpointGroups":     {{"key": "default:g1", "tenantName": "default", "groupName": "g1"}},
+	})
+	defer server.Close()
+
+	changes, err = diffManifestObjects(cl, objs[6:], managed, true)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+	checkChanges(t, changes, []string{
+		"delete Bgp host1",
+		"delete serviceLB default:svc1",
+		"delete endpointGroup default:g1",
+		"delete extContractsGroup default:c1",
+	})
+}
+
+func TestDiffManifestErrors(t *testing.T) {
+	server, cl := fakeNetmaster(t, nil)
+	defer server.Close()
+
+	for _, objs := range [][]manifestObject{
+		{{Kind: "volume", Spec: map[string]interface{}{"volumeName": "v1"}}},
+		{{Kind: "network", Spec: map[string]interface{}{"subnet": "10.1.1.0/24"}}},
+		{
+			{Kind: "tenant", Spec: map[string]interface{}{"tenantName": "t1"}},
+			{Kind: "tenant", Spec: map[string]interface{}{"tenantName": "t1"}},
+		},
+	} {
+		if _, err := diffManifestObjects(cl, objs, map[string]bool{}, false); err == nil {
+			t.Fatalf("Diffing %+v succeeded", objs)
+		}
+	}
+}
+
+func TestDiffManifestUpdate(t *testing.T) {
+	live := map[string]interface{}{
+		"key":         "default:n1",
+		"tenantName":  "default",
+		"networkName": "n1",
+		"encap":       "vxlan",
+		"pktTag":      1001,
+		"subnet":      "10.1.1.0/24",
+		"gateway":     "10.1.1.254",
+		"link-sets":   map[string]interface{}{"endpointGroups": map[string]interface{}{}},
+		"links":       map[string]interface{}{"tenant": map[string]interface{}{"objKey": "default"}},
+	}
+	server, cl := fakeNetmaster(t, map[string][]map[string]interface{}{
+		"networks": {live},
+		"policys":  {{"key": "default:p1", "tenantName": "default", "policyName": "p1"}},
+	})
+	defer server.Close()
+
+	objs := []manifestObject{
+		{Kind: "network", Spec: map[string]interface{}{
+			"networkName": "n1",
+			"pktTag":      float64(1001),
+			"subnet":      "10.1.1.0/24",
+			"gateway":     "10.1.1.1",
+		}},
+		{Kind: "policy", Spec: map[string]interface{}{"policyName": "p1"}},
+	}
+
+	changes, err := diffManifestObjects(cl, objs, map[string]bool{}, false)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+
+	// the unchanged policy is left alone
+	checkChanges(t, changes, []string{"update network default:n1"})
+
+	c := changes[0]
+	if !reflect.DeepEqual(c.fields, []string{"gateway"}) {
+		t.Fatalf("Unexpected changed fields %v", c.fields)
+	}
+	if c.live["gateway"] != "10.1.1.254" || c.spec["gateway"] != "10.1.1.1" {
+		t.Fatalf("Unexpected gateway change %v -> %v", c.live["gateway"], c.spec["gateway"])
+	}
+
+	// the fields missing from the manifest keep their value, the links
+	// aren't posted
+	if c.spec["encap"] != "vxlan" {
+		t.Fatalf("Live field not kept in %+v", c.spec)
+	}
+	for _, field := range []string{"link-sets", "links"} {
+		if _, found := c.spec[field]; found {
+			t.Fatalf("%s posted in %+v", field, c.spec)
+		}
+	}
+}
+
+func TestDiffManifestPrune(t *testing.T) {
+	server, cl := fakeNetmaster(t, map[string][]map[string]interface{}{
+		"tenants": {{"key": "t1", "tenantName": "t1"}},
+		"networks": {
+			{"key": "t1:n1", "tenantName": "t1", "networkName": "n1"},
+			{"key": "t1:n2", "tenantName": "t1", "networkName": "n2"},
+			{"key": "t1:n3", "tenantName": "t1", "networkName": "n3"},
+		},
+	})
+	defer server.Close()
+
+	objs := []manifestObject{
+		{Kind: "tenant", Spec: map[string]interface{}{"tenantName": "t1"}},
+		{Kind: "network", Spec: map[string]interface{}{"tenantName": "t1", "networkName": "n1"}},
+	}
+	// n3 was created outside of apply
+	managed := map[string]bool{
+		"tenant:t1":     true,
+		"network:t1:n1": true,
+		"network:t1:n2": true,
+	}
+
+	changes, err := diffManifestObjects(cl, objs, managed, false)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+	checkChanges(t, changes, []string{})
+
+	changes, err = diffManifestObjects(cl, objs, managed, true)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+	checkChanges(t, changes, []string{"delete network t1:n2"})
+	if changes[0].live["networkName"] != "n2" {
+		t.Fatalf("Live object not set on the delete: %+v", changes[0].live)
+	}
+}
+
+func TestDiffManifestDeleteOrder(t *testing.T) {
+	server, cl := fakeNetmaster(t, map[string][]map[string]interface{}{
+		"tenants":        {{"key": "t1", "tenantName": "t1"}, {"key": "t2", "tenantName": "t2"}},
+		"networks":       {{"key": "t1:n1", "tenantName": "t1", "networkName": "n1"}},
+		"policys":        {{"key": "t1:p1", "tenantName": "t1", "policyName": "p1"}},
+		"rules":          {{"key": "t1:p1:1", "tenantName": "t1", "policyName": "p1", "ruleId": "1"}},
+		"endpointGroups": {{"key": "t1:g1", "tenantName": "t1", "groupName": "g1", "networkName": "n1"}},
+	})
+	defer server.Close()
+
+	objs := []manifestObject{
+		{Kind: "tenant", Spec: map[string]interface{}{"tenantName": "t2"}},
+		{Kind: "network", Spec: map[string]interface{}{"tenantName": "t2", "networkName": "n1"}},
+	}
+	managed := map[string]bool{
+		"tenant:t1":           true,
+		"tenant:t2":           true,
+		"network:t1:n1":       true,
+		"policy:t1:p1":        true,
+		"rule:t1:p1:1":        true,
+		"endpointGroup:t1:g1": true,
+	}
+
+	changes, err := diffManifestObjects(cl, objs, managed, true)
+	if err != nil {
+		t.Fatalf("Error diffing the manifests. Err: %v", err)
+	}
+
+	// creates come first, deletes follow in reverse dependency order so an
+	// object is deleted before the objects it refers to
+	checkChanges(t, changes, []string{
+		"create network t2:n1",
+		"delete endpointGroup t1:g1",
+		"delete rule t1:p1:1",
+		"delete policy t1:p1",
+		"delete network t1:n1",
+		"delete tenant t1",
+	})
+}
@@ -23,6 +23,47 @@ var quietFlag = cli.BoolFlag{
 	Usage: "Only display name field",
 }
 
+var manifestFlag = cli.StringFlag{
+	Name:  "file, f",
+	Usage: "Manifest file or directory of YAML or JSON manifests",
+}
+
+var pruneFlag = cli.BoolFlag{
+	Name:  "prune",
+	Usage: "Delete the objects managed by apply that are missing from the manifests",
+}
+
+var tenantQuotaFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "max-networks",
+		Usage: "Max number of networks in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-groups",
+		Usage: "Max number of endpoint groups in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-endpoints",
+		Usage: "Max number of endpoints in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-policies",
+		Usage: "Max number of policies in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-rules",
+		Usage: "Max number of policy rules in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-services",
+		Usage: "Max number of service LBs in the tenant (0 for no limit)",
+	},
+	cli.IntFlag{
+		Name:  "max-pkt-tags",
+		Usage: "Max number of VLAN/VXLAN tags used by the tenant (0 for no limit)",
+	},
+}
+
 // NetmasterFlags encapsulates the flags required for talking to the netmaster.
 var NetmasterFlags = []cli.Flag{
 	cli.StringFlag{
@@ -81,6 +122,10 @@ var Commands = []cli.Command{
 						Name:  "epg-tag, tag",
 						Usage: "Configured Group Tag",
 					},
+					cli.BoolFlag{
+						Name:  "isolation",
+						Usage: "Deny the traffic between the endpoints of the group",
+					},
 				},
 				Action: createEndpointGroup,
 			},
@@ -243,6 +288,27 @@ var Commands = []cli.Command{
 				},
 				Action: createNetwork,
 			},
+			{
+				Name:      "migrate-tag",
+				Usage:     "Move a network to a new pkt tag, releasing its current one",
+				ArgsUsage: "[network]",
+				Flags: []cli.Flag{
+					tenantFlag,
+					jsonFlag,
+					cli.IntFlag{
+						Name:  "pkt-tag, p",
+						Usage: "New packet tag (Vlan/Vxlan id), any free tag if not set",
+					},
+				},
+				Action: migrateNetworkTag,
+			},
+			{
+				Name:      "migration",
+				Usage:     "Show the pkt tag migration of a network, or of all networks",
+				ArgsUsage: "[network]",
+				Flags:     []cli.Flag{tenantFlag, jsonFlag},
+				Action:    showNetworkMigration,
+			},
 		},
 	},
 	{
@@ -268,13 +334,27 @@ var Commands = []cli.Command{
 				Name:      "create",
 				Usage:     "Create a tenant",
 				ArgsUsage: "[tenant]",
+				Flags:     tenantQuotaFlags,
 				Action:    createTenant,
 			},
+			{
+				Name:      "update",
+				Usage:     "Update the quotas of a tenant",
+				ArgsUsage: "[tenant]",
+				Flags:     tenantQuotaFlags,
+				Action:    updateTenant,
+			},
 			{
 				Name:      "inspect",
 				Usage:     "Inspect a tenant",
 				ArgsUsage: "[tenant]",
-				Action:    inspectTenant,
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "quota",
+						Usage: "Only show the usage of the tenant quotas",
+					},
+				},
+				Action: inspectTenant,
 			},
 		},
 	},
@@ -316,8 +396,13 @@ var Commands = []cli.Command{
 				Name:      "rule-ls",
 				Usage:     "List rules for a given tenant,policy",
 				ArgsUsage: "[policy]",
-				Flags:     []cli.Flag{tenantFlag, jsonFlag, quietFlag},
-				Action:    listRules,
+				Flags: []cli.Flag{tenantFlag, jsonFlag, quietFlag,
+					cli.BoolFlag{
+						Name:  "stats, s",
+						Usage: "Show the rule hit counters",
+					},
+				},
+				Action: listRules,
 			},
 			{
 				Name:      "rule-rm",
@@ -366,6 +451,10 @@ var Commands = []cli.Command{
 						Name:  "to-ip-address, s",
 						Usage: "To IP address/CIDR (Valid in outgoing direction only)",
 					},
+					cli.StringFlag{
+						Name:  "to-fqdn",
+						Usage: "To domain name, *.domain for its subdomains (Valid in outgoing direction only)",
+					},
 					cli.StringFlag{
 						Name:  "protocol, l",
 						Usage: "Protocol (e.g., tcp, udp, icmp)",
@@ -376,12 +465,57 @@ var Commands = []cli.Command{
 					},
 					cli.StringFlag{
 						Name:  "action, j",
-						Usage: "Action to take (allow or deny)",
+						Usage: "Action to take (allow, deny or reject)",
 						Value: "allow",
 					},
+					cli.BoolFlag{
+						Name:  "log",
+						Usage: "Log the packets matching the rule",
+					},
 				},
 				Action: addRule,
 			},
+			{
+				Name:  "check",
+				Usage: "Check if a flow is allowed by the policies",
+				Flags: []cli.Flag{tenantFlag, jsonFlag,
+					cli.StringFlag{
+						Name:  "from, f",
+						Usage: "Source endpoint, endpoint group or IP address",
+					},
+					cli.StringFlag{
+						Name:  "to",
+						Usage: "Destination endpoint, endpoint group or IP address",
+					},
+					cli.StringFlag{
+						Name:  "proto, l",
+						Usage: "Protocol (e.g., tcp, udp, icmp)",
+						Value: "tcp",
+					},
+					cli.IntFlag{
+						Name:  "port, P",
+						Usage: "Destination port",
+					},
+				},
+				Action: checkPolicy,
+			},
+			{
+				Name:      "logs",
+				Usage:     "Show the recent packets logged by policy rules",
+				ArgsUsage: "[policy]",
+				Flags: []cli.Flag{tenantFlag, jsonFlag,
+					cli.StringFlag{
+						Name:  "action, j",
+						Usage: "Only show the packets of rules with the action (allow, deny or reject)",
+					},
+					cli.IntFlag{
+						Name:  "limit, l",
+						Usage: "Max number of packets to show",
+						Value: 50,
+					},
+				},
+				Action: showPolicyLogs,
+			},
 		},
 	},
 	{
@@ -577,6 +711,89 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "node",
+		Usage: "netplugin node inventory and health",
+		Subcommands: []cli.Command{
+			{
+				Name:      "ls",
+				Aliases:   []string{"list"},
+				Usage:     "List nodes",
+				ArgsUsage: " ",
+				Flags:     []cli.Flag{jsonFlag, quietFlag},
+				Action:    listNodes,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Inspect a node",
+				ArgsUsage: "[hostname]",
+				Action:    inspectNode,
+			},
+			{
+				Name:      "cordon",
+				Usage:     "Stop creating endpoints on a node",
+				ArgsUsage: "[hostname]",
+				Flags:     []cli.Flag{jsonFlag},
+				Action:    cordonNode,
+			},
+			{
+				Name:      "uncordon",
+				Usage:     "Resume creating endpoints on a node",
+				ArgsUsage: "[hostname]",
+				Flags:     []cli.Flag{jsonFlag},
+				Action:    uncordonNode,
+			},
+			{
+				Name:      "drain",
+				Usage:     "List the workloads still present on a node",
+				ArgsUsage: "[hostname]",
+				Flags:     []cli.Flag{jsonFlag},
+				Action:    drainNode,
+			},
+			{
+				Name:      "decommission",
+				Usage:     "Remove the endpoints, bgp config and VTEP of a cordoned node",
+				ArgsUsage: "[hostname]",
+				Flags:     []cli.Flag{jsonFlag},
+				Action:    decommissionNode,
+			},
+		},
+	},
+	{
+		Name:      "events",
+		Usage:     "Follow the changes of the endpoints and objects",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			jsonFlag,
+			cli.StringFlag{
+				Name:  "tenant, t",
+				Usage: "Comma separated tenants to follow, all if not set",
+			},
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "Comma separated event types (endpoint, network, group, policy, service, provider), all if not set",
+			},
+			cli.StringFlag{
+				Name:  "cursor, c",
+				Usage: "Resume after the event with this id",
+			},
+		},
+		Action: followEvents,
+	},
+	{
+		Name:      "apply",
+		Usage:     "Create or update the objects of manifests",
+		ArgsUsage: " ",
+		Flags:     []cli.Flag{manifestFlag, pruneFlag},
+		Action:    applyManifests,
+	},
+	{
+		Name:      "diff",
+		Usage:     "Show the changes apply would make",
+		ArgsUsage: " ",
+		Flags:     []cli.Flag{manifestFlag, pruneFlag},
+		Action:    diffManifests,
+	},
 	{
 		Name:  "app-profile",
 		Usage: "Application Profile manipulation tools",
@@ -1,10 +1,12 @@
 package netctl
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/codegangsta/cli"
@@ -26,6 +28,46 @@ func versionURL(ctx *cli.Context) string {
 	return fmt.Sprintf("%s/version", baseURL(ctx))
 }
 
+func policyStatsURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/policystats", baseURL(ctx))
+}
+
+func nodesURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/nodes", baseURL(ctx))
+}
+
+func nodeURL(ctx *cli.Context, hostname string) string {
+	return fmt.Sprintf("%s/node/%s", baseURL(ctx), hostname)
+}
+
+func nodeMaintenanceURL(ctx *cli.Context, hostname, op string) string {
+	return fmt.Sprintf("%s/node/%s/%s", baseURL(ctx), hostname, op)
+}
+
+func pktTagMigrationURL(ctx *cli.Context, netID string) string {
+	return fmt.Sprintf("%s/pkttagmigration/%s", baseURL(ctx), netID)
+}
+
+func pktTagMigrationsURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/pkttagmigrations", baseURL(ctx))
+}
+
+func eventsURL(ctx *cli.Context, query url.Values) string {
+	return fmt.Sprintf("%s/events?%s", baseURL(ctx), query.Encode())
+}
+
+func objectLabelsURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/labels", baseURL(ctx))
+}
+
+func policyCheckURL(ctx *cli.Context, query url.Values) string {
+	return fmt.Sprintf("%s/policycheck?%s", baseURL(ctx), query.Encode())
+}
+
+func policyLogsURL(ctx *cli.Context, query url.Values) string {
+	return fmt.Sprintf("%s/policylogs?%s", baseURL(ctx), query.Encode())
+}
+
 func writeBody(resp *http.Response, ctx *cli.Context) {
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -47,3 +89,24 @@ func getObject(ctx *cli.Context, url string, jdata interface{}) error {
 
 	return nil
 }
+
+func postObject(ctx *cli.Context, url string, jdata interface{}) error {
+	return postJSON(ctx, url, struct{}{}, jdata)
+}
+
+func postJSON(ctx *cli.Context, url string, body, jdata interface{}) error {
+	content, err := json.Marshal(body)
+	handleBasicError(ctx, err)
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(content))
+	handleBasicError(ctx, err)
+
+	respCheck(resp, ctx)
+
+	content, err = ioutil.ReadAll(resp.Body)
+	handleBasicError(ctx, err)
+
+	handleBasicError(ctx, json.Unmarshal(content, jdata))
+
+	return nil
+}
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -166,7 +169,16 @@ func inspectPolicy(ctx *cli.Context) {
 	pol, err := getClient(ctx).PolicyInspect(tenant, policy)
 	errCheck(ctx, err)
 
-	content, err := json.MarshalIndent(pol, "", "  ")
+	polStats := getPolicyStats(ctx)[tenant+":"+policy]
+	if polStats == nil {
+		polStats = &policyStats{}
+	}
+	polInspect := struct {
+		*contivClient.PolicyInspect
+		RuleStats map[string]*ruleStats `json:"ruleStats,omitempty"`
+	}{pol, polStats.Rules}
+
+	content, err := json.MarshalIndent(polInspect, "", "  ")
 	if err != nil {
 		errExit(ctx, exitIO, err.Error(), false)
 	}
@@ -174,6 +186,26 @@ func inspectPolicy(ctx *cli.Context) {
 	os.Stdout.WriteString("\n")
 }
 
+// ruleStats has the cluster wide hit counters of a rule
+type ruleStats struct {
+	Action  string `json:"action"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// policyStats has the cluster wide hit counters of the rules of a policy
+type policyStats struct {
+	Violations uint64                `json:"violations"`
+	Rules      map[string]*ruleStats `json:"rules"`
+}
+
+// getPolicyStats fetches the policy stats of all policies from netmaster
+func getPolicyStats(ctx *cli.Context) map[string]*policyStats {
+	stats := map[string]*policyStats{}
+	getObject(ctx, policyStatsURL(ctx), &stats)
+	return stats
+}
+
 func listPolicies(ctx *cli.Context) {
 	if len(ctx.Args()) != 0 {
 		errExit(ctx, exitHelp, "More arguments than required", true)
@@ -230,6 +262,9 @@ func addRule(ctx *cli.Context) {
 		if ctx.String("to-ip-address") != "" {
 			errExit(ctx, exitHelp, "Cant specify to-ip-address for incoming rule", false)
 		}
+		if ctx.String("to-fqdn") != "" {
+			errExit(ctx, exitHelp, "Cant specify to-fqdn for incoming rule", false)
+		}
 
 		// If from EPG is specified, make sure from network is specified too
 		if ctx.String("from-group") != "" && ctx.String("from-network") != "" {
@@ -250,6 +285,11 @@ func addRule(ctx *cli.Context) {
 		if ctx.String("to-group") != "" && ctx.String("to-network") != "" {
 			errExit(ctx, exitHelp, "Can't specify both -to-group and -to-network", false)
 		}
+
+		if ctx.String("to-fqdn") != "" && (ctx.String("to-group") != "" ||
+			ctx.String("to-network") != "" || ctx.String("to-ip-address") != "") {
+			errExit(ctx, exitHelp, "Can't specify -to-fqdn with -to-group, -to-network or -to-ip-address", false)
+		}
 	} else {
 		errExit(ctx, exitHelp, "Unknown direction", false)
 	}
@@ -266,12 +306,164 @@ func addRule(ctx *cli.Context) {
 		ToNetwork:         ctx.String("to-network"),
 		FromIpAddress:     ctx.String("from-ip-address"),
 		ToIpAddress:       ctx.String("to-ip-address"),
+		ToFqdn:            ctx.String("to-fqdn"),
 		Protocol:          ctx.String("protocol"),
 		Port:              ctx.Int("port"),
 		Action:            ctx.String("action"),
+		Log:               ctx.Bool("log"),
 	}))
 }
 
+// policyCheckEndpoint is a side of a simulated flow
+type policyCheckEndpoint struct {
+	Spec          string `json:"spec"`
+	Endpoint      string `json:"endpoint"`
+	EndpointGroup string `json:"endpointGroup"`
+	IPAddress     string `json:"ipAddress"`
+}
+
+// String describes the resolved endpoint
+func (ep *policyCheckEndpoint) String() string {
+	desc := []string{}
+	if ep.Endpoint != "" {
+		desc = append(desc, "endpoint "+ep.Endpoint)
+	}
+	if ep.EndpointGroup != "" {
+		desc = append(desc, "group "+ep.EndpointGroup)
+	}
+	if ep.IPAddress != "" {
+		desc = append(desc, "ip "+ep.IPAddress)
+	}
+	return fmt.Sprintf("%s (%s)", ep.Spec, strings.Join(desc, ", "))
+}
+
+func checkPolicy(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+	if ctx.String("from") == "" || ctx.String("to") == "" {
+		errExit(ctx, exitHelp, "from and to are required", true)
+	}
+
+	query := url.Values{}
+	query.Set("tenant", ctx.String("tenant"))
+	query.Set("from", ctx.String("from"))
+	query.Set("to", ctx.String("to"))
+	query.Set("proto", ctx.String("proto"))
+	query.Set("port", strconv.Itoa(ctx.Int("port")))
+
+	var result struct {
+		Action        string               `json:"action"`
+		Rule          *contivClient.Rule   `json:"rule"`
+		Direction     string               `json:"direction"`
+		EndpointGroup string               `json:"endpointGroup"`
+		From          *policyCheckEndpoint `json:"from"`
+		To            *policyCheckEndpoint `json:"to"`
+	}
+	getObject(ctx, policyCheckURL(ctx, query), &result)
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, result)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte(fmt.Sprintf("From:\t%s\n", result.From)))
+	writer.Write([]byte(fmt.Sprintf("To:\t%s\n", result.To)))
+	writer.Write([]byte(fmt.Sprintf("Verdict:\t%s\n", result.Action)))
+	if result.Rule == nil {
+		if result.Action != "allow" {
+			writer.Write([]byte(fmt.Sprintf("Rule:\tnone, endpoint group %s is isolated\n", result.EndpointGroup)))
+			return
+		}
+		writer.Write([]byte("Rule:\tnone, allowed by default\n"))
+		return
+	}
+	writer.Write([]byte(fmt.Sprintf("Rule:\t%s (policy %s, priority %d, direction %s)\n",
+		result.Rule.RuleID, result.Rule.PolicyName, result.Rule.Priority, result.Rule.Direction)))
+	writer.Write([]byte(fmt.Sprintf("Attached To:\t%s\n", result.EndpointGroup)))
+}
+
+// policyLog is a packet logged by a policy rule
+type policyLog struct {
+	Time        time.Time `json:"time"`
+	Host        string    `json:"host"`
+	Action      string    `json:"action"`
+	Protocol    uint8     `json:"protocol"`
+	SrcIP       string    `json:"srcIP"`
+	DstIP       string    `json:"dstIP"`
+	SrcPort     uint16    `json:"srcPort"`
+	DstPort     uint16    `json:"dstPort"`
+	SrcEpg      string    `json:"srcEpg"`
+	DstEpg      string    `json:"dstEpg"`
+	SrcEndpoint string    `json:"srcEndpoint"`
+	DstEndpoint string    `json:"dstEndpoint"`
+	TenantName  string    `json:"tenantName"`
+	PolicyName  string    `json:"policyName"`
+	RuleKey     string    `json:"ruleKey"`
+}
+
+// logAddr formats the address of a logged packet
+func logAddr(ip string, port uint16) string {
+	if port == 0 {
+		return ip
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(int(port)))
+}
+
+func showPolicyLogs(ctx *cli.Context) {
+	if len(ctx.Args()) > 1 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	query := url.Values{}
+	query.Set("tenant", ctx.String("tenant"))
+	query.Set("limit", strconv.Itoa(ctx.Int("limit")))
+	if len(ctx.Args()) == 1 {
+		query.Set("policy", ctx.Args()[0])
+	}
+	if action := ctx.String("action"); action != "" {
+		query.Set("action", action)
+	}
+
+	logs := []*policyLog{}
+	getObject(ctx, policyLogsURL(ctx, query), &logs)
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, logs)
+		return
+	}
+
+	protocols := map[uint8]string{1: "icmp", 6: "tcp", 17: "udp"}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte("Time\tHost\tPolicy\tRule\tAction\tProtocol\tFrom\tFrom Endpoint\tFrom Group\tTo\tTo Endpoint\tTo Group\n"))
+	writer.Write([]byte("----\t----\t------\t----\t------\t--------\t----\t-------------\t----------\t--\t-----------\t--------\n"))
+
+	for _, pLog := range logs {
+		protocol, found := protocols[pLog.Protocol]
+		if !found {
+			protocol = strconv.Itoa(int(pLog.Protocol))
+		}
+		writer.Write([]byte(fmt.Sprintf(
+			"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			pLog.Time.Format(time.Stamp),
+			pLog.Host,
+			pLog.PolicyName,
+			pLog.RuleKey,
+			pLog.Action,
+			protocol,
+			logAddr(pLog.SrcIP, pLog.SrcPort),
+			pLog.SrcEndpoint,
+			pLog.SrcEpg,
+			logAddr(pLog.DstIP, pLog.DstPort),
+			pLog.DstEndpoint,
+			pLog.DstEpg,
+		)))
+	}
+}
+
 func deleteRule(ctx *cli.Context) {
 	if len(ctx.Args()) != 2 {
 		errExit(ctx, exitHelp, "Policy name and Rule ID required", true)
@@ -322,13 +514,37 @@ func listRules(ctx *cli.Context) {
 
 	sort.Ints(writePrio)
 
+	var rStats map[string]*ruleStats
+	if ctx.Bool("stats") {
+		if polStats := getPolicyStats(ctx)[tenant+":"+policy]; polStats != nil {
+			rStats = polStats.Rules
+		}
+	}
+	// statsFor returns the counters of a rule, zero if it had no hits
+	statsFor := func(rule *contivClient.Rule) *ruleStats {
+		if stats, found := rStats[rule.Key]; found {
+			return stats
+		}
+		return &ruleStats{Action: rule.Action}
+	}
+
 	for _, prio := range writePrio {
 		for _, rule := range writeRules[prio] {
 			results = append(results, rule)
 		}
 	}
 
-	if ctx.Bool("json") {
+	if ctx.Bool("json") && ctx.Bool("stats") {
+		type ruleWithStats struct {
+			*contivClient.Rule
+			Stats *ruleStats `json:"stats"`
+		}
+		statsResults := []ruleWithStats{}
+		for _, rule := range results {
+			statsResults = append(statsResults, ruleWithStats{rule, statsFor(rule)})
+		}
+		dumpJSONList(ctx, statsResults)
+	} else if ctx.Bool("json") {
 		dumpJSONList(ctx, results)
 	} else if ctx.Bool("quiet") {
 		rules := ""
@@ -337,16 +553,29 @@ func listRules(ctx *cli.Context) {
 		}
 		os.Stdout.WriteString(rules)
 	} else {
+		statsHdr, statsSep := "", ""
+		if ctx.Bool("stats") {
+			statsHdr, statsSep = "\tPackets\tBytes", "\t-------\t-----"
+		}
+		// statsCols returns the counter columns of a rule
+		statsCols := func(rule *contivClient.Rule) string {
+			if !ctx.Bool("stats") {
+				return ""
+			}
+			stats := statsFor(rule)
+			return fmt.Sprintf("\t%v\t%v", stats.Packets, stats.Bytes)
+		}
+
 		writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
 		defer writer.Flush()
 		writer.Write([]byte("Incoming Rules:\n"))
-		writer.Write([]byte("Rule\tPriority\tFrom EndpointGroup\tFrom Network\tFrom IpAddress\tProtocol\tPort\tAction\n"))
-		writer.Write([]byte("----\t--------\t------------------\t------------\t---------\t--------\t----\t------\n"))
+		writer.Write([]byte("Rule\tPriority\tFrom EndpointGroup\tFrom Network\tFrom IpAddress\tProtocol\tPort\tAction" + statsHdr + "\n"))
+		writer.Write([]byte("----\t--------\t------------------\t------------\t---------\t--------\t----\t------" + statsSep + "\n"))
 
 		for _, rule := range results {
 			if rule.Direction == "in" {
 				writer.Write([]byte(fmt.Sprintf(
-					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v%s\n",
 					rule.RuleID,
 					rule.Priority,
 					rule.FromEndpointGroup,
@@ -355,26 +584,29 @@ func listRules(ctx *cli.Context) {
 					rule.Protocol,
 					rule.Port,
 					rule.Action,
+					statsCols(rule),
 				)))
 			}
 		}
 
 		writer.Write([]byte("Outgoing Rules:\n"))
-		writer.Write([]byte("Rule\tPriority\tTo EndpointGroup\tTo Network\tTo IpAddress\tProtocol\tPort\tAction\n"))
-		writer.Write([]byte("----\t--------\t----------------\t----------\t---------\t--------\t----\t------\n"))
+		writer.Write([]byte("Rule\tPriority\tTo EndpointGroup\tTo Network\tTo IpAddress\tTo FQDN\tProtocol\tPort\tAction" + statsHdr + "\n"))
+		writer.Write([]byte("----\t--------\t----------------\t----------\t---------\t-------\t--------\t----\t------" + statsSep + "\n"))
 
 		for _, rule := range results {
 			if rule.Direction == "out" {
 				writer.Write([]byte(fmt.Sprintf(
-					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v%s\n",
 					rule.RuleID,
 					rule.Priority,
 					rule.ToEndpointGroup,
 					rule.ToNetwork,
 					rule.ToIpAddress,
+					rule.ToFqdn,
 					rule.Protocol,
 					rule.Port,
 					rule.Action,
+					statsCols(rule),
 				)))
 			}
 		}
@@ -589,6 +821,198 @@ func inspectNetwork(ctx *cli.Context) {
 	os.Stdout.WriteString("\n")
 }
 
+// streamEvent is a change of an endpoint or object
+type streamEvent struct {
+	ID     string                 `json:"id"`
+	Time   time.Time              `json:"time"`
+	Type   string                 `json:"type"`
+	Action string                 `json:"action"`
+	Tenant string                 `json:"tenant"`
+	Name   string                 `json:"name"`
+	Object map[string]interface{} `json:"object"`
+}
+
+// eventDetails returns the main fields of the object of an event
+func eventDetails(ev *streamEvent) string {
+	fields := map[string][]string{
+		"endpoint": {"ipAddress", "macAddress", "host", "containerName"},
+		"network":  {"pktTagType", "pktTag", "subnet"},
+		"group":    {"network", "pktTag"},
+		"policy":   {"endpointGroup", "rules"},
+		"service":  {"ipAddress", "ports"},
+		"provider": {"providers"},
+	}
+
+	details := []string{}
+	for _, field := range fields[ev.Type] {
+		if value, ok := ev.Object[field]; ok && value != "" {
+			details = append(details, fmt.Sprintf("%s=%v", field, value))
+		}
+	}
+
+	return strings.Join(details, " ")
+}
+
+// followEvents prints the events streamed by netmaster until interrupted
+func followEvents(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	query := url.Values{}
+	if tenant := ctx.String("tenant"); tenant != "" {
+		query.Set("tenant", tenant)
+	}
+	if evType := ctx.String("type"); evType != "" {
+		query.Set("type", evType)
+	}
+	if cursor := ctx.String("cursor"); cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	resp, err := client.Get(eventsURL(ctx, query))
+	handleBasicError(ctx, err)
+	defer resp.Body.Close()
+
+	respCheck(resp, ctx)
+
+	// server-sent events are blank line separated, only the data lines
+	// are needed as the events carry their id and type
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+
+		if ctx.Bool("json") {
+			os.Stdout.WriteString(data + "\n")
+			continue
+		}
+
+		ev := &streamEvent{}
+		if err := json.Unmarshal([]byte(data), ev); err != nil {
+			errExit(ctx, exitIO, err.Error(), false)
+		}
+		if ev.Type == "reset" {
+			fmt.Println("Events were missed since the cursor, resync the objects")
+			continue
+		}
+
+		// lines are printed as they come, the columns are padded
+		fmt.Printf("%s  %-8s  %-6s  %s/%s  %s  (id %s)\n",
+			ev.Time.Format(time.Stamp),
+			ev.Type,
+			ev.Action,
+			ev.Tenant,
+			ev.Name,
+			eventDetails(ev),
+			ev.ID,
+		)
+	}
+	handleBasicError(ctx, scanner.Err())
+}
+
+// pktTagMigration is the progress of moving a network to a new pkt tag
+type pktTagMigration struct {
+	NetworkID    string            `json:"networkID"`
+	PktTagType   string            `json:"pktTagType"`
+	OldPktTag    int               `json:"oldPktTag"`
+	OldExtPktTag int               `json:"oldExtPktTag"`
+	NewPktTag    int               `json:"newPktTag"`
+	NewExtPktTag int               `json:"newExtPktTag"`
+	Status       string            `json:"status"`
+	Error        string            `json:"error"`
+	Hosts        map[string]string `json:"hosts"`
+	StartTime    time.Time         `json:"startTime"`
+}
+
+// pktTagString formats the pkt tags of a network
+func pktTagString(pktTagType string, pktTag, extPktTag int) string {
+	if pktTagType == "vxlan" {
+		return fmt.Sprintf("vxlan %d (vlan %d)", extPktTag, pktTag)
+	}
+	return fmt.Sprintf("vlan %d", pktTag)
+}
+
+// showPktTagMigrations prints the migrations and, for a single one, the
+// progress of its nodes
+func showPktTagMigrations(ctx *cli.Context, migrations []*pktTagMigration) {
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, migrations)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte("Network\tFrom\tTo\tStatus\tStarted\tError\n"))
+	writer.Write([]byte("-------\t----\t--\t------\t-------\t-----\n"))
+	for _, mig := range migrations {
+		writer.Write(
+			[]byte(fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v\n",
+				mig.NetworkID,
+				pktTagString(mig.PktTagType, mig.OldPktTag, mig.OldExtPktTag),
+				pktTagString(mig.PktTagType, mig.NewPktTag, mig.NewExtPktTag),
+				mig.Status,
+				mig.StartTime.Format(time.RFC3339),
+				mig.Error,
+			)))
+	}
+
+	if len(migrations) != 1 || len(migrations[0].Hosts) == 0 {
+		return
+	}
+
+	hosts := []string{}
+	for host := range migrations[0].Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	writer.Write([]byte("\nNode\tProgress\n"))
+	writer.Write([]byte("----\t--------\n"))
+	for _, host := range hosts {
+		writer.Write([]byte(fmt.Sprintf("%v\t%v\n", host, migrations[0].Hosts[host])))
+	}
+}
+
+func migrateNetworkTag(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Network name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	network := ctx.Args()[0]
+
+	migReq := map[string]interface{}{"pktTag": ctx.Int("pkt-tag")}
+	mig := &pktTagMigration{}
+	postJSON(ctx, pktTagMigrationURL(ctx, network+"."+tenant), migReq, mig)
+
+	showPktTagMigrations(ctx, []*pktTagMigration{mig})
+}
+
+func showNetworkMigration(ctx *cli.Context) {
+	if len(ctx.Args()) > 1 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	if len(ctx.Args()) == 0 {
+		migrations := []*pktTagMigration{}
+		getObject(ctx, pktTagMigrationsURL(ctx), &migrations)
+		showPktTagMigrations(ctx, migrations)
+		return
+	}
+
+	tenant := ctx.String("tenant")
+	network := ctx.Args()[0]
+
+	mig := &pktTagMigration{}
+	getObject(ctx, pktTagMigrationURL(ctx, network+"."+tenant), mig)
+
+	showPktTagMigrations(ctx, []*pktTagMigration{mig})
+}
+
 func listNetworks(ctx *cli.Context) {
 	if len(ctx.Args()) != 0 {
 		errExit(ctx, exitHelp, "More arguments than required", true)
@@ -653,12 +1077,50 @@ func createTenant(ctx *cli.Context) {
 	tenant := ctx.Args()[0]
 
 	errCheck(ctx, getClient(ctx).TenantPost(&contivClient.Tenant{
-		TenantName: tenant,
+		TenantName:        tenant,
+		MaxNetworks:       ctx.Int("max-networks"),
+		MaxEndpointGroups: ctx.Int("max-groups"),
+		MaxEndpoints:      ctx.Int("max-endpoints"),
+		MaxPolicies:       ctx.Int("max-policies"),
+		MaxRules:          ctx.Int("max-rules"),
+		MaxServiceLBs:     ctx.Int("max-services"),
+		MaxPktTags:        ctx.Int("max-pkt-tags"),
 	}))
 
 	fmt.Printf("Creating tenant: %s\n", tenant)
 }
 
+func updateTenant(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Tenant name required", true)
+	}
+
+	tenantName := ctx.Args()[0]
+
+	tenant, err := getClient(ctx).TenantGet(tenantName)
+	errCheck(ctx, err)
+
+	// only change the quotas given on the command line
+	quotas := map[string]*int{
+		"max-networks":  &tenant.MaxNetworks,
+		"max-groups":    &tenant.MaxEndpointGroups,
+		"max-endpoints": &tenant.MaxEndpoints,
+		"max-policies":  &tenant.MaxPolicies,
+		"max-rules":     &tenant.MaxRules,
+		"max-services":  &tenant.MaxServiceLBs,
+		"max-pkt-tags":  &tenant.MaxPktTags,
+	}
+	for flag, quota := range quotas {
+		if ctx.IsSet(flag) {
+			*quota = ctx.Int(flag)
+		}
+	}
+
+	errCheck(ctx, getClient(ctx).TenantPost(tenant))
+
+	fmt.Printf("Updating tenant: %s\n", tenantName)
+}
+
 func deleteTenant(ctx *cli.Context) {
 	if len(ctx.Args()) != 1 {
 		errExit(ctx, exitHelp, "Tenant name required", true)
@@ -681,11 +1143,51 @@ func inspectTenant(ctx *cli.Context) {
 	ten, err := getClient(ctx).TenantInspect(tenant)
 	errCheck(ctx, err)
 
+	if ctx.Bool("quota") {
+		showTenantQuotas(ten)
+		return
+	}
+
 	content, err := json.MarshalIndent(ten, "", "  ")
 	os.Stdout.Write(content)
 	os.Stdout.WriteString("\n")
 }
 
+// showTenantQuotas prints the usage of the tenant quotas
+func showTenantQuotas(ten *contivClient.TenantInspect) {
+	quotas := []struct {
+		name  string
+		usage int
+		max   int
+	}{
+		{"networks", ten.Oper.TotalNetworks, ten.Config.MaxNetworks},
+		{"endpoint groups", ten.Oper.TotalEPGs, ten.Config.MaxEndpointGroups},
+		{"endpoints", ten.Oper.TotalEndpoints, ten.Config.MaxEndpoints},
+		{"policies", ten.Oper.TotalPolicies, ten.Config.MaxPolicies},
+		{"rules", ten.Oper.TotalRules, ten.Config.MaxRules},
+		{"service LBs", ten.Oper.TotalServicelbs, ten.Config.MaxServiceLBs},
+		{"pkt tags", ten.Oper.TotalPktTags, ten.Config.MaxPktTags},
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte("Resource\tUsed\tQuota\t\n"))
+	writer.Write([]byte("--------\t----\t-----\t\n"))
+
+	for _, quota := range quotas {
+		max := "unlimited"
+		if quota.max > 0 {
+			max = strconv.Itoa(quota.max)
+		}
+		writer.Write(
+			[]byte(fmt.Sprintf("%v\t%v\t%v\t\n",
+				quota.name,
+				quota.usage,
+				max,
+			)))
+	}
+}
+
 func listTenants(ctx *cli.Context) {
 	if len(ctx.Args()) != 0 {
 		errExit(ctx, exitHelp, "More arguments than required", true)
@@ -758,6 +1260,7 @@ func createEndpointGroup(ctx *cli.Context) {
 		Policies:         policies,
 		ExtContractsGrps: extContractsGrps,
 		CfgdTag:          epgTag,
+		Isolation:        ctx.Bool("isolation"),
 	}))
 
 	fmt.Printf("Creating EndpointGroup %s:%s\n", tenant, group)
@@ -949,6 +1452,184 @@ func inspectBgp(ctx *cli.Context) {
 	os.Stdout.WriteString("\n")
 }
 
+// nodeInfo has the inventory and health of a netplugin node
+type nodeInfo struct {
+	Hostname      string    `json:"hostname"`
+	CtrlIP        string    `json:"ctrlIP"`
+	VtepIP        string    `json:"vtepIP"`
+	Version       string    `json:"version"`
+	FwdMode       string    `json:"fwdMode"`
+	PluginMode    string    `json:"pluginMode"`
+	Uplinks       []string  `json:"uplinks"`
+	NumEndpoints  int       `json:"numEndpoints"`
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+	Health        string    `json:"health"`
+	HeartbeatAge  string    `json:"heartbeatAge"`
+	VersionSkew   bool      `json:"versionSkew"`
+	Cordoned      bool      `json:"cordoned"`
+}
+
+// nodeStatus returns the health of a node with its maintenance mode
+func nodeStatus(node *nodeInfo) string {
+	if node.Cordoned {
+		return node.Health + ",cordoned"
+	}
+	return node.Health
+}
+
+// warnVersionSkew prints a warning for the nodes running a version
+// different from netmaster
+func warnVersionSkew(ctx *cli.Context, nodes []*nodeInfo) {
+	ver := version.Info{}
+	getObject(ctx, versionURL(ctx), &ver)
+	for _, node := range nodes {
+		if node.VersionSkew {
+			fmt.Fprintf(os.Stderr, "WARNING: node %s runs version %q, netmaster runs version %q\n",
+				node.Hostname, node.Version, ver.Version)
+		}
+	}
+}
+
+func listNodes(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	nodes := []*nodeInfo{}
+	getObject(ctx, nodesURL(ctx), &nodes)
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, nodes)
+		return
+	} else if ctx.Bool("quiet") {
+		names := ""
+		for _, node := range nodes {
+			names += node.Hostname + "\n"
+		}
+		os.Stdout.WriteString(names)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	writer.Write([]byte("Hostname\tCtrlIP\tVersion\tFwdMode\tUplinks\tEndpoints\tHeartbeat\tHealth\n"))
+	writer.Write([]byte("--------\t------\t-------\t-------\t-------\t---------\t---------\t------\n"))
+	for _, node := range nodes {
+		writer.Write(
+			[]byte(fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+				node.Hostname,
+				node.CtrlIP,
+				node.Version,
+				node.FwdMode,
+				strings.Join(node.Uplinks, ","),
+				node.NumEndpoints,
+				node.HeartbeatAge,
+				nodeStatus(node),
+			)))
+	}
+	writer.Flush()
+
+	warnVersionSkew(ctx, nodes)
+}
+
+func inspectNode(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Host name required", true)
+	}
+
+	hostname := ctx.Args()[0]
+
+	node := &nodeInfo{}
+	getObject(ctx, nodeURL(ctx, hostname), node)
+
+	content, err := json.MarshalIndent(node, "", "  ")
+	errCheck(ctx, err)
+	os.Stdout.Write(content)
+	os.Stdout.WriteString("\n")
+
+	warnVersionSkew(ctx, []*nodeInfo{node})
+}
+
+// nodeMaintenance has the maintenance mode of a node and its workloads
+type nodeMaintenance struct {
+	Hostname       string `json:"hostname"`
+	Cordoned       bool   `json:"cordoned"`
+	Decommissioned bool   `json:"decommissioned"`
+	Workloads      []struct {
+		EndpointID    string `json:"endpointID"`
+		ContainerID   string `json:"containerID"`
+		Network       string `json:"network"`
+		EndpointGroup string `json:"endpointGroup"`
+		IPAddress     string `json:"ipAddress"`
+	} `json:"workloads"`
+}
+
+// nodeMaintenanceAction runs a maintenance operation on a node and prints
+// the workloads left on it
+func nodeMaintenanceAction(ctx *cli.Context, op string, post bool) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Host name required", true)
+	}
+
+	hostname := ctx.Args()[0]
+
+	maint := &nodeMaintenance{}
+	if post {
+		postObject(ctx, nodeMaintenanceURL(ctx, hostname, op), maint)
+	} else {
+		getObject(ctx, nodeMaintenanceURL(ctx, hostname, op), maint)
+	}
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, maint)
+		return
+	}
+
+	switch {
+	case maint.Decommissioned:
+		fmt.Printf("Node %s is decommissioned\n", maint.Hostname)
+	case maint.Cordoned:
+		fmt.Printf("Node %s is cordoned\n", maint.Hostname)
+	default:
+		fmt.Printf("Node %s is schedulable\n", maint.Hostname)
+	}
+
+	if len(maint.Workloads) == 0 {
+		fmt.Printf("No workloads left on node %s\n", maint.Hostname)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte("Endpoint\tContainer\tNetwork\tGroup\tIP\n"))
+	writer.Write([]byte("--------\t---------\t-------\t-----\t--\n"))
+	for _, wl := range maint.Workloads {
+		writer.Write(
+			[]byte(fmt.Sprintf("%v\t%v\t%v\t%v\t%v\n",
+				wl.EndpointID,
+				wl.ContainerID,
+				wl.Network,
+				wl.EndpointGroup,
+				wl.IPAddress,
+			)))
+	}
+}
+
+func cordonNode(ctx *cli.Context) {
+	nodeMaintenanceAction(ctx, "cordon", true)
+}
+
+func uncordonNode(ctx *cli.Context) {
+	nodeMaintenanceAction(ctx, "uncordon", true)
+}
+
+func drainNode(ctx *cli.Context) {
+	nodeMaintenanceAction(ctx, "drain", false)
+}
+
+func decommissionNode(ctx *cli.Context) {
+	nodeMaintenanceAction(ctx, "decommission", true)
+}
+
 func showGlobal(ctx *cli.Context) {
 	if len(ctx.Args()) != 0 {
 		errExit(ctx, exitHelp, "More arguments than required", true)
@@ -0,0 +1,255 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backup exports the configuration of a cluster, the contivmodel
+// objects and the netmaster state, to an archive and restores it into an
+// empty state store.
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/netmaster/resources"
+	"github.com/contiv/netplugin/netmaster/statecheck"
+	"github.com/contiv/netplugin/version"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ArchiveVersion is the format version of the archives written by Backup,
+// Restore refuses newer archives
+const ArchiveVersion = 1
+
+// contivmodel objects are stored by objdb under this path
+const modelPath = mastercfg.StateBasePath + "obj/modeldb/"
+
+// Archive is a snapshot of the configuration of a cluster
+type Archive struct {
+	Version          int       `json:"version"`
+	NetpluginVersion string    `json:"netpluginVersion"`
+	Time             time.Time `json:"time"`
+	Objects          []*Object `json:"objects"` // in restore order
+}
+
+// Object is a key of the state store with its value
+type Object struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// objKind is a kind of object in the state store
+type objKind struct {
+	path    string // directory of the objects, key of single objects
+	idField string // field the key is built from, empty for single objects
+	runtime bool   // endpoint state
+}
+
+// objKinds lists the objects saved by Backup, in the order they are
+// restored: the global settings and resources first, then the model
+// objects before the objects depending on them, then the state
+var objKinds = []objKind{
+	// global settings and resource pools
+	{path: mastercfg.StateBasePath + "master/config/global"},
+	{path: mastercfg.StateConfigPath + "global/global"},
+	{path: mastercfg.StateOperPath + "global/global"},
+	{path: mastercfg.StateConfigPath + resources.AutoVLANResource + "/", idField: "id"},
+	{path: mastercfg.StateOperPath + resources.AutoVLANResource + "/", idField: "id"},
+	{path: mastercfg.StateConfigPath + resources.AutoVXLANResource + "/", idField: "id"},
+	{path: mastercfg.StateOperPath + resources.AutoVXLANResource + "/", idField: "id"},
+
+	// contivmodel objects
+	{path: modelPath + "global/", idField: "key"},
+	{path: modelPath + "aciGw/", idField: "key"},
+	{path: modelPath + "tenant/", idField: "key"},
+	{path: modelPath + "netprofile/", idField: "key"},
+	{path: modelPath + "network/", idField: "key"},
+	{path: modelPath + "policy/", idField: "key"},
+	{path: modelPath + "rule/", idField: "key"},
+	{path: modelPath + "extContractsGroup/", idField: "key"},
+	{path: modelPath + "endpointGroup/", idField: "key"},
+	{path: modelPath + "appProfile/", idField: "key"},
+	{path: modelPath + "serviceLB/", idField: "key"},
+	{path: modelPath + "Bgp/", idField: "key"},
+
+	// netmaster state
+	{path: mastercfg.StateConfigPath + "nets/", idField: "id"},
+	{path: mastercfg.StateOperPath + "pktTagMigrations/", idField: "id"},
+	{path: mastercfg.StateOperPath + "pktTagHosts/", idField: "id"},
+	{path: mastercfg.StateOperPath + "docknet/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "endpointGroups/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "policy/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "policyRule/", idField: "RuleId"},
+	{path: mastercfg.StateConfigPath + "serviceLB/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "provider/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "bgp/", idField: "hostname"},
+	{path: mastercfg.StateConfigPath + "nodeMaintenance/", idField: "id"},
+	{path: mastercfg.StateConfigPath + "objectLabels/", idField: "id"},
+
+	// runtime endpoint state
+	{path: mastercfg.StateConfigPath + "eps/", idField: "id", runtime: true},
+	{path: mastercfg.StateOperPath + "eps/", idField: "id", runtime: true},
+}
+
+// match returns true if key is an object of the kind
+func (k *objKind) match(key string) bool {
+	if k.idField == "" {
+		return key == k.path
+	}
+	return strings.HasPrefix(key, k.path) && !strings.Contains(key[len(k.path):], "/")
+}
+
+// read returns the objects of the kind sorted by key
+func (k *objKind) read(stateDriver core.StateDriver) ([]*Object, error) {
+	if k.idField == "" {
+		value, err := stateDriver.Read(k.path)
+		if err != nil {
+			if core.ErrIfKeyExists(err) == nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []*Object{{Key: k.path, Value: json.RawMessage(value)}}, nil
+	}
+
+	values, err := stateDriver.ReadAll(k.path)
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	objs := make(map[string]*Object)
+	keys := []string{}
+	for _, value := range values {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(value, &fields); err != nil {
+			log.Errorf("Error parsing object under %s. Err: %v", k.path, err)
+			return nil, err
+		}
+		id, ok := fields[k.idField].(string)
+		if !ok || id == "" {
+			return nil, core.Errorf("object under %s has no %s", k.path, k.idField)
+		}
+
+		key := k.path + id
+		if _, found := objs[key]; !found {
+			keys = append(keys, key)
+		}
+		objs[key] = &Object{Key: key, Value: json.RawMessage(value)}
+	}
+	sort.Strings(keys)
+
+	kindObjs := []*Object{}
+	for _, key := range keys {
+		kindObjs = append(kindObjs, objs[key])
+	}
+
+	return kindObjs, nil
+}
+
+// Backup returns an archive of the configuration in the state store,
+// including the runtime endpoint state
+func Backup(stateDriver core.StateDriver) (*Archive, error) {
+	archive := &Archive{
+		Version:          ArchiveVersion,
+		NetpluginVersion: version.Get().Version,
+		Time:             time.Now(),
+		Objects:          []*Object{},
+	}
+
+	for i := range objKinds {
+		objs, err := objKinds[i].read(stateDriver)
+		if err != nil {
+			return nil, err
+		}
+		archive.Objects = append(archive.Objects, objs...)
+	}
+
+	log.Infof("Backed up %d objects", len(archive.Objects))
+
+	return archive, nil
+}
+
+// Restore writes the objects of an archive into an empty state store, in
+// dependency order. Without endpoints the endpoint state is skipped and the
+// addresses and endpoint counts of the networks and groups are released.
+func Restore(stateDriver core.StateDriver, archive *Archive, endpoints bool) error {
+	if archive.Version > ArchiveVersion {
+		return core.Errorf("archive version %d is not supported, the latest is %d",
+			archive.Version, ArchiveVersion)
+	}
+
+	// every key of the archive must be known before anything is written
+	kindObjs := make([][]*Object, len(objKinds))
+	for _, obj := range archive.Objects {
+		found := false
+		for i := range objKinds {
+			if objKinds[i].match(obj.Key) {
+				kindObjs[i] = append(kindObjs[i], obj)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return core.Errorf("unknown object %s in the archive", obj.Key)
+		}
+	}
+
+	for i := range objKinds {
+		objs, err := objKinds[i].read(stateDriver)
+		if err != nil {
+			return err
+		}
+		if len(objs) != 0 {
+			return core.Errorf("state store is not empty, %s exists", objs[0].Key)
+		}
+	}
+
+	count := 0
+	for i, objs := range kindObjs {
+		if objKinds[i].runtime && !endpoints {
+			continue
+		}
+		for _, obj := range objs {
+			// archives may be reformatted, the values are stored compact
+			value := &bytes.Buffer{}
+			if err := json.Compact(value, obj.Value); err != nil {
+				log.Errorf("Error parsing %s in the archive. Err: %v", obj.Key, err)
+				return err
+			}
+			if err := stateDriver.Write(obj.Key, value.Bytes()); err != nil {
+				log.Errorf("Error restoring %s. Err: %v", obj.Key, err)
+				return err
+			}
+			count++
+		}
+	}
+
+	log.Infof("Restored %d objects from the backup of %s", count, archive.Time)
+
+	if !endpoints {
+		// release what the skipped endpoints held
+		if _, err := statecheck.Run(stateDriver, nil, true); err != nil {
+			log.Errorf("Error releasing the endpoint resources. Err: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
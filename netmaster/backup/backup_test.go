@@ -0,0 +1,231 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/netplugin/utils/netutils"
+)
+
+// populateStore writes a tenant with a network, a group and an endpoint, the
+// network is moving to a new pkt tag
+func populateStore(t *testing.T, stateDriver core.StateDriver) {
+	modelObjs := map[string]interface{}{
+		"tenant/default": &contivModel.Tenant{Key: "default", TenantName: "default"},
+		"network/default:net1": &contivModel.Network{Key: "default:net1", TenantName: "default",
+			NetworkName: "net1", Encap: "vlan", Subnet: "10.1.1.0/24"},
+		"endpointGroup/default:epg1": &contivModel.EndpointGroup{Key: "default:epg1",
+			TenantName: "default", NetworkName: "net1", GroupName: "epg1"},
+	}
+	for key, obj := range modelObjs {
+		value, _ := json.Marshal(obj)
+		if err := stateDriver.Write(modelPath+key, value); err != nil {
+			t.Fatalf("error writing model object %s: %v", key, err)
+		}
+	}
+
+	nwCfg := &mastercfg.CfgNetworkState{
+		Tenant:      "default",
+		NetworkName: "net1",
+		PktTagType:  "vlan",
+		PktTag:      10,
+		SubnetIP:    "10.1.1.0",
+		SubnetLen:   24,
+		IPAddrRange: "10.1.1.0-10.1.1.255",
+		EpAddrCount: 1,
+		EpCount:     1,
+	}
+	nwCfg.ID = "net1.default"
+	nwCfg.StateDriver = stateDriver
+	netutils.InitSubnetBitset(&nwCfg.IPAllocMap, nwCfg.SubnetLen)
+	nwCfg.IPAllocMap.Set(1)
+
+	epgCfg := &mastercfg.EndpointGroupState{
+		GroupName:   "epg1",
+		TenantName:  "default",
+		NetworkName: "net1",
+		PktTagType:  "vlan",
+		PktTag:      10,
+		EpCount:     1,
+	}
+	epgCfg.ID = mastercfg.GetEndpointGroupKey("epg1", "default")
+	epgCfg.StateDriver = stateDriver
+
+	epCfg := &mastercfg.CfgEndpointState{
+		NetID:            "net1.default",
+		EndpointGroupKey: epgCfg.ID,
+		IPAddress:        "10.1.1.1",
+		HomingHost:       "host1",
+	}
+	epCfg.ID = "net1.default-ep1"
+	epCfg.StateDriver = stateDriver
+
+	bgpCfg := &mastercfg.CfgBgpState{Hostname: "host1", RouterIP: "50.1.1.1/24"}
+	bgpCfg.StateDriver = stateDriver
+
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.RuleId = "rule1"
+	ruleCfg.ID = "rule1"
+	ruleCfg.StateDriver = stateDriver
+
+	migCfg := &mastercfg.PktTagMigrationState{
+		NetworkID:  "net1.default",
+		PktTagType: "vlan",
+		OldPktTag:  5,
+		NewPktTag:  10,
+		Status:     mastercfg.PktTagMigrating,
+		Hosts:      map[string]string{"host1": mastercfg.PktTagHostPending},
+	}
+	migCfg.ID = "net1.default"
+	migCfg.StateDriver = stateDriver
+
+	hostState := &mastercfg.PktTagHostState{NetworkID: "net1.default", Hostname: "host1", PktTag: 5}
+	hostState.ID = mastercfg.PktTagHostStateID("net1.default", "host1")
+	hostState.StateDriver = stateDriver
+
+	for _, s := range []core.State{nwCfg, epgCfg, epCfg, bgpCfg, ruleCfg, migCfg, hostState} {
+		if err := s.Write(); err != nil {
+			t.Fatalf("error writing state %+v: %v", s, err)
+		}
+	}
+
+	if err := stateDriver.Write(mastercfg.StateOperPath+"eps/net1.default-ep1",
+		[]byte(`{"id":"net1.default-ep1"}`)); err != nil {
+		t.Fatalf("error writing endpoint oper state: %v", err)
+	}
+}
+
+// roundTrip backs up a store and restores it into a new one
+func roundTrip(t *testing.T, srcDriver, dstDriver core.StateDriver, endpoints bool) *Archive {
+	archive, err := Backup(srcDriver)
+	if err != nil {
+		t.Fatalf("error backing up: %v", err)
+	}
+
+	// go through the archive format
+	content, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		t.Fatalf("error encoding archive: %v", err)
+	}
+	restored := &Archive{}
+	if err := json.Unmarshal(content, restored); err != nil {
+		t.Fatalf("error decoding archive: %v", err)
+	}
+
+	if err := Restore(dstDriver, restored, endpoints); err != nil {
+		t.Fatalf("error restoring: %v", err)
+	}
+
+	return archive
+}
+
+func TestBackupRestore(t *testing.T) {
+	srcDriver := &state.FakeStateDriver{}
+	srcDriver.Init(nil)
+	defer srcDriver.Deinit()
+	populateStore(t, srcDriver)
+
+	dstDriver := &state.FakeStateDriver{}
+	dstDriver.Init(nil)
+	defer dstDriver.Deinit()
+
+	archive := roundTrip(t, srcDriver, dstDriver, true)
+	if len(archive.Objects) != len(srcDriver.TestState) {
+		t.Fatalf("archive has %d objects, the store has %d", len(archive.Objects),
+			len(srcDriver.TestState))
+	}
+	if len(dstDriver.TestState) != len(srcDriver.TestState) {
+		t.Fatalf("restored %d objects, expected %d", len(dstDriver.TestState),
+			len(srcDriver.TestState))
+	}
+	for key := range srcDriver.TestState {
+		srcValue, _ := srcDriver.Read(key)
+		dstValue, err := dstDriver.Read(key)
+		if err != nil || !bytes.Equal(srcValue, dstValue) {
+			t.Fatalf("object %s not restored: %s, expected %s", key, dstValue, srcValue)
+		}
+	}
+
+	// a migration in progress is resumed after the restore
+	migCfg := &mastercfg.PktTagMigrationState{}
+	migCfg.StateDriver = dstDriver
+	if err := migCfg.Read("net1.default"); err != nil || migCfg.Status != mastercfg.PktTagMigrating {
+		t.Fatalf("pkt tag migration not restored: %+v, err: %v", migCfg, err)
+	}
+	hostStates, err := mastercfg.ReadPktTagHostStates(dstDriver, "net1.default")
+	if err != nil || hostStates["host1"] == nil || hostStates["host1"].PktTag != 5 {
+		t.Fatalf("pkt tags of host1 not restored: %+v, err: %v", hostStates, err)
+	}
+
+	// only empty stores can be restored
+	if err := Restore(dstDriver, archive, true); err == nil {
+		t.Fatalf("restore into a non empty store succeeded")
+	}
+
+	archive.Version = ArchiveVersion + 1
+	emptyDriver := &state.FakeStateDriver{}
+	emptyDriver.Init(nil)
+	defer emptyDriver.Deinit()
+	if err := Restore(emptyDriver, archive, true); err == nil {
+		t.Fatalf("restore of a newer archive succeeded")
+	}
+}
+
+func TestRestoreWithoutEndpoints(t *testing.T) {
+	srcDriver := &state.FakeStateDriver{}
+	srcDriver.Init(nil)
+	defer srcDriver.Deinit()
+	populateStore(t, srcDriver)
+
+	dstDriver := &state.FakeStateDriver{}
+	dstDriver.Init(nil)
+	defer dstDriver.Deinit()
+
+	roundTrip(t, srcDriver, dstDriver, false)
+
+	for _, key := range []string{mastercfg.StateConfigPath + "eps/net1.default-ep1",
+		mastercfg.StateOperPath + "eps/net1.default-ep1"} {
+		if _, err := dstDriver.Read(key); err == nil {
+			t.Fatalf("endpoint state %s restored", key)
+		}
+	}
+
+	// the addresses and counts of the endpoint are released
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = dstDriver
+	if err := nwCfg.Read("net1.default"); err != nil {
+		t.Fatalf("error reading network state: %v", err)
+	}
+	if nwCfg.EpCount != 0 || nwCfg.EpAddrCount != 0 || nwCfg.IPAllocMap.Test(1) {
+		t.Fatalf("endpoint resources not released: %+v", nwCfg)
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = dstDriver
+	if err := epgCfg.Read(mastercfg.GetEndpointGroupKey("epg1", "default")); err != nil {
+		t.Fatalf("error reading endpoint group state: %v", err)
+	}
+	if epgCfg.EpCount != 0 {
+		t.Fatalf("endpoint group count not released: %+v", epgCfg)
+	}
+}
@@ -31,10 +31,14 @@ import (
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netmaster/objApi"
 	"github.com/contiv/netplugin/netmaster/resources"
+	"github.com/contiv/netplugin/netmaster/statecheck"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/metrics"
+	"github.com/contiv/netplugin/version"
 	"github.com/contiv/objdb"
 	"github.com/contiv/ofnet"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/netmaster/k8snetwork"
@@ -50,12 +54,22 @@ type MasterDaemon struct {
 	ClusterStore string // state store URL
 	ClusterMode  string // cluster scheduler used docker/kubernetes/mesos etc
 
+	// TLS settings of the state store, unset for plain http
+	ClusterStoreCACert string // CA certificate of the state store
+	ClusterStoreCert   string // client certificate
+	ClusterStoreKey    string // client key
+
+	// RepairState fixes the state store inconsistencies found when the
+	// daemon becomes leader, before it serves any request
+	RepairState bool
+
 	// Private state
 	currState        string                          // Current state of the daemon
 	apiController    *objApi.APIController           // API controller for contiv model
 	stateDriver      core.StateDriver                // KV store
 	resmgr           *resources.StateResourceManager // state resource manager
 	objdbClient      objdb.API                       // Objdb client
+	nodeHeartbeats   *mastercfg.NodeHeartbeats       // receive times of the node heartbeats
 	ofnetMaster      *ofnet.OfnetMaster              // Ofnet master instance
 	listenerMutex    sync.Mutex                      // Mutex for HTTP listener
 	stopLeaderChan   chan bool                       // Channel to stop the leader listener
@@ -73,7 +87,12 @@ func (d *MasterDaemon) Init() {
 	}
 
 	// initialize state driver
-	d.stateDriver, err = initStateDriver(d.ClusterStore)
+	d.stateDriver, err = initStateDriver(&core.InstanceInfo{
+		DbURL:    d.ClusterStore,
+		DbCACert: d.ClusterStoreCACert,
+		DbCert:   d.ClusterStoreCert,
+		DbKey:    d.ClusterStoreKey,
+	})
 	if err != nil {
 		log.Fatalf("Failed to init state-store. Error: %s", err)
 	}
@@ -84,11 +103,23 @@ func (d *MasterDaemon) Init() {
 		log.Fatalf("Failed to init resource manager. Error: %s", err)
 	}
 
+	// report the pool usage on /metrics
+	prometheus.MustRegister(&poolCollector{stateDriver: d.stateDriver})
+
 	// Create an objdb client
-	d.objdbClient, err = objdb.NewClient(d.ClusterStore)
+	d.objdbClient, err = objdb.NewClientWithConfig(&objdb.ClientConfig{
+		DbURL:  d.ClusterStore,
+		CACert: d.ClusterStoreCACert,
+		Cert:   d.ClusterStoreCert,
+		Key:    d.ClusterStoreKey,
+	})
 	if err != nil {
 		log.Fatalf("Error connecting to state store: %v. Err: %v", d.ClusterStore, err)
 	}
+
+	// track the node heartbeats on the master clock
+	d.nodeHeartbeats = mastercfg.NewNodeHeartbeats()
+	go d.nodeHeartbeats.Watch(d.stateDriver)
 }
 
 func (d *MasterDaemon) registerService() {
@@ -131,6 +162,39 @@ func (d *MasterDaemon) registerService() {
 	log.Infof("Registered netmaster service with registry")
 }
 
+// getNodePolicyLogs collects the recent policy logs from all netplugin nodes,
+// nodes that can not be reached are skipped
+func (d *MasterDaemon) getNodePolicyLogs() []core.PolicyLogRecord {
+	records := []core.PolicyLogRecord{}
+
+	srvList, err := d.objdbClient.GetService("netplugin")
+	if err != nil {
+		log.Errorf("Error getting netplugin nodes. Err: %v", err)
+		return records
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, srv := range srvList {
+		url := fmt.Sprintf("http://%s:9090/policylogs", srv.HostAddr)
+		resp, err := client.Get(url)
+		if err != nil {
+			log.Errorf("Error getting policy logs from %s. Err: %v", srv.HostAddr, err)
+			continue
+		}
+
+		var nodeRecords []core.PolicyLogRecord
+		err = json.NewDecoder(resp.Body).Decode(&nodeRecords)
+		resp.Body.Close()
+		if err != nil {
+			log.Errorf("Error decoding policy logs from %s. Err: %v", srv.HostAddr, err)
+			continue
+		}
+		records = append(records, nodeRecords...)
+	}
+
+	return records
+}
+
 // Find all netplugin nodes and add them to ofnet master
 func (d *MasterDaemon) agentDiscoveryLoop() {
 
@@ -176,33 +240,33 @@ func (d *MasterDaemon) registerRoutes(router *mux.Router) {
 
 	s.HandleFunc("/plugin/allocAddress", makeHTTPHandler(master.AllocAddressHandler))
 	s.HandleFunc("/plugin/releaseAddress", makeHTTPHandler(master.ReleaseAddressHandler))
-	s.HandleFunc("/plugin/createEndpoint", makeHTTPHandler(master.CreateEndpointHandler))
-	s.HandleFunc("/plugin/deleteEndpoint", makeHTTPHandler(master.DeleteEndpointHandler))
+	s.HandleFunc("/plugin/createEndpoint", makeHTTPHandler(meterEndpointOp("create", master.CreateEndpointHandler)))
+	s.HandleFunc("/plugin/deleteEndpoint", makeHTTPHandler(meterEndpointOp("delete", master.DeleteEndpointHandler)))
 	s.HandleFunc("/plugin/updateEndpoint", makeHTTPHandler(master.UpdateEndpointHandler))
 
+	// node maintenance
+	s.HandleFunc(fmt.Sprintf("/%s/{id}/%s", master.GetNodeRESTEndpoint, master.CordonNodeRESTEndpoint),
+		makeHTTPHandler(d.nodeMaintenanceHandler(master.CordonNode)))
+	s.HandleFunc(fmt.Sprintf("/%s/{id}/%s", master.GetNodeRESTEndpoint, master.UncordonNodeRESTEndpoint),
+		makeHTTPHandler(d.nodeMaintenanceHandler(master.UncordonNode)))
+	s.HandleFunc(fmt.Sprintf("/%s/{id}/%s", master.GetNodeRESTEndpoint, master.DecommissionNodeRESTEndpoint),
+		makeHTTPHandler(d.nodeMaintenanceHandler(d.decommissionNode)))
+	s.HandleFunc(fmt.Sprintf("/%s", master.ObjectLabelsRESTEndpoint),
+		makeHTTPHandler(master.SetObjectLabelsHandler))
+	s.HandleFunc(fmt.Sprintf("/%s/{id}", master.PktTagMigrationRESTEndpoint),
+		makeHTTPHandler(master.MigratePktTagHandler))
+
 	s = router.Methods("Get").Subrouter()
 
 	// return netmaster version
 	s.HandleFunc(fmt.Sprintf("/%s", master.GetVersionRESTEndpoint), getVersion)
+	// prometheus metrics
+	s.Handle("/metrics", metrics.Handler())
 	// Print info about the cluster
-	s.HandleFunc(fmt.Sprintf("/%s", master.GetInfoRESTEndpoint), func(w http.ResponseWriter, r *http.Request) {
-		info, err := d.getMasterInfo()
-		if err != nil {
-			log.Errorf("Error getting master state. Err: %v", err)
-			http.Error(w, "Error getting master state", http.StatusInternalServerError)
-			return
-		}
-
-		// convert to json
-		resp, err := json.Marshal(info)
-		if err != nil {
-			http.Error(w,
-				core.Errorf("marshaling json failed. Error: %s", err).Error(),
-				http.StatusInternalServerError)
-			return
-		}
-		w.Write(resp)
-	})
+	s.HandleFunc(fmt.Sprintf("/%s", master.GetInfoRESTEndpoint),
+		makeHTTPHandler(func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+			return d.getMasterInfo()
+		}))
 
 	// services REST endpoints
 	// FIXME: we need to remove once service inspect is added
@@ -211,6 +275,34 @@ func (d *MasterDaemon) registerRoutes(router *mux.Router) {
 	s.HandleFunc(fmt.Sprintf("/%s", master.GetServicesRESTEndpoint),
 		get(true, d.services))
 
+	// cluster wide policy rule stats
+	s.HandleFunc(fmt.Sprintf("/%s", master.GetPolicyStatsRESTEndpoint),
+		makeHTTPHandler(d.getPolicyStats))
+
+	// inventory and health of the netplugin nodes
+	s.HandleFunc(fmt.Sprintf("/%s", master.GetNodesRESTEndpoint),
+		makeHTTPHandler(d.getNodesHandler))
+	s.HandleFunc(fmt.Sprintf("/%s", master.ObjectLabelsRESTEndpoint),
+		makeHTTPHandler(master.GetObjectLabelsHandler))
+	s.HandleFunc(fmt.Sprintf("/%s", master.PktTagMigrationsRESTEndpoint),
+		makeHTTPHandler(master.GetPktTagMigrationsHandler))
+	s.HandleFunc(fmt.Sprintf("/%s/{id}", master.PktTagMigrationRESTEndpoint),
+		makeHTTPHandler(master.GetPktTagMigrationHandler))
+	// stream of the object changes
+	s.HandleFunc(fmt.Sprintf("/%s", master.EventsRESTEndpoint), master.EventStreamHandler)
+	s.HandleFunc(fmt.Sprintf("/%s/{id}/%s", master.GetNodeRESTEndpoint, master.DrainNodeRESTEndpoint),
+		makeHTTPHandler(d.nodeMaintenanceHandler(master.DrainNode)))
+	s.HandleFunc(fmt.Sprintf("/%s/%s", master.GetNodeRESTEndpoint, "{id}"),
+		makeHTTPHandler(d.getNodeHandler))
+
+	// recent packets logged by policy rules on all nodes
+	s.HandleFunc(fmt.Sprintf("/%s", master.GetPolicyLogsRESTEndpoint),
+		makeHTTPHandler(d.getPolicyLogs))
+
+	// simulate a flow against the configured policies
+	s.HandleFunc(fmt.Sprintf("/%s", master.PolicyCheckRESTEndpoint),
+		makeHTTPHandler(d.checkPolicy))
+
 	// Debug REST endpoint for inspecting ofnet state
 	s.HandleFunc("/debug/ofnet", func(w http.ResponseWriter, r *http.Request) {
 		ofnetMasterState, err := d.ofnetMaster.InspectState()
@@ -229,11 +321,22 @@ func (d *MasterDaemon) runLeader() {
 	router := mux.NewRouter()
 
 	// Create a new api controller
-	d.apiController = objApi.NewAPIController(router, d.objdbClient, d.ClusterStore)
+	d.apiController = objApi.NewAPIController(router, d.objdbClient)
+
+	// check the state store before anything is changed in it
+	d.checkState()
 
 	//Restore state from clusterStore
 	d.restoreCache()
 
+	// stream the changes of the state store
+	master.StartEventWatches(d.stateDriver)
+
+	// watch the pkt tag migrations left by the previous leader
+	if err := master.ResumePktTagMigrations(d.stateDriver); err != nil {
+		log.Errorf("Error resuming pkt tag migrations. Err: %v", err)
+	}
+
 	// Register netmaster service
 	d.registerService()
 
@@ -248,9 +351,26 @@ func (d *MasterDaemon) runLeader() {
 	log.Infof("Exiting Leader mode")
 }
 
+// checkState reports the state store inconsistencies, repairing them when
+// asked to
+func (d *MasterDaemon) checkState() {
+	incs, err := statecheck.Run(d.stateDriver, d.objdbClient, d.RepairState)
+	if err != nil {
+		log.Errorf("Error checking the state store. Err: %v", err)
+		return
+	}
+
+	if len(incs) != 0 && !d.RepairState {
+		log.Warnf("Found %d state store inconsistencies, restart with --repair-state "+
+			"or run cfgtool check -repair to fix them", len(incs))
+	}
+}
+
 // runFollower runs the follower FSM loop
 func (d *MasterDaemon) runFollower() {
 	router := mux.NewRouter()
+	// metrics are served by each netmaster, the rest by the leader
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 	router.PathPrefix("/").HandlerFunc(slaveProxyHandler)
 
 	// Register netmaster service
@@ -309,6 +429,7 @@ func (d *MasterDaemon) becomeLeader() {
 
 	// set current state
 	d.currState = "leader"
+	metrics.SetLeader(true)
 
 	// Run the HTTP listener
 	go d.runLeader()
@@ -322,6 +443,7 @@ func (d *MasterDaemon) becomeFollower() {
 
 	// set current state
 	d.currState = "follower"
+	metrics.SetLeader(false)
 
 	// run follower loop
 	go d.runFollower()
@@ -404,6 +526,121 @@ func (d *MasterDaemon) restoreCache() {
 
 }
 
+// getNodes returns the inventory of the netplugin nodes, a node is up as
+// long as its netplugin service registration has not expired
+func (d *MasterDaemon) getNodes() ([]*mastercfg.NodeInfo, error) {
+	srvList, err := d.objdbClient.GetService("netplugin")
+	if err != nil {
+		log.Errorf("Error getting netplugin nodes. Err: %v", err)
+		return nil, err
+	}
+
+	registered := make(map[string]bool)
+	for _, srv := range srvList {
+		registered[srv.Hostname] = true
+	}
+
+	return mastercfg.GetNodes(d.stateDriver, registered, d.nodeHeartbeats,
+		version.Get().Version)
+}
+
+// getPolicyStats returns the cluster wide policy rule stats
+func (d *MasterDaemon) getPolicyStats(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	return mastercfg.GetPolicyStats(d.stateDriver)
+}
+
+// getPolicyLogs returns the recent packets logged by policy rules on all
+// nodes, filtered by the query
+func (d *MasterDaemon) getPolicyLogs(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	filter := mastercfg.PolicyLogFilter{
+		TenantName: r.URL.Query().Get("tenant"),
+		PolicyName: r.URL.Query().Get("policy"),
+		Action:     r.URL.Query().Get("action"),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, "invalid limit %q", limit)
+		}
+	}
+
+	return mastercfg.GetPolicyLogs(d.stateDriver, d.getNodePolicyLogs(), filter)
+}
+
+// checkPolicy simulates the flow of the query against the configured
+// policies
+func (d *MasterDaemon) checkPolicy(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	query := r.URL.Query()
+	tenant := query.Get("tenant")
+	if tenant == "" {
+		tenant = "default"
+	}
+	port := 0
+	if portStr := query.Get("port"); portStr != "" {
+		var err error
+		if port, err = strconv.Atoi(portStr); err != nil || port < 0 || port > 65535 {
+			return nil, newHTTPError(http.StatusBadRequest, "invalid port %q", portStr)
+		}
+	}
+	if query.Get("from") == "" || query.Get("to") == "" {
+		return nil, newHTTPError(http.StatusBadRequest, "from and to are required")
+	}
+
+	result, err := master.CheckPolicy(d.stateDriver, tenant, query.Get("from"),
+		query.Get("to"), query.Get("proto"), port)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "%s", err)
+	}
+
+	return result, nil
+}
+
+// getNodesHandler returns the inventory and health of the netplugin nodes
+func (d *MasterDaemon) getNodesHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	return d.getNodes()
+}
+
+// getNodeHandler returns the inventory and health of a netplugin node
+func (d *MasterDaemon) getNodeHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	nodes, err := d.getNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		if node.Hostname == vars["id"] {
+			return node, nil
+		}
+	}
+
+	return nil, newHTTPError(http.StatusNotFound, "node %q not found", vars["id"])
+}
+
+// nodeMaintenanceHandler returns the handler of a node maintenance request
+func (d *MasterDaemon) nodeMaintenanceHandler(maintFunc func(core.StateDriver, string) (*master.NodeMaintenanceReport, error)) httpAPIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+		return maintFunc(d.stateDriver, vars["id"])
+	}
+}
+
+// decommissionNode decommissions a node once its netplugin is stopped, a
+// running netplugin would publish its state again
+func (d *MasterDaemon) decommissionNode(stateDriver core.StateDriver, hostname string) (*master.NodeMaintenanceReport, error) {
+	srvList, err := d.objdbClient.GetService("netplugin")
+	if err != nil {
+		log.Errorf("Error getting netplugin nodes. Err: %v", err)
+		return nil, err
+	}
+
+	for _, srv := range srvList {
+		if srv.Hostname == hostname {
+			return nil, core.Errorf("netplugin is still running on node %s, stop it before decommissioning the node", hostname)
+		}
+	}
+
+	return master.DecommissionNode(stateDriver, hostname)
+}
+
 // getMasterInfo returns information about cluster
 func (d *MasterDaemon) getMasterInfo() (map[string]interface{}, error) {
 	info := make(map[string]interface{})
@@ -0,0 +1,97 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/gstate"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// poolCollector reports the usage of the vlan, vxlan and address pools
+// from the state store when the metrics are scraped
+type poolCollector struct {
+	stateDriver core.StateDriver
+}
+
+// Describe sends the descriptors of the pool metrics
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.VLANPoolSize
+	ch <- metrics.VLANPoolAllocated
+	ch <- metrics.VXLANPoolSize
+	ch <- metrics.VXLANPoolAllocated
+	ch <- metrics.NetworkIPPoolSize
+	ch <- metrics.NetworkIPPoolAllocated
+}
+
+// Collect reads the pools from the state store
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	gCfg := gstate.Cfg{}
+	gCfg.StateDriver = c.stateDriver
+	if err := gCfg.Read(""); err == nil {
+		vlansInUse, _ := gCfg.GetVlansInUse()
+		vxlansInUse, _ := gCfg.GetVxlansInUse()
+
+		ch <- prometheus.MustNewConstMetric(metrics.VLANPoolSize, prometheus.GaugeValue, float64(gCfg.GetVlanPoolSize()))
+		ch <- prometheus.MustNewConstMetric(metrics.VLANPoolAllocated, prometheus.GaugeValue, float64(vlansInUse))
+		ch <- prometheus.MustNewConstMetric(metrics.VXLANPoolSize, prometheus.GaugeValue, float64(gCfg.GetVxlanPoolSize()))
+		ch <- prometheus.MustNewConstMetric(metrics.VXLANPoolAllocated, prometheus.GaugeValue, float64(vxlansInUse))
+	} else if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Error reading global config. Err: %v", err)
+	}
+
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = c.stateDriver
+	networks, err := nwCfg.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Error reading networks. Err: %v", err)
+		return
+	}
+
+	for _, nw := range networks {
+		nw := nw.(*mastercfg.CfgNetworkState)
+		if nw.SubnetIP == "" {
+			continue
+		}
+
+		// addresses of the subnet not reserved nor allocated
+		free := uint(1<<(32-nw.SubnetLen)) - nw.IPAllocMap.Count()
+		allocated := uint(nw.EpAddrCount)
+
+		ch <- prometheus.MustNewConstMetric(metrics.NetworkIPPoolSize, prometheus.GaugeValue,
+			float64(free+allocated), nw.Tenant, nw.NetworkName)
+		ch <- prometheus.MustNewConstMetric(metrics.NetworkIPPoolAllocated, prometheus.GaugeValue,
+			float64(allocated), nw.Tenant, nw.NetworkName)
+	}
+}
+
+// meterEndpointOp records the metrics of an endpoint create or delete
+// request of the plugin api
+func meterEndpointOp(op string, handlerFunc httpAPIFunc) httpAPIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+		start := time.Now()
+		resp, err := handlerFunc(w, r, vars)
+		metrics.ObserveEndpointOp("netmaster", op, start, err)
+		return resp, err
+	}
+}
@@ -35,6 +35,21 @@ import (
 
 type httpAPIFunc func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error)
 
+// httpError is an error returned by a httpAPIFunc with its http status
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+// newHTTPError returns an error sent with the http status code
+func newHTTPError(code int, format string, args ...interface{}) error {
+	return &httpError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
 // get current version
 func getVersion(w http.ResponseWriter, r *http.Request) {
 	ver := version.Get()
@@ -51,11 +66,11 @@ func getVersion(w http.ResponseWriter, r *http.Request) {
 }
 
 // initStateDriver creates a state driver based on the cluster store URL
-func initStateDriver(clusterStore string) (core.StateDriver, error) {
+func initStateDriver(instInfo *core.InstanceInfo) (core.StateDriver, error) {
 	// parse the state store URL
-	parts := strings.Split(clusterStore, "://")
+	parts := strings.Split(instInfo.DbURL, "://")
 	if len(parts) < 2 {
-		return nil, core.Errorf("Invalid state-store URL %q", clusterStore)
+		return nil, core.Errorf("Invalid state-store URL %q", instInfo.DbURL)
 	}
 	stateStore := parts[0]
 
@@ -63,16 +78,12 @@ func initStateDriver(clusterStore string) (core.StateDriver, error) {
 	switch stateStore {
 	case utils.EtcdNameStr:
 	case utils.ConsulNameStr:
+	case utils.BoltNameStr:
 	default:
 		return nil, core.Errorf("Unsupported state-store %q", stateStore)
 	}
 
-	// Setup instance info
-	instInfo := core.InstanceInfo{
-		DbURL: clusterStore,
-	}
-
-	return utils.NewStateDriver(stateStore, &instInfo)
+	return utils.NewStateDriver(stateStore, instInfo)
 }
 
 // GetLocalAddr gets local address to be used
@@ -134,7 +145,11 @@ func makeHTTPHandler(handlerFunc httpAPIFunc) http.HandlerFunc {
 			log.Errorf("Handler for %s %s returned error: %s", r.Method, r.URL, err)
 
 			// Send HTTP response
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			code := http.StatusInternalServerError
+			if httpErr, ok := err.(*httpError); ok {
+				code = httpErr.code
+			}
+			http.Error(w, err.Error(), code)
 		} else {
 			// Send HTTP response as Json
 			err = writeJSON(w, http.StatusOK, resp)
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -108,14 +109,25 @@ func UpdatePluginName(pluginName string) {
 	ipamDriverName = pluginName
 }
 
+// ipamConfigs returns the docker IPAM configuration of a network
+func ipamConfigs(nwCfg *mastercfg.CfgNetworkState) []network.IPAMConfig {
+	ipams := []network.IPAMConfig{{
+		Subnet:  fmt.Sprintf("%s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen),
+		Gateway: nwCfg.Gateway,
+	}}
+	if nwCfg.IPv6Subnet != "" {
+		ipams = append(ipams, network.IPAMConfig{
+			Subnet:  fmt.Sprintf("%s/%d", nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen),
+			Gateway: nwCfg.IPv6Gateway,
+		})
+	}
+
+	return ipams
+}
+
 // CreateDockNet Creates a network in docker daemon
 func CreateDockNet(tenantName, networkName, serviceName string, nwCfg *mastercfg.CfgNetworkState) error {
 	var nwID string
-	var subnetCIDRv6 = ""
-
-	if nwCfg.IPv6Subnet != "" {
-		subnetCIDRv6 = fmt.Sprintf("%s/%d", nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen)
-	}
 
 	// Trim default tenant name
 	docknetName := GetDocknetName(tenantName, networkName, serviceName)
@@ -147,22 +159,7 @@ func CreateDockNet(tenantName, networkName, serviceName string, nwCfg *mastercfg
 			netPluginOptions["pkt-tag"] = strconv.Itoa(nwCfg.PktTag)
 		}
 
-		subnetCIDR := fmt.Sprintf("%s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen)
-
-		var ipams []network.IPAMConfig
-		var IPAMv4 = network.IPAMConfig{
-			Subnet:  subnetCIDR,
-			Gateway: nwCfg.Gateway,
-		}
-		ipams = append(ipams, IPAMv4)
-		var IPAMv6 network.IPAMConfig
-		if subnetCIDRv6 != "" {
-			IPAMv6 = network.IPAMConfig{
-				Subnet:  subnetCIDRv6,
-				Gateway: nwCfg.IPv6Gateway,
-			}
-			ipams = append(ipams, IPAMv6)
-		}
+		ipams := ipamConfigs(nwCfg)
 		ipamOptions := make(map[string]string)
 		ipamOptions["tenant"] = nwCfg.Tenant
 		ipamOptions["network"] = nwCfg.NetworkName
@@ -200,6 +197,49 @@ func CreateDockNet(tenantName, networkName, serviceName string, nwCfg *mastercfg
 	return CreateDockNetState(tenantName, networkName, serviceName, nwID)
 }
 
+// UpdateDockNet brings the IPAM configuration of a docker network in line
+// with the network config. Docker can't change the IPAM of a network, so it
+// is recreated when no containers are attached; otherwise the docker view is
+// left stale and contiv state stays authoritative for new endpoints.
+func UpdateDockNet(tenantName, networkName, serviceName string, nwCfg *mastercfg.CfgNetworkState) error {
+	docknetName := GetDocknetName(tenantName, networkName, serviceName)
+
+	// connect to docker
+	defaultHeaders := map[string]string{"User-Agent": "Docker-Client/" + dockerversion.Version + " (" + runtime.GOOS + ")"}
+	docker, err := dockerclient.NewClient("unix:///var/run/docker.sock", "v1.23", nil, defaultHeaders)
+	if err != nil {
+		log.Errorf("Unable to connect to docker. Error %v", err)
+		return fmt.Errorf("Unable to connect to docker: %s", err.Error())
+	}
+
+	nw, err := docker.NetworkInspect(context.Background(), docknetName)
+	if err != nil {
+		log.Infof("docker network %s not found, creating it", docknetName)
+		return CreateDockNet(tenantName, networkName, serviceName, nwCfg)
+	}
+	if nw.Driver != netDriverName {
+		log.Errorf("Network name %s used by another driver %s", docknetName, nw.Driver)
+		return errors.New("Network name used by another driver")
+	}
+
+	if reflect.DeepEqual(nw.IPAM.Config, ipamConfigs(nwCfg)) {
+		return nil
+	}
+
+	if len(nw.Containers) != 0 {
+		log.Warnf("docker network %s has %d containers, its IPAM config is not updated",
+			docknetName, len(nw.Containers))
+		return nil
+	}
+
+	log.Infof("Recreating docker network %s with the updated IPAM config", docknetName)
+	if err := DeleteDockNet(tenantName, networkName, serviceName); err != nil {
+		return err
+	}
+
+	return CreateDockNet(tenantName, networkName, serviceName, nwCfg)
+}
+
 // DeleteDockNet deletes a network in docker daemon
 func DeleteDockNet(tenantName, networkName, serviceName string) error {
 	// Trim default tenant name
@@ -18,6 +18,7 @@ package gstate
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +44,10 @@ const (
 //GlobalMutex used to syncronize global configuration changes
 var GlobalMutex sync.Mutex
 
+// ModelMutex serializes the changes to the model objects, made through the
+// model routes or by netmaster itself. It is taken before GlobalMutex.
+var ModelMutex sync.Mutex
+
 // AutoParams specifies various parameters for the auto allocation and resource
 // management for networks and endpoints.  This allows for hands-free
 // allocation of resources without having to specify these each time these
@@ -195,6 +200,21 @@ func (gc *Cfg) GetVxlansInUse() (uint, string) {
 	return ra.GetResourceList("global", resources.AutoVXLANResource)
 }
 
+// GetVxlanPoolSize returns the number of vxlans in the vxlan range
+func (gc *Cfg) GetVxlanPoolSize() uint {
+	if gc.Auto.VXLANs == "" {
+		return 0
+	}
+
+	vxlanRsrcCfg, err := gc.initVXLANBitset(gc.Auto.VXLANs)
+	if err != nil {
+		log.Errorf("Error parsing vxlan range %s. Err: %v", gc.Auto.VXLANs, err)
+		return 0
+	}
+
+	return vxlanRsrcCfg.VXLANs.Count()
+}
+
 // AllocVXLAN allocates a new vxlan; ids for both the vxlan and vlan are returned.
 func (gc *Cfg) AllocVXLAN(reqVxlan uint) (vxlan uint, localVLAN uint, err error) {
 
@@ -230,6 +250,48 @@ func (gc *Cfg) AllocVXLAN(reqVxlan uint) (vxlan uint, localVLAN uint, err error)
 	return
 }
 
+// CheckVXLAN returns the vxlan and local vlan AllocVXLAN would allocate,
+// without allocating them.
+func (gc *Cfg) CheckVXLAN(reqVxlan uint) (vxlan uint, localVLAN uint, err error) {
+	g := &Oper{}
+	g.StateDriver = gc.StateDriver
+	err = g.Read("")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if reqVxlan != 0 && reqVxlan <= g.FreeVXLANsStart {
+		return 0, 0, errors.New("Requested vxlan is out of range")
+	}
+
+	oper := &resources.AutoVXLANOperResource{}
+	oper.StateDriver = gc.StateDriver
+	err = oper.Read("global")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if reqVxlan != 0 {
+		vxlan = reqVxlan - g.FreeVXLANsStart
+		if !oper.FreeVXLANs.Test(vxlan) {
+			return 0, 0, errors.New("requested vxlan not available")
+		}
+	} else {
+		ok := false
+		vxlan, ok = oper.FreeVXLANs.NextSet(0)
+		if !ok {
+			return 0, 0, errors.New("no vxlans available")
+		}
+	}
+
+	localVLAN, ok := oper.FreeLocalVLANs.NextSet(0)
+	if !ok {
+		return 0, 0, errors.New("no local vlans available")
+	}
+
+	return vxlan + g.FreeVXLANsStart, localVLAN, nil
+}
+
 // FreeVXLAN returns a VXLAN id to the pool.
 func (gc *Cfg) FreeVXLAN(vxlan uint, localVLAN uint) error {
 	tempRm, err := resources.GetStateResourceManager()
@@ -287,6 +349,21 @@ func (gc *Cfg) GetVlansInUse() (uint, string) {
 	return ra.GetResourceList("global", resources.AutoVLANResource)
 }
 
+// GetVlanPoolSize returns the number of vlans in the vlan range
+func (gc *Cfg) GetVlanPoolSize() uint {
+	if gc.Auto.VLANs == "" {
+		return 0
+	}
+
+	vlanBitset, err := gc.initVLANBitset(gc.Auto.VLANs)
+	if err != nil {
+		log.Errorf("Error parsing vlan range %s. Err: %v", gc.Auto.VLANs, err)
+		return 0
+	}
+
+	return vlanBitset.Count()
+}
+
 // AllocVLAN allocates a new VLAN resource. Returns an ID.
 func (gc *Cfg) AllocVLAN(reqVlan uint) (uint, error) {
 	tempRm, err := resources.GetStateResourceManager()
@@ -304,6 +381,30 @@ func (gc *Cfg) AllocVLAN(reqVlan uint) (uint, error) {
 	return vlan.(uint), err
 }
 
+// CheckVLAN returns the vlan AllocVLAN would allocate, without allocating it.
+func (gc *Cfg) CheckVLAN(reqVlan uint) (uint, error) {
+	oper := &resources.AutoVLANOperResource{}
+	oper.StateDriver = gc.StateDriver
+	err := oper.Read("global")
+	if err != nil {
+		return 0, err
+	}
+
+	if reqVlan != 0 {
+		if !oper.FreeVLANs.Test(reqVlan) {
+			return 0, fmt.Errorf("requested vlan not available - vlan:%d", reqVlan)
+		}
+		return reqVlan, nil
+	}
+
+	vlan, ok := oper.FreeVLANs.NextSet(0)
+	if !ok {
+		return 0, errors.New("no vlans available")
+	}
+
+	return vlan, nil
+}
+
 // FreeVLAN releases a VLAN for a given ID.
 func (gc *Cfg) FreeVLAN(vlan uint) error {
 	tempRm, err := resources.GetStateResourceManager()
@@ -330,3 +330,97 @@ func TestAutoDefaultNetwork(t *testing.T) {
 		t.Fatalf("Error: '%s' could not unassign default network", err)
 	}
 }
+
+func TestGlobalConfigPoolSize(t *testing.T) {
+	cfgData := []byte(`
+        {
+            "Tenant"  : "default",
+            "Auto" : {
+                "VLANs"             : "1-10,4000-4095",
+                "VXLANs"            : "15000-17000"
+            }
+        }`)
+
+	gc, err := Parse(cfgData)
+	if err != nil {
+		t.Fatalf("error '%s' parsing config '%s' \n", err, cfgData)
+	}
+
+	// vlan 4095 is reserved
+	if size := gc.GetVlanPoolSize(); size != 105 {
+		t.Fatalf("error - expecting vlan pool size %d but got %d \n", 105, size)
+	}
+	if size := gc.GetVxlanPoolSize(); size != 2001 {
+		t.Fatalf("error - expecting vxlan pool size %d but got %d \n", 2001, size)
+	}
+
+	gc.Auto.VLANs = ""
+	if size := gc.GetVlanPoolSize(); size != 0 {
+		t.Fatalf("error - expecting empty vlan pool but got size %d \n", size)
+	}
+}
+
+func TestGlobalConfigCheckAlloc(t *testing.T) {
+	cfgData := []byte(`
+        {
+            "Tenant"  : "default",
+            "Auto" : {
+                "VLANs"             : "100-101",
+                "VXLANs"            : "15000-15001"
+            }
+        }`)
+
+	gc, err := Parse(cfgData)
+	if err != nil {
+		t.Fatalf("error '%s' parsing config '%s' \n", err, cfgData)
+	}
+
+	gstateSD.Init(nil)
+	defer func() { gstateSD.Deinit() }()
+	gc.StateDriver = gstateSD
+	_, err = resources.NewStateResourceManager(gstateSD)
+	if err != nil {
+		t.Fatalf("Failed to instantiate resource manager. Error: %s", err)
+	}
+	defer func() { resources.ReleaseStateResourceManager() }()
+
+	for _, res := range []string{"vlan", "vxlan"} {
+		if err := gc.Process(res); err != nil {
+			t.Fatalf("error '%s' processing config %v \n", err, gc)
+		}
+	}
+
+	// checking twice gives the same vlan, nothing is allocated
+	for i := 0; i < 2; i++ {
+		vlan, err := gc.CheckVLAN(0)
+		if err != nil || vlan != 100 {
+			t.Fatalf("error - expecting vlan 100 but got %d, err: %v \n", vlan, err)
+		}
+	}
+	if _, err := gc.AllocVLAN(100); err != nil {
+		t.Fatalf("error - allocating vlan - %s \n", err)
+	}
+	if _, err := gc.CheckVLAN(100); err == nil {
+		t.Fatalf("error - allocated vlan 100 reported available \n")
+	}
+	if vlan, err := gc.CheckVLAN(0); err != nil || vlan != 101 {
+		t.Fatalf("error - expecting vlan 101 but got %d, err: %v \n", vlan, err)
+	}
+
+	vxlan, _, err := gc.CheckVXLAN(15001)
+	if err != nil || vxlan != 15001 {
+		t.Fatalf("error - expecting vxlan 15001 but got %d, err: %v \n", vxlan, err)
+	}
+	if _, _, err := gc.CheckVXLAN(20000); err == nil {
+		t.Fatalf("error - vxlan 20000 outside the range reported available \n")
+	}
+	allocVxlan, localVLAN, err := gc.AllocVXLAN(0)
+	if err != nil {
+		t.Fatalf("error - allocating vxlan - %s \n", err)
+	}
+	vxlan, checkVLAN, err := gc.CheckVXLAN(0)
+	if err != nil || vxlan == allocVxlan || checkVLAN == localVLAN {
+		t.Fatalf("error - check returned allocated vxlan %d vlan %d, err: %v \n",
+			vxlan, checkVLAN, err)
+	}
+}
@@ -22,9 +22,14 @@ const defaultEpgName = "ingress-group"
 const defaultPolicyName = "ingress-policy"
 const defaultRuleID = "1"
 
+// contivObjRetries bounds the wait, at 100ms intervals, for a contiv object
+// to show up after a create or to go away after a delete
+var contivObjRetries = 50
+
 type k8sContext struct {
 	k8sClientSet *kubernetes.Clientset
 	contivClient *client.ContivClient
+	contivK8sCfg k8sutils.ContivConfig
 	isLeader     func() bool
 }
 
@@ -47,99 +52,108 @@ func (k8sNet *k8sContext) handleK8sEvents() {
 	}
 }
 
-func (k8sNet *k8sContext) createNetwork(nwName string) error {
-	npLog.Infof("create network %s", nwName)
+// waitForContivObj waits for a contiv object to be created or deleted,
+// giving up after contivObjRetries attempts
+func waitForContivObj(desc string, deleted bool, getObj func() error) error {
+	for i := 0; i < contivObjRetries; i++ {
+		if err := getObj(); (err != nil) == deleted {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
 
-	if _, err := k8sNet.contivClient.NetworkGet(defaultTenantName, nwName); err == nil {
+	if deleted {
+		return fmt.Errorf("timed out waiting for %s to be deleted", desc)
+	}
+	return fmt.Errorf("timed out waiting for %s to be created", desc)
+}
+
+func (k8sNet *k8sContext) createNetwork(tenantName, nwName, subnet string) error {
+	npLog.Infof("create network %s/%s", tenantName, nwName)
+
+	if _, err := k8sNet.contivClient.NetworkGet(tenantName, nwName); err == nil {
 		return nil
 	}
 
 	if err := k8sNet.contivClient.NetworkPost(&client.Network{
-		TenantName:  defaultTenantName,
+		TenantName:  tenantName,
 		NetworkName: nwName,
-		Subnet:      defaultSubnet,
+		Subnet:      subnet,
 		Encap:       "vxlan",
 	}); err != nil {
-		npLog.Errorf("failed to create network %s, %s", nwName, err)
+		npLog.Errorf("failed to create network %s/%s, %s", tenantName, nwName, err)
 		return err
 	}
 
-	for func() error {
-		_, err := k8sNet.contivClient.NetworkGet(defaultTenantName, nwName)
+	return waitForContivObj("network "+tenantName+"/"+nwName, false, func() error {
+		_, err := k8sNet.contivClient.NetworkGet(tenantName, nwName)
 		return err
-	}() != nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
-func (k8sNet *k8sContext) deleteNetwork(nwName string) error {
-	npLog.Infof("delete network %s", nwName)
+func (k8sNet *k8sContext) deleteNetwork(tenantName, nwName string) error {
+	npLog.Infof("delete network %s/%s", tenantName, nwName)
 
-	if _, err := k8sNet.contivClient.NetworkGet(defaultTenantName, nwName); err != nil {
+	if _, err := k8sNet.contivClient.NetworkGet(tenantName, nwName); err != nil {
 		return nil
 	}
 
 	if err := k8sNet.contivClient.NetworkDelete(
-		defaultTenantName, nwName); err != nil {
-		npLog.Errorf("failed to delete network %s, %s", nwName, err)
+		tenantName, nwName); err != nil {
+		npLog.Errorf("failed to delete network %s/%s, %s", tenantName, nwName, err)
 		return err
 	}
 
-	for func() error {
-		_, err := k8sNet.contivClient.NetworkGet(defaultTenantName, nwName)
+	return waitForContivObj("network "+tenantName+"/"+nwName, true, func() error {
+		_, err := k8sNet.contivClient.NetworkGet(tenantName, nwName)
 		return err
-	}() == nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
-func (k8sNet *k8sContext) createEpg(nwName, epgName, policyName string) error {
-	npLog.Infof("create epg %s", epgName)
+// createEpg creates an epg, with the policy when one is given
+func (k8sNet *k8sContext) createEpg(tenantName, nwName, epgName, policyName string) error {
+	npLog.Infof("create epg %s/%s", tenantName, epgName)
 
-	if _, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName); err == nil {
+	if _, err := k8sNet.contivClient.EndpointGroupGet(tenantName, epgName); err == nil {
 		return nil
 	}
 
-	if err := k8sNet.contivClient.EndpointGroupPost(&client.EndpointGroup{
-		TenantName:  defaultTenantName,
+	epg := &client.EndpointGroup{
+		TenantName:  tenantName,
 		NetworkName: nwName,
 		GroupName:   epgName,
-		Policies:    []string{policyName},
-	}); err != nil {
-		npLog.Errorf("failed to create epg %s, %s", epgName, err)
-		return err
+	}
+	if policyName != "" {
+		epg.Policies = []string{policyName}
 	}
 
-	for func() error {
-		_, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName)
+	if err := k8sNet.contivClient.EndpointGroupPost(epg); err != nil {
+		npLog.Errorf("failed to create epg %s/%s, %s", tenantName, epgName, err)
 		return err
-	}() != nil {
-		time.Sleep(time.Millisecond * 100)
 	}
-	return nil
+
+	return waitForContivObj("epg "+tenantName+"/"+epgName, false, func() error {
+		_, err := k8sNet.contivClient.EndpointGroupGet(tenantName, epgName)
+		return err
+	})
 }
 
-func (k8sNet *k8sContext) deleteEpg(networkname, epgName, policyName string) error {
-	npLog.Infof("delete epg %s", epgName)
-	if _, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName); err != nil {
+func (k8sNet *k8sContext) deleteEpg(tenantName, epgName string) error {
+	npLog.Infof("delete epg %s/%s", tenantName, epgName)
+	if _, err := k8sNet.contivClient.EndpointGroupGet(tenantName, epgName); err != nil {
 		return nil
 	}
 
 	if err := k8sNet.contivClient.EndpointGroupDelete(
-		defaultTenantName, epgName); err != nil {
-		npLog.Errorf("failed to delete epg %s, %s", epgName, err)
+		tenantName, epgName); err != nil {
+		npLog.Errorf("failed to delete epg %s/%s, %s", tenantName, epgName, err)
 		return err
 	}
 
-	for func() error {
-		_, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName)
+	return waitForContivObj("epg "+tenantName+"/"+epgName, true, func() error {
+		_, err := k8sNet.contivClient.EndpointGroupGet(tenantName, epgName)
 		return err
-	}() == nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
 func (k8sNet *k8sContext) createPolicy(policyName string) error {
@@ -157,13 +171,10 @@ func (k8sNet *k8sContext) createPolicy(policyName string) error {
 		return err
 	}
 
-	for func() error {
+	return waitForContivObj("policy "+policyName, false, func() error {
 		_, err := k8sNet.contivClient.PolicyGet(defaultTenantName, policyName)
 		return err
-	}() != nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
 func (k8sNet *k8sContext) deletePolicy(policyName string) error {
@@ -178,13 +189,10 @@ func (k8sNet *k8sContext) deletePolicy(policyName string) error {
 		return err
 	}
 
-	for func() error {
+	return waitForContivObj("policy "+policyName, true, func() error {
 		_, err := k8sNet.contivClient.PolicyGet(defaultTenantName, policyName)
 		return err
-	}() == nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
 func (k8sNet *k8sContext) createRule(policyName, ruleID, action string) error {
@@ -209,13 +217,10 @@ func (k8sNet *k8sContext) createRule(policyName, ruleID, action string) error {
 		return err
 	}
 
-	for func() error {
+	return waitForContivObj("rule "+policyName+"["+ruleID+"]", false, func() error {
 		_, err := k8sNet.contivClient.RuleGet(defaultTenantName, policyName, ruleID)
 		return err
-	}() != nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
 func (k8sNet *k8sContext) deleteRule(policyName, ruleID string) error {
@@ -230,13 +235,10 @@ func (k8sNet *k8sContext) deleteRule(policyName, ruleID string) error {
 		return err
 	}
 
-	for func() error {
+	return waitForContivObj("rule "+policyName+"["+ruleID+"]", true, func() error {
 		_, err := k8sNet.contivClient.RuleGet(defaultTenantName, policyName, ruleID)
 		return err
-	}() == nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return nil
+	})
 }
 
 func (k8sNet *k8sContext) getIsolationPolicy(annotations map[string]string) string {
@@ -268,7 +270,7 @@ func (k8sNet *k8sContext) updateDefaultIngressPolicy(ns string, action string) {
 
 	var err error
 
-	if err = k8sNet.createNetwork(nwName); err != nil {
+	if err = k8sNet.createNetwork(defaultTenantName, nwName, defaultSubnet); err != nil {
 		npLog.Errorf("failed to update network %s, %s", nwName, err)
 		return
 	}
@@ -278,7 +280,7 @@ func (k8sNet *k8sContext) updateDefaultIngressPolicy(ns string, action string) {
 		return
 	}
 
-	if err = k8sNet.createEpg(nwName, epgName, policyName); err != nil {
+	if err = k8sNet.createEpg(defaultTenantName, nwName, epgName, policyName); err != nil {
 		npLog.Errorf("failed to update EPG %s, %s", epgName, err)
 		return
 	}
@@ -290,7 +292,6 @@ func (k8sNet *k8sContext) updateDefaultIngressPolicy(ns string, action string) {
 }
 
 func (k8sNet *k8sContext) deleteDefaultIngressPolicy(ns string) {
-	nwName := ns + "-" + defaultNetworkName
 	policyName := ns + "-" + defaultPolicyName
 	epgName := ns + "-" + defaultEpgName
 
@@ -301,7 +302,7 @@ func (k8sNet *k8sContext) deleteDefaultIngressPolicy(ns string) {
 		return
 	}
 
-	if err = k8sNet.deleteEpg(nwName, epgName, policyName); err != nil {
+	if err = k8sNet.deleteEpg(defaultTenantName, epgName); err != nil {
 		npLog.Errorf("failed to delete EPG %s, %s", epgName, err)
 		return
 	}
@@ -322,6 +323,7 @@ func (k8sNet *k8sContext) processK8sNamespace(opCode watch.EventType, ns *v1.Nam
 
 	switch opCode {
 	case watch.Added, watch.Modified:
+		k8sNet.mapNamespace(ns.Name)
 		if action == "none" {
 			k8sNet.deleteDefaultIngressPolicy(ns.Name)
 		} else {
@@ -329,6 +331,7 @@ func (k8sNet *k8sContext) processK8sNamespace(opCode watch.EventType, ns *v1.Nam
 		}
 	case watch.Deleted:
 		k8sNet.deleteDefaultIngressPolicy(ns.Name)
+		k8sNet.unmapNamespace(ns.Name)
 	}
 }
 
@@ -421,7 +424,13 @@ func InitK8SServiceWatch(listenURL string, isLeader func() bool) error {
 		npLog.Fatalf("failed to init K8S client, %v", err)
 		return err
 	}
+
 	kubeNet := k8sContext{contivClient: contivClient, k8sClientSet: k8sClientSet, isLeader: isLeader}
+	if err := k8sutils.GetK8SConfig(&kubeNet.contivK8sCfg); err != nil {
+		npLog.Fatalf("failed to read K8S config, %v", err)
+		return err
+	}
+	npLog.Infof("k8s namespace mapping [%s]", kubeNet.contivK8sCfg.NsMapping)
 
 	go kubeNet.handleK8sEvents()
 	return nil
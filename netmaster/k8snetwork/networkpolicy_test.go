@@ -10,6 +10,7 @@ import (
 	"github.com/contiv/netplugin/netmaster/resources"
 	"github.com/contiv/netplugin/state"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/k8sutils"
 	"github.com/contiv/objdb"
 	"github.com/contiv/ofnet"
 	etcdclient "github.com/coreos/etcd/client"
@@ -83,6 +84,74 @@ func TestUpdateDefaultIngressPolicy(t *testing.T) {
 	}
 }
 
+func TestNamespaceMapping(t *testing.T) {
+	tData := []struct {
+		mapping string
+		ns      string
+		tenant  string
+	}{
+		{k8sutils.NsMappingEpg, "team-a", defaultTenantName},
+		{k8sutils.NsMappingTenant, "team-b", "team-b"},
+	}
+
+	defer func() { k8sut.contivK8sCfg = k8sutils.ContivConfig{} }()
+	for _, d := range tData {
+		k8sut.contivK8sCfg = k8sutils.ContivConfig{
+			NsMapping: d.mapping,
+			NsNetwork: "ns-net",
+			NsSubnet:  "10.37.0.0/16",
+		}
+
+		k8sut.mapNamespace(d.ns)
+		epg, err := k8sut.contivClient.EndpointGroupGet(d.tenant, d.ns)
+		assertOnTrue(t, err != nil, fmt.Sprintf("epg %s/%s not created, %s", d.tenant, d.ns, err))
+		assertOnTrue(t, epg.NetworkName != "ns-net", fmt.Sprintf("epg %+v in wrong network", epg))
+
+		k8sut.unmapNamespace(d.ns)
+		_, err = k8sut.contivClient.EndpointGroupGet(d.tenant, d.ns)
+		assertOnTrue(t, err == nil, fmt.Sprintf("epg %s/%s not deleted", d.tenant, d.ns))
+
+		_, err = k8sut.contivClient.TenantGet(d.tenant)
+		if d.mapping == k8sutils.NsMappingTenant {
+			assertOnTrue(t, err == nil, fmt.Sprintf("tenant %s not deleted", d.tenant))
+		} else {
+			assertOnTrue(t, err != nil, fmt.Sprintf("tenant %s deleted", d.tenant))
+		}
+	}
+
+	// kube-system is never mapped
+	k8sut.contivK8sCfg.NsMapping = k8sutils.NsMappingTenant
+	k8sut.mapNamespace("kube-system")
+	_, err := k8sut.contivClient.TenantGet("kube-system")
+	assertOnTrue(t, err == nil, "kube-system namespace mapped")
+}
+
+func TestWaitForContivObj(t *testing.T) {
+	retries := contivObjRetries
+	contivObjRetries = 3
+	defer func() { contivObjRetries = retries }()
+
+	// the object shows up on the second get
+	gets := 0
+	err := waitForContivObj("epg t1/g1", false, func() error {
+		gets++
+		if gets < 2 {
+			return fmt.Errorf("not found")
+		}
+		return nil
+	})
+	assertOnTrue(t, err != nil || gets != 2, fmt.Sprintf("wait for create failed after %d gets, %v", gets, err))
+
+	// the object never goes away
+	gets = 0
+	err = waitForContivObj("epg t1/g1", true, func() error {
+		gets++
+		return nil
+	})
+	assertOnTrue(t, err == nil || gets != contivObjRetries,
+		fmt.Sprintf("wait for delete did not time out after %d gets", gets))
+}
+
 func TestGetIsolationPolicy(t *testing.T) {
 	tData := []struct {
 		annotation string
@@ -182,7 +251,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// Create a new api controller
-	if apiController := objApi.NewAPIController(router, objdbClient, "etcd://127.0.0.1:2379"); apiController == nil {
+	if apiController := objApi.NewAPIController(router, objdbClient); apiController == nil {
 		nptLog.Fatalf("failed to create api controller")
 	}
 
@@ -0,0 +1,101 @@
+package networkpolicy
+
+import (
+	"github.com/contiv/contivmodel/client"
+	"github.com/contiv/netplugin/utils/k8sutils"
+)
+
+func (k8sNet *k8sContext) createTenant(tenantName string) error {
+	npLog.Infof("create tenant %s", tenantName)
+
+	if _, err := k8sNet.contivClient.TenantGet(tenantName); err == nil {
+		return nil
+	}
+
+	if err := k8sNet.contivClient.TenantPost(&client.Tenant{
+		TenantName: tenantName,
+	}); err != nil {
+		npLog.Errorf("failed to create tenant %s, %s", tenantName, err)
+		return err
+	}
+
+	return waitForContivObj("tenant "+tenantName, false, func() error {
+		_, err := k8sNet.contivClient.TenantGet(tenantName)
+		return err
+	})
+}
+
+func (k8sNet *k8sContext) deleteTenant(tenantName string) error {
+	npLog.Infof("delete tenant %s", tenantName)
+
+	if _, err := k8sNet.contivClient.TenantGet(tenantName); err != nil {
+		return nil
+	}
+
+	if err := k8sNet.contivClient.TenantDelete(tenantName); err != nil {
+		npLog.Errorf("failed to delete tenant %s, %s", tenantName, err)
+		return err
+	}
+
+	return waitForContivObj("tenant "+tenantName, true, func() error {
+		_, err := k8sNet.contivClient.TenantGet(tenantName)
+		return err
+	})
+}
+
+// mapNamespace creates the contiv objects a namespace maps to
+func (k8sNet *k8sContext) mapNamespace(ns string) {
+	tenantName, nwName, epgName, ok := k8sutils.GetNamespaceTarget(&k8sNet.contivK8sCfg, ns)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	if k8sNet.contivK8sCfg.NsMapping == k8sutils.NsMappingTenant {
+		if err = k8sNet.createTenant(tenantName); err != nil {
+			npLog.Errorf("failed to map namespace %s to tenant, %s", ns, err)
+			return
+		}
+	}
+
+	if err = k8sNet.createNetwork(tenantName, nwName, k8sNet.contivK8sCfg.NsSubnet); err != nil {
+		npLog.Errorf("failed to map namespace %s to network, %s", ns, err)
+		return
+	}
+
+	if err = k8sNet.createEpg(tenantName, nwName, epgName, ""); err != nil {
+		npLog.Errorf("failed to map namespace %s to epg, %s", ns, err)
+		return
+	}
+}
+
+// unmapNamespace deletes the contiv objects a namespace maps to, the shared
+// network of the default tenant is left in place
+func (k8sNet *k8sContext) unmapNamespace(ns string) {
+	tenantName, nwName, epgName, ok := k8sutils.GetNamespaceTarget(&k8sNet.contivK8sCfg, ns)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	if err = k8sNet.deleteEpg(tenantName, epgName); err != nil {
+		npLog.Errorf("failed to unmap namespace %s from epg, %s", ns, err)
+		return
+	}
+
+	if k8sNet.contivK8sCfg.NsMapping != k8sutils.NsMappingTenant {
+		return
+	}
+
+	if err = k8sNet.deleteNetwork(tenantName, nwName); err != nil {
+		npLog.Errorf("failed to unmap namespace %s from network, %s", ns, err)
+		return
+	}
+
+	if err = k8sNet.deleteTenant(tenantName); err != nil {
+		npLog.Errorf("failed to unmap namespace %s from tenant, %s", ns, err)
+		return
+	}
+}
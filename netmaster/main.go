@@ -34,9 +34,13 @@ type cliOpts struct {
 	debug        bool
 	pluginName   string
 	clusterStore string
+	storeCACert  string
+	storeCert    string
+	storeKey     string
 	listenURL    string
 	controlURL   string
 	clusterMode  string
+	repairState  bool
 	version      bool
 }
 
@@ -69,7 +73,19 @@ func parseOpts(opts *cliOpts) error {
 	flagSet.StringVar(&opts.clusterStore,
 		"cluster-store",
 		"etcd://127.0.0.1:2379",
-		"Etcd or Consul cluster store url.")
+		"Etcd, Consul or Bolt cluster store url, comma separated for multiple members.")
+	flagSet.StringVar(&opts.storeCACert,
+		"cluster-store-cacert",
+		"",
+		"CA certificate of the cluster store, enables TLS")
+	flagSet.StringVar(&opts.storeCert,
+		"cluster-store-cert",
+		"",
+		"Client certificate for the cluster store")
+	flagSet.StringVar(&opts.storeKey,
+		"cluster-store-key",
+		"",
+		"Client key for the cluster store")
 	flagSet.StringVar(&opts.controlURL,
 		"control-url",
 		defaultControlPort,
@@ -82,6 +98,10 @@ func parseOpts(opts *cliOpts) error {
 		"cluster-mode",
 		"docker",
 		"{docker, kubernetes, swarm-mode}")
+	flagSet.BoolVar(&opts.repairState,
+		"repair-state",
+		false,
+		"Repair the state store inconsistencies found at startup")
 	flagSet.BoolVar(&opts.version,
 		"version",
 		false,
@@ -174,6 +194,12 @@ func main() {
 		ControlURL:   opts.controlURL,
 		ClusterStore: opts.clusterStore,
 		ClusterMode:  opts.clusterMode,
+
+		ClusterStoreCACert: opts.storeCACert,
+		ClusterStoreCert:   opts.storeCert,
+		ClusterStoreKey:    opts.storeKey,
+
+		RepairState: opts.repairState,
 	}
 
 	// initialize master daemon
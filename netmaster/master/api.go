@@ -25,6 +25,7 @@ import (
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/contivmodel"
 	"github.com/contiv/netplugin/netmaster/intent"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/utils"
@@ -340,6 +341,13 @@ func CreateEndpointHandler(w http.ResponseWriter, r *http.Request, vars map[stri
 		return nil, err
 	}
 
+	// no new endpoints on a node in maintenance
+	err = checkNodeCordoned(stateDriver, epReq.ConfigEP.Host)
+	if err != nil {
+		log.Errorf("Rejecting endpoint %s. Err: %v", epReq.EndpointID, err)
+		return nil, err
+	}
+
 	// find the network from network id
 	netID := epReq.NetworkName + "." + epReq.TenantName
 	nwCfg := &mastercfg.CfgNetworkState{}
@@ -350,6 +358,18 @@ func CreateEndpointHandler(w http.ResponseWriter, r *http.Request, vars map[stri
 		return nil, err
 	}
 
+	// new endpoints count against the tenant quota
+	existingEp := &mastercfg.CfgEndpointState{}
+	existingEp.StateDriver = stateDriver
+	tenant := contivModel.FindTenant(epReq.TenantName)
+	if tenant != nil && existingEp.Read(getEpName(nwCfg.ID, &epReq.ConfigEP)) != nil {
+		err = checkEndpointQuota(stateDriver, tenant.TenantName, tenant.MaxEndpoints)
+		if err != nil {
+			log.Errorf("Rejecting endpoint %s. Err: %v", epReq.EndpointID, err)
+			return nil, err
+		}
+	}
+
 	// Create the endpoint
 	epCfg, err := CreateEndpoint(stateDriver, nwCfg, &epReq)
 	if err != nil {
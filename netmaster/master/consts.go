@@ -24,4 +24,30 @@ const (
 	GetServiceRESTEndpoint = "service"
 	//GetServicesRESTEndpoint is the REST endpoint to request info of all services
 	GetServicesRESTEndpoint = "services"
+	// GetPolicyStatsRESTEndpoint is the REST endpoint to get the policy rule stats
+	GetPolicyStatsRESTEndpoint = "policystats"
+	// GetPolicyLogsRESTEndpoint is the REST endpoint to get the packets logged by policy rules
+	GetPolicyLogsRESTEndpoint = "policylogs"
+	// GetNodesRESTEndpoint is the REST endpoint to get the inventory of all nodes
+	GetNodesRESTEndpoint = "nodes"
+	// GetNodeRESTEndpoint is the REST endpoint to get the inventory of a node
+	GetNodeRESTEndpoint = "node"
+	// CordonNodeRESTEndpoint is the REST endpoint to stop endpoint creation on a node
+	CordonNodeRESTEndpoint = "cordon"
+	// UncordonNodeRESTEndpoint is the REST endpoint to allow endpoint creation on a node
+	UncordonNodeRESTEndpoint = "uncordon"
+	// DrainNodeRESTEndpoint is the REST endpoint to get the workloads left on a node
+	DrainNodeRESTEndpoint = "drain"
+	// DecommissionNodeRESTEndpoint is the REST endpoint to remove the state of a node
+	DecommissionNodeRESTEndpoint = "decommission"
+	// PolicyCheckRESTEndpoint is the REST endpoint to simulate a flow against the policies
+	PolicyCheckRESTEndpoint = "policycheck"
+	// ObjectLabelsRESTEndpoint is the REST endpoint to get and set the labels of the model objects
+	ObjectLabelsRESTEndpoint = "labels"
+	// PktTagMigrationRESTEndpoint is the REST endpoint to migrate the pkt tag of a network and get its progress
+	PktTagMigrationRESTEndpoint = "pkttagmigration"
+	// PktTagMigrationsRESTEndpoint is the REST endpoint to get the pkt tag migrations of all networks
+	PktTagMigrationsRESTEndpoint = "pkttagmigrations"
+	// EventsRESTEndpoint is the REST endpoint to stream the changes of the endpoints and objects
+	EventsRESTEndpoint = "events"
 )
@@ -0,0 +1,269 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/docknet"
+	"github.com/contiv/netplugin/netmaster/gstate"
+	"github.com/contiv/netplugin/netmaster/intent"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The Check functions run the checks of the matching create, update and
+// delete functions against the current state without changing it, the
+// state store, docker and the agents are left untouched. They return the
+// resources the operation would allocate or release.
+
+// networkResources lists the subnet and packet tags of a network
+func networkResources(nwCfg *mastercfg.CfgNetworkState) []string {
+	res := []string{}
+	if nwCfg.SubnetIP != "" {
+		res = append(res, fmt.Sprintf("subnet %s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen))
+	}
+	if nwCfg.IPv6Subnet != "" {
+		res = append(res, fmt.Sprintf("ipv6 subnet %s/%d", nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen))
+	}
+	if nwCfg.PktTagType == "vlan" {
+		res = append(res, fmt.Sprintf("vlan %d", nwCfg.PktTag))
+	} else if nwCfg.PktTagType == "vxlan" {
+		res = append(res, fmt.Sprintf("vxlan %d", nwCfg.ExtPktTag),
+			fmt.Sprintf("local vlan %d", nwCfg.PktTag))
+	}
+
+	return res
+}
+
+// hasDockNet returns true if a network of the type gets a docker network
+func hasDockNet(nwType string) bool {
+	aci, _ := IsAciConfigured()
+	return nwType != "infra" && !aci && GetClusterMode() == "docker"
+}
+
+// CheckGlobalUpdate checks the update of the global settings
+func CheckGlobalUpdate(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+	gCfg := &gstate.Cfg{}
+	gCfg.StateDriver = stateDriver
+	gCfg.Read("global")
+
+	return checkGlobalUpdate(gCfg, gc)
+}
+
+// CheckNetwork checks the creation of a network, it returns the resources
+// the network would be allocated
+func CheckNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, tenantName string) ([]string, error) {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+	gCfg := gstate.Cfg{}
+	gCfg.StateDriver = stateDriver
+	err := gCfg.Read("")
+	if err != nil {
+		log.Errorf("error reading tenant cfg state. Error: %s", err)
+		return nil, err
+	}
+
+	// creating an existing network does nothing
+	networkID := network.Name + "." + tenantName
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if nwCfg.Read(networkID) == nil {
+		return []string{}, nil
+	}
+
+	nwCfg, err = newNetworkState(network, stateDriver, tenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	reqPktTag := uint(network.PktTag)
+	if nwCfg.PktTagType == "vlan" {
+		pktTag, err := gCfg.CheckVLAN(reqPktTag)
+		if err != nil {
+			return nil, err
+		}
+		nwCfg.PktTag = int(pktTag)
+	} else if nwCfg.PktTagType == "vxlan" {
+		extPktTag, pktTag, err := gCfg.CheckVXLAN(reqPktTag)
+		if err != nil {
+			return nil, err
+		}
+		nwCfg.ExtPktTag = int(extPktTag)
+		nwCfg.PktTag = int(pktTag)
+	}
+
+	res := networkResources(nwCfg)
+	if hasDockNet(network.NwType) {
+		res = append(res, "docker network "+docknet.GetDocknetName(tenantName, network.Name, ""))
+	}
+
+	return res, nil
+}
+
+// CheckNetworkUpdate checks the update of a network, it returns the
+// addresses the network would be given
+func CheckNetworkUpdate(network intent.ConfigNetwork, stateDriver core.StateDriver, tenantName string) ([]string, error) {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+
+	networkID := network.Name + "." + tenantName
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if err := nwCfg.Read(networkID); err != nil {
+		log.Errorf("network %s is not operational", networkID)
+		return nil, err
+	}
+
+	oldCfg := *nwCfg
+	if err := applyNetworkUpdate(nwCfg, network); err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	if nwCfg.SubnetLen != oldCfg.SubnetLen {
+		res = append(res, fmt.Sprintf("subnet %s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen))
+	}
+	if nwCfg.Gateway != oldCfg.Gateway && nwCfg.Gateway != "" {
+		res = append(res, "gateway "+nwCfg.Gateway)
+	}
+	if nwCfg.IPv6Subnet != oldCfg.IPv6Subnet {
+		res = append(res, fmt.Sprintf("ipv6 subnet %s/%d", nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen))
+	}
+	if nwCfg.IPv6Gateway != oldCfg.IPv6Gateway && nwCfg.IPv6Gateway != "" {
+		res = append(res, "ipv6 gateway "+nwCfg.IPv6Gateway)
+	}
+
+	return res, nil
+}
+
+// CheckNetworkDelete checks the deletion of a network, it returns the
+// resources that would be released
+func CheckNetworkDelete(stateDriver core.StateDriver, netID string) ([]string, error) {
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	err := nwCfg.Read(netID)
+	if err != nil {
+		log.Errorf("network %s is not operational", netID)
+		return nil, err
+	}
+
+	if nwCfg.NwType != "infra" && hasActiveEndpoints(nwCfg) {
+		return nil, core.Errorf("Error: Network has active endpoints")
+	}
+
+	res := networkResources(nwCfg)
+	if hasDockNet(nwCfg.NwType) {
+		res = append(res, "docker network "+docknet.GetDocknetName(nwCfg.Tenant, nwCfg.NetworkName, ""))
+	}
+
+	return res, nil
+}
+
+// CheckEndpointGroup checks the creation of an endpoint group, it returns
+// the resources the group would be allocated
+func CheckEndpointGroup(stateDriver core.StateDriver, tenantName, networkName, groupName, ipPool string) ([]string, error) {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+	gCfg := gstate.Cfg{}
+	gCfg.StateDriver = stateDriver
+	err := gCfg.Read(tenantName)
+	if err != nil {
+		log.Errorf("error reading tenant cfg state. Error: %s", err)
+		return nil, err
+	}
+
+	networkID := networkName + "." + tenantName
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	err = nwCfg.Read(networkID)
+	if err != nil {
+		log.Errorf("Could not find network %s. Err: %v", networkID, err)
+		return nil, err
+	}
+
+	if err := checkEndpointGroupPool(nwCfg, ipPool); err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	if len(ipPool) > 0 {
+		res = append(res, "ip-pool "+ipPool)
+	}
+
+	// aci mode allocates a vlan per group
+	aciMode, err := IsAciConfigured()
+	if err != nil {
+		return nil, err
+	}
+	if aciMode {
+		if nwCfg.PktTagType != "vlan" {
+			return nil, errors.New("Network type must be VLAN for ACI mode")
+		}
+		pktTag, err := gCfg.CheckVLAN(0)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, fmt.Sprintf("vlan %d", pktTag))
+	}
+
+	if GetClusterMode() == "docker" {
+		res = append(res, "docker network "+docknet.GetDocknetName(tenantName, networkName, groupName))
+	}
+
+	return res, nil
+}
+
+// CheckEndpointGroupDelete checks the deletion of an endpoint group, it
+// returns the resources that would be released
+func CheckEndpointGroupDelete(stateDriver core.StateDriver, tenantName, groupName string) ([]string, error) {
+	epgKey := mastercfg.GetEndpointGroupKey(groupName, tenantName)
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = stateDriver
+	err := epgCfg.Read(epgKey)
+	if err != nil {
+		log.Errorf("error reading EPG key %s. Error: %s", epgKey, err)
+		return nil, err
+	}
+
+	if epgCfg.EpCount != 0 {
+		return nil, core.Errorf("Error: EPG %s has active endpoints", groupName)
+	}
+
+	res := []string{}
+	if len(epgCfg.IPPool) > 0 {
+		res = append(res, "ip-pool "+epgCfg.IPPool)
+	}
+
+	aciMode, err := IsAciConfigured()
+	if err != nil {
+		return nil, err
+	}
+	if aciMode && epgCfg.PktTagType == "vlan" {
+		res = append(res, fmt.Sprintf("vlan %d", epgCfg.PktTag))
+	}
+
+	if GetClusterMode() == "docker" {
+		res = append(res, "docker network "+docknet.GetDocknetName(tenantName, epgCfg.NetworkName, groupName))
+	}
+
+	return res, nil
+}
@@ -97,6 +97,45 @@ func freeAddrOnErr(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointG
 	}
 }
 
+// TenantEndpointCount returns the number of endpoints in the networks of a tenant
+func TenantEndpointCount(stateDriver core.StateDriver, tenantName string) (int, error) {
+	readNet := &mastercfg.CfgNetworkState{}
+	readNet.StateDriver = stateDriver
+	nwCfgs, err := readNet.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, nw := range nwCfgs {
+		nwCfg := nw.(*mastercfg.CfgNetworkState)
+		if nwCfg.Tenant == tenantName {
+			count += nwCfg.EpCount
+		}
+	}
+
+	return count, nil
+}
+
+// checkEndpointQuota fails if the tenant has reached its endpoint quota,
+// a quota of zero means no limit
+func checkEndpointQuota(stateDriver core.StateDriver, tenantName string, maxEndpoints int) error {
+	if maxEndpoints == 0 {
+		return nil
+	}
+
+	count, err := TenantEndpointCount(stateDriver, tenantName)
+	if err != nil {
+		return err
+	}
+	if count >= maxEndpoints {
+		return core.Errorf("tenant %s has reached its quota of %d endpoints",
+			tenantName, maxEndpoints)
+	}
+
+	return nil
+}
+
 // CreateEndpoint creates an endpoint
 func CreateEndpoint(stateDriver core.StateDriver, nwCfg *mastercfg.CfgNetworkState,
 	epReq *CreateEndpointRequest) (*mastercfg.CfgEndpointState, error) {
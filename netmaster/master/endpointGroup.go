@@ -36,6 +36,35 @@ const maxEpgID = 65535
 // FIXME: hack to allocate unique endpoint group ids
 var globalEpgID = 1
 
+// checkEndpointGroupPool checks the ip pool of a group is a free range of
+// its network
+func checkEndpointGroupPool(nwCfg *mastercfg.CfgNetworkState, ipPool string) error {
+	if len(ipPool) == 0 {
+		return nil
+	}
+
+	if netutils.IsIPv6(ipPool) == true {
+		return fmt.Errorf("ipv6 address pool is not supported for Endpoint Groups")
+	}
+
+	if err := netutils.ValidateNetworkRangeParams(ipPool, nwCfg.SubnetLen); err != nil {
+		return fmt.Errorf("invalid ip-pool %s", ipPool)
+	}
+
+	addrRangeList := strings.Split(ipPool, "-")
+	if _, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[0]); err != nil {
+		return fmt.Errorf("bad ip-pool %s, EPG ip-pool must be a subset of network %s/%d", ipPool, nwCfg.SubnetIP,
+			nwCfg.SubnetLen)
+	}
+	if _, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[1]); err != nil {
+		return fmt.Errorf("bad ip-pool %s, EPG ip-pool must be a subset of network %s/%d", ipPool, nwCfg.SubnetIP,
+			nwCfg.SubnetLen)
+	}
+
+	return netutils.TestIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP,
+		nwCfg.SubnetLen)
+}
+
 // CreateEndpointGroup handles creation of endpoint group
 func CreateEndpointGroup(tenantName, networkName, groupName, ipPool, cfgdTag string) error {
 	var epgID int
@@ -68,29 +97,8 @@ func CreateEndpointGroup(tenantName, networkName, groupName, ipPool, cfgdTag str
 	}
 
 	// check epg range is with in network
-	if len(ipPool) > 0 {
-		if netutils.IsIPv6(ipPool) == true {
-			return fmt.Errorf("ipv6 address pool is not supported for Endpoint Groups")
-		}
-
-		if err = netutils.ValidateNetworkRangeParams(ipPool, nwCfg.SubnetLen); err != nil {
-			return fmt.Errorf("invalid ip-pool %s", ipPool)
-		}
-
-		addrRangeList := strings.Split(ipPool, "-")
-		if _, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[0]); err != nil {
-			return fmt.Errorf("bad ip-pool %s, EPG ip-pool must be a subset of network %s/%d", ipPool, nwCfg.SubnetIP,
-				nwCfg.SubnetLen)
-		}
-		if _, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[1]); err != nil {
-			return fmt.Errorf("bad ip-pool %s, EPG ip-pool must be a subset of network %s/%d", ipPool, nwCfg.SubnetIP,
-				nwCfg.SubnetLen)
-		}
-
-		if err := netutils.TestIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP,
-			nwCfg.SubnetLen); err != nil {
-			return err
-		}
+	if err := checkEndpointGroupPool(nwCfg, ipPool); err != nil {
+		return err
 	}
 
 	// if there is no label given generate one for the epg
@@ -197,6 +205,14 @@ func DeleteEndpointGroup(tenantName, groupName string) error {
 		return core.Errorf("Error: EPG %s has active endpoints", groupName)
 	}
 
+	// remove the isolation rule of the group
+	if epgCfg.Isolation {
+		err = mastercfg.DelEpgIsolation(epgCfg)
+		if err != nil {
+			log.Errorf("Error removing isolation of EPG %s. Err: %v", epgKey, err)
+		}
+	}
+
 	networkID := epgCfg.NetworkName + "." + epgCfg.TenantName
 	nwCfg := &mastercfg.CfgNetworkState{}
 	nwCfg.StateDriver = stateDriver
@@ -287,3 +303,43 @@ func UpdateEndpointGroup(bandwidth, groupName, tenantName string, Dscp, burst in
 	//Write to etcd
 	return epCfg.Write()
 }
+
+// SetEndpointGroupIsolation enables or disables the isolation of the
+// endpoints of a group from each other
+func SetEndpointGroupIsolation(tenantName, groupName string, isolation bool) error {
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	epgKey := mastercfg.GetEndpointGroupKey(groupName, tenantName)
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = stateDriver
+	err = epgCfg.Read(epgKey)
+	if err != nil {
+		log.Errorf("Error finding endpointgroup %s. Err: %v", epgKey, err)
+		return err
+	}
+
+	if epgCfg.Isolation == isolation {
+		return nil
+	}
+
+	// isolation is enforced by the policy rules, not available in ACI mode
+	if !isPolicyEnabled() {
+		return core.Errorf("endpoint group isolation is not supported in ACI mode")
+	}
+
+	if isolation {
+		err = mastercfg.AddEpgIsolation(epgCfg)
+	} else {
+		err = mastercfg.DelEpgIsolation(epgCfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	epgCfg.Isolation = isolation
+	return epgCfg.Write()
+}
@@ -0,0 +1,571 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The event stream turns the changes seen by the state store watches into
+// typed events and streams them to the clients as server-sent events. The
+// recent events are kept so that a client can resume from the id of the
+// last event it received, an id from another stream or too old to be in the
+// buffer gets a reset event telling the client to resync.
+
+// Types of the streamed events
+const (
+	EventTypeEndpoint = "endpoint"
+	EventTypeNetwork  = "network"
+	EventTypeGroup    = "group"
+	EventTypePolicy   = "policy"
+	EventTypeService  = "service"
+	EventTypeProvider = "provider"
+	// EventTypeReset tells a client that events were missed
+	EventTypeReset = "reset"
+)
+
+// Actions of the streamed events
+const (
+	EventActionCreate = "create"
+	EventActionUpdate = "update"
+	EventActionDelete = "delete"
+)
+
+var eventTypes = map[string]bool{
+	EventTypeEndpoint: true,
+	EventTypeNetwork:  true,
+	EventTypeGroup:    true,
+	EventTypePolicy:   true,
+	EventTypeService:  true,
+	EventTypeProvider: true,
+}
+
+var (
+	// eventBufferSize is the number of recent events kept for resuming clients
+	eventBufferSize = 1024
+	// eventKeepalive is the interval of the keepalives sent to idle clients
+	eventKeepalive = 15 * time.Second
+)
+
+// Event is a change of an object of the state store
+type Event struct {
+	ID     string      `json:"id"` // cursor to resume the stream after this event
+	Time   time.Time   `json:"time"`
+	Type   string      `json:"type"`
+	Action string      `json:"action"`
+	Tenant string      `json:"tenant"`
+	Name   string      `json:"name"`
+	Object interface{} `json:"object,omitempty"`
+
+	seq uint64
+}
+
+// EndpointEvent is the object of an endpoint event
+type EndpointEvent struct {
+	EndpointID    string            `json:"endpointID"`
+	Network       string            `json:"network"`
+	EndpointGroup string            `json:"endpointGroup"`
+	IPAddress     string            `json:"ipAddress"`
+	IPv6Address   string            `json:"ipv6Address"`
+	MacAddress    string            `json:"macAddress"`
+	Host          string            `json:"host"`
+	ContainerID   string            `json:"containerID"`
+	ContainerName string            `json:"containerName"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// NetworkEvent is the object of a network event
+type NetworkEvent struct {
+	Network     string `json:"network"`
+	NwType      string `json:"nwType"`
+	PktTagType  string `json:"pktTagType"`
+	PktTag      int    `json:"pktTag"`
+	ExtPktTag   int    `json:"extPktTag"`
+	Subnet      string `json:"subnet"`
+	Gateway     string `json:"gateway"`
+	IPv6Subnet  string `json:"ipv6Subnet"`
+	IPv6Gateway string `json:"ipv6Gateway"`
+}
+
+// GroupEvent is the object of an endpoint group event
+type GroupEvent struct {
+	EndpointGroup string `json:"endpointGroup"`
+	Network       string `json:"network"`
+	PktTag        int    `json:"pktTag"`
+	IPPool        string `json:"ipPool"`
+	Bandwidth     string `json:"bandwidth"`
+	DSCP          int    `json:"dscp"`
+	Isolation     bool   `json:"isolation"`
+}
+
+// PolicyEvent is the object of a policy event, the rules of a policy
+// applied to an endpoint group
+type PolicyEvent struct {
+	EndpointGroup string   `json:"endpointGroup"`
+	Policy        string   `json:"policy"`
+	Rules         []string `json:"rules"`
+}
+
+// ServiceEvent is the object of a service event
+type ServiceEvent struct {
+	Service   string            `json:"service"`
+	Network   string            `json:"network"`
+	IPAddress string            `json:"ipAddress"`
+	Ports     []string          `json:"ports"`
+	Selectors map[string]string `json:"selectors"`
+}
+
+// ProviderEvent is the object of a service provider event
+type ProviderEvent struct {
+	Service   string   `json:"service"`
+	Providers []string `json:"providers"`
+}
+
+// EventFilter selects the events sent to a client, an empty set matches
+// all tenants or types
+type EventFilter struct {
+	Tenants map[string]bool
+	Types   map[string]bool
+}
+
+// Match returns true if the event passes the filter
+func (f *EventFilter) Match(ev *Event) bool {
+	if len(f.Tenants) > 0 && !f.Tenants[ev.Tenant] {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[ev.Type] {
+		return false
+	}
+
+	return true
+}
+
+// parseEventFilter builds the filter of the comma separated tenant and
+// type query parameters
+func parseEventFilter(r *http.Request) (*EventFilter, error) {
+	filter := &EventFilter{
+		Tenants: make(map[string]bool),
+		Types:   make(map[string]bool),
+	}
+
+	query := r.URL.Query()
+	for _, param := range query["tenant"] {
+		for _, tenant := range strings.Split(param, ",") {
+			if tenant != "" {
+				filter.Tenants[tenant] = true
+			}
+		}
+	}
+	for _, param := range query["type"] {
+		for _, evType := range strings.Split(param, ",") {
+			if evType == "" {
+				continue
+			}
+			if !eventTypes[evType] {
+				return nil, core.Errorf("unknown event type %s", evType)
+			}
+			filter.Types[evType] = true
+		}
+	}
+
+	return filter, nil
+}
+
+// eventStream holds the recent events and the channels of the clients
+type eventStream struct {
+	sync.Mutex
+	streamID string
+	seq      uint64
+	events   []*Event
+	subs     map[chan *Event]bool
+}
+
+// events is the event stream of this netmaster
+var events = newEventStream()
+
+func newEventStream() *eventStream {
+	return &eventStream{
+		streamID: strconv.FormatInt(time.Now().UnixNano(), 36),
+		events:   []*Event{},
+		subs:     make(map[chan *Event]bool),
+	}
+}
+
+// publish numbers an event, keeps it for resuming clients and sends it to
+// the clients. A client too slow to keep up is dropped, it resumes from the
+// buffer when it reconnects.
+func (s *eventStream) publish(ev *Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.seq++
+	ev.seq = s.seq
+	ev.ID = fmt.Sprintf("%s-%d", s.streamID, s.seq)
+
+	s.events = append(s.events, ev)
+	if len(s.events) > eventBufferSize {
+		s.events = s.events[len(s.events)-eventBufferSize:]
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Warnf("Dropping slow event stream client")
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a client resuming after the cursor, it returns the
+// buffered events following the cursor and whether the client missed
+// events. An empty cursor only gets the new events.
+func (s *eventStream) subscribe(cursor string) ([]*Event, bool, chan *Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	ch := make(chan *Event, eventBufferSize)
+	s.subs[ch] = true
+
+	if cursor == "" {
+		return nil, false, ch
+	}
+
+	// the cursor is the id of an event of this stream
+	idx := strings.LastIndex(cursor, "-")
+	if idx < 0 || cursor[:idx] != s.streamID {
+		return nil, true, ch
+	}
+	seq, err := strconv.ParseUint(cursor[idx+1:], 10, 64)
+	if err != nil {
+		return nil, true, ch
+	}
+
+	// the events after the cursor must still be buffered
+	oldest := s.seq + 1
+	if len(s.events) > 0 {
+		oldest = s.events[0].seq
+	}
+	if seq > s.seq || seq+1 < oldest {
+		return nil, true, ch
+	}
+
+	backlog := []*Event{}
+	for _, ev := range s.events {
+		if ev.seq > seq {
+			backlog = append(backlog, ev)
+		}
+	}
+
+	return backlog, false, ch
+}
+
+// unsubscribe removes a client
+func (s *eventStream) unsubscribe(ch chan *Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.subs[ch] {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// splitNetworkID returns the tenant and name of a network from its id,
+// the state is read as both names can contain dots
+func splitNetworkID(stateDriver core.StateDriver, netID string) (string, string) {
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if stateDriver != nil && nwCfg.Read(netID) == nil {
+		return nwCfg.Tenant, nwCfg.NetworkName
+	}
+
+	idx := strings.LastIndex(netID, ".")
+	if idx < 0 {
+		return "", netID
+	}
+	return netID[idx+1:], netID[:idx]
+}
+
+// lastField returns the part of a key after its last colon
+func lastField(key string) string {
+	return key[strings.LastIndex(key, ":")+1:]
+}
+
+// stateEvent returns the type, tenant, name and object of the event of a
+// state, ok is false for the states that are not streamed
+func stateEvent(stateDriver core.StateDriver, state core.State) (evType, tenant, name string, obj interface{}, ok bool) {
+	switch st := state.(type) {
+	case *mastercfg.CfgEndpointState:
+		tenant, network := splitNetworkID(stateDriver, st.NetID)
+		group := ""
+		if st.EndpointGroupKey != "" {
+			group = strings.TrimSuffix(st.EndpointGroupKey, ":"+tenant)
+		}
+		return EventTypeEndpoint, tenant, st.ID, &EndpointEvent{
+			EndpointID:    st.EndpointID,
+			Network:       network,
+			EndpointGroup: group,
+			IPAddress:     st.IPAddress,
+			IPv6Address:   st.IPv6Address,
+			MacAddress:    st.MacAddress,
+			Host:          st.HomingHost,
+			ContainerID:   st.ContainerID,
+			ContainerName: st.EPCommonName,
+			Labels:        st.Labels,
+		}, true
+
+	case *mastercfg.CfgNetworkState:
+		nwEvent := &NetworkEvent{
+			Network:     st.NetworkName,
+			NwType:      st.NwType,
+			PktTagType:  st.PktTagType,
+			PktTag:      st.PktTag,
+			ExtPktTag:   st.ExtPktTag,
+			Gateway:     st.Gateway,
+			IPv6Gateway: st.IPv6Gateway,
+		}
+		if st.SubnetIP != "" {
+			nwEvent.Subnet = fmt.Sprintf("%s/%d", st.SubnetIP, st.SubnetLen)
+		}
+		if st.IPv6Subnet != "" {
+			nwEvent.IPv6Subnet = fmt.Sprintf("%s/%d", st.IPv6Subnet, st.IPv6SubnetLen)
+		}
+		return EventTypeNetwork, st.Tenant, st.NetworkName, nwEvent, true
+
+	case *mastercfg.EndpointGroupState:
+		return EventTypeGroup, st.TenantName, st.GroupName, &GroupEvent{
+			EndpointGroup: st.GroupName,
+			Network:       st.NetworkName,
+			PktTag:        st.PktTag,
+			IPPool:        st.IPPool,
+			Bandwidth:     st.Bandwidth,
+			DSCP:          st.DSCP,
+			Isolation:     st.Isolation,
+		}, true
+
+	case *mastercfg.EpgPolicy:
+		// the key is the endpoint group key followed by the policy key
+		fields := strings.Split(st.EpgPolicyKey, ":")
+		if len(fields) != 4 {
+			return "", "", "", nil, false
+		}
+		rules := []string{}
+		for ruleKey := range st.RuleMaps {
+			rules = append(rules, lastField(ruleKey))
+		}
+		sort.Strings(rules)
+		return EventTypePolicy, fields[0], fields[1] + ":" + fields[3], &PolicyEvent{
+			EndpointGroup: fields[1],
+			Policy:        fields[3],
+			Rules:         rules,
+		}, true
+
+	case *mastercfg.CfgServiceLBState:
+		return EventTypeService, st.Tenant, st.ServiceName, &ServiceEvent{
+			Service:   st.ServiceName,
+			Network:   st.Network,
+			IPAddress: st.IPAddress,
+			Ports:     st.Ports,
+			Selectors: st.Selectors,
+		}, true
+
+	case *mastercfg.SvcProvider:
+		// the service name is the service id of the service LB
+		service := strings.TrimSuffix(st.ServiceName, ":"+lastField(st.ServiceName))
+		providers := append([]string{}, st.Providers...)
+		sort.Strings(providers)
+		return EventTypeProvider, lastField(st.ServiceName), service, &ProviderEvent{
+			Service:   service,
+			Providers: providers,
+		}, true
+	}
+
+	return "", "", "", nil, false
+}
+
+// watchEvent builds the event of a state store change, it returns nil when
+// nothing streamed changed
+func watchEvent(stateDriver core.StateDriver, rsp core.WatchState) *Event {
+	action := EventActionUpdate
+	state := rsp.Curr
+	if rsp.Curr == nil {
+		action = EventActionDelete
+		state = rsp.Prev
+	} else if rsp.Prev == nil {
+		action = EventActionCreate
+	}
+	if state == nil {
+		return nil
+	}
+
+	evType, tenant, name, obj, ok := stateEvent(stateDriver, state)
+	if !ok {
+		return nil
+	}
+
+	if action == EventActionUpdate {
+		// most writes only change counters and allocation maps
+		_, _, _, prevObj, _ := stateEvent(stateDriver, rsp.Prev)
+		if reflect.DeepEqual(obj, prevObj) {
+			return nil
+		}
+	}
+
+	return &Event{
+		Time:   time.Now(),
+		Type:   evType,
+		Action: action,
+		Tenant: tenant,
+		Name:   name,
+		Object: obj,
+	}
+}
+
+// processEventWatch publishes the events of a state store watch
+func processEventWatch(stateDriver core.StateDriver, rsps chan core.WatchState) {
+	for rsp := range rsps {
+		if ev := watchEvent(stateDriver, rsp); ev != nil {
+			events.publish(ev)
+		}
+	}
+}
+
+var startEventsOnce sync.Once
+
+// StartEventWatches watches the state store for the streamed events, the
+// watches are started once and outlive a loss of leadership
+func StartEventWatches(stateDriver core.StateDriver) {
+	startEventsOnce.Do(func() {
+		epCfg := &mastercfg.CfgEndpointState{}
+		epCfg.StateDriver = stateDriver
+		nwCfg := &mastercfg.CfgNetworkState{}
+		nwCfg.StateDriver = stateDriver
+		epgCfg := &mastercfg.EndpointGroupState{}
+		epgCfg.StateDriver = stateDriver
+		policyCfg := &mastercfg.EpgPolicy{}
+		policyCfg.StateDriver = stateDriver
+		serviceCfg := &mastercfg.CfgServiceLBState{}
+		serviceCfg.StateDriver = stateDriver
+		providerCfg := &mastercfg.SvcProvider{}
+		providerCfg.StateDriver = stateDriver
+
+		for _, cfg := range []core.WatchableState{epCfg, nwCfg, epgCfg, policyCfg, serviceCfg, providerCfg} {
+			rsps := make(chan core.WatchState)
+			go processEventWatch(stateDriver, rsps)
+			go func(cfg core.WatchableState) {
+				if err := cfg.WatchAll(rsps); err != nil {
+					log.Errorf("Error watching %T for the event stream. Err: %v", cfg, err)
+				}
+			}(cfg)
+		}
+	})
+}
+
+// writeEvent writes an event in the server-sent events format
+func writeEvent(w http.ResponseWriter, ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
+
+// EventStreamHandler streams the events as server-sent events. The stream
+// resumes after the event id of the Last-Event-ID header or of the cursor
+// parameter, the tenant and type parameters filter the events.
+func EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("cursor")
+	}
+
+	backlog, reset, ch := events.subscribe(cursor)
+	defer events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if reset {
+		// the reset event carries no id so the client keeps its cursor
+		// until it gets a new event
+		fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q,\"cursor\":%q}\n\n",
+			EventTypeReset, EventTypeReset, cursor)
+	}
+	for _, ev := range backlog {
+		if filter.Match(ev) {
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				// dropped for being too slow, the client resumes from its cursor
+				return
+			}
+			if !filter.Match(ev) {
+				continue
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,98 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// GetObjectLabels returns the labels of all labelled objects
+func GetObjectLabels(stateDriver core.StateDriver) ([]*mastercfg.ObjectLabelState, error) {
+	labelCfg := &mastercfg.ObjectLabelState{}
+	labelCfg.StateDriver = stateDriver
+	labelList, err := labelCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	labels := []*mastercfg.ObjectLabelState{}
+	for _, labelState := range labelList {
+		labels = append(labels, labelState.(*mastercfg.ObjectLabelState))
+	}
+
+	return labels, nil
+}
+
+// SetObjectLabels replaces the labels of an object, no labels removes them
+func SetObjectLabels(stateDriver core.StateDriver, labelCfg *mastercfg.ObjectLabelState) error {
+	if labelCfg.ObjType == "" || labelCfg.ObjKey == "" {
+		return core.Errorf("object type and key are required")
+	}
+
+	labelCfg.ID = mastercfg.GetObjectLabelKey(labelCfg.ObjType, labelCfg.ObjKey)
+	labelCfg.StateDriver = stateDriver
+	if len(labelCfg.Labels) == 0 {
+		if err := labelCfg.Clear(); err != nil && core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error clearing labels of %s. Err: %v", labelCfg.ID, err)
+			return err
+		}
+		return nil
+	}
+
+	if err := labelCfg.Write(); err != nil {
+		log.Errorf("Error writing labels of %s. Err: %v", labelCfg.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetObjectLabelsHandler returns the labels of all labelled objects
+func GetObjectLabelsHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetObjectLabels(stateDriver)
+}
+
+// SetObjectLabelsHandler replaces the labels of an object
+func SetObjectLabelsHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	labelCfg := &mastercfg.ObjectLabelState{}
+	if err := json.NewDecoder(r.Body).Decode(labelCfg); err != nil {
+		log.Errorf("Error decoding SetObjectLabelsHandler. Err %v", err)
+		return nil, err
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SetObjectLabels(stateDriver, labelCfg); err != nil {
+		return nil, err
+	}
+
+	return labelCfg, nil
+}
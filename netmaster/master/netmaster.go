@@ -181,6 +181,41 @@ func CreateGlobal(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
 	return masterGc.Write()
 }
 
+// checkGlobalUpdate checks the fabric mode and that the new vlan and vxlan
+// ranges include the tags in use
+func checkGlobalUpdate(gCfg *gstate.Cfg, gc *intent.ConfigGlobal) error {
+	if gc.NwInfraType != "" {
+		switch gc.NwInfraType {
+		case "default", "aci", "aci-opflex":
+			// These values are acceptable.
+		default:
+			return errors.New("Invalid fabric mode")
+		}
+	}
+
+	if gc.VLANs != "" {
+		_, vlansInUse := gCfg.GetVlansInUse()
+		if !gCfg.CheckInBitRange(gc.VLANs, vlansInUse, "vlan") {
+			return fmt.Errorf("cannot update the vlan range due to existing vlans %s", vlansInUse)
+		}
+		if _, err := netutils.ParseTagRanges(gc.VLANs, "vlan"); err != nil {
+			return err
+		}
+	}
+
+	if gc.VXLANs != "" {
+		_, vxlansInUse := gCfg.GetVxlansInUse()
+		if !gCfg.CheckInBitRange(gc.VXLANs, vxlansInUse, "vxlan") {
+			return fmt.Errorf("cannot update the vxlan range due to existing vxlans %s", vxlansInUse)
+		}
+		if _, err := netutils.ParseTagRanges(gc.VXLANs, "vxlan"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // UpdateGlobal updates the global state
 func UpdateGlobal(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
 	log.Infof("Received global update with intent {%v}", gc)
@@ -198,41 +233,20 @@ func UpdateGlobal(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
 	gCfg.StateDriver = stateDriver
 	gCfg.Read("global")
 
-	_, vlansInUse := gCfg.GetVlansInUse()
-	_, vxlansInUse := gCfg.GetVxlansInUse()
-
 	// check for valid values
+	if err := checkGlobalUpdate(gCfg, gc); err != nil {
+		return err
+	}
+
 	if gc.NwInfraType != "" {
-		switch gc.NwInfraType {
-		case "default", "aci", "aci-opflex":
-			// These values are acceptable.
-		default:
-			return errors.New("Invalid fabric mode")
-		}
 		masterGc.NwInfraType = gc.NwInfraType
 	}
 	if gc.VLANs != "" {
-
-		if !gCfg.CheckInBitRange(gc.VLANs, vlansInUse, "vlan") {
-			return fmt.Errorf("cannot update the vlan range due to existing vlans %s", vlansInUse)
-		}
-		_, err := netutils.ParseTagRanges(gc.VLANs, "vlan")
-		if err != nil {
-			return err
-		}
 		gCfg.Auto.VLANs = gc.VLANs
 		gcfgUpdateList = append(gcfgUpdateList, "vlan")
 	}
 
 	if gc.VXLANs != "" {
-		if !gCfg.CheckInBitRange(gc.VXLANs, vxlansInUse, "vxlan") {
-			return fmt.Errorf("cannot update the vxlan range due to existing vxlans %s", vxlansInUse)
-		}
-
-		_, err = netutils.ParseTagRanges(gc.VXLANs, "vxlan")
-		if err != nil {
-			return err
-		}
 		gCfg.Auto.VXLANs = gc.VXLANs
 		gcfgUpdateList = append(gcfgUpdateList, "vxlan")
 	}
@@ -16,9 +16,13 @@ limitations under the License.
 package master
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -716,3 +720,714 @@ func TestGetNwAndEpgFromAddrReq(t *testing.T) {
 		assertOnTrue(t, e != d.epgName, fmt.Sprintf("epgname mismatch [%s] != [%s]", e, d.epgName))
 	}
 }
+
+func TestNetworkUpdate(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                      : "tenant-one",
+        "DefaultNetType"            : "vlan",
+        "Networks"  : [{
+            "Name"                : "orange",
+            "SubnetCIDR"          : "11.1.1.0/25",
+            "Gateway"             : "11.1.1.126",
+            "Endpoints" : [{
+                "Container"       : "myContainer1"
+            },
+            {
+                "Container"       : "myContainer2"
+            }]
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+
+	networkID := "orange.tenant-one"
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Read(networkID); err != nil {
+		t.Fatalf("unable to locate network: %s", networkID)
+	}
+
+	network := intent.ConfigNetwork{
+		Name:       "orange",
+		NwType:     nwCfg.NwType,
+		PktTagType: nwCfg.PktTagType,
+		SubnetCIDR: "11.1.1.0/25",
+		Gateway:    "11.1.1.2",
+	}
+
+	// the new gateway can't be an allocated address
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("gateway update to an allocated address succeeded")
+	}
+
+	// the subnet can only be widened from the same subnet address
+	network.Gateway = "11.1.1.126"
+	network.SubnetCIDR = "11.1.1.0/26"
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("subnet shrink succeeded")
+	}
+	network.SubnetCIDR = "11.1.0.0/23"
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("subnet address change succeeded")
+	}
+
+	network.PktTagType = "vxlan"
+	network.SubnetCIDR = "11.1.1.0/24"
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("encap change succeeded")
+	}
+
+	network.PktTagType = nwCfg.PktTagType
+	network.Gateway = "11.1.1.254"
+	network.IPv6SubnetCIDR = "2016:0630::/64"
+	network.IPv6Gateway = "2016:0630::1"
+	network.CfgdTag = "orange-tag"
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err != nil {
+		t.Fatalf("error updating network: %v", err)
+	}
+
+	if err := nwCfg.Read(networkID); err != nil {
+		t.Fatalf("unable to locate network: %s", networkID)
+	}
+	if nwCfg.SubnetLen != 24 || nwCfg.Gateway != "11.1.1.254" ||
+		nwCfg.IPv6Subnet != "2016:0630::" || nwCfg.IPv6Gateway != "2016:0630::1" ||
+		nwCfg.NetworkTag != "orange-tag" {
+		t.Fatalf("network not updated: %+v", nwCfg)
+	}
+
+	// the old gateway and broadcast address are released
+	expectedAllocedIPs := "11.1.1.1-11.1.1.2, 11.1.1.254"
+	if allocated := ListAllocatedIPs(nwCfg); allocated != expectedAllocedIPs {
+		t.Fatalf("got allocated IPs '%s' expected '%s'", allocated, expectedAllocedIPs)
+	}
+
+	// the IPv6 subnet can't be changed once added
+	network.IPv6SubnetCIDR = "2016:0631::/64"
+	network.IPv6Gateway = ""
+	if err := UpdateNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("IPv6 subnet change succeeded")
+	}
+}
+
+func TestResolvePolicyCheckEndpoint(t *testing.T) {
+	newEp := func(id, name, group, ip string) *mastercfg.CfgEndpointState {
+		ep := &mastercfg.CfgEndpointState{
+			EPCommonName: name,
+			ContainerID:  "0123456789abcdef" + id,
+			ServiceName:  group,
+			IPAddress:    ip,
+			NetID:        "net1.default",
+		}
+		ep.ID = id
+		return ep
+	}
+	eps := []*mastercfg.CfgEndpointState{
+		newEp("1", "web1", "web", "10.1.1.2"),
+		newEp("2", "", "", "10.1.1.3"),
+	}
+
+	pcEp, err := resolvePolicyCheckEndpoint("10.1.1.2", "default", eps)
+	if err != nil || pcEp.Endpoint != "web1" || pcEp.EndpointGroup != "web" {
+		t.Fatalf("unexpected resolution of ip: %+v, err: %v", pcEp, err)
+	}
+	pcEp, err = resolvePolicyCheckEndpoint("20.1.1.1", "default", eps)
+	if err != nil || pcEp.Endpoint != "" || pcEp.IPAddress != "20.1.1.1" {
+		t.Fatalf("unexpected resolution of external ip: %+v, err: %v", pcEp, err)
+	}
+	pcEp, err = resolvePolicyCheckEndpoint("web1", "default", eps)
+	if err != nil || pcEp.IPAddress != "10.1.1.2" {
+		t.Fatalf("unexpected resolution of endpoint name: %+v, err: %v", pcEp, err)
+	}
+	pcEp, err = resolvePolicyCheckEndpoint("0123456789ab", "default", eps)
+	if err != nil || pcEp.IPAddress != "10.1.1.2" {
+		t.Fatalf("unexpected resolution of container id: %+v, err: %v", pcEp, err)
+	}
+	if _, err = resolvePolicyCheckEndpoint("unknown", "default", eps); err == nil {
+		t.Fatalf("unknown endpoint was resolved")
+	}
+}
+
+func TestNodeMaintenance(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                  : "tenant-one",
+        "Networks"  : [{
+            "Name"              : "orange",
+            "SubnetCIDR"        : "10.1.1.1/24",
+            "Gateway"           : "10.1.1.254",
+            "Endpoints" : [
+            {
+                "Container"     : "myContainer1",
+                "Host"          : "host1"
+            },
+            {
+                "Container"     : "myContainer2",
+                "Host"          : "host1"
+            },
+            {
+                "Container"     : "myContainer3",
+                "Host"          : "host2"
+            }
+            ]
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+
+	if _, err := DecommissionNode(fakeDriver, "host1"); err == nil {
+		t.Fatalf("node was decommissioned without being cordoned")
+	}
+
+	report, err := CordonNode(fakeDriver, "host1")
+	if err != nil {
+		t.Fatalf("error cordoning node: %v", err)
+	}
+	if !report.Cordoned || len(report.Workloads) != 2 {
+		t.Fatalf("unexpected cordon report: %+v", report)
+	}
+	if err := checkNodeCordoned(fakeDriver, "host1"); err == nil {
+		t.Fatalf("endpoint creation allowed on a cordoned node")
+	}
+	if err := checkNodeCordoned(fakeDriver, "host2"); err != nil {
+		t.Fatalf("endpoint creation rejected on a schedulable node: %v", err)
+	}
+
+	bgpCfg := &mastercfg.CfgBgpState{Hostname: "host1"}
+	bgpCfg.ID = "host1"
+	bgpCfg.StateDriver = fakeDriver
+	if err := bgpCfg.Write(); err != nil {
+		t.Fatalf("error writing bgp config: %v", err)
+	}
+	nodeCfg := &mastercfg.NodeState{Hostname: "host1", VtepIP: "192.168.2.10"}
+	nodeCfg.ID = "host1"
+	nodeCfg.StateDriver = fakeDriver
+	if err := nodeCfg.Write(); err != nil {
+		t.Fatalf("error writing node state: %v", err)
+	}
+
+	report, err = DecommissionNode(fakeDriver, "host1")
+	if err != nil {
+		t.Fatalf("error decommissioning node: %v", err)
+	}
+	if !report.Decommissioned || len(report.Workloads) != 0 {
+		t.Fatalf("unexpected decommission report: %+v", report)
+	}
+
+	verifyKeysDoNotExist(t, []string{"myContainer1", "myContainer2", "bgp/host1", "nodes/host1"})
+	verifyKeys(t, []string{"myContainer3"})
+
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Read("orange.tenant-one"); err != nil {
+		t.Fatalf("error reading network: %v", err)
+	}
+	if nwCfg.EpCount != 1 {
+		t.Fatalf("got %d endpoints in network, expected 1", nwCfg.EpCount)
+	}
+
+	maintCfg := &mastercfg.NodeMaintenanceState{}
+	maintCfg.StateDriver = fakeDriver
+	if err := maintCfg.Read("host1"); err != nil || maintCfg.VtepIP != "192.168.2.10" {
+		t.Fatalf("unexpected maintenance state: %+v, err: %v", maintCfg, err)
+	}
+}
+
+func TestObjectLabels(t *testing.T) {
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	labelCfg := &mastercfg.ObjectLabelState{
+		ObjType: "network",
+		ObjKey:  "default:net1",
+		Labels:  map[string]string{"managed-by": "netctl"},
+	}
+	if err := SetObjectLabels(fakeDriver, labelCfg); err != nil {
+		t.Fatalf("error setting labels: %v", err)
+	}
+	if err := SetObjectLabels(fakeDriver, &mastercfg.ObjectLabelState{ObjType: "network"}); err == nil {
+		t.Fatalf("labels set without an object key")
+	}
+
+	labels, err := GetObjectLabels(fakeDriver)
+	if err != nil {
+		t.Fatalf("error getting labels: %v", err)
+	}
+	if len(labels) != 1 || labels[0].ObjKey != "default:net1" || labels[0].Labels["managed-by"] != "netctl" {
+		t.Fatalf("unexpected labels: %+v", labels)
+	}
+
+	// no labels removes them
+	labelCfg.Labels = nil
+	if err := SetObjectLabels(fakeDriver, labelCfg); err != nil {
+		t.Fatalf("error removing labels: %v", err)
+	}
+	labels, err = GetObjectLabels(fakeDriver)
+	if err != nil || len(labels) != 0 {
+		t.Fatalf("labels not removed: %+v, err: %v", labels, err)
+	}
+}
+
+func TestEndpointQuota(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                  : "tenant-one",
+        "Networks"  : [{
+            "Name"              : "orange",
+            "SubnetCIDR"        : "10.1.1.1/24",
+            "Gateway"           : "10.1.1.254",
+            "Endpoints" : [
+            {
+                "Container"     : "myContainer1",
+                "Host"          : "host1"
+            },
+            {
+                "Container"     : "myContainer2",
+                "Host"          : "host2"
+            }
+            ]
+        },
+        {
+            "Name"              : "purple",
+            "SubnetCIDR"        : "10.1.2.1/24",
+            "Gateway"           : "10.1.2.254",
+            "Endpoints" : [
+            {
+                "Container"     : "myContainer3",
+                "Host"          : "host1"
+            }
+            ]
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+
+	count, err := TenantEndpointCount(fakeDriver, "tenant-one")
+	if err != nil || count != 3 {
+		t.Fatalf("got %d endpoints in tenant, expected 3. Err: %v", count, err)
+	}
+	count, err = TenantEndpointCount(fakeDriver, "tenant-two")
+	if err != nil || count != 0 {
+		t.Fatalf("got %d endpoints in unknown tenant, expected 0. Err: %v", count, err)
+	}
+
+	if err := checkEndpointQuota(fakeDriver, "tenant-one", 0); err != nil {
+		t.Fatalf("endpoint rejected without a quota: %v", err)
+	}
+	if err := checkEndpointQuota(fakeDriver, "tenant-one", 4); err != nil {
+		t.Fatalf("endpoint rejected below the quota: %v", err)
+	}
+	if err := checkEndpointQuota(fakeDriver, "tenant-one", 3); err == nil {
+		t.Fatalf("endpoint allowed over the quota")
+	}
+}
+
+// readStore returns a copy of the fake state store
+func readStore(t *testing.T) map[string]string {
+	store := make(map[string]string)
+	for key := range fakeDriver.TestState {
+		value, err := fakeDriver.Read(key)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", key, err)
+		}
+		store[key] = string(value)
+	}
+	return store
+}
+
+func TestDryRunChecks(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                      : "tenant-one",
+        "DefaultNetType"            : "vlan",
+        "Networks"  : [{
+            "Name"                : "orange",
+            "PktTagType"          : "vlan",
+            "PktTag"              : 10,
+            "SubnetCIDR"          : "11.1.1.0/24",
+            "Gateway"             : "11.1.1.254",
+            "Endpoints" : [{
+                "Container"       : "myContainer1"
+            }]
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+	before := readStore(t)
+
+	network := intent.ConfigNetwork{
+		Name:       "blue",
+		PktTagType: "vlan",
+		PktTag:     11,
+		SubnetCIDR: "12.1.1.0/24",
+		Gateway:    "12.1.1.254",
+	}
+	res, err := CheckNetwork(network, fakeDriver, "tenant-one")
+	if err != nil {
+		t.Fatalf("error checking network: %v", err)
+	}
+	if strings.Join(res, ", ") != "subnet 12.1.1.0/24, vlan 11" {
+		t.Fatalf("unexpected network resources: %v", res)
+	}
+
+	// the vlan of orange is taken, the gateway must be in the subnet
+	network.PktTag = 10
+	if _, err := CheckNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("network check with a vlan in use succeeded")
+	}
+	network.PktTag = 0
+	network.Gateway = "13.1.1.254"
+	if _, err := CheckNetwork(network, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("network check with a gateway outside the subnet succeeded")
+	}
+
+	update := intent.ConfigNetwork{
+		Name:       "orange",
+		PktTagType: "vlan",
+		SubnetCIDR: "11.1.0.0/23",
+		Gateway:    "11.1.1.254",
+	}
+	if _, err := CheckNetworkUpdate(update, fakeDriver, "tenant-one"); err == nil {
+		t.Fatalf("network update check with a subnet address change succeeded")
+	}
+	update.SubnetCIDR = "11.1.1.0/24"
+	update.Gateway = "11.1.1.253"
+	res, err = CheckNetworkUpdate(update, fakeDriver, "tenant-one")
+	if err != nil || strings.Join(res, ", ") != "gateway 11.1.1.253" {
+		t.Fatalf("unexpected network update check: %v, err: %v", res, err)
+	}
+
+	if _, err := CheckNetworkDelete(fakeDriver, "orange.tenant-one"); err == nil {
+		t.Fatalf("network delete check with active endpoints succeeded")
+	}
+
+	if _, err := CheckEndpointGroup(fakeDriver, "tenant-one", "orange", "epg1",
+		"12.1.1.10-12.1.1.20"); err == nil {
+		t.Fatalf("group check with a pool outside the network succeeded")
+	}
+	res, err = CheckEndpointGroup(fakeDriver, "tenant-one", "orange", "epg1",
+		"11.1.1.10-11.1.1.20")
+	if err != nil || strings.Join(res, ", ") != "ip-pool 11.1.1.10-11.1.1.20" {
+		t.Fatalf("unexpected group check: %v, err: %v", res, err)
+	}
+
+	// nothing was allocated or written
+	after := readStore(t)
+	if len(after) != len(before) {
+		t.Fatalf("checks changed the number of keys from %d to %d", len(before), len(after))
+	}
+	for key, value := range before {
+		if after[key] != value {
+			t.Fatalf("checks changed %s from %s to %s", key, value, after[key])
+		}
+	}
+}
+
+// ackPktTags publishes the pkt tags of a network on a node
+func ackPktTags(t *testing.T, netID, host string, pktTag, extPktTag int, ackErr string) {
+	hostState := &mastercfg.PktTagHostState{
+		NetworkID: netID,
+		Hostname:  host,
+		PktTag:    pktTag,
+		ExtPktTag: extPktTag,
+		Error:     ackErr,
+	}
+	hostState.ID = mastercfg.PktTagHostStateID(netID, host)
+	hostState.StateDriver = fakeDriver
+	if err := hostState.Write(); err != nil {
+		t.Fatalf("error writing pkt tags of %s: %v", host, err)
+	}
+}
+
+// verifyNetworkPktTag checks the vlan of a network and its endpoint group
+func verifyNetworkPktTag(t *testing.T, pktTag int) {
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Read("orange.tenant-one"); err != nil || nwCfg.PktTag != pktTag {
+		t.Fatalf("network has vlan %d, expected %d. Err: %v", nwCfg.PktTag, pktTag, err)
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = fakeDriver
+	if err := epgCfg.Read(mastercfg.GetEndpointGroupKey("web", "tenant-one")); err != nil ||
+		epgCfg.PktTag != pktTag {
+		t.Fatalf("endpoint group has vlan %d, expected %d. Err: %v", epgCfg.PktTag, pktTag, err)
+	}
+}
+
+func TestPktTagMigration(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                  : "tenant-one",
+        "DefaultNetType"        : "vlan",
+        "Networks"  : [{
+            "Name"              : "orange",
+            "PktTagType"        : "vlan",
+            "PktTag"            : 10,
+            "SubnetCIDR"        : "10.1.1.1/24",
+            "Gateway"           : "10.1.1.254",
+            "Endpoints" : [
+            {
+                "Container"     : "myContainer1",
+                "Host"          : "host1"
+            },
+            {
+                "Container"     : "myContainer2",
+                "Host"          : "host2"
+            }
+            ]
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+
+	_, err := resources.NewStateResourceManager(fakeDriver)
+	if err != nil {
+		log.Fatalf("state store initialization failed. Error: %s", err)
+	}
+	defer func() { resources.ReleaseStateResourceManager() }()
+
+	epgCfg := &mastercfg.EndpointGroupState{
+		GroupName:   "web",
+		TenantName:  "tenant-one",
+		NetworkName: "orange",
+		PktTagType:  "vlan",
+		PktTag:      10,
+	}
+	epgCfg.ID = mastercfg.GetEndpointGroupKey("web", "tenant-one")
+	epgCfg.StateDriver = fakeDriver
+	if err := epgCfg.Write(); err != nil {
+		t.Fatalf("error writing endpoint group: %v", err)
+	}
+
+	gCfg := &gstate.Cfg{}
+	gCfg.StateDriver = fakeDriver
+	netID := "orange.tenant-one"
+
+	if _, err := startPktTagMigration(fakeDriver, netID, 10); err == nil {
+		t.Fatalf("migration to the current vlan succeeded")
+	}
+
+	// all nodes move to the new vlan
+	migCfg, err := startPktTagMigration(fakeDriver, netID, 20)
+	if err != nil {
+		t.Fatalf("error starting migration: %v", err)
+	}
+	if migCfg.OldPktTag != 10 || migCfg.NewPktTag != 20 || len(migCfg.Hosts) != 2 {
+		t.Fatalf("unexpected migration: %+v", migCfg)
+	}
+	verifyNetworkPktTag(t, 20)
+	if _, err := startPktTagMigration(fakeDriver, netID, 30); err == nil {
+		t.Fatalf("second migration of the network started")
+	}
+	if err := DeleteNetworkID(fakeDriver, netID); err == nil ||
+		!strings.Contains(err.Error(), "migrating") {
+		t.Fatalf("network deleted during its migration. Err: %v", err)
+	}
+
+	ackPktTags(t, netID, "host1", 20, 0, "")
+	done, err := advancePktTagMigration(fakeDriver, netID)
+	if err != nil || done {
+		t.Fatalf("migration over with a pending node. Err: %v", err)
+	}
+	migCfg, err = GetPktTagMigration(fakeDriver, netID)
+	if err != nil || migCfg.Hosts["host1"] != mastercfg.PktTagHostMigrated ||
+		migCfg.Hosts["host2"] != mastercfg.PktTagHostPending {
+		t.Fatalf("unexpected migration progress: %+v. Err: %v", migCfg, err)
+	}
+
+	ackPktTags(t, netID, "host2", 20, 0, "")
+	done, err = advancePktTagMigration(fakeDriver, netID)
+	if err != nil || !done {
+		t.Fatalf("migration not over once the nodes moved. Err: %v", err)
+	}
+	migCfg, err = GetPktTagMigration(fakeDriver, netID)
+	if err != nil || migCfg.Status != mastercfg.PktTagMigrated {
+		t.Fatalf("unexpected migration: %+v. Err: %v", migCfg, err)
+	}
+	verifyNetworkPktTag(t, 20)
+	if _, err := gCfg.CheckVLAN(10); err != nil {
+		t.Fatalf("old vlan was not released: %v", err)
+	}
+
+	// a node fails to move to the new vlan
+	if _, err := startPktTagMigration(fakeDriver, netID, 30); err != nil {
+		t.Fatalf("error starting migration: %v", err)
+	}
+	ackPktTags(t, netID, "host1", 30, 0, "")
+	ackPktTags(t, netID, "host2", 30, 0, "ovs error")
+	done, err = advancePktTagMigration(fakeDriver, netID)
+	if err != nil || !done {
+		t.Fatalf("migration not over after a node failed. Err: %v", err)
+	}
+	migCfg, err = GetPktTagMigration(fakeDriver, netID)
+	if err != nil || migCfg.Status != mastercfg.PktTagRolledBack ||
+		!strings.Contains(migCfg.Error, "host2: ovs error") {
+		t.Fatalf("unexpected migration: %+v. Err: %v", migCfg, err)
+	}
+	verifyNetworkPktTag(t, 20)
+	if _, err := gCfg.CheckVLAN(30); err != nil {
+		t.Fatalf("new vlan was not released: %v", err)
+	}
+
+	// the nodes do not answer
+	if _, err := startPktTagMigration(fakeDriver, netID, 40); err != nil {
+		t.Fatalf("error starting migration: %v", err)
+	}
+	migCfg, err = GetPktTagMigration(fakeDriver, netID)
+	if err != nil {
+		t.Fatalf("error reading migration: %v", err)
+	}
+	migCfg.StartTime = time.Now().Add(-2 * pktTagMigrationTimeout)
+	if err := migCfg.Write(); err != nil {
+		t.Fatalf("error writing migration: %v", err)
+	}
+	done, err = advancePktTagMigration(fakeDriver, netID)
+	if err != nil || !done {
+		t.Fatalf("migration not over after its timeout. Err: %v", err)
+	}
+	migCfg, err = GetPktTagMigration(fakeDriver, netID)
+	if err != nil || migCfg.Status != mastercfg.PktTagRolledBack ||
+		!strings.Contains(migCfg.Error, "timed out waiting for nodes host1, host2") {
+		t.Fatalf("unexpected migration: %+v. Err: %v", migCfg, err)
+	}
+	verifyNetworkPktTag(t, 20)
+	if _, err := gCfg.CheckVLAN(40); err != nil {
+		t.Fatalf("new vlan was not released: %v", err)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	nwCfg := &mastercfg.CfgNetworkState{
+		Tenant:      "tenant.one",
+		NetworkName: "orange",
+		PktTagType:  "vlan",
+		PktTag:      10,
+		SubnetIP:    "10.1.1.0",
+		SubnetLen:   24,
+	}
+	nwCfg.ID = "orange.tenant.one"
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Write(); err != nil {
+		t.Fatalf("error writing network: %v", err)
+	}
+
+	// endpoint created, network allocation updated, endpoint deleted
+	epCfg := &mastercfg.CfgEndpointState{
+		NetID:            nwCfg.ID,
+		EndpointID:       "ep1",
+		EndpointGroupKey: "web:tenant.one",
+		IPAddress:        "10.1.1.1",
+		MacAddress:       "02:02:0a:01:01:01",
+		HomingHost:       "host1",
+		EPCommonName:     "web1",
+	}
+	epCfg.ID = "orange.tenant.one-ep1"
+	ev := watchEvent(fakeDriver, core.WatchState{Curr: epCfg})
+	if ev == nil || ev.Type != EventTypeEndpoint || ev.Action != EventActionCreate ||
+		ev.Tenant != "tenant.one" {
+		t.Fatalf("unexpected endpoint event: %+v", ev)
+	}
+	epEvent := ev.Object.(*EndpointEvent)
+	if epEvent.Network != "orange" || epEvent.EndpointGroup != "web" || epEvent.Host != "host1" ||
+		epEvent.IPAddress != "10.1.1.1" || epEvent.ContainerName != "web1" {
+		t.Fatalf("unexpected endpoint event object: %+v", epEvent)
+	}
+
+	prevNw := *nwCfg
+	nwCfg.EpCount = 1
+	if ev := watchEvent(fakeDriver, core.WatchState{Curr: nwCfg, Prev: &prevNw}); ev != nil {
+		t.Fatalf("event on an allocation change: %+v", ev)
+	}
+	prevNw = *nwCfg
+	nwCfg.PktTag = 20
+	ev = watchEvent(fakeDriver, core.WatchState{Curr: nwCfg, Prev: &prevNw})
+	if ev == nil || ev.Action != EventActionUpdate || ev.Object.(*NetworkEvent).PktTag != 20 {
+		t.Fatalf("unexpected network event: %+v", ev)
+	}
+
+	ev = watchEvent(fakeDriver, core.WatchState{Prev: epCfg})
+	if ev == nil || ev.Action != EventActionDelete || ev.Name != epCfg.ID {
+		t.Fatalf("unexpected endpoint delete event: %+v", ev)
+	}
+
+	svcProvider := &mastercfg.SvcProvider{ServiceName: "db:tenant-two", Providers: []string{"10.1.1.3", "10.1.1.2"}}
+	ev = watchEvent(fakeDriver, core.WatchState{Curr: svcProvider})
+	if ev == nil || ev.Type != EventTypeProvider || ev.Tenant != "tenant-two" || ev.Name != "db" ||
+		strings.Join(ev.Object.(*ProviderEvent).Providers, ",") != "10.1.1.2,10.1.1.3" {
+		t.Fatalf("unexpected provider event: %+v", ev)
+	}
+
+	// resuming clients get the buffered events after their cursor
+	events = newEventStream()
+	defer func() { events = newEventStream() }()
+	bufSize := eventBufferSize
+	eventBufferSize = 3
+	defer func() { eventBufferSize = bufSize }()
+
+	for i := 1; i <= 4; i++ {
+		tenant := "tenant-one"
+		if i%2 == 0 {
+			tenant = "tenant-two"
+		}
+		events.publish(&Event{Type: EventTypeNetwork, Tenant: tenant, Name: fmt.Sprintf("net%d", i)})
+	}
+
+	backlog, reset, ch := events.subscribe(events.streamID + "-2")
+	events.unsubscribe(ch)
+	if reset || len(backlog) != 2 || backlog[0].Name != "net3" || backlog[1].Name != "net4" {
+		t.Fatalf("unexpected backlog %+v, reset %v", backlog, reset)
+	}
+	for _, cursor := range []string{events.streamID + "-0", "otherstream-3", events.streamID + "-9", "bad"} {
+		_, reset, ch = events.subscribe(cursor)
+		events.unsubscribe(ch)
+		if !reset {
+			t.Fatalf("no reset for cursor %s", cursor)
+		}
+	}
+
+	// the handler streams the filtered backlog
+	req, err := http.NewRequest("GET", "/events?tenant=tenant-two&type=network", nil)
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	req.Header.Set("Last-Event-ID", events.streamID+"-1")
+	reqCtx, cancel := context.WithCancel(req.Context())
+	cancel()
+	w := httptest.NewRecorder()
+	EventStreamHandler(w, req.WithContext(reqCtx))
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Count(body, "event: network") != 2 ||
+		!strings.Contains(body, "id: "+events.streamID+"-4\n") || strings.Contains(body, "net3") {
+		t.Fatalf("unexpected stream %d: %s", w.Code, body)
+	}
+	if len(events.subs) != 0 {
+		t.Fatalf("client was not removed")
+	}
+
+	req, _ = http.NewRequest("GET", "/events?type=bogus", nil)
+	w = httptest.NewRecorder()
+	EventStreamHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown event type accepted: %d", w.Code)
+	}
+}
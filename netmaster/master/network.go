@@ -94,12 +94,66 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		return nil
 	}
 
-	subnetIP, subnetLen, _ := netutils.ParseCIDR(network.SubnetCIDR)
-	err = netutils.ValidateNetworkRangeParams(subnetIP, subnetLen)
+	nwCfg, err = newNetworkState(network, stateDriver, tenantName)
+	if err != nil {
+		return err
+	}
+
+	// Allocate pkt tags
+	reqPktTag := uint(network.PktTag)
+	if nwCfg.PktTagType == "vlan" {
+		pktTag, err = gCfg.AllocVLAN(reqPktTag)
+		if err != nil {
+			return err
+		}
+	} else if nwCfg.PktTagType == "vxlan" {
+		extPktTag, pktTag, err = gCfg.AllocVXLAN(reqPktTag)
+		if err != nil {
+			return err
+		}
+	}
+
+	nwCfg.ExtPktTag = int(extPktTag)
+	nwCfg.PktTag = int(pktTag)
+
+	err = nwCfg.Write()
 	if err != nil {
 		return err
 	}
 
+	// Skip docker and service container configs for infra nw
+	if network.NwType == "infra" {
+		return nil
+	}
+
+	aci, _ := IsAciConfigured()
+	if aci {
+		// Skip docker network creation for ACI fabric mode.
+		return nil
+	}
+
+	if GetClusterMode() == "docker" {
+		// Create the network in docker
+		err = docknet.CreateDockNet(tenantName, network.Name, "", nwCfg)
+		if err != nil {
+			log.Errorf("Error creating network %s in docker. Err: %v", nwCfg.ID, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newNetworkState builds the state of a new network with its gateways
+// reserved, the packet tags are left to the caller
+func newNetworkState(network intent.ConfigNetwork, stateDriver core.StateDriver,
+	tenantName string) (*mastercfg.CfgNetworkState, error) {
+	subnetIP, subnetLen, _ := netutils.ParseCIDR(network.SubnetCIDR)
+	err := netutils.ValidateNetworkRangeParams(subnetIP, subnetLen)
+	if err != nil {
+		return nil, err
+	}
+
 	ipv6Subnet, ipv6SubnetLen, _ := netutils.ParseCIDR(network.IPv6SubnetCIDR)
 
 	// if there is no label given generate one for the network
@@ -109,7 +163,7 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 	}
 
 	// construct and update network state
-	nwCfg = &mastercfg.CfgNetworkState{
+	nwCfg := &mastercfg.CfgNetworkState{
 		Tenant:        tenantName,
 		NetworkName:   network.Name,
 		NwType:        network.NwType,
@@ -121,7 +175,7 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		NetworkTag:    nwTag,
 	}
 
-	nwCfg.ID = networkID
+	nwCfg.ID = network.Name + "." + tenantName
 	nwCfg.StateDriver = stateDriver
 
 	netutils.InitSubnetBitset(&nwCfg.IPAllocMap, nwCfg.SubnetLen)
@@ -136,7 +190,7 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		ipAddrValue, err := netutils.GetIPNumber(subnetAddr, nwCfg.SubnetLen, 32, nwCfg.Gateway)
 		if err != nil {
 			log.Errorf("Error parsing gateway address %s. Err: %v", nwCfg.Gateway, err)
-			return err
+			return nil, err
 		}
 		nwCfg.IPAllocMap.Set(ipAddrValue)
 	}
@@ -152,49 +206,214 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		hostID, err := netutils.GetIPv6HostID(nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen, nwCfg.IPv6Gateway)
 		if err != nil {
 			log.Errorf("Error parsing gateway address %s. Err: %v", nwCfg.IPv6Gateway, err)
-			return err
+			return nil, err
 		}
 		netutils.ReserveIPv6HostID(hostID, &nwCfg.IPv6AllocMap)
 	}
 
-	// Allocate pkt tags
-	reqPktTag := uint(network.PktTag)
-	if nwCfg.PktTagType == "vlan" {
-		pktTag, err = gCfg.AllocVLAN(reqPktTag)
+	return nwCfg, nil
+}
+
+// widenSubnet grows the subnet of a network, the subnet address can't change
+// so that the allocated addresses keep their position in the allocation map
+func widenSubnet(nwCfg *mastercfg.CfgNetworkState, subnetCIDR string) error {
+	subnetIP, subnetLen, err := netutils.ParseCIDR(subnetCIDR)
+	if err != nil {
+		return err
+	}
+	if subnetLen == nwCfg.SubnetLen &&
+		netutils.GetIPAddrRange(subnetIP, subnetLen) == nwCfg.IPAddrRange {
+		return nil
+	}
+
+	if strings.Contains(subnetIP, "-") ||
+		nwCfg.IPAddrRange != netutils.GetIPAddrRange(nwCfg.SubnetIP, nwCfg.SubnetLen) {
+		return core.Errorf("subnet with an address range can't be changed")
+	}
+	if err := netutils.ValidateNetworkRangeParams(subnetIP, subnetLen); err != nil {
+		return err
+	}
+	if subnetLen >= nwCfg.SubnetLen ||
+		netutils.GetSubnetAddr(subnetIP, subnetLen) != nwCfg.SubnetIP {
+		return core.Errorf("subnet %s/%d can only be widened keeping the subnet address",
+			nwCfg.SubnetIP, nwCfg.SubnetLen)
+	}
+
+	// the old broadcast address becomes a host address
+	nwCfg.IPAllocMap.Clear(uint((1 << (32 - nwCfg.SubnetLen)) - 1))
+	netutils.InitSubnetBitset(&nwCfg.IPAllocMap, subnetLen)
+	nwCfg.SubnetLen = subnetLen
+	nwCfg.IPAddrRange = netutils.GetIPAddrRange(nwCfg.SubnetIP, subnetLen)
+	return nil
+}
+
+// updateGateway moves the reserved gateway address of a network
+func updateGateway(nwCfg *mastercfg.CfgNetworkState, gateway string) error {
+	if gateway == nwCfg.Gateway {
+		return nil
+	}
+
+	if gateway != "" {
+		ipAddrValue, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, gateway)
 		if err != nil {
+			log.Errorf("Error parsing gateway address %s. Err: %v", gateway, err)
 			return err
 		}
-	} else if nwCfg.PktTagType == "vxlan" {
-		extPktTag, pktTag, err = gCfg.AllocVXLAN(reqPktTag)
-		if err != nil {
-			return err
+		if nwCfg.IPAllocMap.Test(ipAddrValue) {
+			return core.Errorf("gateway %s is already in use", gateway)
 		}
+		nwCfg.IPAllocMap.Set(ipAddrValue)
 	}
 
-	nwCfg.ExtPktTag = int(extPktTag)
-	nwCfg.PktTag = int(pktTag)
+	if nwCfg.Gateway != "" {
+		ipAddrValue, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, nwCfg.Gateway)
+		if err == nil {
+			nwCfg.IPAllocMap.Clear(ipAddrValue)
+		}
+	}
 
-	err = nwCfg.Write()
+	nwCfg.Gateway = gateway
+	return nil
+}
+
+// updateIPv6 adds an IPv6 subnet to a network or moves its IPv6 gateway
+func updateIPv6(nwCfg *mastercfg.CfgNetworkState, subnetCIDR, gateway string) error {
+	if subnetCIDR == "" && nwCfg.IPv6Subnet == "" {
+		if gateway != "" {
+			return core.Errorf("IPv6 gateway %s requires an IPv6 subnet", gateway)
+		}
+		return nil
+	}
+
+	subnetIP, subnetLen, err := netutils.ParseCIDR(subnetCIDR)
 	if err != nil {
 		return err
 	}
+	if nwCfg.IPv6Subnet == "" {
+		nwCfg.IPv6Subnet = subnetIP
+		nwCfg.IPv6SubnetLen = subnetLen
+	} else if subnetIP != nwCfg.IPv6Subnet || subnetLen != nwCfg.IPv6SubnetLen {
+		return core.Errorf("IPv6 subnet %s/%d can't be changed",
+			nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen)
+	}
 
-	// Skip docker and service container configs for infra nw
-	if network.NwType == "infra" {
+	if gateway == nwCfg.IPv6Gateway {
 		return nil
 	}
 
+	if gateway != "" {
+		hostID, err := netutils.GetIPv6HostID(nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen, gateway)
+		if err != nil {
+			log.Errorf("Error parsing gateway address %s. Err: %v", gateway, err)
+			return err
+		}
+		if nwCfg.IPv6AllocMap[hostID] {
+			return core.Errorf("IPv6 gateway %s is already in use", gateway)
+		}
+		netutils.ReserveIPv6HostID(hostID, &nwCfg.IPv6AllocMap)
+	}
+
+	if nwCfg.IPv6Gateway != "" {
+		hostID, err := netutils.GetIPv6HostID(nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen, nwCfg.IPv6Gateway)
+		if err == nil {
+			delete(nwCfg.IPv6AllocMap, hostID)
+		}
+	}
+
+	nwCfg.IPv6Gateway = gateway
+	return nil
+}
+
+// applyNetworkUpdate checks the changes of a network and applies them to
+// its state, the state is not written
+func applyNetworkUpdate(nwCfg *mastercfg.CfgNetworkState, network intent.ConfigNetwork) error {
+	if network.NwType != nwCfg.NwType || network.PktTagType != nwCfg.PktTagType {
+		return core.Errorf("network type or encapsulation can't be changed")
+	}
+	cfgdTag := nwCfg.PktTag
+	if nwCfg.PktTagType == "vxlan" {
+		cfgdTag = nwCfg.ExtPktTag
+	}
+	if network.PktTag != 0 && network.PktTag != cfgdTag {
+		return core.Errorf("packet tag can't be changed")
+	}
+
+	if network.Gateway != nwCfg.Gateway {
+		masterGc := &mastercfg.GlobConfig{}
+		masterGc.StateDriver = nwCfg.StateDriver
+		if err := masterGc.Read(""); err == nil && masterGc.FwdMode == "routing" {
+			return core.Errorf("gateway can't be changed in routing mode")
+		}
+	}
+
+	if err := widenSubnet(nwCfg, network.SubnetCIDR); err != nil {
+		return err
+	}
+	if err := updateGateway(nwCfg, network.Gateway); err != nil {
+		return err
+	}
+	if err := updateIPv6(nwCfg, network.IPv6SubnetCIDR, network.IPv6Gateway); err != nil {
+		return err
+	}
+
+	nwTag := network.CfgdTag
+	if nwTag == "" {
+		nwTag = nwCfg.ID
+	}
+	nwCfg.NetworkTag = nwTag
+
+	return nil
+}
+
+// UpdateNetwork applies the changes of a network that can be made while it
+// has endpoints: gateway, IPv6 subnet addition, network tag and widening of
+// the subnet. Agents pick up the change from the network state.
+func UpdateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, tenantName string) error {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+
+	networkID := network.Name + "." + tenantName
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if err := nwCfg.Read(networkID); err != nil {
+		log.Errorf("network %s is not operational", networkID)
+		return err
+	}
+
+	if err := applyNetworkUpdate(nwCfg, network); err != nil {
+		return err
+	}
+
+	if err := nwCfg.Write(); err != nil {
+		log.Errorf("error writing nw config. Error: %s", err)
+		return err
+	}
+
 	aci, _ := IsAciConfigured()
-	if aci {
-		// Skip docker network creation for ACI fabric mode.
+	if nwCfg.NwType == "infra" || aci || GetClusterMode() != "docker" {
 		return nil
 	}
 
-	if GetClusterMode() == "docker" {
-		// Create the network in docker
-		err = docknet.CreateDockNet(tenantName, network.Name, "", nwCfg)
+	// Update the network and its groups in docker
+	if err := docknet.UpdateDockNet(tenantName, network.Name, "", nwCfg); err != nil {
+		log.Errorf("Error updating network %s in docker. Err: %v", nwCfg.ID, err)
+		return err
+	}
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = stateDriver
+	epgList, err := epgCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, epg := range epgList {
+		epg := epg.(*mastercfg.EndpointGroupState)
+		if epg.TenantName != tenantName || epg.NetworkName != network.Name {
+			continue
+		}
+		err = docknet.UpdateDockNet(tenantName, network.Name, epg.GroupName, nwCfg)
 		if err != nil {
-			log.Errorf("Error creating network %s in docker. Err: %v", nwCfg.ID, err)
+			log.Errorf("Error updating docker network for group %s.%s. Err: %v",
+				network.Name, epg.GroupName, err)
 			return err
 		}
 	}
@@ -254,6 +473,14 @@ func DeleteNetworkID(stateDriver core.StateDriver, netID string) error {
 		return err
 	}
 
+	migrating, err := isMigratingPktTag(stateDriver, netID)
+	if err != nil {
+		return err
+	}
+	if migrating {
+		return core.Errorf("Error: Network %s is migrating its pkt tag", netID)
+	}
+
 	// Will Skip docker network deletion for ACI fabric mode.
 	aci, _ := IsAciConfigured()
 
@@ -301,6 +528,13 @@ func DeleteNetworkID(stateDriver core.StateDriver, netID string) error {
 		return err
 	}
 
+	// remove the report of past pkt tag migrations
+	err = clearPktTagMigration(stateDriver, netID)
+	if err != nil {
+		log.Errorf("error clearing pkt tag migration of network %s. Error: %s", netID, err)
+		return err
+	}
+
 	return err
 }
 
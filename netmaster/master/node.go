@@ -0,0 +1,236 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"time"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// NodeWorkload is an endpoint homed on a node
+type NodeWorkload struct {
+	EndpointID    string `json:"endpointID"`
+	ContainerID   string `json:"containerID"`
+	Network       string `json:"network"`
+	EndpointGroup string `json:"endpointGroup,omitempty"`
+	IPAddress     string `json:"ipAddress"`
+}
+
+// NodeMaintenanceReport is the maintenance mode of a node and the
+// workloads still present on it
+type NodeMaintenanceReport struct {
+	Hostname       string         `json:"hostname"`
+	Cordoned       bool           `json:"cordoned"`
+	Decommissioned bool           `json:"decommissioned"`
+	Workloads      []NodeWorkload `json:"workloads"`
+}
+
+// readNodeMaintenance reads the maintenance mode of a node, a node never
+// put in maintenance has an empty state
+func readNodeMaintenance(stateDriver core.StateDriver, hostname string) (*mastercfg.NodeMaintenanceState, error) {
+	maintCfg := &mastercfg.NodeMaintenanceState{}
+	maintCfg.StateDriver = stateDriver
+	err := maintCfg.Read(hostname)
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	maintCfg.ID = hostname
+	maintCfg.Hostname = hostname
+	return maintCfg, nil
+}
+
+// checkNodeCordoned returns an error if endpoints can not be created on
+// the node
+func checkNodeCordoned(stateDriver core.StateDriver, hostname string) error {
+	if hostname == "" {
+		return nil
+	}
+
+	maintCfg, err := readNodeMaintenance(stateDriver, hostname)
+	if err != nil {
+		return err
+	}
+	if maintCfg.Cordoned {
+		return core.Errorf("node %s is cordoned, no endpoints can be created on it", hostname)
+	}
+
+	return nil
+}
+
+// nodeWorkloads returns the endpoints homed on a node
+func nodeWorkloads(stateDriver core.StateDriver, hostname string) ([]*mastercfg.CfgEndpointState, error) {
+	epCfg := &mastercfg.CfgEndpointState{}
+	epCfg.StateDriver = stateDriver
+	epList, err := epCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	eps := []*mastercfg.CfgEndpointState{}
+	for _, epState := range epList {
+		ep := epState.(*mastercfg.CfgEndpointState)
+		if ep.HomingHost == hostname {
+			eps = append(eps, ep)
+		}
+	}
+
+	return eps, nil
+}
+
+// nodeMaintenanceReport builds the report of a node from its maintenance
+// mode and endpoints
+func nodeMaintenanceReport(maintCfg *mastercfg.NodeMaintenanceState,
+	eps []*mastercfg.CfgEndpointState) *NodeMaintenanceReport {
+	report := &NodeMaintenanceReport{
+		Hostname:       maintCfg.Hostname,
+		Cordoned:       maintCfg.Cordoned,
+		Decommissioned: maintCfg.Decommissioned,
+		Workloads:      []NodeWorkload{},
+	}
+	for _, ep := range eps {
+		report.Workloads = append(report.Workloads, NodeWorkload{
+			EndpointID:    ep.ID,
+			ContainerID:   ep.EndpointID,
+			Network:       ep.NetID,
+			EndpointGroup: ep.EndpointGroupKey,
+			IPAddress:     ep.IPAddress,
+		})
+	}
+
+	return report
+}
+
+// CordonNode stops the creation of endpoints on a node
+func CordonNode(stateDriver core.StateDriver, hostname string) (*NodeMaintenanceReport, error) {
+	maintCfg, err := readNodeMaintenance(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	if !maintCfg.Cordoned {
+		log.Infof("Cordoning node %s", hostname)
+		maintCfg.Cordoned = true
+		maintCfg.Time = time.Now()
+		if err := maintCfg.Write(); err != nil {
+			log.Errorf("Error writing maintenance state of node %s. Err: %v", hostname, err)
+			return nil, err
+		}
+	}
+
+	return DrainNode(stateDriver, hostname)
+}
+
+// UncordonNode allows the creation of endpoints on a node again
+func UncordonNode(stateDriver core.StateDriver, hostname string) (*NodeMaintenanceReport, error) {
+	maintCfg, err := readNodeMaintenance(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	if maintCfg.Cordoned {
+		log.Infof("Uncordoning node %s", hostname)
+		if err := maintCfg.Clear(); err != nil && core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error clearing maintenance state of node %s. Err: %v", hostname, err)
+			return nil, err
+		}
+	}
+
+	return DrainNode(stateDriver, hostname)
+}
+
+// DrainNode reports the workloads still present on a node
+func DrainNode(stateDriver core.StateDriver, hostname string) (*NodeMaintenanceReport, error) {
+	maintCfg, err := readNodeMaintenance(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	eps, err := nodeWorkloads(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeMaintenanceReport(maintCfg, eps), nil
+}
+
+// DecommissionNode removes the endpoints homed on a cordoned node, releasing
+// their addresses, and its bgp config. The peers remove the VTEP of the
+// node when they see it decommissioned.
+func DecommissionNode(stateDriver core.StateDriver, hostname string) (*NodeMaintenanceReport, error) {
+	maintCfg, err := readNodeMaintenance(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if !maintCfg.Cordoned {
+		return nil, core.Errorf("node %s must be cordoned before it is decommissioned", hostname)
+	}
+
+	// hold the address allocation lock while the addresses are released
+	addrMutex.Lock()
+	defer addrMutex.Unlock()
+
+	eps, err := nodeWorkloads(stateDriver, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Decommissioning node %s with %d endpoints", hostname, len(eps))
+
+	for _, ep := range eps {
+		if _, err := DeleteEndpointID(stateDriver, ep.ID); err != nil {
+			log.Errorf("Error deleting endpoint %s of node %s. Err: %v", ep.ID, hostname, err)
+			return nil, err
+		}
+	}
+
+	// the bgp config is deleted through the model when it was created there
+	bgpCfg := &mastercfg.CfgBgpState{}
+	bgpCfg.StateDriver = stateDriver
+	if contivModel.FindBgp(hostname) != nil {
+		err = contivModel.DeleteBgp(hostname)
+	} else if bgpCfg.Read(hostname) == nil {
+		err = bgpCfg.Clear()
+	}
+	if err != nil {
+		log.Errorf("Error deleting bgp config of node %s. Err: %v", hostname, err)
+		return nil, err
+	}
+
+	// let the peers know the VTEP of the node
+	nodeCfg := &mastercfg.NodeState{}
+	nodeCfg.StateDriver = stateDriver
+	if err := nodeCfg.Read(hostname); err == nil {
+		maintCfg.VtepIP = nodeCfg.VtepIP
+		if err := nodeCfg.Clear(); err != nil {
+			log.Errorf("Error clearing node state of %s. Err: %v", hostname, err)
+		}
+	}
+
+	maintCfg.Decommissioned = true
+	maintCfg.Time = time.Now()
+	if err := maintCfg.Write(); err != nil {
+		log.Errorf("Error writing maintenance state of node %s. Err: %v", hostname, err)
+		return nil, err
+	}
+
+	return nodeMaintenanceReport(maintCfg, nil), nil
+}
@@ -0,0 +1,547 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/gstate"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// A packet tag migration moves a network to new packet tags so that its old
+// ones can be taken out of the global vlan/vxlan ranges. The new tags are
+// allocated next to the old ones, the network and its endpoint groups are
+// switched to them and the netplugins reprogram the network when they see the
+// change through the network watch. Once every node with endpoints in the
+// network published the new tags the old ones are released, a failure on any
+// node or a timeout moves the network back and releases the new tags.
+
+var (
+	// pktTagMigrationTimeout is how long the nodes are given to move to the new tags
+	pktTagMigrationTimeout = 2 * time.Minute
+	// pktTagMigrationPoll is the interval between checks of the nodes progress
+	pktTagMigrationPoll = 2 * time.Second
+)
+
+// PktTagMigrationRequest is the request to move a network to a new pkt tag,
+// a vlan for vlan networks and a vxlan for vxlan networks. A zero tag picks
+// any free tag.
+type PktTagMigrationRequest struct {
+	PktTag int `json:"pktTag"`
+}
+
+// readPktTagMigration reads the migration of a network, it returns nil if
+// the network was never migrated
+func readPktTagMigration(stateDriver core.StateDriver, netID string) (*mastercfg.PktTagMigrationState, error) {
+	migCfg := &mastercfg.PktTagMigrationState{}
+	migCfg.StateDriver = stateDriver
+	err := migCfg.Read(netID)
+	if err != nil {
+		if core.ErrIfKeyExists(err) == nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return migCfg, nil
+}
+
+// isMigratingPktTag returns true if the network is moving to new pkt tags
+func isMigratingPktTag(stateDriver core.StateDriver, netID string) (bool, error) {
+	migCfg, err := readPktTagMigration(stateDriver, netID)
+	if err != nil {
+		return false, err
+	}
+
+	return migCfg != nil && migCfg.Status == mastercfg.PktTagMigrating, nil
+}
+
+// clearPktTagMigration removes the migration state of a network and the
+// tags published by the nodes
+func clearPktTagMigration(stateDriver core.StateDriver, netID string) error {
+	hostStates, err := mastercfg.ReadPktTagHostStates(stateDriver, netID)
+	if err != nil {
+		return err
+	}
+	for _, hostState := range hostStates {
+		if err := hostState.Clear(); err != nil {
+			log.Errorf("Error clearing pkt tags of network %s on %s. Err: %v",
+				netID, hostState.Hostname, err)
+			return err
+		}
+	}
+
+	migCfg, err := readPktTagMigration(stateDriver, netID)
+	if err != nil || migCfg == nil {
+		return err
+	}
+
+	return migCfg.Clear()
+}
+
+// allocPktTags allocates the pkt tags of a network
+func allocPktTags(gCfg *gstate.Cfg, pktTagType string, reqPktTag uint) (pktTag, extPktTag uint, err error) {
+	if pktTagType == "vlan" {
+		pktTag, err = gCfg.AllocVLAN(reqPktTag)
+	} else {
+		extPktTag, pktTag, err = gCfg.AllocVXLAN(reqPktTag)
+	}
+
+	return pktTag, extPktTag, err
+}
+
+// freePktTags releases the pkt tags of a network
+func freePktTags(gCfg *gstate.Cfg, pktTagType string, pktTag, extPktTag int) error {
+	if pktTagType == "vlan" {
+		return gCfg.FreeVLAN(uint(pktTag))
+	}
+
+	return gCfg.FreeVXLAN(uint(extPktTag), uint(pktTag))
+}
+
+// setNetworkPktTags moves a network and its endpoint groups to new pkt tags.
+// The groups are updated first so that the netplugins find the new tags
+// there when they reprogram the endpoints on the network change.
+func setNetworkPktTags(nwCfg *mastercfg.CfgNetworkState, pktTag, extPktTag int) error {
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = nwCfg.StateDriver
+	epgList, err := epgCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, epgState := range epgList {
+		epg := epgState.(*mastercfg.EndpointGroupState)
+		if epg.TenantName != nwCfg.Tenant || epg.NetworkName != nwCfg.NetworkName {
+			continue
+		}
+		epg.PktTag = pktTag
+		epg.ExtPktTag = extPktTag
+		if err := epg.Write(); err != nil {
+			log.Errorf("Error writing pkt tags of EPG %s. Err: %v", epg.ID, err)
+			return err
+		}
+	}
+
+	nwCfg.PktTag = pktTag
+	nwCfg.ExtPktTag = extPktTag
+	return nwCfg.Write()
+}
+
+// setModelPktTag keeps the pkt tag configured on the network object in line
+// with the pkt tags of the network, the network object of a network with
+// auto allocated pkt tags has no tag and is left alone. It is called with
+// gstate.ModelMutex held so the network is not changed by a model route at
+// the same time.
+func setModelPktTag(nwCfg *mastercfg.CfgNetworkState, pktTagType string, pktTag, extPktTag int) error {
+	netKey := nwCfg.Tenant + ":" + nwCfg.NetworkName
+	network := contivModel.FindNetwork(netKey)
+	if network == nil || network.PktTag == 0 {
+		return nil
+	}
+
+	if pktTagType == "vxlan" {
+		pktTag = extPktTag
+	}
+	if network.PktTag == pktTag {
+		return nil
+	}
+
+	network.PktTag = pktTag
+	if err := network.Write(); err != nil {
+		log.Errorf("Error writing pkt tag of network %s. Err: %v", netKey, err)
+		return err
+	}
+
+	return nil
+}
+
+// networkHosts returns the nodes with endpoints in a network
+func networkHosts(stateDriver core.StateDriver, netID string) ([]string, error) {
+	epCfg := &mastercfg.CfgEndpointState{}
+	epCfg.StateDriver = stateDriver
+	epList, err := epCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	hostMap := make(map[string]bool)
+	for _, epState := range epList {
+		ep := epState.(*mastercfg.CfgEndpointState)
+		if ep.NetID == netID && ep.HomingHost != "" {
+			hostMap[ep.HomingHost] = true
+		}
+	}
+
+	hosts := []string{}
+	for host := range hostMap {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+// startPktTagMigration allocates the new pkt tags of a network and moves the
+// network to them
+func startPktTagMigration(stateDriver core.StateDriver, netID string, reqPktTag uint) (*mastercfg.PktTagMigrationState, error) {
+	aci, _ := IsAciConfigured()
+	if aci {
+		return nil, core.Errorf("pkt tag migration is not supported in ACI mode")
+	}
+
+	// a rollback changes the network object
+	gstate.ModelMutex.Lock()
+	defer gstate.ModelMutex.Unlock()
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+	gCfg := &gstate.Cfg{}
+	gCfg.StateDriver = stateDriver
+	err := gCfg.Read("")
+	if err != nil {
+		log.Errorf("error reading global cfg state. Error: %s", err)
+		return nil, err
+	}
+
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if err := nwCfg.Read(netID); err != nil {
+		log.Errorf("network %s is not operational", netID)
+		return nil, err
+	}
+	if nwCfg.NwType == "infra" {
+		return nil, core.Errorf("pkt tags of infra network %s can not be migrated", netID)
+	}
+	if nwCfg.PktTagType != "vlan" && nwCfg.PktTagType != "vxlan" {
+		return nil, core.Errorf("network %s has no pkt tag to migrate", netID)
+	}
+
+	migrating, err := isMigratingPktTag(stateDriver, netID)
+	if err != nil {
+		return nil, err
+	}
+	if migrating {
+		return nil, core.Errorf("pkt tag migration of network %s is already in progress", netID)
+	}
+
+	curPktTag := nwCfg.PktTag
+	if nwCfg.PktTagType == "vxlan" {
+		curPktTag = nwCfg.ExtPktTag
+	}
+	if reqPktTag != 0 && int(reqPktTag) == curPktTag {
+		return nil, core.Errorf("network %s already uses %s %d", netID, nwCfg.PktTagType, reqPktTag)
+	}
+
+	pktTag, extPktTag, err := allocPktTags(gCfg, nwCfg.PktTagType, reqPktTag)
+	if err != nil {
+		log.Errorf("Error allocating %s %d for network %s. Err: %v", nwCfg.PktTagType, reqPktTag, netID, err)
+		return nil, err
+	}
+
+	hosts, err := networkHosts(stateDriver, netID)
+	if err == nil {
+		err = clearPktTagMigration(stateDriver, netID)
+	}
+	if err != nil {
+		freePktTags(gCfg, nwCfg.PktTagType, int(pktTag), int(extPktTag))
+		return nil, err
+	}
+
+	migCfg := &mastercfg.PktTagMigrationState{
+		NetworkID:    netID,
+		PktTagType:   nwCfg.PktTagType,
+		OldPktTag:    nwCfg.PktTag,
+		OldExtPktTag: nwCfg.ExtPktTag,
+		NewPktTag:    int(pktTag),
+		NewExtPktTag: int(extPktTag),
+		Status:       mastercfg.PktTagMigrating,
+		Hosts:        make(map[string]string),
+		StartTime:    time.Now(),
+	}
+	migCfg.ID = netID
+	migCfg.StateDriver = stateDriver
+	for _, host := range hosts {
+		migCfg.Hosts[host] = mastercfg.PktTagHostPending
+	}
+	if err := migCfg.Write(); err != nil {
+		log.Errorf("Error writing pkt tag migration of network %s. Err: %v", netID, err)
+		freePktTags(gCfg, nwCfg.PktTagType, int(pktTag), int(extPktTag))
+		return nil, err
+	}
+
+	log.Infof("Migrating network %s from pkt tag %d/%d to %d/%d on nodes %v", netID,
+		migCfg.OldPktTag, migCfg.OldExtPktTag, pktTag, extPktTag, hosts)
+
+	if err := setNetworkPktTags(nwCfg, int(pktTag), int(extPktTag)); err != nil {
+		log.Errorf("Error moving network %s to the new pkt tags. Err: %v", netID, err)
+		if rbErr := rollbackPktTagMigration(gCfg, nwCfg, migCfg, err.Error()); rbErr != nil {
+			log.Errorf("Error rolling back pkt tag migration of network %s. Err: %v", netID, rbErr)
+		}
+		return nil, err
+	}
+
+	return migCfg, nil
+}
+
+// rollbackPktTagMigration moves a network back to its old pkt tags and
+// releases the new ones
+func rollbackPktTagMigration(gCfg *gstate.Cfg, nwCfg *mastercfg.CfgNetworkState,
+	migCfg *mastercfg.PktTagMigrationState, reason string) error {
+	log.Warnf("Rolling back pkt tag migration of network %s: %s", migCfg.NetworkID, reason)
+
+	if err := setNetworkPktTags(nwCfg, migCfg.OldPktTag, migCfg.OldExtPktTag); err != nil {
+		return err
+	}
+	if err := setModelPktTag(nwCfg, migCfg.PktTagType, migCfg.OldPktTag, migCfg.OldExtPktTag); err != nil {
+		return err
+	}
+	if err := freePktTags(gCfg, migCfg.PktTagType, migCfg.NewPktTag, migCfg.NewExtPktTag); err != nil {
+		log.Errorf("Error freeing pkt tags %d/%d. Err: %v", migCfg.NewPktTag, migCfg.NewExtPktTag, err)
+	}
+
+	migCfg.Status = mastercfg.PktTagRolledBack
+	migCfg.Error = reason
+	migCfg.EndTime = time.Now()
+	return migCfg.Write()
+}
+
+// completePktTagMigration releases the old pkt tags of a network once the
+// nodes moved to the new ones
+func completePktTagMigration(gCfg *gstate.Cfg, nwCfg *mastercfg.CfgNetworkState,
+	migCfg *mastercfg.PktTagMigrationState) error {
+	if err := setModelPktTag(nwCfg, migCfg.PktTagType, migCfg.NewPktTag, migCfg.NewExtPktTag); err != nil {
+		return err
+	}
+	if err := freePktTags(gCfg, migCfg.PktTagType, migCfg.OldPktTag, migCfg.OldExtPktTag); err != nil {
+		log.Errorf("Error freeing pkt tags %d/%d. Err: %v", migCfg.OldPktTag, migCfg.OldExtPktTag, err)
+		return err
+	}
+
+	log.Infof("Network %s migrated to pkt tag %d/%d", migCfg.NetworkID,
+		migCfg.NewPktTag, migCfg.NewExtPktTag)
+
+	migCfg.Status = mastercfg.PktTagMigrated
+	migCfg.EndTime = time.Now()
+	return migCfg.Write()
+}
+
+// advancePktTagMigration checks the progress of the nodes in the migration
+// of a network, completing or rolling it back when it can. It returns true
+// once the migration is over.
+func advancePktTagMigration(stateDriver core.StateDriver, netID string) (bool, error) {
+	// completing or rolling back changes the network object
+	gstate.ModelMutex.Lock()
+	defer gstate.ModelMutex.Unlock()
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+
+	migCfg, err := readPktTagMigration(stateDriver, netID)
+	if err != nil {
+		return false, err
+	}
+	if migCfg == nil || migCfg.Status != mastercfg.PktTagMigrating {
+		return true, nil
+	}
+
+	gCfg := &gstate.Cfg{}
+	gCfg.StateDriver = stateDriver
+	if err := gCfg.Read(""); err != nil {
+		log.Errorf("error reading global cfg state. Error: %s", err)
+		return false, err
+	}
+
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	if err := nwCfg.Read(netID); err != nil {
+		return false, err
+	}
+
+	// nodes can gain or lose endpoints during the migration
+	hosts, err := networkHosts(stateDriver, netID)
+	if err != nil {
+		return false, err
+	}
+	hostStates, err := mastercfg.ReadPktTagHostStates(stateDriver, netID)
+	if err != nil {
+		return false, err
+	}
+
+	migCfg.Hosts = make(map[string]string)
+	failed := []string{}
+	pending := []string{}
+	for _, host := range hosts {
+		status := mastercfg.PktTagHostPending
+		hostState := hostStates[host]
+		if hostState != nil && hostState.PktTag == migCfg.NewPktTag &&
+			hostState.ExtPktTag == migCfg.NewExtPktTag {
+			status = mastercfg.PktTagHostMigrated
+			if hostState.Error != "" {
+				status = mastercfg.PktTagHostFailed
+				failed = append(failed, host+": "+hostState.Error)
+			}
+		}
+		if status == mastercfg.PktTagHostPending {
+			pending = append(pending, host)
+		}
+		migCfg.Hosts[host] = status
+	}
+
+	switch {
+	case len(failed) > 0:
+		err = rollbackPktTagMigration(gCfg, nwCfg, migCfg,
+			"failed on nodes "+strings.Join(failed, ", "))
+	case len(pending) == 0:
+		err = completePktTagMigration(gCfg, nwCfg, migCfg)
+	case time.Since(migCfg.StartTime) > pktTagMigrationTimeout:
+		err = rollbackPktTagMigration(gCfg, nwCfg, migCfg,
+			"timed out waiting for nodes "+strings.Join(pending, ", "))
+	default:
+		return false, migCfg.Write()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// watchPktTagMigration advances the migration of a network until it is over
+func watchPktTagMigration(stateDriver core.StateDriver, netID string) {
+	for {
+		done, err := advancePktTagMigration(stateDriver, netID)
+		if err != nil {
+			log.Errorf("Error checking pkt tag migration of network %s. Err: %v", netID, err)
+			if core.ErrIfKeyExists(err) == nil {
+				// the network is gone
+				return
+			}
+		}
+		if done {
+			return
+		}
+
+		time.Sleep(pktTagMigrationPoll)
+	}
+}
+
+// MigrateNetworkPktTag starts moving a network to a new pkt tag from the
+// global ranges, the progress is reported in the returned migration state
+func MigrateNetworkPktTag(stateDriver core.StateDriver, netID string, reqPktTag uint) (*mastercfg.PktTagMigrationState, error) {
+	migCfg, err := startPktTagMigration(stateDriver, netID, reqPktTag)
+	if err != nil {
+		return nil, err
+	}
+
+	go watchPktTagMigration(stateDriver, netID)
+
+	return migCfg, nil
+}
+
+// ResumePktTagMigrations watches the migrations left in progress by a
+// previous master
+func ResumePktTagMigrations(stateDriver core.StateDriver) error {
+	migList, err := GetPktTagMigrations(stateDriver)
+	if err != nil {
+		return err
+	}
+
+	for _, migCfg := range migList {
+		if migCfg.Status == mastercfg.PktTagMigrating {
+			log.Infof("Resuming pkt tag migration of network %s", migCfg.NetworkID)
+			go watchPktTagMigration(stateDriver, migCfg.NetworkID)
+		}
+	}
+
+	return nil
+}
+
+// GetPktTagMigration returns the migration of a network
+func GetPktTagMigration(stateDriver core.StateDriver, netID string) (*mastercfg.PktTagMigrationState, error) {
+	migCfg, err := readPktTagMigration(stateDriver, netID)
+	if err != nil {
+		return nil, err
+	}
+	if migCfg == nil {
+		return nil, core.Errorf("network %s has no pkt tag migration", netID)
+	}
+
+	return migCfg, nil
+}
+
+// GetPktTagMigrations returns the migrations of all networks
+func GetPktTagMigrations(stateDriver core.StateDriver) ([]*mastercfg.PktTagMigrationState, error) {
+	migCfg := &mastercfg.PktTagMigrationState{}
+	migCfg.StateDriver = stateDriver
+	migList, err := migCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	migrations := []*mastercfg.PktTagMigrationState{}
+	for _, migState := range migList {
+		migrations = append(migrations, migState.(*mastercfg.PktTagMigrationState))
+	}
+
+	return migrations, nil
+}
+
+// MigratePktTagHandler starts the pkt tag migration of a network
+func MigratePktTagHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	migReq := PktTagMigrationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&migReq); err != nil {
+		log.Errorf("Error decoding MigratePktTagHandler. Err %v", err)
+		return nil, err
+	}
+	if migReq.PktTag < 0 {
+		return nil, core.Errorf("Invalid pkt tag %d", migReq.PktTag)
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return MigrateNetworkPktTag(stateDriver, vars["id"], uint(migReq.PktTag))
+}
+
+// GetPktTagMigrationHandler returns the pkt tag migration of a network
+func GetPktTagMigrationHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPktTagMigration(stateDriver, vars["id"])
+}
+
+// GetPktTagMigrationsHandler returns the pkt tag migrations of all networks
+func GetPktTagMigrationsHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPktTagMigrations(stateDriver)
+}
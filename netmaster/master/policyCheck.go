@@ -0,0 +1,199 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/netmaster/policysim"
+)
+
+// PolicyCheckEndpoint is one side of a simulated flow
+type PolicyCheckEndpoint struct {
+	Spec          string `json:"spec"`                    // endpoint, group or ip as given
+	Endpoint      string `json:"endpoint,omitempty"`      // resolved endpoint name
+	EndpointGroup string `json:"endpointGroup,omitempty"` // resolved endpoint group
+	IPAddress     string `json:"ipAddress,omitempty"`     // resolved address
+}
+
+// PolicyCheckResult is the verdict of a simulated flow
+type PolicyCheckResult struct {
+	policysim.Verdict
+	From     *PolicyCheckEndpoint `json:"from"`
+	To       *PolicyCheckEndpoint `json:"to"`
+	Protocol string               `json:"protocol"`
+	Port     int                  `json:"port,omitempty"`
+}
+
+// tenantEndpoints returns the endpoints of a tenant sorted by id
+func tenantEndpoints(stateDriver core.StateDriver, tenantName string) ([]*mastercfg.CfgEndpointState, error) {
+	readEp := &mastercfg.CfgEndpointState{}
+	readEp.StateDriver = stateDriver
+	epList, err := readEp.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	eps := []*mastercfg.CfgEndpointState{}
+	for _, epState := range epList {
+		ep := epState.(*mastercfg.CfgEndpointState)
+		if strings.HasSuffix(ep.NetID, "."+tenantName) {
+			eps = append(eps, ep)
+		}
+	}
+	sort.Sort(endpointsByID(eps))
+
+	return eps, nil
+}
+
+type endpointsByID []*mastercfg.CfgEndpointState
+
+func (e endpointsByID) Len() int           { return len(e) }
+func (e endpointsByID) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e endpointsByID) Less(i, j int) bool { return e[i].ID < e[j].ID }
+
+// resolvePolicyCheckEndpoint resolves an ip, endpoint group or endpoint name.
+// Groups are simulated with the address of one of their endpoints.
+func resolvePolicyCheckEndpoint(spec, tenantName string,
+	eps []*mastercfg.CfgEndpointState) (*PolicyCheckEndpoint, error) {
+	pcEp := &PolicyCheckEndpoint{Spec: spec}
+
+	setEndpoint := func(ep *mastercfg.CfgEndpointState) {
+		pcEp.Endpoint = ep.EPCommonName
+		if pcEp.Endpoint == "" {
+			pcEp.Endpoint = ep.ContainerID
+		}
+		pcEp.EndpointGroup = ep.ServiceName
+		pcEp.IPAddress = ep.IPAddress
+	}
+
+	if net.ParseIP(spec) != nil {
+		pcEp.IPAddress = spec
+		for _, ep := range eps {
+			if ep.IPAddress == spec {
+				setEndpoint(ep)
+				break
+			}
+		}
+		return pcEp, nil
+	}
+
+	if contivModel.FindEndpointGroup(tenantName+":"+spec) != nil {
+		pcEp.EndpointGroup = spec
+		for _, ep := range eps {
+			if ep.ServiceName == spec {
+				pcEp.IPAddress = ep.IPAddress
+				break
+			}
+		}
+		return pcEp, nil
+	}
+
+	for _, ep := range eps {
+		if ep.EPCommonName == spec || ep.ContainerID == spec ||
+			(len(spec) >= 12 && strings.HasPrefix(ep.ContainerID, spec)) {
+			setEndpoint(ep)
+			return pcEp, nil
+		}
+	}
+
+	return nil, core.Errorf("%s is not an endpoint, endpoint group or ip address in tenant %s",
+		spec, tenantName)
+}
+
+// CheckPolicy simulates a flow between two endpoints, endpoint groups or
+// addresses of a tenant against the configured policies
+func CheckPolicy(stateDriver core.StateDriver, tenantName, from, to, protocol string,
+	port int) (*PolicyCheckResult, error) {
+	if !isPolicyEnabled() {
+		return nil, core.Errorf("policy check is not supported in ACI mode")
+	}
+
+	tenant := contivModel.FindTenant(tenantName)
+	if tenant == nil {
+		return nil, core.Errorf("Tenant %s not found", tenantName)
+	}
+
+	eps, err := tenantEndpoints(stateDriver, tenantName)
+	if err != nil {
+		return nil, err
+	}
+	fromEp, err := resolvePolicyCheckEndpoint(from, tenantName, eps)
+	if err != nil {
+		return nil, err
+	}
+	toEp, err := resolvePolicyCheckEndpoint(to, tenantName, eps)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the policies of the tenant
+	groups := []*contivModel.EndpointGroup{}
+	for epgKey := range tenant.LinkSets.EndpointGroups {
+		if epg := contivModel.FindEndpointGroup(epgKey); epg != nil {
+			groups = append(groups, epg)
+		}
+	}
+	rules := []*contivModel.Rule{}
+	for policyKey := range tenant.LinkSets.Policies {
+		policy := contivModel.FindPolicy(policyKey)
+		if policy == nil {
+			continue
+		}
+		for ruleKey := range policy.LinkSets.Rules {
+			if rule := contivModel.FindRule(ruleKey); rule != nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	networks := []*contivModel.Network{}
+	for nwKey := range tenant.LinkSets.Networks {
+		if nw := contivModel.FindNetwork(nwKey); nw != nil {
+			networks = append(networks, nw)
+		}
+	}
+
+	flow := &policysim.Flow{
+		SrcIP:    net.ParseIP(fromEp.IPAddress),
+		DstIP:    net.ParseIP(toEp.IPAddress),
+		Protocol: protocol,
+		Port:     port,
+	}
+	if fromEp.EndpointGroup != "" {
+		flow.SrcGroup = tenantName + ":" + fromEp.EndpointGroup
+	}
+	if toEp.EndpointGroup != "" {
+		flow.DstGroup = tenantName + ":" + toEp.EndpointGroup
+	}
+
+	verdict, err := policysim.NewSimulator(groups, rules, networks).Check(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyCheckResult{
+		Verdict:  *verdict,
+		From:     fromEp,
+		To:       toEp,
+		Protocol: protocol,
+		Port:     port,
+	}, nil
+}
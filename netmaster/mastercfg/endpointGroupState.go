@@ -42,6 +42,7 @@ type EndpointGroupState struct {
 	IPPool          string        `json:"IPPool"`
 	EPGIPAllocMap   bitset.BitSet `json:"epgIpAllocMap"`
 	GroupTag        string        `json:"groupTag"`
+	Isolation       bool          `json:"isolation"` // deny traffic between the endpoints
 }
 
 // Write the state.
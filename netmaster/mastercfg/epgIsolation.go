@@ -0,0 +1,122 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/ofnet"
+)
+
+// EpgIsolationPriority is the priority of the rule isolating the endpoints of
+// a group. It is above the highest policy rule priority so that isolation
+// can't be overridden by an allow rule.
+const EpgIsolationPriority = 101
+
+// EpgIsolationRuleID returns the id of the ofnet rule isolating the endpoints
+// of a group
+func EpgIsolationRuleID(epgKey string) string {
+	return epgKey + ":isolation"
+}
+
+// epgIsolationRule builds the rule denying the traffic between endpoints of
+// the same group. Traffic to the gateway and to other groups does not carry
+// the group as destination and is left to the policies.
+func epgIsolationRule(epgCfg *EndpointGroupState) *ofnet.OfnetPolicyRule {
+	return &ofnet.OfnetPolicyRule{
+		RuleId:           EpgIsolationRuleID(epgCfg.ID),
+		Priority:         EpgIsolationPriority,
+		SrcEndpointGroup: epgCfg.EndpointGroupID,
+		DstEndpointGroup: epgCfg.EndpointGroupID,
+		Action:           "deny",
+	}
+}
+
+// AddEpgIsolation installs the isolation rule of an endpoint group
+func AddEpgIsolation(epgCfg *EndpointGroupState) error {
+	if ofnetMaster == nil {
+		return core.Errorf("policy manager is not initialized")
+	}
+
+	ofnetRule := epgIsolationRule(epgCfg)
+	err := ofnetMaster.AddRule(ofnetRule)
+	if err != nil {
+		log.Errorf("Error creating isolation rule {%+v}. Err: %v", ofnetRule, err)
+		return err
+	}
+
+	// Send AddRule to netplugin agents
+	err = addPolicyRuleState(ofnetRule)
+	if err != nil {
+		log.Errorf("Error creating isolation rule {%+v}. Err: %v", ofnetRule, err)
+		return err
+	}
+
+	log.Infof("Isolated endpoint group %s", epgCfg.ID)
+
+	return nil
+}
+
+// DelEpgIsolation removes the isolation rule of an endpoint group
+func DelEpgIsolation(epgCfg *EndpointGroupState) error {
+	if ofnetMaster == nil {
+		return core.Errorf("policy manager is not initialized")
+	}
+
+	ofnetRule := epgIsolationRule(epgCfg)
+	err := ofnetMaster.DelRule(ofnetRule)
+	if err != nil {
+		log.Errorf("Error deleting isolation rule {%+v}. Err: %v", ofnetRule, err)
+	}
+
+	// Send DelRule to netplugin agents
+	err = delPolicyRuleState(ofnetRule)
+	if err != nil {
+		log.Errorf("Error deleting isolation rule {%+v}. Err: %v", ofnetRule, err)
+		return err
+	}
+
+	log.Infof("Removed isolation of endpoint group %s", epgCfg.ID)
+
+	return nil
+}
+
+// restoreEpgIsolation reinstalls the isolation rules of all isolated groups
+func restoreEpgIsolation(stateDriver core.StateDriver) error {
+	epgCfg := &EndpointGroupState{}
+	epgCfg.StateDriver = stateDriver
+	epgList, err := epgCfg.ReadAll()
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			return err
+		}
+		return nil
+	}
+
+	for _, epgState := range epgList {
+		epg := epgState.(*EndpointGroupState)
+		if !epg.Isolation {
+			continue
+		}
+
+		log.Infof("Restoring isolation of endpoint group %s", epg.ID)
+		if err := AddEpgIsolation(epg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	nodeOperPathPrefix = StateOperPath + "nodes/"
+	nodeOperPath       = nodeOperPathPrefix + "%s"
+)
+
+// NodeHeartbeatInterval is how often the netplugins publish their node state
+const NodeHeartbeatInterval = 10 * time.Second
+
+// Health of a node
+const (
+	// NodeHealthy is a node registered with the service registry and
+	// publishing its node state
+	NodeHealthy = "healthy"
+	// NodeStale is a node registered with the service registry that stopped
+	// publishing its node state
+	NodeStale = "stale"
+	// NodeDown is a node whose service registration expired
+	NodeDown = "down"
+)
+
+// NodeState is the inventory of a node published by its netplugin, keyed
+// by host label
+type NodeState struct {
+	core.CommonState
+	Hostname      string    `json:"hostname"`
+	CtrlIP        string    `json:"ctrlIP"`
+	VtepIP        string    `json:"vtepIP"`
+	Version       string    `json:"version"`
+	FwdMode       string    `json:"fwdMode"`
+	PluginMode    string    `json:"pluginMode"`
+	Uplinks       []string  `json:"uplinks"`
+	NumEndpoints  int       `json:"numEndpoints"`
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+}
+
+// Write the state.
+func (s *NodeState) Write() error {
+	key := fmt.Sprintf(nodeOperPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *NodeState) Read(id string) error {
+	key := fmt.Sprintf(nodeOperPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the nodes.
+func (s *NodeState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(nodeOperPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to nodes.
+func (s *NodeState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(nodeOperPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *NodeState) Clear() error {
+	key := fmt.Sprintf(nodeOperPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// NodeHeartbeats tracks when netmaster received the heartbeats of the
+// nodes. The node health is derived from the receive times rather than from
+// the LastHeartbeat of the nodes, which is skewed by the node clocks.
+type NodeHeartbeats struct {
+	mutex    sync.Mutex
+	started  time.Time            // time the tracking started
+	received map[string]time.Time // receive time of the last heartbeat by host label
+}
+
+// NewNodeHeartbeats returns a tracker of the node heartbeats
+func NewNodeHeartbeats() *NodeHeartbeats {
+	return &NodeHeartbeats{
+		started:  time.Now(),
+		received: make(map[string]time.Time),
+	}
+}
+
+// Received records a heartbeat of a node received now
+func (h *NodeHeartbeats) Received(hostname string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.received[hostname] = time.Now()
+}
+
+// Age returns the time since the last heartbeat received from a node, a
+// node not heard from yet is aged from the start of the tracking
+func (h *NodeHeartbeats) Age(hostname string) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if received, ok := h.received[hostname]; ok {
+		return time.Since(received)
+	}
+	return time.Since(h.started)
+}
+
+// Watch records the heartbeats the nodes write to the state store
+func (h *NodeHeartbeats) Watch(stateDriver core.StateDriver) {
+	nodeCfg := &NodeState{}
+	nodeCfg.StateDriver = stateDriver
+	rsps := make(chan core.WatchState)
+	go func() {
+		if err := nodeCfg.WatchAll(rsps); err != nil {
+			log.Errorf("Error watching node heartbeats. Err: %v", err)
+		}
+	}()
+
+	for rsp := range rsps {
+		if rsp.Curr != nil {
+			h.Received(rsp.Curr.(*NodeState).Hostname)
+		}
+	}
+}
+
+// NodeInfo is the inventory of a node with its health as seen by netmaster
+type NodeInfo struct {
+	NodeState
+	Health       string `json:"health"`
+	HeartbeatAge string `json:"heartbeatAge"` // time since the last heartbeat
+	VersionSkew  bool   `json:"versionSkew"`  // node and master versions differ
+	Cordoned     bool   `json:"cordoned"`     // node is in maintenance mode
+}
+
+// nodesByHostname sorts the nodes by hostname
+type nodesByHostname []*NodeInfo
+
+func (n nodesByHostname) Len() int           { return len(n) }
+func (n nodesByHostname) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n nodesByHostname) Less(i, j int) bool { return n[i].Hostname < n[j].Hostname }
+
+// GetNodes returns the inventory of all nodes. registered has the host
+// labels with a live netplugin service registration, the health of the
+// nodes is derived from it and from the age of their last heartbeat as
+// received by netmaster.
+func GetNodes(stateDriver core.StateDriver, registered map[string]bool,
+	heartbeats *NodeHeartbeats, masterVersion string) ([]*NodeInfo, error) {
+	nodeCfg := &NodeState{}
+	nodeCfg.StateDriver = stateDriver
+	nodeList, err := nodeCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	maintCfg := &NodeMaintenanceState{}
+	maintCfg.StateDriver = stateDriver
+	maintList, err := maintCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+	cordoned := make(map[string]bool)
+	for _, maintState := range maintList {
+		maint := maintState.(*NodeMaintenanceState)
+		cordoned[maint.Hostname] = maint.Cordoned
+	}
+
+	nodes := []*NodeInfo{}
+	for _, nodeState := range nodeList {
+		node := &NodeInfo{NodeState: *nodeState.(*NodeState)}
+		node.Cordoned = cordoned[node.Hostname]
+		age := heartbeats.Age(node.Hostname)
+		node.HeartbeatAge = (age / time.Second * time.Second).String()
+		node.VersionSkew = node.Version != masterVersion
+
+		switch {
+		case !registered[node.Hostname]:
+			node.Health = NodeDown
+		case age > 3*NodeHeartbeatInterval:
+			node.Health = NodeStale
+		default:
+			node.Health = NodeHealthy
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	sort.Sort(nodesByHostname(nodes))
+
+	return nodes, nil
+}
+
+const (
+	nodeMaintConfigPathPrefix = StateConfigPath + "nodeMaintenance/"
+	nodeMaintConfigPath       = nodeMaintConfigPathPrefix + "%s"
+)
+
+// NodeMaintenanceState is the maintenance mode of a node set by the admin,
+// keyed by host label. No endpoints are created on a cordoned node, the
+// peers of a decommissioned node remove its VTEP.
+type NodeMaintenanceState struct {
+	core.CommonState
+	Hostname       string    `json:"hostname"`
+	Cordoned       bool      `json:"cordoned"`
+	Decommissioned bool      `json:"decommissioned"`
+	VtepIP         string    `json:"vtepIP"`
+	Time           time.Time `json:"time"` // time of the last change
+}
+
+// Write the state.
+func (s *NodeMaintenanceState) Write() error {
+	key := fmt.Sprintf(nodeMaintConfigPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *NodeMaintenanceState) Read(id string) error {
+	key := fmt.Sprintf(nodeMaintConfigPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the node maintenance modes.
+func (s *NodeMaintenanceState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(nodeMaintConfigPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to node maintenance.
+func (s *NodeMaintenanceState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(nodeMaintConfigPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *NodeMaintenanceState) Clear() error {
+	key := fmt.Sprintf(nodeMaintConfigPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
@@ -0,0 +1,87 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/state"
+)
+
+func TestGetNodes(t *testing.T) {
+	fakeDriver := &state.FakeStateDriver{}
+	fakeDriver.Init(nil)
+	defer fakeDriver.Deinit()
+
+	// the node clocks are skewed, the health only depends on the receive
+	// times of the heartbeats
+	nodeStates := []*NodeState{
+		{Hostname: "host3", Version: "1.1.0", LastHeartbeat: time.Now()},
+		{Hostname: "host1", Version: "1.1.0", LastHeartbeat: time.Now().Add(-time.Hour)},
+		{Hostname: "host2", Version: "1.0.0", LastHeartbeat: time.Now().Add(time.Hour)},
+	}
+	for _, nodeState := range nodeStates {
+		nodeState.ID = nodeState.Hostname
+		nodeState.StateDriver = fakeDriver
+		if err := nodeState.Write(); err != nil {
+			t.Fatalf("error writing node state: %v", err)
+		}
+	}
+
+	heartbeats := NewNodeHeartbeats()
+	heartbeats.Received("host1")
+	heartbeats.Received("host3")
+	heartbeats.received["host2"] = time.Now().Add(-time.Minute)
+
+	registered := map[string]bool{"host1": true, "host2": true}
+	nodes, err := GetNodes(fakeDriver, registered, heartbeats, "1.1.0")
+	if err != nil {
+		t.Fatalf("error getting nodes: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("unexpected nodes: %+v", nodes)
+	}
+
+	expected := []struct {
+		hostname string
+		health   string
+		skew     bool
+	}{
+		{"host1", NodeHealthy, false},
+		{"host2", NodeStale, true},
+		{"host3", NodeDown, false},
+	}
+	for i, exp := range expected {
+		node := nodes[i]
+		if node.Hostname != exp.hostname || node.Health != exp.health || node.VersionSkew != exp.skew {
+			t.Fatalf("unexpected node %d: %+v, expected %+v", i, node, exp)
+		}
+	}
+}
+
+func TestNodeHeartbeatsAge(t *testing.T) {
+	heartbeats := NewNodeHeartbeats()
+	heartbeats.started = time.Now().Add(-time.Minute)
+	heartbeats.Received("host1")
+
+	if age := heartbeats.Age("host1"); age > time.Second {
+		t.Fatalf("unexpected age of a received heartbeat: %v", age)
+	}
+	if age := heartbeats.Age("host2"); age < time.Minute {
+		t.Fatalf("node not heard from is not aged from the start: %v", age)
+	}
+}
@@ -0,0 +1,71 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	objLabelConfigPathPrefix = StateConfigPath + "objectLabels/"
+	objLabelConfigPath       = objLabelConfigPathPrefix + "%s"
+)
+
+// ObjectLabelState holds the labels of a contivmodel object, the model has
+// no place for them. Keyed by GetObjectLabelKey.
+type ObjectLabelState struct {
+	core.CommonState
+	ObjType string            `json:"objType"` // contivmodel type, e.g. network
+	ObjKey  string            `json:"objKey"`  // contivmodel key, e.g. default:net1
+	Labels  map[string]string `json:"labels"`
+}
+
+// GetObjectLabelKey returns the key of the labels of an object
+func GetObjectLabelKey(objType, objKey string) string {
+	return objType + ":" + objKey
+}
+
+// Write the state.
+func (s *ObjectLabelState) Write() error {
+	key := fmt.Sprintf(objLabelConfigPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *ObjectLabelState) Read(id string) error {
+	key := fmt.Sprintf(objLabelConfigPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the object labels.
+func (s *ObjectLabelState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(objLabelConfigPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to object labels.
+func (s *ObjectLabelState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(objLabelConfigPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *ObjectLabelState) Clear() error {
+	key := fmt.Sprintf(objLabelConfigPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
@@ -0,0 +1,167 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	pktTagMigrationOperPathPrefix = StateOperPath + "pktTagMigrations/"
+	pktTagMigrationOperPath       = pktTagMigrationOperPathPrefix + "%s"
+	pktTagHostOperPathPrefix      = StateOperPath + "pktTagHosts/"
+	pktTagHostOperPath            = pktTagHostOperPathPrefix + "%s"
+)
+
+// Status of a packet tag migration
+const (
+	// PktTagMigrating is a migration waiting for the nodes to move to the
+	// new packet tags
+	PktTagMigrating = "migrating"
+	// PktTagMigrated is a migration that released the old packet tags
+	PktTagMigrated = "completed"
+	// PktTagRolledBack is a failed migration that moved the network back to
+	// the old packet tags and released the new ones
+	PktTagRolledBack = "rolled back"
+)
+
+// Progress of a node in a packet tag migration
+const (
+	// PktTagHostPending is a node that has not moved to the new tags yet
+	PktTagHostPending = "pending"
+	// PktTagHostMigrated is a node that moved to the new tags
+	PktTagHostMigrated = "migrated"
+	// PktTagHostFailed is a node that failed to move to the new tags
+	PktTagHostFailed = "failed"
+)
+
+// PktTagMigrationState is the progress of moving a network to new packet
+// tags, keyed by network id. The tags are the vlan and, for vxlan networks,
+// the local vlan and the vxlan id.
+type PktTagMigrationState struct {
+	core.CommonState
+	NetworkID    string            `json:"networkID"`
+	PktTagType   string            `json:"pktTagType"`
+	OldPktTag    int               `json:"oldPktTag"`
+	OldExtPktTag int               `json:"oldExtPktTag"`
+	NewPktTag    int               `json:"newPktTag"`
+	NewExtPktTag int               `json:"newExtPktTag"`
+	Status       string            `json:"status"`
+	Error        string            `json:"error,omitempty"`
+	Hosts        map[string]string `json:"hosts"` // progress by node with endpoints in the network
+	StartTime    time.Time         `json:"startTime"`
+	EndTime      time.Time         `json:"endTime,omitempty"`
+}
+
+// Write the state.
+func (s *PktTagMigrationState) Write() error {
+	key := fmt.Sprintf(pktTagMigrationOperPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *PktTagMigrationState) Read(id string) error {
+	key := fmt.Sprintf(pktTagMigrationOperPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the packet tag migrations.
+func (s *PktTagMigrationState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(pktTagMigrationOperPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to packet tag migrations.
+func (s *PktTagMigrationState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(pktTagMigrationOperPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *PktTagMigrationState) Clear() error {
+	key := fmt.Sprintf(pktTagMigrationOperPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// PktTagHostState is the packet tags of a network programmed by a node,
+// published by its netplugin after a tag change
+type PktTagHostState struct {
+	core.CommonState
+	NetworkID string `json:"networkID"`
+	Hostname  string `json:"hostname"`
+	PktTag    int    `json:"pktTag"`
+	ExtPktTag int    `json:"extPktTag"`
+	Error     string `json:"error,omitempty"` // failure moving to the tags
+}
+
+// PktTagHostStateID returns the id of the state of a network on a node
+func PktTagHostStateID(networkID, hostname string) string {
+	return networkID + ":" + hostname
+}
+
+// Write the state.
+func (s *PktTagHostState) Write() error {
+	key := fmt.Sprintf(pktTagHostOperPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *PktTagHostState) Read(id string) error {
+	key := fmt.Sprintf(pktTagHostOperPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the packet tags of the nodes.
+func (s *PktTagHostState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(pktTagHostOperPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to the packet tags
+// of the nodes.
+func (s *PktTagHostState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(pktTagHostOperPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *PktTagHostState) Clear() error {
+	key := fmt.Sprintf(pktTagHostOperPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// ReadPktTagHostStates returns the packet tags of a network published by
+// the nodes, keyed by host label
+func ReadPktTagHostStates(stateDriver core.StateDriver, networkID string) (map[string]*PktTagHostState, error) {
+	hostCfg := &PktTagHostState{}
+	hostCfg.StateDriver = stateDriver
+	hostList, err := hostCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	hostStates := make(map[string]*PktTagHostState)
+	for _, hostState := range hostList {
+		host := hostState.(*PktTagHostState)
+		if host.NetworkID == networkID {
+			hostStates[host.Hostname] = host
+		}
+	}
+
+	return hostStates, nil
+}
@@ -0,0 +1,58 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+
+	"github.com/contiv/netplugin/state"
+)
+
+func TestReadPktTagHostStates(t *testing.T) {
+	fakeDriver := &state.FakeStateDriver{}
+	fakeDriver.Init(nil)
+	defer fakeDriver.Deinit()
+
+	hostStates, err := ReadPktTagHostStates(fakeDriver, "net1.default")
+	if err != nil {
+		t.Fatalf("error reading empty host states: %v", err)
+	}
+	if len(hostStates) != 0 {
+		t.Fatalf("unexpected host states: %+v", hostStates)
+	}
+
+	hosts := []*PktTagHostState{
+		{NetworkID: "net1.default", Hostname: "host1", PktTag: 10},
+		{NetworkID: "net1.default", Hostname: "host2", PktTag: 20, Error: "failed"},
+		{NetworkID: "net2.default", Hostname: "host1", PktTag: 30},
+	}
+	for _, host := range hosts {
+		host.ID = PktTagHostStateID(host.NetworkID, host.Hostname)
+		host.StateDriver = fakeDriver
+		if err := host.Write(); err != nil {
+			t.Fatalf("error writing host state: %v", err)
+		}
+	}
+
+	hostStates, err = ReadPktTagHostStates(fakeDriver, "net1.default")
+	if err != nil {
+		t.Fatalf("error reading host states: %v", err)
+	}
+	if len(hostStates) != 2 || hostStates["host1"].PktTag != 10 ||
+		hostStates["host2"].Error != "failed" {
+		t.Fatalf("unexpected host states: %+v", hostStates)
+	}
+}
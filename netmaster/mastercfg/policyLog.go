@@ -0,0 +1,79 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"sort"
+
+	"github.com/contiv/netplugin/core"
+)
+
+// PolicyLog is a packet logged by a policy rule along with the rule it hit
+type PolicyLog struct {
+	core.PolicyLogRecord
+	TenantName string `json:"tenantName,omitempty"`
+	PolicyName string `json:"policyName,omitempty"`
+	RuleKey    string `json:"ruleKey,omitempty"`
+}
+
+// PolicyLogFilter selects the policy logs returned by GetPolicyLogs, empty
+// fields match everything
+type PolicyLogFilter struct {
+	TenantName string
+	PolicyName string
+	Action     string
+	Limit      int // max number of logs, 0 for no limit
+}
+
+type policyLogsByTime []*PolicyLog
+
+func (l policyLogsByTime) Len() int           { return len(l) }
+func (l policyLogsByTime) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l policyLogsByTime) Less(i, j int) bool { return l[i].Time.After(l[j].Time) }
+
+// GetPolicyLogs maps the records logged by the nodes to their policy rules
+// and returns the ones matching the filter, newest first
+func GetPolicyLogs(stateDriver core.StateDriver, records []core.PolicyLogRecord,
+	filter PolicyLogFilter) ([]*PolicyLog, error) {
+	ruleMaps, err := readOfnetRuleMaps(stateDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	policyLogs := []*PolicyLog{}
+	for _, record := range records {
+		policyLog := &PolicyLog{PolicyLogRecord: record}
+		if ruleMap, found := ruleMaps[record.RuleID]; found && ruleMap.Rule != nil {
+			policyLog.TenantName = ruleMap.Rule.TenantName
+			policyLog.PolicyName = ruleMap.Rule.PolicyName
+			policyLog.RuleKey = ruleMap.Rule.Key
+		}
+
+		if (filter.TenantName != "" && filter.TenantName != policyLog.TenantName) ||
+			(filter.PolicyName != "" && filter.PolicyName != policyLog.PolicyName) ||
+			(filter.Action != "" && filter.Action != policyLog.Action) {
+			continue
+		}
+		policyLogs = append(policyLogs, policyLog)
+	}
+
+	sort.Sort(policyLogsByTime(policyLogs))
+	if filter.Limit > 0 && len(policyLogs) > filter.Limit {
+		policyLogs = policyLogs[:filter.Limit]
+	}
+
+	return policyLogs, nil
+}
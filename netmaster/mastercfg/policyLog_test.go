@@ -0,0 +1,67 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/state"
+)
+
+func TestGetPolicyLogs(t *testing.T) {
+	fakeDriver := &state.FakeStateDriver{}
+	fakeDriver.Init(nil)
+	defer fakeDriver.Deinit()
+
+	gp := &EpgPolicy{EpgPolicyKey: "default:web"}
+	gp.ID = gp.EpgPolicyKey
+	gp.StateDriver = fakeDriver
+	gp.RuleMaps = map[string]*RuleMap{
+		"default:web:1": newStatsRuleMap("default:web:1", "allow", "web:1:inRx"),
+		"default:web:2": newStatsRuleMap("default:web:2", "deny", "web:2:inRx"),
+	}
+	if err := gp.Write(); err != nil {
+		t.Fatalf("error writing epg policy: %v", err)
+	}
+
+	now := time.Now()
+	records := []core.PolicyLogRecord{
+		{Time: now.Add(-3 * time.Second), Host: "host1", RuleID: "web:1:inRx", Action: "allow"},
+		{Time: now.Add(-2 * time.Second), Host: "host1", RuleID: "web:2:inRx", Action: "deny"},
+		{Time: now.Add(-1 * time.Second), Host: "host2", RuleID: "web:2:inRx", Action: "deny"},
+		{Time: now, Host: "host2", RuleID: "deleted:1:out", Action: "deny"},
+	}
+
+	policyLogs, err := GetPolicyLogs(fakeDriver, records, PolicyLogFilter{})
+	if err != nil {
+		t.Fatalf("error getting policy logs: %v", err)
+	}
+	if len(policyLogs) != 4 || !policyLogs[0].Time.Equal(now) || policyLogs[0].RuleKey != "" {
+		t.Fatalf("unexpected policy logs: %+v", policyLogs)
+	}
+
+	policyLogs, err = GetPolicyLogs(fakeDriver, records,
+		PolicyLogFilter{TenantName: "default", PolicyName: "web", Action: "deny", Limit: 1})
+	if err != nil {
+		t.Fatalf("error getting policy logs: %v", err)
+	}
+	if len(policyLogs) != 1 || policyLogs[0].Host != "host2" ||
+		policyLogs[0].RuleKey != "default:web:2" {
+		t.Fatalf("unexpected filtered policy logs: %+v", policyLogs)
+	}
+}
@@ -32,6 +32,16 @@ const (
 type CfgPolicyRule struct {
 	core.CommonState
 	ofnet.OfnetPolicyRule
+	FqdnRule *FqdnRule `json:"fqdnRule,omitempty"` // set for rules matching a domain name
+}
+
+// FqdnRule is the domain name match of a policy rule. Such rules are not
+// installed as is, the agents install a copy of the rule for each address
+// the name resolves to.
+type FqdnRule struct {
+	TenantName string `json:"tenantName"`
+	Fqdn       string `json:"fqdn"`     // domain name, *.domain matches the subdomains
+	MatchSrc   bool   `json:"matchSrc"` // match the source address, for return traffic
 }
 
 // Write the state.
@@ -73,6 +83,17 @@ func addPolicyRuleState(ofnetRule *ofnet.OfnetPolicyRule) error {
 	return ruleCfg.Write()
 }
 
+// addFqdnPolicyRuleState adds a policy rule matching a domain name to state store
+func addFqdnPolicyRuleState(ofnetRule *ofnet.OfnetPolicyRule, fqdnRule *FqdnRule) error {
+	ruleCfg := &CfgPolicyRule{}
+	ruleCfg.StateDriver = stateStore
+	ruleCfg.OfnetPolicyRule = (*ofnetRule)
+	ruleCfg.FqdnRule = fqdnRule
+
+	// Save the rule
+	return ruleCfg.Write()
+}
+
 // delPolicyRuleState deletes policy rule from state store
 func delPolicyRuleState(ofnetRule *ofnet.OfnetPolicyRule) error {
 	ruleCfg := &CfgPolicyRule{}
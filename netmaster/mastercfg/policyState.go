@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -67,6 +68,12 @@ func InitPolicyMgr(stateDriver core.StateDriver, ofm *ofnet.OfnetMaster) error {
 	if err != nil {
 		log.Errorf("Error restoring EPG policies. ")
 	}
+
+	// restore the isolation of endpoint groups
+	err = restoreEpgIsolation(stateDriver)
+	if err != nil {
+		log.Errorf("Error restoring EPG isolation. Err: %v", err)
+	}
 	return nil
 }
 
@@ -175,6 +182,7 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 	ofnetRule.RuleId = ruleID
 	ofnetRule.Priority = rule.Priority
 	ofnetRule.Action = rule.Action
+	ofnetRule.Log = rule.Log
 
 	// See if user specified an endpoint Group in the rule
 	if rule.FromEndpointGroup != "" {
@@ -286,6 +294,25 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 		log.Fatalf("Unknown rule direction %s", dir)
 	}
 
+	// Rules on a domain name are installed by the agents for each resolved
+	// address, they are not added to the policyDB
+	if rule.ToFqdn != "" {
+		fqdnRule := &FqdnRule{
+			TenantName: rule.TenantName,
+			Fqdn:       strings.ToLower(strings.TrimSuffix(rule.ToFqdn, ".")),
+			MatchSrc:   dir == "outRx",
+		}
+		err = addFqdnPolicyRuleState(ofnetRule, fqdnRule)
+		if err != nil {
+			log.Errorf("Error creating rule {%+v}. Err: %v", ofnetRule, err)
+			return nil, err
+		}
+
+		log.Infof("Added rule {%+v} on %s", ofnetRule, fqdnRule.Fqdn)
+
+		return ofnetRule, nil
+	}
+
 	// Add the Rule to policyDB
 	err = ofnetMaster.AddRule(ofnetRule)
 	if err != nil {
@@ -374,13 +401,15 @@ func (gp *EpgPolicy) DelRule(rule *contivModel.Rule) error {
 		log.Infof("Deleting rule {%+v} from policyDB", ofnetRule)
 
 		// Delete the rule from policyDB
-		err := ofnetMaster.DelRule(ofnetRule)
-		if err != nil {
-			log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
+		if rule.ToFqdn == "" {
+			err := ofnetMaster.DelRule(ofnetRule)
+			if err != nil {
+				log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
+			}
 		}
 
 		// Send DelRule to netplugin agents
-		err = delPolicyRuleState(ofnetRule)
+		err := delPolicyRuleState(ofnetRule)
 		if err != nil {
 			log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
 		}
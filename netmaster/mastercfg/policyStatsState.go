@@ -0,0 +1,147 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	policyStatsOperPathPrefix = StateOperPath + "policyStats/"
+	policyStatsOperPath       = policyStatsOperPathPrefix + "%s"
+)
+
+// PolicyStatsState has the policy rule counters published by a node, the
+// rules are keyed by the ofnet rule id
+type PolicyStatsState struct {
+	core.CommonState
+	Rules map[string]core.PolicyRuleStats `json:"rules"`
+}
+
+// Write the state.
+func (s *PolicyStatsState) Write() error {
+	key := fmt.Sprintf(policyStatsOperPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state for a given identifier.
+func (s *PolicyStatsState) Read(id string) error {
+	key := fmt.Sprintf(policyStatsOperPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all state objects for the policy stats.
+func (s *PolicyStatsState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(policyStatsOperPathPrefix, s, json.Unmarshal)
+}
+
+// WatchAll fills a channel on each state event related to policy stats.
+func (s *PolicyStatsState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(policyStatsOperPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// Clear removes the state.
+func (s *PolicyStatsState) Clear() error {
+	key := fmt.Sprintf(policyStatsOperPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// readOfnetRuleMaps maps the ofnet rules back to the policy rules they
+// implement, keyed by ofnet rule id
+func readOfnetRuleMaps(stateDriver core.StateDriver) (map[string]*RuleMap, error) {
+	gp := &EpgPolicy{}
+	gp.StateDriver = stateDriver
+	gpList, err := gp.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+
+	ruleMaps := make(map[string]*RuleMap)
+	for _, gpState := range gpList {
+		for _, ruleMap := range gpState.(*EpgPolicy).RuleMaps {
+			for ofnetRuleID := range ruleMap.OfnetRules {
+				ruleMaps[ofnetRuleID] = ruleMap
+			}
+		}
+	}
+
+	return ruleMaps, nil
+}
+
+// RuleStats has the cluster wide counters of a policy rule
+type RuleStats struct {
+	Action  string `json:"action"`  // rule action
+	Packets uint64 `json:"packets"` // packets matching the rule
+	Bytes   uint64 `json:"bytes"`   // bytes matching the rule
+}
+
+// PolicyStats has the cluster wide counters of a policy, packets hitting
+// deny or reject rules are counted as violations
+type PolicyStats struct {
+	Violations uint64                `json:"violations"`
+	Rules      map[string]*RuleStats `json:"rules"` // stats by rule key
+}
+
+// GetPolicyStats aggregates the rule counters published by all nodes per
+// policy, keyed by policy key
+func GetPolicyStats(stateDriver core.StateDriver) (map[string]*PolicyStats, error) {
+	policyStats := make(map[string]*PolicyStats)
+
+	ruleMaps, err := readOfnetRuleMaps(stateDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	statsCfg := &PolicyStatsState{}
+	statsCfg.StateDriver = stateDriver
+	statsList, err := statsCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return nil, err
+	}
+	for _, statsState := range statsList {
+		for ofnetRuleID, hits := range statsState.(*PolicyStatsState).Rules {
+			ruleMap, found := ruleMaps[ofnetRuleID]
+			if !found || ruleMap.Rule == nil {
+				continue // the rule was deleted
+			}
+
+			rule := ruleMap.Rule
+			policyKey := rule.TenantName + ":" + rule.PolicyName
+			pStats, found := policyStats[policyKey]
+			if !found {
+				pStats = &PolicyStats{Rules: make(map[string]*RuleStats)}
+				policyStats[policyKey] = pStats
+			}
+			rStats, found := pStats.Rules[rule.Key]
+			if !found {
+				rStats = &RuleStats{Action: rule.Action}
+				pStats.Rules[rule.Key] = rStats
+			}
+
+			rStats.Packets += hits.Packets
+			rStats.Bytes += hits.Bytes
+			if rule.Action == "deny" || rule.Action == "reject" {
+				pStats.Violations += hits.Packets
+			}
+		}
+	}
+
+	return policyStats, nil
+}
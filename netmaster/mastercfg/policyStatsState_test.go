@@ -0,0 +1,103 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/ofnet"
+)
+
+func newStatsRuleMap(key, action string, ofnetRuleIDs ...string) *RuleMap {
+	ruleMap := &RuleMap{
+		Rule: &contivModel.Rule{
+			Key:        key,
+			TenantName: "default",
+			PolicyName: "web",
+			Action:     action,
+		},
+		OfnetRules: make(map[string]*ofnet.OfnetPolicyRule),
+	}
+	for _, ruleID := range ofnetRuleIDs {
+		ruleMap.OfnetRules[ruleID] = &ofnet.OfnetPolicyRule{RuleId: ruleID, Action: action}
+	}
+
+	return ruleMap
+}
+
+func TestGetPolicyStats(t *testing.T) {
+	fakeDriver := &state.FakeStateDriver{}
+	fakeDriver.Init(nil)
+	defer fakeDriver.Deinit()
+
+	gp := &EpgPolicy{EpgPolicyKey: "default:web"}
+	gp.ID = gp.EpgPolicyKey
+	gp.StateDriver = fakeDriver
+	gp.RuleMaps = map[string]*RuleMap{
+		"default:web:1": newStatsRuleMap("default:web:1", "allow", "web:1:inRx", "web:1:inTx"),
+		"default:web:2": newStatsRuleMap("default:web:2", "deny", "web:2:inRx"),
+	}
+	if err := gp.Write(); err != nil {
+		t.Fatalf("error writing epg policy: %v", err)
+	}
+
+	nodeStats := map[string]map[string]core.PolicyRuleStats{
+		"host1": {
+			"web:1:inRx":    {Packets: 10, Bytes: 1000},
+			"web:1:inTx":    {Packets: 5, Bytes: 500},
+			"web:2:inRx":    {Packets: 3, Bytes: 180},
+			"deleted:1:out": {Packets: 7, Bytes: 700},
+		},
+		"host2": {
+			"web:2:inRx": {Packets: 4, Bytes: 240},
+		},
+	}
+	for host, rules := range nodeStats {
+		statsCfg := &PolicyStatsState{Rules: rules}
+		statsCfg.ID = host
+		statsCfg.StateDriver = fakeDriver
+		if err := statsCfg.Write(); err != nil {
+			t.Fatalf("error writing policy stats: %v", err)
+		}
+	}
+
+	policyStats, err := GetPolicyStats(fakeDriver)
+	if err != nil {
+		t.Fatalf("error getting policy stats: %v", err)
+	}
+	if len(policyStats) != 1 {
+		t.Fatalf("unexpected policies: %+v", policyStats)
+	}
+
+	pStats := policyStats["default:web"]
+	if pStats == nil || len(pStats.Rules) != 2 {
+		t.Fatalf("unexpected policy stats: %+v", pStats)
+	}
+	if pStats.Violations != 7 {
+		t.Fatalf("got %d violations, expected 7", pStats.Violations)
+	}
+	allow := pStats.Rules["default:web:1"]
+	if allow.Packets != 15 || allow.Bytes != 1500 || allow.Action != "allow" {
+		t.Fatalf("unexpected allow rule stats: %+v", allow)
+	}
+	deny := pStats.Rules["default:web:2"]
+	if deny.Packets != 7 || deny.Bytes != 420 || deny.Action != "deny" {
+		t.Fatalf("unexpected deny rule stats: %+v", deny)
+	}
+}
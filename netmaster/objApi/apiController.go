@@ -60,13 +60,13 @@ type BgpInspect struct {
 var apiCtrler *APIController
 
 // NewAPIController creates a new controller
-func NewAPIController(router *mux.Router, objdbClient objdb.API, storeURL string) *APIController {
+func NewAPIController(router *mux.Router, objdbClient objdb.API) *APIController {
 	ctrler := new(APIController)
 	ctrler.router = router
 	ctrler.objdbClient = objdbClient
 
-	// init modeldb
-	modeldb.Init(storeURL)
+	// init modeldb, sharing the connection to the state store
+	modeldb.InitClient(objdbClient)
 
 	// initialize the model objects
 	contivModel.Init()
@@ -85,8 +85,9 @@ func NewAPIController(router *mux.Router, objdbClient objdb.API, storeURL string
 	contivModel.RegisterEndpointCallbacks(ctrler)
 	contivModel.RegisterNetprofileCallbacks(ctrler)
 	contivModel.RegisterAciGwCallbacks(ctrler)
-	// Register routes
-	contivModel.AddRoutes(router)
+	// Register routes, dry runs are matched first
+	ctrler.addDryRunRoutes(router)
+	addModelRoutes(router)
 
 	// Init global state
 	gc := contivModel.FindGlobal("global")
@@ -140,6 +141,22 @@ func NewAPIController(router *mux.Router, objdbClient objdb.API, storeURL string
 	return ctrler
 }
 
+// addModelRoutes routes the model objects, their changes are serialized
+// with the changes netmaster makes to them
+func addModelRoutes(router *mux.Router) {
+	changes := mux.NewRouter()
+	contivModel.AddRoutes(changes)
+	router.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+		return r.Method != "GET" && changes.Match(r, &mux.RouteMatch{})
+	}).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gstate.ModelMutex.Lock()
+		defer gstate.ModelMutex.Unlock()
+		changes.ServeHTTP(w, r)
+	})
+
+	contivModel.AddRoutes(router)
+}
+
 // Utility function to check if string exists in a slice
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
@@ -212,24 +229,14 @@ func (ac *APIController) GlobalCreate(global *contivModel.Global) error {
 	return nil
 }
 
-// GlobalUpdate updates global state
-func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error {
-	log.Infof("Received GlobalUpdate: %+v. Old: %+v", params, global)
-
-	// Get the state driver
-	stateDriver, err := utils.GetStateDriver()
-	if err != nil {
-		return err
-	}
-
+// checkGlobalUpdate checks the forwarding mode and the private subnet can be
+// changed
+func checkGlobalUpdate(stateDriver core.StateDriver, global, params *contivModel.Global) error {
 	gCfg := &gstate.Cfg{}
 	gCfg.StateDriver = stateDriver
 	numVlans, vlansInUse := gCfg.GetVlansInUse()
 	numVxlans, vxlansInUse := gCfg.GetVxlansInUse()
 
-	// Build global config
-	globalCfg := intent.ConfigGlobal{}
-
 	//check for change in forwarding mode
 	if global.FwdMode != params.FwdMode {
 		//check if there exists any non default network and tenants
@@ -247,6 +254,21 @@ func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error
 				return fmt.Errorf("please delete existing Bgp configs")
 			}
 		}
+	}
+	if global.PvtSubnet != params.PvtSubnet {
+		if (global.PvtSubnet != "" || params.PvtSubnet != defHostPvtNet) && numVlans+numVxlans > 0 {
+			log.Errorf("Unable to update provate subnet due to existing networks")
+			return fmt.Errorf("Please delete %v vlans and %v vxlans before changing private subnet", vlansInUse, vxlansInUse)
+		}
+	}
+
+	return nil
+}
+
+// globalUpdateConfig returns the global config with the changed parameters
+func globalUpdateConfig(global, params *contivModel.Global) intent.ConfigGlobal {
+	globalCfg := intent.ConfigGlobal{}
+	if global.FwdMode != params.FwdMode {
 		globalCfg.FwdMode = params.FwdMode
 	}
 	if global.ArpMode != params.ArpMode {
@@ -262,13 +284,30 @@ func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error
 		globalCfg.NwInfraType = params.NetworkInfraType
 	}
 	if global.PvtSubnet != params.PvtSubnet {
-		if (global.PvtSubnet != "" || params.PvtSubnet != defHostPvtNet) && numVlans+numVxlans > 0 {
-			log.Errorf("Unable to update provate subnet due to existing networks")
-			return fmt.Errorf("Please delete %v vlans and %v vxlans before changing private subnet", vlansInUse, vxlansInUse)
-		}
 		globalCfg.PvtSubnet = params.PvtSubnet
 	}
 
+	return globalCfg
+}
+
+// GlobalUpdate updates global state
+func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error {
+	log.Infof("Received GlobalUpdate: %+v. Old: %+v", params, global)
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	err = checkGlobalUpdate(stateDriver, global, params)
+	if err != nil {
+		return err
+	}
+
+	// Build global config
+	globalCfg := globalUpdateConfig(global, params)
+
 	// Create the object
 	err = master.UpdateGlobal(stateDriver, &globalCfg)
 	if err != nil {
@@ -336,10 +375,8 @@ func (ac *APIController) AciGwUpdate(aci, params *contivModel.AciGw) error {
 	return nil
 }
 
-// AciGwDelete deletes aci state
-func (ac *APIController) AciGwDelete(aci *contivModel.AciGw) error {
-	log.Infof("Received AciGwDelete")
-	// Fail the delete if app profiles exist
+// checkAciGwInUse fails the delete of the aci state if app profiles exist
+func checkAciGwInUse() error {
 	profCount := contivModel.GetAppProfileCount()
 	if profCount != 0 {
 		return core.Errorf("%d App-Profiles found. Delete them first",
@@ -349,32 +386,75 @@ func (ac *APIController) AciGwDelete(aci *contivModel.AciGw) error {
 	return nil
 }
 
+// AciGwDelete deletes aci state
+func (ac *APIController) AciGwDelete(aci *contivModel.AciGw) error {
+	log.Infof("Received AciGwDelete")
+	return checkAciGwInUse()
+}
+
 // AciGwGetOper provides operational info for the aci object
 func (ac *APIController) AciGwGetOper(op *contivModel.AciGwInspect) error {
 	op.Oper.NumAppProfiles = contivModel.GetAppProfileCount()
 	return nil
 }
 
-// AppProfileCreate creates app profile state
-func (ac *APIController) AppProfileCreate(prof *contivModel.AppProfile) error {
-	log.Infof("Received AppProfileCreate: %+v", prof)
-
-	// Make sure tenant exists
-	if prof.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
+// findTenant returns the tenant of an object, it fails if the tenant does
+// not exist
+func findTenant(tenantName string) (*contivModel.Tenant, error) {
+	if tenantName == "" {
+		return nil, core.Errorf("Invalid tenant name")
 	}
 
-	tenant := contivModel.FindTenant(prof.TenantName)
+	tenant := contivModel.FindTenant(tenantName)
 	if tenant == nil {
-		return core.Errorf("Tenant %s not found", prof.TenantName)
+		return nil, core.Errorf("Tenant %s not found", tenantName)
 	}
 
+	return tenant, nil
+}
+
+// checkAppProfileGroups returns the endpoint groups of an app profile, they
+// must exist
+func checkAppProfileGroups(prof *contivModel.AppProfile) ([]*contivModel.EndpointGroup, error) {
+	epgObjs := []*contivModel.EndpointGroup{}
 	for _, epg := range prof.EndpointGroups {
 		epgKey := prof.TenantName + ":" + epg
 		epgObj := contivModel.FindEndpointGroup(epgKey)
 		if epgObj == nil {
-			return core.Errorf("EndpointGroup %s not found", epgKey)
+			return nil, core.Errorf("EndpointGroup %s not found", epgKey)
 		}
+		epgObjs = append(epgObjs, epgObj)
+	}
+
+	return epgObjs, nil
+}
+
+// checkAppProfileCreate checks the tenant and the endpoint groups of a new
+// app profile exist
+func checkAppProfileCreate(prof *contivModel.AppProfile) (*contivModel.Tenant, []*contivModel.EndpointGroup, error) {
+	tenant, err := findTenant(prof.TenantName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	epgObjs, err := checkAppProfileGroups(prof)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tenant, epgObjs, nil
+}
+
+// AppProfileCreate creates app profile state
+func (ac *APIController) AppProfileCreate(prof *contivModel.AppProfile) error {
+	log.Infof("Received AppProfileCreate: %+v", prof)
+
+	tenant, epgObjs, err := checkAppProfileCreate(prof)
+	if err != nil {
+		return err
+	}
+
+	for _, epgObj := range epgObjs {
 		modeldb.AddLinkSet(&prof.LinkSets.EndpointGroups, epgObj)
 		modeldb.AddLink(&epgObj.Links.AppProfile, prof)
 		err := epgObj.Write()
@@ -388,7 +468,7 @@ func (ac *APIController) AppProfileCreate(prof *contivModel.AppProfile) error {
 	modeldb.AddLink(&prof.Links.Tenant, tenant)
 	modeldb.AddLinkSet(&tenant.LinkSets.AppProfiles, prof)
 
-	err := tenant.Write()
+	err = tenant.Write()
 	if err != nil {
 		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
 		return err
@@ -402,14 +482,14 @@ func (ac *APIController) AppProfileCreate(prof *contivModel.AppProfile) error {
 func (ac *APIController) AppProfileUpdate(oldProf, newProf *contivModel.AppProfile) error {
 	log.Infof("Received AppProfileUpdate: %+v, newProf: %+v", oldProf, newProf)
 
+	epgObjs, err := checkAppProfileGroups(newProf)
+	if err != nil {
+		return err
+	}
+
 	// handle any epg addition
-	for _, epg := range newProf.EndpointGroups {
-		epgKey := newProf.TenantName + ":" + epg
-		log.Infof("Add %s to %s", epgKey, newProf.AppProfileName)
-		epgObj := contivModel.FindEndpointGroup(epgKey)
-		if epgObj == nil {
-			return core.Errorf("EndpointGroup %s not found", epgKey)
-		}
+	for _, epgObj := range epgObjs {
+		log.Infof("Add %s to %s", epgObj.Key, newProf.AppProfileName)
 		modeldb.AddLinkSet(&newProf.LinkSets.EndpointGroups, epgObj)
 
 		// workaround for objdb update problem
@@ -458,9 +538,9 @@ func (ac *APIController) AppProfileUpdate(oldProf, newProf *contivModel.AppProfi
 func (ac *APIController) AppProfileDelete(prof *contivModel.AppProfile) error {
 	log.Infof("Received AppProfileDelete: %+v", prof)
 
-	tenant := contivModel.FindTenant(prof.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", prof.TenantName)
+	tenant, err := findTenant(prof.TenantName)
+	if err != nil {
+		return err
 	}
 
 	DeleteAppNw(prof)
@@ -604,34 +684,91 @@ func endpointGroupCleanup(endpointGroup *contivModel.EndpointGroup) error {
 // FIXME: hack to allocate unique endpoint group ids
 var globalEpgID = 1
 
-// EndpointGroupCreate creates Endpoint Group
-func (ac *APIController) EndpointGroupCreate(endpointGroup *contivModel.EndpointGroup) error {
-	log.Infof("Received EndpointGroupCreate: %+v", endpointGroup)
+// checkEndpointGroupLinks checks the policies, netprofile and external
+// contracts groups of an endpoint group exist
+func checkEndpointGroupLinks(endpointGroup *contivModel.EndpointGroup) error {
+	for _, policyName := range endpointGroup.Policies {
+		policyKey := GetpolicyKey(endpointGroup.TenantName, policyName)
+		if contivModel.FindPolicy(policyKey) == nil {
+			log.Errorf("Could not find policy %s", policyName)
+			return core.Errorf("Policy not found")
+		}
+	}
+
+	if endpointGroup.NetProfile != "" {
+		profileKey := GetNetprofileKey(endpointGroup.TenantName, endpointGroup.NetProfile)
+		if contivModel.FindNetprofile(profileKey) == nil {
+			log.Errorf("Error finding netprofile: %s", profileKey)
+			return errors.New("Netprofile not found")
+		}
+	}
+
+	for _, contractsGrp := range endpointGroup.ExtContractsGrps {
+		contractsGrpKey := endpointGroup.TenantName + ":" + contractsGrp
+		if contivModel.FindExtContractsGroup(contractsGrpKey) == nil {
+			return core.Errorf("External contracts group %s not found", contractsGrp)
+		}
+	}
+
+	return nil
+}
 
+// checkEndpointGroupCreate checks a new endpoint group, it returns its
+// tenant and network
+func checkEndpointGroupCreate(endpointGroup *contivModel.EndpointGroup) (*contivModel.Tenant, *contivModel.Network, error) {
 	// Find the tenant
-	tenant := contivModel.FindTenant(endpointGroup.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant not found")
+	tenant, err := findTenant(endpointGroup.TenantName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkEndpointGroupQuota(tenant); err != nil {
+		return nil, nil, err
 	}
 	// Find the network
 	nwObjKey := endpointGroup.TenantName + ":" + endpointGroup.NetworkName
 	network := contivModel.FindNetwork(nwObjKey)
 	if network == nil {
-		return core.Errorf("Network %s not found", endpointGroup.NetworkName)
+		return nil, nil, core.Errorf("Network %s not found", endpointGroup.NetworkName)
 	}
 	// If there is a Network with the same name as this endpointGroup, reject.
 	nameClash := contivModel.FindNetwork(endpointGroup.Key)
 	if nameClash != nil {
-		return core.Errorf("Network %s conflicts with the endpointGroup name",
+		return nil, nil, core.Errorf("Network %s conflicts with the endpointGroup name",
 			nameClash.NetworkName)
 	}
+
+	if err := checkEndpointGroupLinks(endpointGroup); err != nil {
+		return nil, nil, err
+	}
+
+	return tenant, network, nil
+}
+
+// EndpointGroupCreate creates Endpoint Group
+func (ac *APIController) EndpointGroupCreate(endpointGroup *contivModel.EndpointGroup) error {
+	log.Infof("Received EndpointGroupCreate: %+v", endpointGroup)
+
+	tenant, network, err := checkEndpointGroupCreate(endpointGroup)
+	if err != nil {
+		return err
+	}
+
 	// create the endpoint group state
-	err := master.CreateEndpointGroup(endpointGroup.TenantName, endpointGroup.NetworkName,
+	err = master.CreateEndpointGroup(endpointGroup.TenantName, endpointGroup.NetworkName,
 		endpointGroup.GroupName, endpointGroup.IpPool, endpointGroup.CfgdTag)
 	if err != nil {
 		log.Errorf("Error creating endpoint group %+v. Err: %v", endpointGroup, err)
 		return err
 	}
+	// isolate the endpoints of the group from each other
+	if endpointGroup.Isolation {
+		err = master.SetEndpointGroupIsolation(endpointGroup.TenantName, endpointGroup.GroupName, true)
+		if err != nil {
+			log.Errorf("Error isolating endpoint group %s. Err: %v", endpointGroup.Key, err)
+			endpointGroupCleanup(endpointGroup)
+			return err
+		}
+	}
 	// for each policy create an epg policy Instance
 	for _, policyName := range endpointGroup.Policies {
 		policyKey := GetpolicyKey(endpointGroup.TenantName, policyName)
@@ -723,10 +860,9 @@ func (ac *APIController) EndpointGroupCreate(endpointGroup *contivModel.Endpoint
 	return nil
 }
 
-// EndpointGroupUpdate updates endpoint group
-func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.EndpointGroup) error {
-	log.Infof("Received EndpointGroupUpdate: %+v, params: %+v", endpointGroup, params)
-
+// checkEndpointGroupUpdate checks the changes to an endpoint group, its
+// network and IP pool can not change
+func checkEndpointGroupUpdate(endpointGroup, params *contivModel.EndpointGroup) error {
 	// if the network association was changed, reject the update.
 	if endpointGroup.NetworkName != params.NetworkName {
 		return core.Errorf("Cannot change network association after epg is created.")
@@ -736,6 +872,26 @@ func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.
 		return core.Errorf("Cannot change IP pool after epg is created.")
 	}
 
+	return checkEndpointGroupLinks(params)
+}
+
+// EndpointGroupUpdate updates endpoint group
+func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.EndpointGroup) error {
+	log.Infof("Received EndpointGroupUpdate: %+v, params: %+v", endpointGroup, params)
+
+	if err := checkEndpointGroupUpdate(endpointGroup, params); err != nil {
+		return err
+	}
+
+	if endpointGroup.Isolation != params.Isolation {
+		err := master.SetEndpointGroupIsolation(endpointGroup.TenantName, endpointGroup.GroupName, params.Isolation)
+		if err != nil {
+			log.Errorf("Error updating isolation of epg %s. Err: %v", endpointGroup.Key, err)
+			return err
+		}
+		endpointGroup.Isolation = params.Isolation
+	}
+
 	// Only update policy attachments
 
 	// Look for policy adds
@@ -974,14 +1130,24 @@ func (ac *APIController) EndpointGroupGetOper(endpointGroup *contivModel.Endpoin
 	return nil
 }
 
+// checkEndpointGroupDelete fails the delete of an endpoint group used by an
+// app profile
+func checkEndpointGroupDelete(endpointGroup *contivModel.EndpointGroup) error {
+	if endpointGroup.Links.AppProfile.ObjKey != "" {
+		return core.Errorf("Cannot delete %s, associated to appProfile %s",
+			endpointGroup.GroupName, endpointGroup.Links.AppProfile.ObjKey)
+	}
+
+	return nil
+}
+
 // EndpointGroupDelete deletes end point group
 func (ac *APIController) EndpointGroupDelete(endpointGroup *contivModel.EndpointGroup) error {
 	log.Infof("Received EndpointGroupDelete: %+v", endpointGroup)
 
 	// if this is associated with an app profile, reject the delete
-	if endpointGroup.Links.AppProfile.ObjKey != "" {
-		return core.Errorf("Cannot delete %s, associated to appProfile %s",
-			endpointGroup.GroupName, endpointGroup.Links.AppProfile.ObjKey)
+	if err := checkEndpointGroupDelete(endpointGroup); err != nil {
+		return err
 	}
 
 	// get the netprofile structure by finding the netprofile
@@ -1002,21 +1168,13 @@ func (ac *APIController) EndpointGroupDelete(endpointGroup *contivModel.Endpoint
 
 }
 
-// NetworkCreate creates network
-func (ac *APIController) NetworkCreate(network *contivModel.Network) error {
-	log.Infof("Received NetworkCreate: %+v", network)
-
-	// Make sure tenant exists
-	if network.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
-	}
-
-	tenant := contivModel.FindTenant(network.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant not found")
-	}
-
+// checkSubnetOverlap checks the subnets of a network don't overlap the other
+// networks of the tenant
+func checkSubnetOverlap(tenant *contivModel.Tenant, network *contivModel.Network) error {
 	for key := range tenant.LinkSets.Networks {
+		if key == network.Key {
+			continue
+		}
 		networkDetail := contivModel.FindNetwork(key)
 		if networkDetail == nil {
 			log.Errorf("Network key %s not found", key)
@@ -1024,31 +1182,73 @@ func (ac *APIController) NetworkCreate(network *contivModel.Network) error {
 		}
 
 		// Check for overlapping subnetv6 if existing and current subnetv6 is non-empty
-		if network.Ipv6Subnet != "" && networkDetail.Ipv6Subnet != "" {
-			flagv6 := netutils.IsOverlappingSubnetv6(network.Ipv6Subnet, networkDetail.Ipv6Subnet)
-			if flagv6 == true {
-				log.Errorf("Overlapping of Subnetv6 Networks")
-				return errors.New("Network " + networkDetail.NetworkName + " conflicts with subnetv6  " + network.Ipv6Subnet)
-			}
+		if network.Ipv6Subnet != "" && networkDetail.Ipv6Subnet != "" &&
+			netutils.IsOverlappingSubnetv6(network.Ipv6Subnet, networkDetail.Ipv6Subnet) {
+			log.Errorf("Overlapping of Subnetv6 Networks")
+			return errors.New("Network " + networkDetail.NetworkName + " conflicts with subnetv6  " + network.Ipv6Subnet)
 		}
 
 		// Check for overlapping subnet if existing and current subnet is non-empty
-		if network.Subnet != "" && networkDetail.Subnet != "" {
-			flag := netutils.IsOverlappingSubnet(network.Subnet, networkDetail.Subnet)
-			if flag == true {
-				log.Errorf("Overlapping of Networks")
-				return errors.New("Network " + networkDetail.NetworkName + " conflicts with subnet " + network.Subnet)
-			}
+		if network.Subnet != "" && networkDetail.Subnet != "" &&
+			netutils.IsOverlappingSubnet(network.Subnet, networkDetail.Subnet) {
+			log.Errorf("Overlapping of Networks")
+			return errors.New("Network " + networkDetail.NetworkName + " conflicts with subnet " + network.Subnet)
 		}
 	}
 
+	return nil
+}
+
+// networkConfig returns the intent config of a network
+func networkConfig(network *contivModel.Network) intent.ConfigNetwork {
+	return intent.ConfigNetwork{
+		Name:           network.NetworkName,
+		NwType:         network.NwType,
+		PktTagType:     network.Encap,
+		PktTag:         network.PktTag,
+		SubnetCIDR:     network.Subnet,
+		Gateway:        network.Gateway,
+		IPv6SubnetCIDR: network.Ipv6Subnet,
+		IPv6Gateway:    network.Ipv6Gateway,
+		CfgdTag:        network.CfgdTag,
+	}
+}
+
+// checkNetworkCreate checks a new network, it returns its tenant
+func checkNetworkCreate(network *contivModel.Network) (*contivModel.Tenant, error) {
+	// Make sure tenant exists
+	tenant, err := findTenant(network.TenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNetworkQuota(tenant); err != nil {
+		return nil, err
+	}
+
+	if err := checkSubnetOverlap(tenant, network); err != nil {
+		return nil, err
+	}
+
 	// If there is an EndpointGroup with the same name as this network, reject.
 	nameClash := contivModel.FindEndpointGroup(network.Key)
 	if nameClash != nil {
-		return core.Errorf("EndpointGroup %s conflicts with the network name",
+		return nil, core.Errorf("EndpointGroup %s conflicts with the network name",
 			nameClash.GroupName)
 	}
 
+	return tenant, nil
+}
+
+// NetworkCreate creates network
+func (ac *APIController) NetworkCreate(network *contivModel.Network) error {
+	log.Infof("Received NetworkCreate: %+v", network)
+
+	tenant, err := checkNetworkCreate(network)
+	if err != nil {
+		return err
+	}
+
 	// Get the state driver
 	stateDriver, err := utils.GetStateDriver()
 	if err != nil {
@@ -1056,17 +1256,7 @@ func (ac *APIController) NetworkCreate(network *contivModel.Network) error {
 	}
 
 	// Build network config
-	networkCfg := intent.ConfigNetwork{
-		Name:           network.NetworkName,
-		NwType:         network.NwType,
-		PktTagType:     network.Encap,
-		PktTag:         network.PktTag,
-		SubnetCIDR:     network.Subnet,
-		Gateway:        network.Gateway,
-		IPv6SubnetCIDR: network.Ipv6Subnet,
-		IPv6Gateway:    network.Ipv6Gateway,
-		CfgdTag:        network.CfgdTag,
-	}
+	networkCfg := networkConfig(network)
 
 	// Create the network
 	err = master.CreateNetwork(networkCfg, stateDriver, network.TenantName)
@@ -1143,35 +1333,89 @@ func (ac *APIController) NetworkGetOper(network *contivModel.NetworkInspect) err
 	return nil
 }
 
+// checkNetworkUpdate checks the changes to a network, its type, encap and
+// pkt tag can not change and its subnets must not overlap
+func checkNetworkUpdate(network, params *contivModel.Network) error {
+	if params.NwType != network.NwType || params.Encap != network.Encap ||
+		params.PktTag != network.PktTag {
+		return core.Errorf("Cant change network type, encap or pkt-tag after its created")
+	}
+
+	tenant, err := findTenant(network.TenantName)
+	if err != nil {
+		return err
+	}
+
+	return checkSubnetOverlap(tenant, params)
+}
+
 // NetworkUpdate updates network
 func (ac *APIController) NetworkUpdate(network, params *contivModel.Network) error {
 	log.Infof("Received NetworkUpdate: %+v, params: %+v", network, params)
-	return core.Errorf("Cant change network parameters after its created")
-}
 
-// NetworkDelete deletes network
-func (ac *APIController) NetworkDelete(network *contivModel.Network) error {
-	log.Infof("Received NetworkDelete: %+v", network)
+	if err := checkNetworkUpdate(network, params); err != nil {
+		return err
+	}
 
-	// Find the tenant
-	tenant := contivModel.FindTenant(network.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant not found")
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
 	}
 
-	// if the network has associated epgs, fail the delete
-	epgCount := len(network.LinkSets.EndpointGroups)
-	if epgCount != 0 {
-		return core.Errorf("cannot delete %s has %d endpoint groups",
+	networkCfg := networkConfig(params)
+	networkCfg.Name = network.NetworkName
+
+	err = master.UpdateNetwork(networkCfg, stateDriver, network.TenantName)
+	if err != nil {
+		log.Errorf("Error updating network {%+v}. Err: %v", network, err)
+		return err
+	}
+
+	// the model saves the updated network object
+	network.Subnet = params.Subnet
+	network.Gateway = params.Gateway
+	network.Ipv6Subnet = params.Ipv6Subnet
+	network.Ipv6Gateway = params.Ipv6Gateway
+	network.CfgdTag = params.CfgdTag
+
+	return nil
+}
+
+// checkNetworkDelete fails the delete of a network with endpoint groups or
+// services, it returns the tenant of the network
+func checkNetworkDelete(network *contivModel.Network) (*contivModel.Tenant, error) {
+	// Find the tenant
+	tenant, err := findTenant(network.TenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	// if the network has associated epgs, fail the delete
+	epgCount := len(network.LinkSets.EndpointGroups)
+	if epgCount != 0 {
+		return nil, core.Errorf("cannot delete %s has %d endpoint groups",
 			network.NetworkName, epgCount)
 	}
 
 	svcCount := len(network.LinkSets.Servicelbs)
 	if svcCount != 0 {
-		return core.Errorf("cannot delete %s has %d services ",
+		return nil, core.Errorf("cannot delete %s has %d services ",
 			network.NetworkName, svcCount)
 	}
 
+	return tenant, nil
+}
+
+// NetworkDelete deletes network
+func (ac *APIController) NetworkDelete(network *contivModel.Network) error {
+	log.Infof("Received NetworkDelete: %+v", network)
+
+	tenant, err := checkNetworkDelete(network)
+	if err != nil {
+		return err
+	}
+
 	// Remove link
 	modeldb.RemoveLinkSet(&tenant.LinkSets.Networks, network)
 
@@ -1193,22 +1437,37 @@ func (ac *APIController) NetworkDelete(network *contivModel.Network) error {
 	return tenant.Write()
 }
 
-// NetprofileCreate creates the network rule
-func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) error {
-	log.Infof("Received NetprofileCreate: %+v", netProfile)
+// checkNetprofileParams checks the burst size of a netprofile
+func checkNetprofileParams(netProfile *contivModel.Netprofile) error {
+	if netProfile.Burst > 0 && netProfile.Burst < 2 {
+		return core.Errorf("Invalid Burst size. burst size must be > 1500 bytes")
+	}
+
+	return nil
+}
 
+// checkNetprofileCreate checks a new netprofile, it returns its tenant
+func checkNetprofileCreate(netProfile *contivModel.Netprofile) (*contivModel.Tenant, error) {
 	// Check if the tenant exists
-	if netProfile.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
+	tenant, err := findTenant(netProfile.TenantName)
+	if err != nil {
+		return nil, err
 	}
 
-	if netProfile.Burst > 0 && netProfile.Burst < 2 {
-		return core.Errorf("Invalid Burst size. burst size > 1500 bytes")
+	if err := checkNetprofileParams(netProfile); err != nil {
+		return nil, err
 	}
 
-	tenant := contivModel.FindTenant(netProfile.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant not found")
+	return tenant, nil
+}
+
+// NetprofileCreate creates the network rule
+func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) error {
+	log.Infof("Received NetprofileCreate: %+v", netProfile)
+
+	tenant, err := checkNetprofileCreate(netProfile)
+	if err != nil {
+		return err
 	}
 
 	// Setup links & Linksets.
@@ -1216,7 +1475,7 @@ func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) er
 	modeldb.AddLinkSet(&tenant.LinkSets.NetProfiles, netProfile)
 
 	// Save the tenant in etcd - This writes to etcd.
-	err := tenant.Write()
+	err = tenant.Write()
 	if err != nil {
 		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
 		return err
@@ -1225,24 +1484,39 @@ func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) er
 	return nil
 }
 
+// checkNetprofileUpdate checks the changes to a netprofile, it returns the
+// endpoint groups using it
+func checkNetprofileUpdate(profile, params *contivModel.Netprofile) ([]*contivModel.EndpointGroup, error) {
+	if err := checkNetprofileParams(params); err != nil {
+		return nil, err
+	}
+
+	epgs := []*contivModel.EndpointGroup{}
+	for key := range profile.LinkSets.EndpointGroups {
+		// Find the corresponding epg
+		epg := contivModel.FindEndpointGroup(key)
+		if epg == nil {
+			return nil, core.Errorf("EndpointGroups not found")
+		}
+		epgs = append(epgs, epg)
+	}
+
+	return epgs, nil
+}
+
 // NetprofileUpdate updates the netprofile
 func (ac *APIController) NetprofileUpdate(profile, params *contivModel.Netprofile) error {
 	log.Infof("Received NetprofileUpdate: %+v, params: %+v", profile, params)
 
-	if params.Burst > 0 && params.Burst < 2 {
-		return core.Errorf("Invalid Burst size. burst size must be > 1500 bytes")
+	epgs, err := checkNetprofileUpdate(profile, params)
+	if err != nil {
+		return err
 	}
 	profile.Bandwidth = params.Bandwidth
 	profile.DSCP = params.DSCP
 	profile.Burst = params.Burst
 
-	for key := range profile.LinkSets.EndpointGroups {
-		// Find the corresponding epg
-		epg := contivModel.FindEndpointGroup(key)
-		if epg == nil {
-			return core.Errorf("EndpointGroups not found")
-		}
-
+	for _, epg := range epgs {
 		err := master.UpdateEndpointGroup(params.Bandwidth, epg.GroupName, epg.TenantName, params.DSCP, params.Burst)
 		if err != nil {
 			log.Errorf("Error updating the EndpointGroups: %s. Err: %v", epg.GroupName, err)
@@ -1251,36 +1525,57 @@ func (ac *APIController) NetprofileUpdate(profile, params *contivModel.Netprofil
 	return nil
 }
 
-// NetprofileDelete deletes netprofile
-func (ac *APIController) NetprofileDelete(netProfile *contivModel.Netprofile) error {
-	log.Infof("Deleting Netprofile:%s", netProfile.ProfileName)
-
+// checkNetprofileDelete fails the delete of a netprofile used by endpoint
+// groups, it returns the tenant of the netprofile
+func checkNetprofileDelete(netProfile *contivModel.Netprofile) (*contivModel.Tenant, error) {
 	// Find Tenant
-	tenant := contivModel.FindTenant(netProfile.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", netProfile.TenantName)
+	tenant, err := findTenant(netProfile.TenantName)
+	if err != nil {
+		return nil, err
 	}
 	// Check if any endpoint group is using the network policy
 	if len(netProfile.LinkSets.EndpointGroups) != 0 {
-		return core.Errorf("NetProfile is being used")
+		return nil, core.Errorf("NetProfile is being used")
+	}
+
+	return tenant, nil
+}
+
+// NetprofileDelete deletes netprofile
+func (ac *APIController) NetprofileDelete(netProfile *contivModel.Netprofile) error {
+	log.Infof("Deleting Netprofile:%s", netProfile.ProfileName)
+
+	tenant, err := checkNetprofileDelete(netProfile)
+	if err != nil {
+		return err
 	}
 
 	modeldb.RemoveLinkSet(&tenant.LinkSets.NetProfiles, netProfile)
 	return nil
 }
 
+// checkPolicyCreate checks a new policy, it returns its tenant
+func checkPolicyCreate(policy *contivModel.Policy) (*contivModel.Tenant, error) {
+	// Make sure tenant exists
+	tenant, err := findTenant(policy.TenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPolicyQuota(tenant); err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
+
 // PolicyCreate creates policy
 func (ac *APIController) PolicyCreate(policy *contivModel.Policy) error {
 	log.Infof("Received PolicyCreate: %+v", policy)
 
-	// Make sure tenant exists
-	if policy.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
-	}
-
-	tenant := contivModel.FindTenant(policy.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant not found")
+	tenant, err := checkPolicyCreate(policy)
+	if err != nil {
+		return err
 	}
 
 	// Setup links
@@ -1288,7 +1583,7 @@ func (ac *APIController) PolicyCreate(policy *contivModel.Policy) error {
 	modeldb.AddLinkSet(&tenant.LinkSets.Policies, policy)
 
 	// Save the tenant too since we added the links
-	err := tenant.Write()
+	err = tenant.Write()
 	if err != nil {
 		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
 		return err
@@ -1372,6 +1667,16 @@ func (ac *APIController) PolicyGetOper(policy *contivModel.PolicyInspect) error
 
 	policy.Oper.NumEndpoints = policyEPCount
 
+	// Deny rule hits reported by the agents are policy violations
+	policyStats, err := mastercfg.GetPolicyStats(stateDriver)
+	if err != nil {
+		log.Errorf("Error reading policy stats. Err: %v", err)
+		return err
+	}
+	if pStats, found := policyStats[policy.Config.Key]; found {
+		policy.Oper.PolicyViolations = int(pStats.Violations)
+	}
+
 	return nil
 }
 
@@ -1381,19 +1686,30 @@ func (ac *APIController) PolicyUpdate(policy, params *contivModel.Policy) error
 	return nil
 }
 
-// PolicyDelete deletes policy
-func (ac *APIController) PolicyDelete(policy *contivModel.Policy) error {
-	log.Infof("Received PolicyDelete: %+v", policy)
-
+// checkPolicyDelete fails the delete of a policy used by endpoint groups,
+// it returns the tenant of the policy
+func checkPolicyDelete(policy *contivModel.Policy) (*contivModel.Tenant, error) {
 	// Find Tenant
-	tenant := contivModel.FindTenant(policy.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", policy.TenantName)
+	tenant, err := findTenant(policy.TenantName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if any endpoint group is using the Policy
 	if len(policy.LinkSets.EndpointGroups) != 0 {
-		return core.Errorf("Policy is being used")
+		return nil, core.Errorf("Policy is being used")
+	}
+
+	return tenant, nil
+}
+
+// PolicyDelete deletes policy
+func (ac *APIController) PolicyDelete(policy *contivModel.Policy) error {
+	log.Infof("Received PolicyDelete: %+v", policy)
+
+	tenant, err := checkPolicyDelete(policy)
+	if err != nil {
+		return err
 	}
 
 	// Delete all associated Rules
@@ -1409,7 +1725,7 @@ func (ac *APIController) PolicyDelete(policy *contivModel.Policy) error {
 	modeldb.RemoveLinkSet(&tenant.LinkSets.Policies, policy)
 
 	// Save the tenant too since we added the links
-	err := tenant.Write()
+	err = tenant.Write()
 	if err != nil {
 		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
 		return err
@@ -1461,15 +1777,11 @@ func syncAppProfile(profMap map[string]bool) {
 	}
 }
 
-// RuleCreate Creates the rule within a policy
-func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
-	log.Infof("Received RuleCreate: %+v", rule)
-	var epg *contivModel.EndpointGroup
-	epg = nil
-
+// checkRuleParams checks the direction, action and match parameters of a rule
+func checkRuleParams(rule *contivModel.Rule) error {
 	// verify parameter values
 	if rule.Direction == "in" {
-		if rule.ToNetwork != "" || rule.ToEndpointGroup != "" || rule.ToIpAddress != "" {
+		if rule.ToNetwork != "" || rule.ToEndpointGroup != "" || rule.ToIpAddress != "" || rule.ToFqdn != "" {
 			return errors.New("Can not specify 'to' parameters in incoming rule")
 		}
 		if rule.FromNetwork != "" && rule.FromIpAddress != "" {
@@ -1489,10 +1801,46 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		if rule.ToNetwork != "" && rule.ToEndpointGroup != "" {
 			return errors.New("Can not specify both to-network and to-EndpointGroup")
 		}
+		if rule.ToFqdn != "" && (rule.ToNetwork != "" || rule.ToEndpointGroup != "" || rule.ToIpAddress != "") {
+			return errors.New("Can not specify to-fqdn with other 'to' parameters")
+		}
 	} else {
 		return errors.New("Invalid direction for the rule")
 	}
 
+	switch rule.Action {
+	case "allow", "deny", "reject":
+	default:
+		return errors.New("Invalid action for the rule")
+	}
+
+	return nil
+}
+
+// findRulePolicy returns the policy of a rule
+func findRulePolicy(rule *contivModel.Rule) (*contivModel.Policy, error) {
+	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
+
+	// find the policy
+	policy := contivModel.FindPolicy(policyKey)
+	if policy == nil {
+		log.Errorf("Error finding policy %s", policyKey)
+		return nil, core.Errorf("Policy not found")
+	}
+
+	return policy, nil
+}
+
+// checkRuleCreate checks a new rule, the endpoint groups and networks it
+// refers to and its policy must exist. It returns the policy and the
+// endpoint group matched by the rule, if any.
+func checkRuleCreate(rule *contivModel.Rule) (*contivModel.Policy, *contivModel.EndpointGroup, error) {
+	var epg *contivModel.EndpointGroup
+
+	if err := checkRuleParams(rule); err != nil {
+		return nil, nil, err
+	}
+
 	// Make sure endpoint groups and networks referred exists.
 	if rule.FromEndpointGroup != "" {
 		epgKey := rule.TenantName + ":" + rule.FromEndpointGroup
@@ -1500,7 +1848,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		epg = contivModel.FindEndpointGroup(epgKey)
 		if epg == nil {
 			log.Errorf("Error finding endpoint group %s", epgKey)
-			return errors.New("endpoint group not found")
+			return nil, nil, errors.New("endpoint group not found")
 		}
 	} else if rule.ToEndpointGroup != "" {
 		epgKey := rule.TenantName + ":" + rule.ToEndpointGroup
@@ -1509,7 +1857,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		epg = contivModel.FindEndpointGroup(epgKey)
 		if epg == nil {
 			log.Errorf("Error finding endpoint group %s", epgKey)
-			return errors.New("endpoint group not found")
+			return nil, nil, errors.New("endpoint group not found")
 		}
 	} else if rule.FromNetwork != "" {
 		netKey := rule.TenantName + ":" + rule.FromNetwork
@@ -1517,7 +1865,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		net := contivModel.FindNetwork(netKey)
 		if net == nil {
 			log.Errorf("Network %s not found", netKey)
-			return errors.New("From Network not found")
+			return nil, nil, errors.New("From Network not found")
 		}
 	} else if rule.ToNetwork != "" {
 		netKey := rule.TenantName + ":" + rule.ToNetwork
@@ -1525,21 +1873,36 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		net := contivModel.FindNetwork(netKey)
 		if net == nil {
 			log.Errorf("Network %s not found", netKey)
-			return errors.New("To Network not found")
+			return nil, nil, errors.New("To Network not found")
 		}
 	}
 
-	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
+	policy, err := findRulePolicy(rule)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// find the policy
-	policy := contivModel.FindPolicy(policyKey)
-	if policy == nil {
-		log.Errorf("Error finding policy %s", policyKey)
-		return core.Errorf("Policy not found")
+	tenant := contivModel.FindTenant(rule.TenantName)
+	if tenant != nil {
+		if err := checkRuleQuota(tenant); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return policy, epg, nil
+}
+
+// RuleCreate Creates the rule within a policy
+func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
+	log.Infof("Received RuleCreate: %+v", rule)
+
+	policy, epg, err := checkRuleCreate(rule)
+	if err != nil {
+		return err
 	}
 
 	// Trigger policyDB Update
-	err := master.PolicyAddRule(policy, rule)
+	err = master.PolicyAddRule(policy, rule)
 	if err != nil {
 		log.Errorf("Error adding rule %s to policy %s. Err: %v", rule.Key, policy.Key, err)
 		return err
@@ -1570,10 +1933,15 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 	return nil
 }
 
+// checkRuleUpdate fails all updates, rules can not change once created
+func checkRuleUpdate() error {
+	return errors.New("Can not update a rule after its created")
+}
+
 // RuleUpdate updates the rule within a policy
 func (ac *APIController) RuleUpdate(rule, params *contivModel.Rule) error {
 	log.Infof("Received RuleUpdate: %+v, params: %+v", rule, params)
-	return errors.New("Can not update a rule after its created")
+	return checkRuleUpdate()
 }
 
 // RuleDelete deletes the rule within a policy
@@ -1583,18 +1951,15 @@ func (ac *APIController) RuleDelete(rule *contivModel.Rule) error {
 	epg = nil
 	log.Infof("Received RuleDelete: %+v", rule)
 
-	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
-
 	// find the policy
-	policy := contivModel.FindPolicy(policyKey)
-	if policy == nil {
-		log.Errorf("Error finding policy %s", policyKey)
-		return core.Errorf("Policy not found")
+	policy, err := findRulePolicy(rule)
+	if err != nil {
+		return err
 	}
 
 	// unlink the rule from policy
 	modeldb.RemoveLinkSet(&policy.LinkSets.Rules, rule)
-	err := policy.Write()
+	err = policy.Write()
 	if err != nil {
 		return err
 	}
@@ -1622,12 +1987,21 @@ func (ac *APIController) RuleDelete(rule *contivModel.Rule) error {
 	return nil
 }
 
+// checkTenantCreate checks the name and the quotas of a new tenant
+func checkTenantCreate(tenant *contivModel.Tenant) error {
+	if tenant.TenantName == "" {
+		return core.Errorf("Invalid tenant name")
+	}
+
+	return checkTenantQuotaParams(tenant)
+}
+
 // TenantCreate creates a tenant
 func (ac *APIController) TenantCreate(tenant *contivModel.Tenant) error {
 	log.Infof("Received TenantCreate: %+v", tenant)
 
-	if tenant.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
+	if err := checkTenantCreate(tenant); err != nil {
+		return err
 	}
 
 	// Get the state driver
@@ -1790,6 +2164,8 @@ func (ac *APIController) TenantGetOper(tenant *contivModel.TenantInspect) error
 	tenant.Oper.TotalPolicies = len(tenant.Config.LinkSets.Policies)
 	tenant.Oper.TotalAppProfiles = len(tenant.Config.LinkSets.AppProfiles)
 	tenant.Oper.TotalServicelbs = len(tenant.Config.LinkSets.Servicelbs)
+	tenant.Oper.TotalRules = tenantRuleCount(&tenant.Config)
+	tenant.Oper.TotalPktTags = tenantPktTagCount(&tenant.Config)
 
 	//Get all the networks config and oper parmeters under this tenant
 	getTenantNetworks(tenant)
@@ -1805,19 +2181,24 @@ func (ac *APIController) TenantGetOper(tenant *contivModel.TenantInspect) error
 func (ac *APIController) TenantUpdate(tenant, params *contivModel.Tenant) error {
 	log.Infof("Received TenantUpdate: %+v, params: %+v", tenant, params)
 
-	return core.Errorf("Cant change tenant parameters after its created")
-}
-
-// TenantDelete deletes a tenant
-func (ac *APIController) TenantDelete(tenant *contivModel.Tenant) error {
-	log.Infof("Received TenantDelete: %+v", tenant)
-
-	// Get the state driver
-	stateDriver, err := utils.GetStateDriver()
-	if err != nil {
+	if err := checkTenantUpdate(tenant, params); err != nil {
 		return err
 	}
 
+	// only the quotas can change
+	tenant.MaxNetworks = params.MaxNetworks
+	tenant.MaxEndpointGroups = params.MaxEndpointGroups
+	tenant.MaxEndpoints = params.MaxEndpoints
+	tenant.MaxPolicies = params.MaxPolicies
+	tenant.MaxRules = params.MaxRules
+	tenant.MaxServiceLBs = params.MaxServiceLBs
+	tenant.MaxPktTags = params.MaxPktTags
+
+	return nil
+}
+
+// checkTenantInUse fails if the tenant has any objects
+func checkTenantInUse(tenant *contivModel.Tenant) error {
 	// if the tenant has associated app profiles, fail the delete
 	profCount := len(tenant.LinkSets.AppProfiles)
 	if profCount != 0 {
@@ -1847,6 +2228,23 @@ func (ac *APIController) TenantDelete(tenant *contivModel.Tenant) error {
 			tenant.TenantName, nwCount)
 	}
 
+	return nil
+}
+
+// TenantDelete deletes a tenant
+func (ac *APIController) TenantDelete(tenant *contivModel.Tenant) error {
+	log.Infof("Received TenantDelete: %+v", tenant)
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	if err := checkTenantInUse(tenant); err != nil {
+		return err
+	}
+
 	// Build tenant config
 	tenantCfg := intent.ConfigTenant{
 		Name:           tenant.TenantName,
@@ -1862,12 +2260,21 @@ func (ac *APIController) TenantDelete(tenant *contivModel.Tenant) error {
 	return nil
 }
 
+// checkBgp checks the host of a bgp neighbor config
+func checkBgp(bgpCfg *contivModel.Bgp) error {
+	if bgpCfg.Hostname == "" {
+		return core.Errorf("Invalid host name")
+	}
+
+	return nil
+}
+
 //BgpCreate add bgp neighbor
 func (ac *APIController) BgpCreate(bgpCfg *contivModel.Bgp) error {
 	log.Infof("Received BgpCreate: %+v", bgpCfg)
 
-	if bgpCfg.Hostname == "" {
-		return core.Errorf("Invalid host name")
+	if err := checkBgp(bgpCfg); err != nil {
+		return err
 	}
 
 	// Get the state driver
@@ -1916,8 +2323,8 @@ func (ac *APIController) BgpDelete(bgpCfg *contivModel.Bgp) error {
 func (ac *APIController) BgpUpdate(oldbgpCfg *contivModel.Bgp, NewbgpCfg *contivModel.Bgp) error {
 	log.Infof("Received BgpUpdate: %+v", NewbgpCfg)
 
-	if NewbgpCfg.Hostname == "" {
-		return core.Errorf("Invalid host name")
+	if err := checkBgp(NewbgpCfg); err != nil {
+		return err
 	}
 
 	// Get the state driver
@@ -2018,35 +2425,52 @@ func (ac *APIController) BgpGetOper(bgp *contivModel.BgpInspect) error {
 	return nil
 }
 
-//ServiceLBCreate creates service object
-func (ac *APIController) ServiceLBCreate(serviceCfg *contivModel.ServiceLB) error {
-
-	log.Infof("Received Service Load Balancer create: %+v", serviceCfg)
-
+// checkServiceLB checks the parameters of a service, its tenant and network
+// must exist. It returns the tenant and the network.
+func checkServiceLB(serviceCfg *contivModel.ServiceLB) (*contivModel.Tenant, *contivModel.Network, error) {
 	if serviceCfg.ServiceName == "" {
-		return core.Errorf("Invalid service name")
+		return nil, nil, core.Errorf("Invalid service name")
 	}
 
 	if len(serviceCfg.Selectors) == 0 {
-		return core.Errorf("Invalid selector options")
+		return nil, nil, core.Errorf("Invalid selector options")
 	}
 
 	if !validatePorts(serviceCfg.Ports) {
-		return core.Errorf("Invalid Port maping . Port format is - Port:TargetPort:Protocol")
+		return nil, nil, core.Errorf("Invalid Port maping . Port format is - Port:TargetPort:Protocol")
 	}
 
-	if serviceCfg.TenantName == "" {
-		return core.Errorf("Invalid tenant name")
+	for _, selector := range serviceCfg.Selectors {
+		if !validateSelectors(selector) {
+			return nil, nil, core.Errorf("Invalid selector %s. selector format is key1=value1", selector)
+		}
 	}
 
-	tenant := contivModel.FindTenant(serviceCfg.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", serviceCfg.TenantName)
+	tenant, err := findTenant(serviceCfg.TenantName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkServiceLBQuota(tenant, serviceCfg); err != nil {
+		return nil, nil, err
 	}
 
 	network := contivModel.FindNetwork(serviceCfg.TenantName + ":" + serviceCfg.NetworkName)
 	if network == nil {
-		return core.Errorf("Network %s not found", serviceCfg.NetworkName)
+		return nil, nil, core.Errorf("Network %s not found", serviceCfg.NetworkName)
+	}
+
+	return tenant, network, nil
+}
+
+//ServiceLBCreate creates service object
+func (ac *APIController) ServiceLBCreate(serviceCfg *contivModel.ServiceLB) error {
+
+	log.Infof("Received Service Load Balancer create: %+v", serviceCfg)
+
+	tenant, network, err := checkServiceLB(serviceCfg)
+	if err != nil {
+		return err
 	}
 
 	// Get the state driver
@@ -2067,13 +2491,9 @@ func (ac *APIController) ServiceLBCreate(serviceCfg *contivModel.ServiceLB) erro
 	serviceIntentCfg.Selectors = make(map[string]string)
 
 	for _, selector := range serviceCfg.Selectors {
-		if validateSelectors(selector) {
-			key := strings.Split(selector, "=")[0]
-			value := strings.Split(selector, "=")[1]
-			serviceIntentCfg.Selectors[key] = value
-		} else {
-			return core.Errorf("Invalid selector %s. selector format is key1=value1", selector)
-		}
+		key := strings.Split(selector, "=")[0]
+		value := strings.Split(selector, "=")[1]
+		serviceIntentCfg.Selectors[key] = value
 	}
 	// Add the service object
 	err = master.CreateServiceLB(stateDriver, &serviceIntentCfg)
@@ -2116,13 +2536,22 @@ func (ac *APIController) ServiceLBUpdate(oldServiceCfg *contivModel.ServiceLB, s
 	return nil
 }
 
+// checkServiceLBDelete checks the name of a deleted service
+func checkServiceLBDelete(serviceCfg *contivModel.ServiceLB) error {
+	if serviceCfg.ServiceName == "" {
+		return core.Errorf("Invalid service name")
+	}
+
+	return nil
+}
+
 //ServiceLBDelete deletes service object
 func (ac *APIController) ServiceLBDelete(serviceCfg *contivModel.ServiceLB) error {
 
 	log.Info("Received Service Load Balancer delete : {%+v}", serviceCfg)
 
-	if serviceCfg.ServiceName == "" {
-		return core.Errorf("Invalid service name")
+	if err := checkServiceLBDelete(serviceCfg); err != nil {
+		return err
 	}
 
 	// Get the state driver
@@ -2138,9 +2567,9 @@ func (ac *APIController) ServiceLBDelete(serviceCfg *contivModel.ServiceLB) erro
 		return err
 	}
 	// Find the tenant
-	tenant := contivModel.FindTenant(serviceCfg.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", serviceCfg.TenantName)
+	tenant, err := findTenant(serviceCfg.TenantName)
+	if err != nil {
+		return err
 	}
 
 	modeldb.RemoveLinkSet(&tenant.LinkSets.Servicelbs, serviceCfg)
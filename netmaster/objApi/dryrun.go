@@ -0,0 +1,632 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objApi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/master"
+	"github.com/contiv/netplugin/utils"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// A create, update or delete of a model object made with the dryRun query
+// parameter or the X-Dry-Run header set to true is only checked: the
+// validations of the callbacks run against the current state and the
+// response lists what the change would do, nothing is written and docker
+// and the agents are not told.
+const (
+	dryRunParam  = "dryRun"
+	dryRunHeader = "X-Dry-Run"
+	dryRunRoute  = "/api/v1/{objType}/{key}/"
+)
+
+// DryRunResult is the response to a dry run
+type DryRunResult struct {
+	DryRun      bool     `json:"dryRun"`
+	Operation   string   `json:"operation"` // create, update or delete
+	ObjType     string   `json:"objType"`
+	Key         string   `json:"key"`
+	Allocations []string `json:"allocations"` // resources that would be allocated
+	Releases    []string `json:"releases"`    // resources that would be released
+	Affected    []string `json:"affected"`    // other objects that would change, as type/key
+}
+
+// isDryRun matches the requests asking for a dry run
+func isDryRun(r *http.Request, rm *mux.RouteMatch) bool {
+	value := r.URL.Query().Get(dryRunParam)
+	if value == "" {
+		value = r.Header.Get(dryRunHeader)
+	}
+	dryRun, _ := strconv.ParseBool(value)
+	return dryRun
+}
+
+// addDryRunRoutes routes the dry runs ahead of the model routes
+func (ac *APIController) addDryRunRoutes(router *mux.Router) {
+	router.Path(dryRunRoute).Methods("POST", "PUT", "DELETE").
+		MatcherFunc(isDryRun).HandlerFunc(ac.httpDryRun)
+}
+
+// httpDryRun checks a create, update or delete of a model object
+func (ac *APIController) httpDryRun(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	res := &DryRunResult{
+		DryRun:      true,
+		ObjType:     vars["objType"],
+		Key:         vars["key"],
+		Allocations: []string{},
+		Releases:    []string{},
+		Affected:    []string{},
+	}
+
+	var err error
+	if r.Method == "DELETE" {
+		res.Operation = "delete"
+		err = dryRunDelete(res)
+	} else {
+		err = dryRunCreate(res, r.Body)
+	}
+	if err != nil {
+		log.Errorf("Dry run of %s %s returned error: %s", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("Error generating json. Err: %v", err)
+	}
+}
+
+// affect records a model object that would change
+func (res *DryRunResult) affect(objType, key string) {
+	ref := objType + "/" + key
+	for _, affected := range res.Affected {
+		if affected == ref {
+			return
+		}
+	}
+	res.Affected = append(res.Affected, ref)
+}
+
+// setOperation sets the operation to update if the object exists
+func (res *DryRunResult) setOperation(exists bool) {
+	res.Operation = "create"
+	if exists {
+		res.Operation = "update"
+	}
+}
+
+// dryRunCreate checks the create or update of the object in the body
+func dryRunCreate(res *DryRunResult, body io.Reader) error {
+	decode := func(obj interface{}) error {
+		if err := json.NewDecoder(body).Decode(obj); err != nil {
+			log.Errorf("Error decoding %s dry run request. Err %v", res.ObjType, err)
+			return err
+		}
+		return nil
+	}
+
+	switch res.ObjType {
+	case "globals":
+		obj := &contivModel.Global{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateGlobal(obj); err != nil {
+			return err
+		}
+		return dryRunGlobal(res, obj)
+
+	case "aciGws":
+		obj := &contivModel.AciGw{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		res.setOperation(contivModel.FindAciGw(obj.Key) != nil)
+		return contivModel.ValidateAciGw(obj)
+
+	case "tenants":
+		obj := &contivModel.Tenant{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateTenant(obj); err != nil {
+			return err
+		}
+		tenant := contivModel.FindTenant(obj.Key)
+		res.setOperation(tenant != nil)
+		if tenant != nil {
+			return checkTenantUpdate(tenant, obj)
+		}
+		return checkTenantCreate(obj)
+
+	case "networks":
+		obj := &contivModel.Network{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateNetwork(obj); err != nil {
+			return err
+		}
+		return dryRunNetwork(res, obj)
+
+	case "netprofiles":
+		obj := &contivModel.Netprofile{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateNetprofile(obj); err != nil {
+			return err
+		}
+		return dryRunNetprofile(res, obj)
+
+	case "policys":
+		obj := &contivModel.Policy{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidatePolicy(obj); err != nil {
+			return err
+		}
+		res.setOperation(contivModel.FindPolicy(obj.Key) != nil)
+		if res.Operation == "create" {
+			if _, err := checkPolicyCreate(obj); err != nil {
+				return err
+			}
+			res.affect("tenant", obj.TenantName)
+		}
+		return nil
+
+	case "rules":
+		obj := &contivModel.Rule{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateRule(obj); err != nil {
+			return err
+		}
+		return dryRunRule(res, obj)
+
+	case "endpointGroups":
+		obj := &contivModel.EndpointGroup{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateEndpointGroup(obj); err != nil {
+			return err
+		}
+		return dryRunEndpointGroup(res, obj)
+
+	case "appProfiles":
+		obj := &contivModel.AppProfile{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateAppProfile(obj); err != nil {
+			return err
+		}
+		return dryRunAppProfile(res, obj)
+
+	case "serviceLBs":
+		obj := &contivModel.ServiceLB{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateServiceLB(obj); err != nil {
+			return err
+		}
+		return dryRunServiceLB(res, obj)
+
+	case "Bgps":
+		obj := &contivModel.Bgp{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateBgp(obj); err != nil {
+			return err
+		}
+		res.setOperation(contivModel.FindBgp(obj.Key) != nil)
+		return checkBgp(obj)
+
+	case "extContractsGroups":
+		obj := &contivModel.ExtContractsGroup{}
+		if err := decode(obj); err != nil {
+			return err
+		}
+		obj.Key = res.Key
+		if err := contivModel.ValidateExtContractsGroup(obj); err != nil {
+			return err
+		}
+		res.setOperation(contivModel.FindExtContractsGroup(obj.Key) != nil)
+		if res.Operation == "update" {
+			return checkExtContractsGroupUpdate(obj)
+		}
+		return checkExtContractsGroupCreate(obj)
+	}
+
+	return core.Errorf("dry run is not supported for %s", res.ObjType)
+}
+
+// dryRunDelete checks the delete of an object
+func dryRunDelete(res *DryRunResult) error {
+	switch res.ObjType {
+	case "globals":
+		if contivModel.FindGlobal(res.Key) == nil {
+			return errors.New("global not found")
+		}
+		return nil
+
+	case "aciGws":
+		if contivModel.FindAciGw(res.Key) == nil {
+			return errors.New("aciGw not found")
+		}
+		return checkAciGwInUse()
+
+	case "tenants":
+		tenant := contivModel.FindTenant(res.Key)
+		if tenant == nil {
+			return errors.New("tenant not found")
+		}
+		return checkTenantInUse(tenant)
+
+	case "networks":
+		network := contivModel.FindNetwork(res.Key)
+		if network == nil {
+			return errors.New("network not found")
+		}
+		if _, err := checkNetworkDelete(network); err != nil {
+			return err
+		}
+
+		stateDriver, err := utils.GetStateDriver()
+		if err != nil {
+			return err
+		}
+		res.Releases, err = master.CheckNetworkDelete(stateDriver,
+			network.NetworkName+"."+network.TenantName)
+		if err != nil {
+			return err
+		}
+		res.affect("tenant", network.TenantName)
+		return nil
+
+	case "netprofiles":
+		netProfile := contivModel.FindNetprofile(res.Key)
+		if netProfile == nil {
+			return errors.New("netprofile not found")
+		}
+		if _, err := checkNetprofileDelete(netProfile); err != nil {
+			return err
+		}
+		res.affect("tenant", netProfile.TenantName)
+		return nil
+
+	case "policys":
+		policy := contivModel.FindPolicy(res.Key)
+		if policy == nil {
+			return errors.New("policy not found")
+		}
+		if _, err := checkPolicyDelete(policy); err != nil {
+			return err
+		}
+		res.affect("tenant", policy.TenantName)
+		// the rules of the policy are deleted with it
+		for key := range policy.LinkSets.Rules {
+			res.affect("rule", key)
+		}
+		return nil
+
+	case "rules":
+		rule := contivModel.FindRule(res.Key)
+		if rule == nil {
+			return errors.New("rule not found")
+		}
+		policy, err := findRulePolicy(rule)
+		if err != nil {
+			return err
+		}
+		affectRule(res, rule, policy)
+		return nil
+
+	case "endpointGroups":
+		endpointGroup := contivModel.FindEndpointGroup(res.Key)
+		if endpointGroup == nil {
+			return errors.New("endpointGroup not found")
+		}
+		if err := checkEndpointGroupDelete(endpointGroup); err != nil {
+			return err
+		}
+
+		stateDriver, err := utils.GetStateDriver()
+		if err != nil {
+			return err
+		}
+		res.Releases, err = master.CheckEndpointGroupDelete(stateDriver,
+			endpointGroup.TenantName, endpointGroup.GroupName)
+		if err != nil {
+			return err
+		}
+		affectEndpointGroup(res, endpointGroup)
+		return nil
+
+	case "appProfiles":
+		prof := contivModel.FindAppProfile(res.Key)
+		if prof == nil {
+			return errors.New("appProfile not found")
+		}
+		if _, err := findTenant(prof.TenantName); err != nil {
+			return err
+		}
+		res.affect("tenant", prof.TenantName)
+		for _, epg := range prof.EndpointGroups {
+			res.affect("endpointGroup", prof.TenantName+":"+epg)
+		}
+		return nil
+
+	case "serviceLBs":
+		serviceCfg := contivModel.FindServiceLB(res.Key)
+		if serviceCfg == nil {
+			return errors.New("serviceLB not found")
+		}
+		if err := checkServiceLBDelete(serviceCfg); err != nil {
+			return err
+		}
+		if _, err := findTenant(serviceCfg.TenantName); err != nil {
+			return err
+		}
+		res.affect("tenant", serviceCfg.TenantName)
+		res.affect("network", serviceCfg.TenantName+":"+serviceCfg.NetworkName)
+		return nil
+
+	case "Bgps":
+		if contivModel.FindBgp(res.Key) == nil {
+			return errors.New("Bgp not found")
+		}
+		return nil
+
+	case "extContractsGroups":
+		contractsGroup := contivModel.FindExtContractsGroup(res.Key)
+		if contractsGroup == nil {
+			return errors.New("extContractsGroup not found")
+		}
+		return checkExtContractsGroupDelete(contractsGroup)
+	}
+
+	return core.Errorf("dry run is not supported for %s", res.ObjType)
+}
+
+// dryRunGlobal checks the global settings, the vlan and vxlan ranges must
+// include the tags in use
+func dryRunGlobal(res *DryRunResult, params *contivModel.Global) error {
+	global := contivModel.FindGlobal(params.Key)
+	res.setOperation(global != nil)
+	if global == nil {
+		return nil
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+	if err := checkGlobalUpdate(stateDriver, global, params); err != nil {
+		return err
+	}
+	globalCfg := globalUpdateConfig(global, params)
+
+	return master.CheckGlobalUpdate(stateDriver, &globalCfg)
+}
+
+// dryRunNetwork checks a network, the tenant must exist and the subnets must
+// not overlap the other networks of the tenant
+func dryRunNetwork(res *DryRunResult, params *contivModel.Network) error {
+	network := contivModel.FindNetwork(params.Key)
+	res.setOperation(network != nil)
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	if network != nil {
+		if err := checkNetworkUpdate(network, params); err != nil {
+			return err
+		}
+		networkCfg := networkConfig(params)
+		networkCfg.Name = network.NetworkName
+		res.Allocations, err = master.CheckNetworkUpdate(networkCfg, stateDriver, network.TenantName)
+		return err
+	}
+
+	if _, err := checkNetworkCreate(params); err != nil {
+		return err
+	}
+	res.Allocations, err = master.CheckNetwork(networkConfig(params), stateDriver, params.TenantName)
+	if err != nil {
+		return err
+	}
+	res.affect("tenant", params.TenantName)
+
+	return nil
+}
+
+// dryRunNetprofile checks a netprofile, updates change the groups using it
+func dryRunNetprofile(res *DryRunResult, params *contivModel.Netprofile) error {
+	profile := contivModel.FindNetprofile(params.Key)
+	res.setOperation(profile != nil)
+
+	if profile != nil {
+		epgs, err := checkNetprofileUpdate(profile, params)
+		if err != nil {
+			return err
+		}
+		for _, epg := range epgs {
+			res.affect("endpointGroup", epg.Key)
+		}
+		return nil
+	}
+
+	if _, err := checkNetprofileCreate(params); err != nil {
+		return err
+	}
+	res.affect("tenant", params.TenantName)
+
+	return nil
+}
+
+// affectRule records the policy of a rule and the groups the rule applies to
+func affectRule(res *DryRunResult, rule *contivModel.Rule, policy *contivModel.Policy) {
+	res.affect("policy", policy.Key)
+	for key := range policy.LinkSets.EndpointGroups {
+		res.affect("endpointGroup", key)
+	}
+	if rule.Links.MatchEndpointGroup.ObjKey != "" {
+		res.affect("endpointGroup", rule.Links.MatchEndpointGroup.ObjKey)
+	}
+}
+
+// dryRunRule checks a rule, the groups and networks it refers to and its
+// policy must exist
+func dryRunRule(res *DryRunResult, rule *contivModel.Rule) error {
+	res.setOperation(contivModel.FindRule(rule.Key) != nil)
+	if res.Operation == "update" {
+		return checkRuleUpdate()
+	}
+
+	policy, epg, err := checkRuleCreate(rule)
+	if err != nil {
+		return err
+	}
+	if epg != nil {
+		rule.Links.MatchEndpointGroup.ObjKey = epg.Key
+	}
+	affectRule(res, rule, policy)
+
+	return nil
+}
+
+// affectEndpointGroup records the objects linked to a group
+func affectEndpointGroup(res *DryRunResult, endpointGroup *contivModel.EndpointGroup) {
+	res.affect("tenant", endpointGroup.TenantName)
+	res.affect("network", endpointGroup.TenantName+":"+endpointGroup.NetworkName)
+	for _, policyName := range endpointGroup.Policies {
+		res.affect("policy", GetpolicyKey(endpointGroup.TenantName, policyName))
+	}
+	if endpointGroup.NetProfile != "" {
+		res.affect("netprofile", GetNetprofileKey(endpointGroup.TenantName, endpointGroup.NetProfile))
+	}
+	for _, contractsGrp := range endpointGroup.ExtContractsGrps {
+		res.affect("extContractsGroup", endpointGroup.TenantName+":"+contractsGrp)
+	}
+}
+
+// dryRunEndpointGroup checks a group, its tenant, network, policies,
+// netprofile and external contracts must exist
+func dryRunEndpointGroup(res *DryRunResult, params *contivModel.EndpointGroup) error {
+	endpointGroup := contivModel.FindEndpointGroup(params.Key)
+	res.setOperation(endpointGroup != nil)
+
+	if endpointGroup != nil {
+		if err := checkEndpointGroupUpdate(endpointGroup, params); err != nil {
+			return err
+		}
+		// only the attachments change, old and new ones are affected
+		affectEndpointGroup(res, endpointGroup)
+		affectEndpointGroup(res, params)
+		return nil
+	}
+
+	if _, _, err := checkEndpointGroupCreate(params); err != nil {
+		return err
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+	res.Allocations, err = master.CheckEndpointGroup(stateDriver, params.TenantName,
+		params.NetworkName, params.GroupName, params.IpPool)
+	if err != nil {
+		return err
+	}
+	affectEndpointGroup(res, params)
+
+	return nil
+}
+
+// dryRunAppProfile checks an app profile, its groups must exist
+func dryRunAppProfile(res *DryRunResult, params *contivModel.AppProfile) error {
+	prof := contivModel.FindAppProfile(params.Key)
+	res.setOperation(prof != nil)
+
+	var epgObjs []*contivModel.EndpointGroup
+	var err error
+	if prof == nil {
+		_, epgObjs, err = checkAppProfileCreate(params)
+		res.affect("tenant", params.TenantName)
+	} else {
+		epgObjs, err = checkAppProfileGroups(params)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, epgObj := range epgObjs {
+		res.affect("endpointGroup", epgObj.Key)
+	}
+	if prof != nil {
+		for _, epg := range prof.EndpointGroups {
+			res.affect("endpointGroup", prof.TenantName+":"+epg)
+		}
+	}
+
+	return nil
+}
+
+// dryRunServiceLB checks a service, its tenant and network must exist
+func dryRunServiceLB(res *DryRunResult, serviceCfg *contivModel.ServiceLB) error {
+	res.setOperation(contivModel.FindServiceLB(serviceCfg.Key) != nil)
+
+	if _, _, err := checkServiceLB(serviceCfg); err != nil {
+		return err
+	}
+
+	res.affect("tenant", serviceCfg.TenantName)
+	res.affect("network", serviceCfg.TenantName+":"+serviceCfg.NetworkName)
+
+	return nil
+}
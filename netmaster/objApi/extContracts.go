@@ -84,38 +84,27 @@ func isExtContractsGroupUsed(contractsGroup *contivModel.ExtContractsGroup) bool
 	return len(contractsGroup.LinkSets.EndpointGroups) > 0
 }
 
-// ExtContractsGroupCreate creates a new group of external contracts
-func (ac *APIController) ExtContractsGroupCreate(contractsGroup *contivModel.ExtContractsGroup) error {
-	log.Infof("Received ExtContractsGroupCreate: %+v", contractsGroup)
-
+// checkExtContractsGroupCreate checks the contracts type and the tenant of
+// a new external contracts group
+func checkExtContractsGroupCreate(contractsGroup *contivModel.ExtContractsGroup) error {
 	// Validate contracts type
 	if contractsGroup.ContractsType != "provided" && contractsGroup.ContractsType != "consumed" {
 		return core.Errorf("Contracts group need to be either 'provided' or 'consumed'")
 	}
 	// Make sure the tenant exists
-	tenant := contivModel.FindTenant(contractsGroup.TenantName)
-	if tenant == nil {
-		return core.Errorf("Tenant %s not found", contractsGroup.TenantName)
-	}
-
-	// NOTE: Nothing more needs to be done here. This object
-	// need not be created in the masterCfg.
-
-	return nil
+	_, err := findTenant(contractsGroup.TenantName)
+	return err
 }
 
-// ExtContractsGroupUpdate updates an existing group of contract sets
-func (ac *APIController) ExtContractsGroupUpdate(contractsGroup, params *contivModel.ExtContractsGroup) error {
-	log.Infof("Received ExtContractsGroupUpdate: %+v, params: %+v", contractsGroup, params)
+// checkExtContractsGroupUpdate fails all updates of external contracts groups
+func checkExtContractsGroupUpdate(contractsGroup *contivModel.ExtContractsGroup) error {
 	log.Errorf("Error: external contracts update not supported: %s", contractsGroup.ContractsGroupName)
-
 	return core.Errorf("external contracts update not supported")
 }
 
-// ExtContractsGroupDelete deletes an existing external contracts group
-func (ac *APIController) ExtContractsGroupDelete(contractsGroup *contivModel.ExtContractsGroup) error {
-	log.Infof("Received ExtContractsGroupDelete: %+v", contractsGroup)
-
+// checkExtContractsGroupDelete fails the delete of an external contracts
+// group used by endpoint groups
+func checkExtContractsGroupDelete(contractsGroup *contivModel.ExtContractsGroup) error {
 	// At this moment, we let the external contracts to be deleted only
 	// if there are no consumers of this external contracts group
 	if isExtContractsGroupUsed(contractsGroup) == true {
@@ -125,3 +114,24 @@ func (ac *APIController) ExtContractsGroupDelete(contractsGroup *contivModel.Ext
 
 	return nil
 }
+
+// ExtContractsGroupCreate creates a new group of external contracts
+func (ac *APIController) ExtContractsGroupCreate(contractsGroup *contivModel.ExtContractsGroup) error {
+	log.Infof("Received ExtContractsGroupCreate: %+v", contractsGroup)
+
+	// NOTE: Nothing more needs to be done here. This object
+	// need not be created in the masterCfg.
+	return checkExtContractsGroupCreate(contractsGroup)
+}
+
+// ExtContractsGroupUpdate updates an existing group of contract sets
+func (ac *APIController) ExtContractsGroupUpdate(contractsGroup, params *contivModel.ExtContractsGroup) error {
+	log.Infof("Received ExtContractsGroupUpdate: %+v, params: %+v", contractsGroup, params)
+	return checkExtContractsGroupUpdate(contractsGroup)
+}
+
+// ExtContractsGroupDelete deletes an existing external contracts group
+func (ac *APIController) ExtContractsGroupDelete(contractsGroup *contivModel.ExtContractsGroup) error {
+	log.Infof("Received ExtContractsGroupDelete: %+v", contractsGroup)
+	return checkExtContractsGroupDelete(contractsGroup)
+}
@@ -204,8 +204,8 @@ func addPolicyContracts(csMap map[string]*contrSpec, epg *epgSpec, policy *conti
 			return errors.New(errStr)
 		}
 
-		if rule.Action == "deny" {
-			log.Debugf("==Ignoring deny rule %v", ruleName)
+		if rule.Action != "allow" {
+			log.Debugf("==Ignoring %s rule %v", rule.Action, ruleName)
 			continue
 		}
 
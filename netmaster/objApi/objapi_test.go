@@ -135,7 +135,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// Create a new api controller
-	apiController = NewAPIController(router, objdbClient, "etcd://127.0.0.1:2379")
+	apiController = NewAPIController(router, objdbClient)
 
 	ofnetMaster := ofnet.NewOfnetMaster("127.0.0.1", ofnet.OFNET_MASTER_PORT)
 	if ofnetMaster == nil {
@@ -746,6 +746,37 @@ func checkEpgPolicyDeleted(t *testing.T, tenant, network, group, policy string)
 
 }
 
+// checkEpgIsolation verifies the isolation rule of an EPG
+func checkEpgIsolation(t *testing.T, tenant, group string, isolated bool) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		t.Fatalf("Error getting state driver. Err: %v", err)
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = stateDriver
+	err = epgCfg.Read(mastercfg.GetEndpointGroupKey(group, tenant))
+	if err != nil {
+		t.Fatalf("Error reading EPG %s:%s. Err: %v", tenant, group, err)
+	}
+	if epgCfg.Isolation != isolated {
+		t.Fatalf("EPG %s:%s isolation is %v, expected %v", tenant, group, epgCfg.Isolation, isolated)
+	}
+
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.StateDriver = stateDriver
+	err = ruleCfg.Read(mastercfg.EpgIsolationRuleID(epgCfg.ID))
+	if isolated && err != nil {
+		t.Fatalf("Isolation rule of EPG %s:%s not found. Err: %v", tenant, group, err)
+	} else if !isolated && err == nil {
+		t.Fatalf("Found isolation rule of EPG %s:%s while expecting it to be deleted", tenant, group)
+	}
+	if isolated && (ruleCfg.SrcEndpointGroup != epgCfg.EndpointGroupID ||
+		ruleCfg.DstEndpointGroup != epgCfg.EndpointGroupID || ruleCfg.Action != "deny") {
+		t.Fatalf("Invalid isolation rule %+v for EPG %s:%s", ruleCfg.OfnetPolicyRule, tenant, group)
+	}
+}
+
 // checkDeleteEpg deletes EPG
 func checkDeleteEpg(t *testing.T, expError bool, tenant, network, group string) {
 	err := contivClient.EndpointGroupDelete(tenant, group)
@@ -1417,6 +1448,156 @@ func TestNetworkPktRanges(t *testing.T) {
 	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
 }
 
+// waitPktTagMigration waits for the pkt tag migration of a network to end
+func waitPktTagMigration(t *testing.T, netID, status string) {
+	for i := 0; i < 50; i++ {
+		migCfg, err := master.GetPktTagMigration(stateStore, netID)
+		if err != nil {
+			t.Fatalf("Error reading pkt tag migration of %s. Err: %v", netID, err)
+		}
+		if migCfg.Status != mastercfg.PktTagMigrating {
+			if migCfg.Status != status {
+				t.Fatalf("Pkt tag migration %+v did not end %s", migCfg, status)
+			}
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	t.Fatalf("Pkt tag migration of %s did not end", netID)
+}
+
+// verifyModelPktTag checks the pkt tag of the network object
+func verifyModelPktTag(t *testing.T, tenant, network string, pktTag int) {
+	nw := contivModel.FindNetwork(tenant + ":" + network)
+	if nw == nil || nw.PktTag != pktTag {
+		t.Fatalf("Network object %+v does not have pkt tag %d", nw, pktTag)
+	}
+}
+
+// TestNetworkPktTagMigration tests the network object follows the pkt tag migrations
+func TestNetworkPktTagMigration(t *testing.T) {
+	netID := "contiv.default"
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vlan", "10.1.1.1/24", "10.1.1.254", 10, "", "", "")
+
+	// the migration completes without endpoints
+	if _, err := master.MigrateNetworkPktTag(stateStore, netID, 20); err != nil {
+		t.Fatalf("Error migrating network %s. Err: %v", netID, err)
+	}
+	waitPktTagMigration(t, netID, mastercfg.PktTagMigrated)
+	verifyNetworkState(t, "default", "contiv", "data", "vlan", "10.1.1.1", "10.1.1.254", 24, 20, 0, "", "", 0)
+	verifyModelPktTag(t, "default", "contiv", 20)
+
+	// the network can be updated with its new pkt tag
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vlan", "10.1.1.1/24", "10.1.1.254", 20, "", "", "")
+
+	// a node fails to move to the new tag
+	epCfg := &mastercfg.CfgEndpointState{NetID: netID, HomingHost: "host1"}
+	epCfg.ID = "contiv-pkttag-ep"
+	epCfg.StateDriver = stateStore
+	if err := epCfg.Write(); err != nil {
+		t.Fatalf("Error writing endpoint. Err: %v", err)
+	}
+	defer epCfg.Clear()
+
+	if _, err := master.MigrateNetworkPktTag(stateStore, netID, 30); err != nil {
+		t.Fatalf("Error migrating network %s. Err: %v", netID, err)
+	}
+	hostState := &mastercfg.PktTagHostState{
+		NetworkID: netID,
+		Hostname:  "host1",
+		PktTag:    30,
+		Error:     "ovs error",
+	}
+	hostState.ID = mastercfg.PktTagHostStateID(netID, "host1")
+	hostState.StateDriver = stateStore
+	if err := hostState.Write(); err != nil {
+		t.Fatalf("Error writing pkt tags of host1. Err: %v", err)
+	}
+	waitPktTagMigration(t, netID, mastercfg.PktTagRolledBack)
+	verifyNetworkState(t, "default", "contiv", "data", "vlan", "10.1.1.1", "10.1.1.254", 24, 20, 0, "", "", 0)
+	verifyModelPktTag(t, "default", "contiv", 20)
+
+	epCfg.Clear()
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// TestNetworkPktTagMigrationUpdate tests network updates made while the
+// migration moves the network object to its new pkt tag
+func TestNetworkPktTagMigrationUpdate(t *testing.T) {
+	netID := "contiv.default"
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vlan", "10.1.1.1/24", "10.1.1.254", 10, "", "", "")
+
+	epCfg := &mastercfg.CfgEndpointState{NetID: netID, HomingHost: "host1"}
+	epCfg.ID = "contiv-pkttag-ep"
+	epCfg.StateDriver = stateStore
+	if err := epCfg.Write(); err != nil {
+		t.Fatalf("Error writing endpoint. Err: %v", err)
+	}
+	defer epCfg.Clear()
+
+	if _, err := master.MigrateNetworkPktTag(stateStore, netID, 20); err != nil {
+		t.Fatalf("Error migrating network %s. Err: %v", netID, err)
+	}
+
+	// update the gateway of the network until the migration is over, an
+	// update carrying the pkt tag the network just left is refused
+	done := make(chan bool)
+	updated := make(chan int)
+	go func() {
+		count := 0
+		defer func() { updated <- count }()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			nw, err := contivClient.NetworkGet("default", "contiv")
+			if err != nil {
+				t.Errorf("Error reading network. Err: %v", err)
+				return
+			}
+			nw.Gateway = []string{"10.1.1.250", "10.1.1.251"}[i%2]
+			if err := contivClient.NetworkPost(nw); err == nil {
+				count++
+			}
+		}
+	}()
+
+	hostState := &mastercfg.PktTagHostState{
+		NetworkID: netID,
+		Hostname:  "host1",
+		PktTag:    20,
+	}
+	hostState.ID = mastercfg.PktTagHostStateID(netID, "host1")
+	hostState.StateDriver = stateStore
+	if err := hostState.Write(); err != nil {
+		t.Fatalf("Error writing pkt tags of host1. Err: %v", err)
+	}
+	waitPktTagMigration(t, netID, mastercfg.PktTagMigrated)
+	close(done)
+	if count := <-updated; count == 0 {
+		t.Fatalf("Network not updated during the migration")
+	}
+
+	// neither change is lost
+	verifyModelPktTag(t, "default", "contiv", 20)
+	nw, err := contivClient.NetworkGet("default", "contiv")
+	if err != nil {
+		t.Fatalf("Error reading network. Err: %v", err)
+	}
+	if nw.PktTag != 20 {
+		t.Fatalf("Network %+v does not have pkt tag 20", nw)
+	}
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vlan", "10.1.1.1/24", "10.1.1.254", 20, "", "", "")
+	verifyNetworkState(t, "default", "contiv", "data", "vlan", "10.1.1.1", "10.1.1.254", 24, 20, 0, "", "", 0)
+
+	epCfg.Clear()
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
 // TestPolicyRules tests policy and rule REST objects
 func TestPolicyRules(t *testing.T) {
 	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
@@ -1435,6 +1616,8 @@ func TestPolicyRules(t *testing.T) {
 	checkCreateRule(t, false, "default", "policy1", "5", "out", "", "", "", "", "", "10.1.1.1/24", "tcp", "allow", 1, 80)
 	checkCreateRule(t, false, "default", "policy1", "6", "in", "", "group1", "", "", "", "", "", "deny", 1, 0)
 	checkCreateRule(t, false, "default", "policy1", "7", "out", "", "", "", "", "group1", "", "tcp", "allow", 1, 80)
+	checkCreateRule(t, false, "default", "policy1", "8", "in", "", "group1", "", "", "", "", "tcp", "reject", 1, 443)
+	checkCreateRule(t, false, "default", "policy1", "9", "in", "", "", "2001:db8::/64", "", "", "", "tcp", "reject", 1, 443)
 
 	// verify duplicate rule id fails
 	checkCreateRule(t, true, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
@@ -1487,6 +1670,8 @@ func TestPolicyRules(t *testing.T) {
 	checkDeleteRule(t, false, "default", "policy1", "5")
 	checkDeleteRule(t, false, "default", "policy1", "6")
 	checkDeleteRule(t, false, "default", "policy1", "7")
+	checkDeleteRule(t, false, "default", "policy1", "8")
+	checkDeleteRule(t, false, "default", "policy1", "9")
 
 	// verify cant delete a rule and policy that doesnt exist
 	checkDeleteRule(t, true, "default", "policy1", "100")
@@ -1566,6 +1751,50 @@ func TestEpgPolicies(t *testing.T) {
 	checkDeleteNetwork(t, false, "default", "newnet")
 }
 
+// TestEpgIsolation tests isolating the endpoints of an EPG
+func TestEpgIsolation(t *testing.T) {
+	// create network
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+
+	// create an isolated EPG
+	epg := client.EndpointGroup{
+		TenantName:  "default",
+		NetworkName: "contiv",
+		GroupName:   "build",
+		Isolation:   true,
+	}
+	err := contivClient.EndpointGroupPost(&epg)
+	if err != nil {
+		t.Fatalf("Error creating epg {%+v}. Err: %v", epg, err)
+	}
+	checkEpgIsolation(t, "default", "build", true)
+
+	// remove and restore the isolation
+	epg.Isolation = false
+	err = contivClient.EndpointGroupPost(&epg)
+	if err != nil {
+		t.Fatalf("Error updating epg {%+v}. Err: %v", epg, err)
+	}
+	checkEpgIsolation(t, "default", "build", false)
+	epg.Isolation = true
+	err = contivClient.EndpointGroupPost(&epg)
+	if err != nil {
+		t.Fatalf("Error updating epg {%+v}. Err: %v", epg, err)
+	}
+	checkEpgIsolation(t, "default", "build", true)
+
+	// delete the EPG and verify the isolation rule is gone
+	checkDeleteEpg(t, false, "default", "contiv", "build")
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.StateDriver, _ = utils.GetStateDriver()
+	if err := ruleCfg.Read(mastercfg.EpgIsolationRuleID(mastercfg.GetEndpointGroupKey("build", "default"))); err == nil {
+		t.Fatalf("Found isolation rule of deleted EPG")
+	}
+
+	// delete the network
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
 // TestExtContractsGroups tests management of external contracts groups
 func TestExtContractsGroups(t *testing.T) {
 	// create network for the test
@@ -2364,6 +2593,70 @@ func TestEPCreate(t *testing.T) {
 	checkInspectNetwork(t, false, "teatwo", "t2-net", "60.1.1.1-60.1.1.3, 60.1.1.254", 1, 3)
 }
 
+// TestTenantQuotas tests enforcement of the tenant quotas
+func TestTenantQuotas(t *testing.T) {
+	tenant := client.Tenant{
+		TenantName:   "quota",
+		MaxNetworks:  1,
+		MaxEndpoints: 1,
+		MaxPolicies:  1,
+		MaxRules:     1,
+	}
+	err := contivClient.TenantPost(&tenant)
+	if err != nil {
+		t.Fatalf("Error creating tenant {%+v}. Err: %v", tenant, err)
+	}
+
+	// negative quotas are rejected
+	invalid := client.Tenant{TenantName: "quota-invalid", MaxNetworks: -1}
+	if err := contivClient.TenantPost(&invalid); err == nil {
+		t.Fatalf("Created tenant {%+v} with a negative quota", invalid)
+	}
+
+	checkCreateNetwork(t, false, "quota", "q-net1", "data", "vlan", "61.1.1.1/24", "61.1.1.254", 1, "", "", "")
+	checkCreateNetwork(t, true, "quota", "q-net2", "data", "vlan", "61.1.2.1/24", "61.1.2.254", 2, "", "", "")
+	checkCreateEpg(t, false, "quota", "q-net1", "q-epg", []string{}, []string{}, "")
+
+	checkCreatePolicy(t, false, "quota", "q-policy1")
+	checkCreatePolicy(t, true, "quota", "q-policy2")
+	checkCreateRule(t, false, "quota", "q-policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
+	checkCreateRule(t, true, "quota", "q-policy1", "2", "in", "", "", "", "", "", "", "tcp", "allow", 1, 443)
+
+	if err := AddEP("quota", "q-net1", "q-epg", "q-c1"); err != nil {
+		t.Fatalf("Error creating ep q-c1. Err: %v", err)
+	}
+	if err := AddEP("quota", "q-net1", "q-epg", "q-c2"); err == nil {
+		t.Fatalf("Created ep q-c2 over the endpoint quota")
+	}
+
+	// verify the usage is reported
+	insp, err := contivClient.TenantInspect("quota")
+	if err != nil {
+		t.Fatalf("Error inspecting tenant quota. Err: %v", err)
+	}
+	if insp.Oper.TotalNetworks != 1 || insp.Oper.TotalEndpoints != 1 ||
+		insp.Oper.TotalRules != 1 || insp.Oper.TotalPktTags != 1 {
+		t.Fatalf("Unexpected tenant usage: %+v", insp.Oper)
+	}
+
+	// raise the network quota, it can not go below the usage again
+	tenant.MaxNetworks = 2
+	err = contivClient.TenantPost(&tenant)
+	if err != nil {
+		t.Fatalf("Error updating tenant quotas {%+v}. Err: %v", tenant, err)
+	}
+	checkCreateNetwork(t, false, "quota", "q-net2", "data", "vlan", "61.1.2.1/24", "61.1.2.254", 2, "", "", "")
+	tenant.MaxNetworks = 1
+	if err := contivClient.TenantPost(&tenant); err == nil {
+		t.Fatalf("Lowered the network quota below its usage")
+	}
+	tenant.MaxNetworks = 2
+	tenant.DefaultNetwork = "q-net1"
+	if err := contivClient.TenantPost(&tenant); err == nil {
+		t.Fatalf("Changed the default network of a tenant")
+	}
+}
+
 // TestClusterMode verifies cluster mode is correctly reflected.
 func TestClusterMode(t *testing.T) {
 
@@ -2387,3 +2680,103 @@ func TestClusterMode(t *testing.T) {
 }
 
 type httpAPIFunc func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error)
+
+// dryRunRequest sends a dry run of a mutation, with the header or the query parameter
+func dryRunRequest(method, objType, key string, obj interface{}, header bool) (*DryRunResult, error) {
+	url := netmasterTestURL + "/api/v1/" + objType + "/" + key + "/"
+	if !header {
+		url += "?dryRun=true"
+	}
+	jsonStr, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, strings.NewReader(string(jsonStr)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if header {
+		req.Header.Set(dryRunHeader, "true")
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, core.Errorf("HTTP error response. Status: %s", res.Status)
+	}
+
+	result := &DryRunResult{}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TestDryRun tests mutations made with dry run change nothing
+func TestDryRun(t *testing.T) {
+	checkCreateNetwork(t, false, "default", "contiv1", "", "vlan", "10.1.1.0/24", "10.1.1.254", 1, "", "", "")
+
+	net := client.Network{
+		TenantName:  "default",
+		NetworkName: "contiv2",
+		Encap:       "vlan",
+		Subnet:      "10.1.2.0/24",
+		Gateway:     "10.1.2.254",
+		PktTag:      2,
+	}
+	res, err := dryRunRequest("POST", "networks", "default:contiv2", &net, false)
+	if err != nil {
+		t.Fatalf("Error in network create dry run. Err: %v", err)
+	}
+	if res.Operation != "create" || !stringInSlice("vlan 2", res.Allocations) ||
+		!stringInSlice("tenant/default", res.Affected) {
+		t.Fatalf("Unexpected network create dry run result: %+v", res)
+	}
+	if _, err := contivClient.NetworkGet("default", "contiv2"); err == nil {
+		t.Fatalf("Network created by a dry run")
+	}
+	checkInspectGlobal(t, false, "1", "")
+
+	// overlapping subnet and vlan in use
+	net.Subnet = "10.1.1.0/25"
+	net.Gateway = ""
+	if _, err := dryRunRequest("POST", "networks", "default:contiv2", &net, true); err == nil {
+		t.Fatalf("Dry run of a network with an overlapping subnet succeeded")
+	}
+	net.Subnet = "10.1.2.0/24"
+	net.PktTag = 1
+	if _, err := dryRunRequest("POST", "networks", "default:contiv2", &net, true); err == nil {
+		t.Fatalf("Dry run of a network with a vlan in use succeeded")
+	}
+
+	res, err = dryRunRequest("DELETE", "networks", "default:contiv1", nil, true)
+	if err != nil {
+		t.Fatalf("Error in network delete dry run. Err: %v", err)
+	}
+	if res.Operation != "delete" || !stringInSlice("vlan 1", res.Releases) {
+		t.Fatalf("Unexpected network delete dry run result: %+v", res)
+	}
+	if _, err := contivClient.NetworkGet("default", "contiv1"); err != nil {
+		t.Fatalf("Network deleted by a dry run")
+	}
+
+	// a global vlan range without the vlans in use is rejected
+	global := client.Global{
+		Name:             "global",
+		NetworkInfraType: "default",
+		Vlans:            "100-200",
+		Vxlans:           "1-10000",
+		FwdMode:          "bridge",
+		ArpMode:          "proxy",
+		PvtSubnet:        defHostPvtNet,
+	}
+	if _, err := dryRunRequest("POST", "globals", "global", &global, false); err == nil {
+		t.Fatalf("Dry run of a vlan range without the vlans in use succeeded")
+	}
+
+	checkDeleteNetwork(t, false, "default", "contiv1")
+}
@@ -0,0 +1,184 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objApi
+
+import (
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/master"
+	"github.com/contiv/netplugin/utils"
+)
+
+// A tenant quota of zero means the tenant has no limit on the objects.
+
+// tenantQuota is a quota of a tenant along with its current usage
+type tenantQuota struct {
+	name  string
+	max   int
+	usage int
+}
+
+// tenantRuleCount returns the number of rules in the policies of a tenant
+func tenantRuleCount(tenant *contivModel.Tenant) int {
+	count := 0
+	for key := range tenant.LinkSets.Policies {
+		policy := contivModel.FindPolicy(key)
+		if policy != nil {
+			count += len(policy.LinkSets.Rules)
+		}
+	}
+
+	return count
+}
+
+// tenantPktTagCount returns the number of packet tags allocated to a tenant,
+// every network gets one and in aci mode every endpoint group gets a vlan
+func tenantPktTagCount(tenant *contivModel.Tenant) int {
+	count := len(tenant.LinkSets.Networks)
+	if aci, _ := master.IsAciConfigured(); aci {
+		count += len(tenant.LinkSets.EndpointGroups)
+	}
+
+	return count
+}
+
+// tenantQuotas returns the quotas of a tenant with their usage
+func tenantQuotas(tenant *contivModel.Tenant) ([]tenantQuota, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+	numEPs, err := master.TenantEndpointCount(stateDriver, tenant.TenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	return []tenantQuota{
+		{"networks", tenant.MaxNetworks, len(tenant.LinkSets.Networks)},
+		{"endpoint groups", tenant.MaxEndpointGroups, len(tenant.LinkSets.EndpointGroups)},
+		{"endpoints", tenant.MaxEndpoints, numEPs},
+		{"policies", tenant.MaxPolicies, len(tenant.LinkSets.Policies)},
+		{"rules", tenant.MaxRules, tenantRuleCount(tenant)},
+		{"service LBs", tenant.MaxServiceLBs, len(tenant.LinkSets.Servicelbs)},
+		{"pkt tags", tenant.MaxPktTags, tenantPktTagCount(tenant)},
+	}, nil
+}
+
+// checkQuota fails if a tenant can not add objects to its current usage
+func checkQuota(tenantName, name string, max, usage, added int) error {
+	if max > 0 && usage+added > max {
+		return core.Errorf("tenant %s exceeds its quota of %d %s, %d in use",
+			tenantName, max, name, usage)
+	}
+
+	return nil
+}
+
+// checkTenantQuotaParams fails on negative quotas
+func checkTenantQuotaParams(tenant *contivModel.Tenant) error {
+	quotas := []tenantQuota{
+		{"networks", tenant.MaxNetworks, 0},
+		{"endpoint groups", tenant.MaxEndpointGroups, 0},
+		{"endpoints", tenant.MaxEndpoints, 0},
+		{"policies", tenant.MaxPolicies, 0},
+		{"rules", tenant.MaxRules, 0},
+		{"service LBs", tenant.MaxServiceLBs, 0},
+		{"pkt tags", tenant.MaxPktTags, 0},
+	}
+	for _, quota := range quotas {
+		if quota.max < 0 {
+			return core.Errorf("Invalid quota %d for %s", quota.max, quota.name)
+		}
+	}
+
+	return nil
+}
+
+// checkTenantUpdate checks the changes to a tenant, only the quotas can be
+// changed and not below their current usage
+func checkTenantUpdate(tenant, params *contivModel.Tenant) error {
+	if params.DefaultNetwork != tenant.DefaultNetwork {
+		return core.Errorf("Cant change tenant parameters after its created")
+	}
+	if err := checkTenantQuotaParams(params); err != nil {
+		return err
+	}
+
+	newTenant := *params
+	newTenant.LinkSets = tenant.LinkSets
+	quotas, err := tenantQuotas(&newTenant)
+	if err != nil {
+		return err
+	}
+	for _, quota := range quotas {
+		if err := checkQuota(tenant.TenantName, quota.name, quota.max, quota.usage, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNetworkQuota checks a tenant can add a network
+func checkNetworkQuota(tenant *contivModel.Tenant) error {
+	err := checkQuota(tenant.TenantName, "networks", tenant.MaxNetworks,
+		len(tenant.LinkSets.Networks), 1)
+	if err != nil {
+		return err
+	}
+
+	return checkQuota(tenant.TenantName, "pkt tags", tenant.MaxPktTags,
+		tenantPktTagCount(tenant), 1)
+}
+
+// checkEndpointGroupQuota checks a tenant can add an endpoint group
+func checkEndpointGroupQuota(tenant *contivModel.Tenant) error {
+	err := checkQuota(tenant.TenantName, "endpoint groups", tenant.MaxEndpointGroups,
+		len(tenant.LinkSets.EndpointGroups), 1)
+	if err != nil {
+		return err
+	}
+
+	if aci, _ := master.IsAciConfigured(); aci {
+		return checkQuota(tenant.TenantName, "pkt tags", tenant.MaxPktTags,
+			tenantPktTagCount(tenant), 1)
+	}
+
+	return nil
+}
+
+// checkPolicyQuota checks a tenant can add a policy
+func checkPolicyQuota(tenant *contivModel.Tenant) error {
+	return checkQuota(tenant.TenantName, "policies", tenant.MaxPolicies,
+		len(tenant.LinkSets.Policies), 1)
+}
+
+// checkRuleQuota checks a tenant can add a rule
+func checkRuleQuota(tenant *contivModel.Tenant) error {
+	return checkQuota(tenant.TenantName, "rules", tenant.MaxRules,
+		tenantRuleCount(tenant), 1)
+}
+
+// checkServiceLBQuota checks a tenant can add a service LB, updates of a
+// service go through its create and are not counted again
+func checkServiceLBQuota(tenant *contivModel.Tenant, serviceCfg *contivModel.ServiceLB) error {
+	if _, ok := tenant.LinkSets.Servicelbs[serviceCfg.Key]; ok {
+		return nil
+	}
+
+	return checkQuota(tenant.TenantName, "service LBs", tenant.MaxServiceLBs,
+		len(tenant.LinkSets.Servicelbs), 1)
+}
@@ -0,0 +1,317 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policysim evaluates contiv policies offline. The rules attached to
+// the endpoint groups are expanded into directional rules the same way
+// netmaster builds the ofnet rules and the first packet of a flow is matched
+// against them, without touching the datapath.
+package policysim
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/contivmodel"
+)
+
+// Flow is the first packet of a simulated connection
+type Flow struct {
+	SrcGroup string // source endpoint group key, empty if not in a group
+	SrcIP    net.IP // source address, nil if unknown
+	DstGroup string // destination endpoint group key, empty if not in a group
+	DstIP    net.IP // destination address, nil if unknown
+	Protocol string // tcp, udp, icmp, igmp or protocol number
+	Port     int    // destination port
+}
+
+// Verdict is the result of a simulation
+type Verdict struct {
+	Action        string            `json:"action"`                  // allow, deny or reject
+	Rule          *contivModel.Rule `json:"rule,omitempty"`          // deciding rule, nil for the default
+	Direction     string            `json:"direction,omitempty"`     // directional rule that matched
+	EndpointGroup string            `json:"endpointGroup,omitempty"` // group the policy is attached to
+}
+
+// dirRule is a directional rule, the equivalent of an ofnet rule
+type dirRule struct {
+	rule     *contivModel.Rule
+	group    string // group the policy is attached to
+	dir      string // inRx, inTx, outRx or outTx
+	srcGroup string // empty matches any group
+	dstGroup string
+	srcNet   *net.IPNet // nil matches any address
+	dstNet   *net.IPNet
+	fqdn     string // domain name of the remote addresses
+	protocol uint8  // 0 matches any protocol
+	srcPort  uint16
+	dstPort  uint16
+}
+
+type dirRulesByKey []*dirRule
+
+func (r dirRulesByKey) Len() int      { return len(r) }
+func (r dirRulesByKey) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r dirRulesByKey) Less(i, j int) bool {
+	if r[i].group != r[j].group {
+		return r[i].group < r[j].group
+	}
+	return r[i].rule.Key < r[j].rule.Key
+}
+
+// Simulator evaluates flows against a set of policies
+type Simulator struct {
+	rules    []*dirRule
+	isolated map[string]bool // isolated groups
+}
+
+// NewSimulator expands the rules of the policies attached to the endpoint
+// groups, networks are needed to resolve rules matching on a network
+func NewSimulator(groups []*contivModel.EndpointGroup, rules []*contivModel.Rule,
+	networks []*contivModel.Network) *Simulator {
+	sim := &Simulator{isolated: make(map[string]bool)}
+
+	subnets := make(map[string]string)
+	for _, nw := range networks {
+		subnets[nw.TenantName+":"+nw.NetworkName] = nw.Subnet
+	}
+
+	policyRules := make(map[string][]*contivModel.Rule)
+	for _, rule := range rules {
+		policyKey := rule.TenantName + ":" + rule.PolicyName
+		policyRules[policyKey] = append(policyRules[policyKey], rule)
+	}
+
+	for _, epg := range groups {
+		groupKey := epg.TenantName + ":" + epg.GroupName
+		if epg.Isolation {
+			sim.isolated[groupKey] = true
+		}
+		for _, policyName := range epg.Policies {
+			for _, rule := range policyRules[epg.TenantName+":"+policyName] {
+				dirRules, err := expandRule(rule, groupKey, subnets)
+				if err != nil {
+					log.Warnf("Skipping rule %s of group %s. Err: %v", rule.Key, groupKey, err)
+					continue
+				}
+				sim.rules = append(sim.rules, dirRules...)
+			}
+		}
+	}
+
+	// keep the evaluation order stable between runs
+	sort.Stable(dirRulesByKey(sim.rules))
+
+	return sim
+}
+
+// Check returns the verdict for a flow. Flows between endpoints of an isolated
+// group are denied without a rule. Otherwise the highest priority matching
+// rule decides, ties are resolved towards deny or reject since the datapath
+// order of equal priority flows is undefined. Flows matching no rule are
+// allowed.
+func (sim *Simulator) Check(flow *Flow) (*Verdict, error) {
+	protocol, err := protocolNumber(flow.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	if flow.SrcGroup != "" && flow.SrcGroup == flow.DstGroup && sim.isolated[flow.SrcGroup] {
+		return &Verdict{Action: "deny", EndpointGroup: flow.SrcGroup}, nil
+	}
+
+	var best *dirRule
+	for _, dr := range sim.rules {
+		if !dr.matches(flow, protocol) {
+			continue
+		}
+		if best == nil || dr.rule.Priority > best.rule.Priority ||
+			(dr.rule.Priority == best.rule.Priority && best.rule.Action == "allow" && dr.rule.Action != "allow") {
+			best = dr
+		}
+	}
+
+	if best == nil {
+		return &Verdict{Action: "allow"}, nil
+	}
+
+	return &Verdict{
+		Action:        best.rule.Action,
+		Rule:          best.rule,
+		Direction:     best.dir,
+		EndpointGroup: best.group,
+	}, nil
+}
+
+// matches checks if the first packet of the flow hits the rule, the source
+// port of the packet is ephemeral so rules on the source port never match.
+// The addresses of domain names are learned by the agents at run time, rules
+// on a domain name never match either.
+func (dr *dirRule) matches(flow *Flow, protocol uint8) bool {
+	if dr.fqdn != "" {
+		return false
+	}
+	if dr.srcGroup != "" && dr.srcGroup != flow.SrcGroup {
+		return false
+	}
+	if dr.dstGroup != "" && dr.dstGroup != flow.DstGroup {
+		return false
+	}
+	if dr.srcNet != nil && (flow.SrcIP == nil || !dr.srcNet.Contains(flow.SrcIP)) {
+		return false
+	}
+	if dr.dstNet != nil && (flow.DstIP == nil || !dr.dstNet.Contains(flow.DstIP)) {
+		return false
+	}
+	if dr.protocol != 0 && dr.protocol != protocol {
+		return false
+	}
+	if dr.srcPort != 0 {
+		return false
+	}
+	if dr.dstPort != 0 && int(dr.dstPort) != flow.Port {
+		return false
+	}
+
+	return true
+}
+
+// expandRule builds the directional rules of a policy rule attached to a
+// group, same as EpgPolicy.AddRule and EpgPolicy.createOfnetRule
+func expandRule(rule *contivModel.Rule, groupKey string, subnets map[string]string) ([]*dirRule, error) {
+	var dirs []string
+	hasPort := (rule.Protocol == "udp" || rule.Protocol == "tcp") && rule.Port != 0
+	switch rule.Direction {
+	case "in":
+		if hasPort {
+			dirs = []string{"inRx", "inTx"}
+		} else {
+			dirs = []string{"inRx"}
+		}
+	case "out":
+		if hasPort {
+			dirs = []string{"outRx", "outTx"}
+		} else {
+			dirs = []string{"outTx"}
+		}
+	case "both":
+		if hasPort {
+			dirs = []string{"inRx", "inTx", "outRx", "outTx"}
+		} else {
+			dirs = []string{"inRx", "outTx"}
+		}
+	}
+
+	// the remote group takes precedence over the remote network
+	var remoteGroup string
+	fromIP, toIP := rule.FromIpAddress, rule.ToIpAddress
+	if rule.FromEndpointGroup != "" {
+		remoteGroup = rule.TenantName + ":" + rule.FromEndpointGroup
+	} else if rule.ToEndpointGroup != "" {
+		remoteGroup = rule.TenantName + ":" + rule.ToEndpointGroup
+	} else if rule.FromNetwork != "" {
+		subnet, found := subnets[rule.TenantName+":"+rule.FromNetwork]
+		if !found {
+			return nil, fmt.Errorf("network %s not found", rule.FromNetwork)
+		}
+		fromIP = subnet
+	} else if rule.ToNetwork != "" {
+		subnet, found := subnets[rule.TenantName+":"+rule.ToNetwork]
+		if !found {
+			return nil, fmt.Errorf("network %s not found", rule.ToNetwork)
+		}
+		toIP = subnet
+	}
+
+	fromNet, err := parseIPNet(fromIP)
+	if err != nil {
+		return nil, err
+	}
+	toNet, err := parseIPNet(toIP)
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := protocolNumber(rule.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	dirRules := []*dirRule{}
+	for _, dir := range dirs {
+		dr := &dirRule{rule: rule, group: groupKey, dir: dir, protocol: protocol, fqdn: rule.ToFqdn}
+		switch dir {
+		case "inRx":
+			dr.dstGroup, dr.srcGroup = groupKey, remoteGroup
+			dr.srcNet = fromNet
+			dr.dstPort = uint16(rule.Port)
+		case "inTx":
+			dr.srcGroup, dr.dstGroup = groupKey, remoteGroup
+			dr.dstNet = fromNet
+			dr.srcPort = uint16(rule.Port)
+		case "outRx":
+			dr.dstGroup, dr.srcGroup = groupKey, remoteGroup
+			dr.srcNet = toNet
+			dr.srcPort = uint16(rule.Port)
+		case "outTx":
+			dr.srcGroup, dr.dstGroup = groupKey, remoteGroup
+			dr.dstNet = toNet
+			dr.dstPort = uint16(rule.Port)
+		}
+		dirRules = append(dirRules, dr)
+	}
+
+	return dirRules, nil
+}
+
+// parseIPNet parses an address or a subnet, empty matches any address
+func parseIPNet(addr string) (*net.IPNet, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	if !strings.Contains(addr, "/") {
+		addr += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s", addr)
+	}
+
+	return ipNet, nil
+}
+
+// protocolNumber converts a rule protocol to its ip protocol number
+func protocolNumber(protocol string) (uint8, error) {
+	switch protocol {
+	case "tcp":
+		return 6, nil
+	case "udp":
+		return 17, nil
+	case "icmp":
+		return 1, nil
+	case "igmp":
+		return 2, nil
+	case "":
+		return 0, nil
+	}
+
+	proto, err := strconv.Atoi(protocol)
+	if err != nil || proto < 0 || proto > 255 {
+		return 0, fmt.Errorf("invalid protocol %s", protocol)
+	}
+	return uint8(proto), nil
+}
@@ -0,0 +1,139 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policysim
+
+import (
+	"net"
+	"testing"
+
+	"github.com/contiv/contivmodel"
+)
+
+func newRule(policy, id string, prio int, dir, action string) *contivModel.Rule {
+	return &contivModel.Rule{
+		Key:        "default:" + policy + ":" + id,
+		TenantName: "default",
+		PolicyName: policy,
+		RuleID:     id,
+		Priority:   prio,
+		Direction:  dir,
+		Action:     action,
+	}
+}
+
+// simFixture has a web group only reachable from the app group on tcp/443,
+// the app group can't reach the internet except for dns servers and the
+// endpoints of the build group are isolated
+func simFixture() *Simulator {
+	groups := []*contivModel.EndpointGroup{
+		{TenantName: "default", GroupName: "web", NetworkName: "net1", Policies: []string{"webpol"}},
+		{TenantName: "default", GroupName: "app", NetworkName: "net1", Policies: []string{"apppol"}},
+		{TenantName: "default", GroupName: "db", NetworkName: "net2"},
+		{TenantName: "default", GroupName: "build", NetworkName: "net2", Isolation: true},
+	}
+
+	denyIn := newRule("webpol", "1", 1, "in", "deny")
+	denyIn.Protocol = "tcp"
+	allowHTTPS := newRule("webpol", "2", 10, "in", "allow")
+	allowHTTPS.Protocol = "tcp"
+	allowHTTPS.Port = 443
+	allowHTTPS.FromEndpointGroup = "app"
+	denyNet2 := newRule("webpol", "3", 20, "in", "deny")
+	denyNet2.FromNetwork = "net2"
+
+	denyOut := newRule("apppol", "1", 1, "out", "deny")
+	allowDNS := newRule("apppol", "2", 5, "out", "allow")
+	allowDNS.Protocol = "udp"
+	allowDNS.Port = 53
+	allowDNS.ToIpAddress = "8.8.8.0/24"
+	tieDeny := newRule("apppol", "3", 5, "out", "deny")
+	tieDeny.ToIpAddress = "8.8.8.8"
+	rejectNTP := newRule("apppol", "4", 5, "out", "reject")
+	rejectNTP.Protocol = "udp"
+	rejectNTP.Port = 123
+
+	allowSaaS := newRule("apppol", "5", 5, "out", "allow")
+	allowSaaS.Protocol = "tcp"
+	allowSaaS.Port = 443
+	allowSaaS.ToFqdn = "api.example.com"
+
+	rules := []*contivModel.Rule{denyIn, allowHTTPS, denyNet2, denyOut, allowDNS, tieDeny, rejectNTP, allowSaaS}
+	networks := []*contivModel.Network{
+		{TenantName: "default", NetworkName: "net1", Subnet: "10.1.1.0/24"},
+		{TenantName: "default", NetworkName: "net2", Subnet: "10.1.2.0/24"},
+	}
+
+	return NewSimulator(groups, rules, networks)
+}
+
+func TestCheck(t *testing.T) {
+	sim := simFixture()
+
+	testCases := []struct {
+		name   string
+		flow   Flow
+		action string
+		ruleID string // empty for the default verdict
+		dir    string
+	}{
+		{"app to web https", Flow{"default:app", net.ParseIP("10.1.1.2"), "default:web", net.ParseIP("10.1.1.3"), "tcp", 443},
+			"allow", "2", "inRx"},
+		{"app to web http", Flow{"default:app", net.ParseIP("10.1.1.2"), "default:web", net.ParseIP("10.1.1.3"), "tcp", 80},
+			"deny", "1", "outTx"},
+		{"db to web https", Flow{"default:db", net.ParseIP("10.1.2.2"), "default:web", net.ParseIP("10.1.1.3"), "tcp", 443},
+			"deny", "3", "inRx"},
+		{"external to web udp", Flow{"", net.ParseIP("1.1.1.1"), "default:web", net.ParseIP("10.1.1.3"), "udp", 53},
+			"allow", "", ""},
+		{"app to dns", Flow{"default:app", net.ParseIP("10.1.1.2"), "", net.ParseIP("8.8.8.53"), "udp", 53},
+			"allow", "2", "outTx"},
+		{"app to dns tie", Flow{"default:app", net.ParseIP("10.1.1.2"), "", net.ParseIP("8.8.8.8"), "udp", 53},
+			"deny", "3", "outTx"},
+		{"app to ntp", Flow{"default:app", net.ParseIP("10.1.1.2"), "", net.ParseIP("8.8.8.53"), "udp", 123},
+			"reject", "4", "outTx"},
+		{"app to saas", Flow{"default:app", net.ParseIP("10.1.1.2"), "", net.ParseIP("192.0.2.10"), "tcp", 443},
+			"deny", "1", "outTx"},
+		{"db to db", Flow{"default:db", net.ParseIP("10.1.2.2"), "default:db", net.ParseIP("10.1.2.3"), "tcp", 22},
+			"allow", "", ""},
+		{"build to build", Flow{"default:build", net.ParseIP("10.1.2.4"), "default:build", net.ParseIP("10.1.2.5"), "tcp", 22},
+			"deny", "", ""},
+		{"build to db", Flow{"default:build", net.ParseIP("10.1.2.4"), "default:db", net.ParseIP("10.1.2.2"), "tcp", 22},
+			"allow", "", ""},
+	}
+
+	for _, tc := range testCases {
+		verdict, err := sim.Check(&tc.flow)
+		if err != nil {
+			t.Fatalf("%s: error checking flow: %v", tc.name, err)
+		}
+		if verdict.Action != tc.action {
+			t.Errorf("%s: got %s, expected %s", tc.name, verdict.Action, tc.action)
+		}
+		if tc.ruleID == "" {
+			if verdict.Rule != nil {
+				t.Errorf("%s: unexpected rule %+v", tc.name, verdict.Rule)
+			}
+			continue
+		}
+		if verdict.Rule == nil || verdict.Rule.RuleID != tc.ruleID || verdict.Direction != tc.dir {
+			t.Errorf("%s: got rule %+v dir %s, expected rule %s dir %s",
+				tc.name, verdict.Rule, verdict.Direction, tc.ruleID, tc.dir)
+		}
+	}
+
+	if _, err := sim.Check(&Flow{Protocol: "sctp"}); err == nil {
+		t.Fatalf("invalid protocol was accepted")
+	}
+}
@@ -0,0 +1,667 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package statecheck cross-validates the objects of the state store: the
+// mastercfg state, the global vlan and vxlan resources, the contivmodel
+// objects and the docker network oper state. It reports each inconsistency
+// with the key of the object and optionally repairs the ones that can be
+// fixed without losing configuration.
+//
+// The checker reads and writes the objects without taking the netmaster
+// locks, it must run while no endpoints or networks are being changed, i.e.
+// at netmaster startup or with netmaster stopped.
+package statecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/contiv/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/docknet"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/netmaster/resources"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/objdb"
+	"github.com/jainvipin/bitset"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// keys of the checked objects, for the reports
+const (
+	networkKeyPrefix  = mastercfg.StateConfigPath + "nets/"
+	endpointKeyPrefix = mastercfg.StateConfigPath + "eps/"
+	epgKeyPrefix      = mastercfg.StateConfigPath + "endpointGroups/"
+	vlanKey           = mastercfg.StateOperPath + resources.AutoVLANResource + "/global"
+	vxlanKey          = mastercfg.StateOperPath + resources.AutoVXLANResource + "/global"
+	docknetKeyPrefix  = mastercfg.StateOperPath + "docknet/"
+	modelKeyPrefix    = "/modeldb/"
+)
+
+// Inconsistency is a mismatch between objects of the state store
+type Inconsistency struct {
+	Key         string `json:"key"`         // key of the inconsistent object
+	Description string `json:"description"` // what is wrong
+	Repairable  bool   `json:"repairable"`  // can be fixed by the checker
+	Repaired    bool   `json:"repaired"`    // was fixed by the checker
+}
+
+// String returns the report line of the inconsistency
+func (inc *Inconsistency) String() string {
+	status := "not repairable"
+	if inc.Repaired {
+		status = "repaired"
+	} else if inc.Repairable {
+		status = "repairable"
+	}
+
+	return fmt.Sprintf("%s: %s (%s)", inc.Key, inc.Description, status)
+}
+
+// checker holds the objects read from the state store
+type checker struct {
+	stateDriver core.StateDriver
+	modelDb     objdb.API
+	repair      bool
+
+	networks  map[string]*mastercfg.CfgNetworkState
+	endpoints map[string]*mastercfg.CfgEndpointState
+	epgs      map[string]*mastercfg.EndpointGroupState
+
+	// in progress pkt tag migrations, the networks hold their old and new tags
+	migrations map[string]*mastercfg.PktTagMigrationState
+
+	incs []*Inconsistency
+}
+
+// Run checks the state store and returns the inconsistencies found, fixing
+// the repairable ones when repair is set. The contivmodel objects are read
+// from modelDb, they are not checked when it is nil.
+func Run(stateDriver core.StateDriver, modelDb objdb.API, repair bool) ([]*Inconsistency, error) {
+	c := &checker{
+		stateDriver: stateDriver,
+		modelDb:     modelDb,
+		repair:      repair,
+		networks:    make(map[string]*mastercfg.CfgNetworkState),
+		endpoints:   make(map[string]*mastercfg.CfgEndpointState),
+		epgs:        make(map[string]*mastercfg.EndpointGroupState),
+		migrations:  make(map[string]*mastercfg.PktTagMigrationState),
+		incs:        []*Inconsistency{},
+	}
+
+	if err := c.readState(); err != nil {
+		return nil, err
+	}
+
+	// endpoints are removed first so that the counts and address maps are
+	// checked against the remaining ones
+	checks := []func() error{
+		c.checkEndpoints,
+		c.checkEpCounts,
+		c.checkNetworkAddresses,
+		c.checkEpgAddresses,
+		c.checkVLANs,
+		c.checkVXLANs,
+		c.checkDocknets,
+		c.checkModel,
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return c.incs, err
+		}
+	}
+
+	return c.incs, nil
+}
+
+// report records an inconsistency, fix is called to repair it when the
+// checker runs in repair mode, a nil fix means it is not repairable
+func (c *checker) report(key string, fix func() error, format string, args ...interface{}) error {
+	inc := &Inconsistency{
+		Key:         key,
+		Description: fmt.Sprintf(format, args...),
+		Repairable:  fix != nil,
+	}
+	c.incs = append(c.incs, inc)
+
+	if !c.repair || fix == nil {
+		log.Warnf("State inconsistency: %s", inc)
+		return nil
+	}
+
+	if err := fix(); err != nil {
+		log.Errorf("Error repairing %s. Err: %v", inc, err)
+		return err
+	}
+	inc.Repaired = true
+	log.Infof("State inconsistency: %s", inc)
+
+	return nil
+}
+
+func (c *checker) readState() error {
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = c.stateDriver
+	nwList, err := nwCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, nw := range nwList {
+		c.networks[nw.(*mastercfg.CfgNetworkState).ID] = nw.(*mastercfg.CfgNetworkState)
+	}
+
+	epCfg := &mastercfg.CfgEndpointState{}
+	epCfg.StateDriver = c.stateDriver
+	epList, err := epCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, ep := range epList {
+		c.endpoints[ep.(*mastercfg.CfgEndpointState).ID] = ep.(*mastercfg.CfgEndpointState)
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = c.stateDriver
+	epgList, err := epgCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, epg := range epgList {
+		c.epgs[epg.(*mastercfg.EndpointGroupState).ID] = epg.(*mastercfg.EndpointGroupState)
+	}
+
+	migCfg := &mastercfg.PktTagMigrationState{}
+	migCfg.StateDriver = c.stateDriver
+	migList, err := migCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+	for _, mig := range migList {
+		mig := mig.(*mastercfg.PktTagMigrationState)
+		if mig.Status == mastercfg.PktTagMigrating {
+			c.migrations[mig.NetworkID] = mig
+		}
+	}
+
+	return nil
+}
+
+// checkEndpoints removes the endpoints of deleted networks
+func (c *checker) checkEndpoints() error {
+	for id, ep := range c.endpoints {
+		ep := ep
+		if _, found := c.networks[ep.NetID]; !found {
+			err := c.report(endpointKeyPrefix+id, func() error {
+				delete(c.endpoints, ep.ID)
+				return ep.Clear()
+			}, "endpoint of deleted network %s", ep.NetID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ep.EndpointGroupKey != "" {
+			if _, found := c.epgs[ep.EndpointGroupKey]; !found {
+				err := c.report(endpointKeyPrefix+id, nil,
+					"endpoint of deleted endpoint group %s", ep.EndpointGroupKey)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkEpCounts compares the endpoint counts of the networks and endpoint
+// groups with their endpoints
+func (c *checker) checkEpCounts() error {
+	nwCounts := make(map[string]int)
+	epgCounts := make(map[string]int)
+	for _, ep := range c.endpoints {
+		nwCounts[ep.NetID]++
+		if ep.EndpointGroupKey != "" {
+			epgCounts[ep.EndpointGroupKey]++
+		}
+	}
+
+	for id, nw := range c.networks {
+		nw, count := nw, nwCounts[id]
+		if nw.EpCount != count {
+			err := c.report(networkKeyPrefix+id, func() error {
+				nw.EpCount = count
+				return nw.Write()
+			}, "endpoint count is %d, the network has %d endpoints", nw.EpCount, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for id, epg := range c.epgs {
+		epg, count := epg, epgCounts[id]
+		if epg.EpCount != count {
+			err := c.report(epgKeyPrefix+id, func() error {
+				epg.EpCount = count
+				return epg.Write()
+			}, "endpoint count is %d, the endpoint group has %d endpoints", epg.EpCount, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// epgPool returns the endpoint group an endpoint allocates its address
+// from, nil when it is allocated from the network
+func (c *checker) epgPool(ep *mastercfg.CfgEndpointState) *mastercfg.EndpointGroupState {
+	epg := c.epgs[ep.EndpointGroupKey]
+	if epg == nil || epg.IPPool == "" {
+		return nil
+	}
+	return epg
+}
+
+// compareAllocMap reports the addresses allocated in allocMap and not in
+// expected as leaked, and the ones of expected missing from allocMap as
+// not reserved. write saves the object of allocMap after a repair.
+func (c *checker) compareAllocMap(key string, allocMap, expected *bitset.BitSet,
+	nw *mastercfg.CfgNetworkState, owners map[uint]string, write func() error) error {
+	for i, e := allocMap.NextSet(0); e; i, e = allocMap.NextSet(i + 1) {
+		if expected.Test(i) {
+			continue
+		}
+
+		idx := i
+		ipAddress, _ := netutils.GetSubnetIP(nw.SubnetIP, nw.SubnetLen, 32, idx)
+		err := c.report(key, func() error {
+			allocMap.Clear(idx)
+			nw.EpAddrCount--
+			if err := write(); err != nil {
+				return err
+			}
+			return nw.Write()
+		}, "address %s is allocated but not used by any endpoint", ipAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, owner := range owners {
+		if allocMap.Test(idx) {
+			continue
+		}
+
+		idx := idx
+		ipAddress, _ := netutils.GetSubnetIP(nw.SubnetIP, nw.SubnetLen, 32, idx)
+		err := c.report(key, func() error {
+			allocMap.Set(idx)
+			nw.EpAddrCount++
+			if err := write(); err != nil {
+				return err
+			}
+			return nw.Write()
+		}, "address %s of endpoint %s is not allocated", ipAddress, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endpointAddrs returns the address indexes of the endpoints matching
+// filter in the subnet of a network, by endpoint
+func (c *checker) endpointAddrs(nw *mastercfg.CfgNetworkState,
+	filter func(*mastercfg.CfgEndpointState) bool) map[uint]string {
+	owners := make(map[uint]string)
+	for _, ep := range c.endpoints {
+		if ep.NetID != nw.ID || ep.IPAddress == "" || netutils.IsIPv6(ep.IPAddress) || !filter(ep) {
+			continue
+		}
+
+		idx, err := netutils.GetIPNumber(nw.SubnetIP, nw.SubnetLen, 32, ep.IPAddress)
+		if err != nil {
+			log.Warnf("Address %s of endpoint %s is not in subnet %s/%d", ep.IPAddress,
+				ep.ID, nw.SubnetIP, nw.SubnetLen)
+			continue
+		}
+		owners[idx] = ep.ID
+	}
+
+	return owners
+}
+
+// checkNetworkAddresses compares the address maps of the networks with the
+// addresses of their endpoints, gateways and endpoint group pools
+func (c *checker) checkNetworkAddresses() error {
+	for id, nw := range c.networks {
+		if nw.SubnetIP == "" {
+			continue
+		}
+
+		expected := &bitset.BitSet{}
+		netutils.InitSubnetBitset(expected, nw.SubnetLen)
+		if nw.IPAddrRange != "" {
+			netutils.SetBitsOutsideRange(expected, nw.IPAddrRange, nw.SubnetLen)
+		}
+		if nw.Gateway != "" {
+			if idx, err := netutils.GetIPNumber(nw.SubnetIP, nw.SubnetLen, 32, nw.Gateway); err == nil {
+				expected.Set(idx)
+			}
+		}
+		for _, epg := range c.epgs {
+			if epg.IPPool != "" && epg.NetworkName == nw.NetworkName && epg.TenantName == nw.Tenant {
+				netutils.SetIPAddrRange(expected, epg.IPPool, nw.SubnetIP, nw.SubnetLen)
+			}
+		}
+
+		owners := c.endpointAddrs(nw, func(ep *mastercfg.CfgEndpointState) bool {
+			return c.epgPool(ep) == nil
+		})
+		for idx := range owners {
+			expected.Set(idx)
+		}
+
+		if err := c.compareAllocMap(networkKeyPrefix+id, &nw.IPAllocMap, expected,
+			nw, owners, nw.Write); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkEpgAddresses compares the address maps of the endpoint group pools
+// with the addresses of their endpoints
+func (c *checker) checkEpgAddresses() error {
+	for id, epg := range c.epgs {
+		nw := c.networks[epg.NetworkName+"."+epg.TenantName]
+		if epg.IPPool == "" || nw == nil || nw.SubnetIP == "" {
+			continue
+		}
+
+		expected := &bitset.BitSet{}
+		netutils.InitSubnetBitset(expected, nw.SubnetLen)
+		netutils.SetBitsOutsideRange(expected, epg.IPPool, nw.SubnetLen)
+
+		owners := c.endpointAddrs(nw, func(ep *mastercfg.CfgEndpointState) bool {
+			return c.epgPool(ep) == epg
+		})
+		for idx := range owners {
+			expected.Set(idx)
+		}
+
+		if err := c.compareAllocMap(epgKeyPrefix+id, &epg.EPGIPAllocMap, expected,
+			nw, owners, epg.Write); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareInUse reports the values of inUse not in expected as leaked and
+// the values of expected not in inUse as not allocated. free is the free
+// map of the resource, offset is added to the reported values.
+func (c *checker) compareInUse(key, name string, inUse, free, pool *bitset.BitSet,
+	expected map[uint]string, offset uint, write func() error) error {
+	for i, e := inUse.NextSet(0); e; i, e = inUse.NextSet(i + 1) {
+		if _, found := expected[i]; found {
+			continue
+		}
+
+		idx := i
+		err := c.report(key, func() error {
+			free.Set(idx)
+			return write()
+		}, "%s %d is allocated but not used by any network or endpoint group", name, idx+offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, owner := range expected {
+		if inUse.Test(idx) {
+			continue
+		}
+
+		idx := idx
+		fix := func() error {
+			free.Clear(idx)
+			return write()
+		}
+		if !pool.Test(idx) {
+			// out of the configured range, can't be allocated
+			fix = nil
+		}
+		err := c.report(key, fix, "%s %d of %s is not allocated", name, idx+offset, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkVLANs compares the global vlan pool with the vlans of the networks
+// and endpoint groups
+func (c *checker) checkVLANs() error {
+	cfg := &resources.AutoVLANCfgResource{}
+	cfg.StateDriver = c.stateDriver
+	oper := &resources.AutoVLANOperResource{}
+	oper.StateDriver = c.stateDriver
+	if cfg.Read("global") != nil || oper.Read("global") != nil ||
+		cfg.VLANs == nil || oper.FreeVLANs == nil {
+		// no vlan pool configured
+		return nil
+	}
+
+	expected := make(map[uint]string)
+	for id, nw := range c.networks {
+		if nw.PktTagType == "vlan" {
+			expected[uint(nw.PktTag)] = "network " + id
+		}
+	}
+	for id, mig := range c.migrations {
+		if _, found := c.networks[id]; found && mig.PktTagType == "vlan" {
+			for _, tag := range []int{mig.OldPktTag, mig.NewPktTag} {
+				if _, found := expected[uint(tag)]; !found {
+					expected[uint(tag)] = "pkt tag migration of network " + id
+				}
+			}
+		}
+	}
+	for id, epg := range c.epgs {
+		if epg.PktTagType == "vlan" {
+			if _, found := expected[uint(epg.PktTag)]; !found {
+				expected[uint(epg.PktTag)] = "endpoint group " + id
+			}
+		}
+	}
+
+	inUse := cfg.VLANs.Difference(oper.FreeVLANs)
+	return c.compareInUse(vlanKey, "vlan", inUse, oper.FreeVLANs, cfg.VLANs,
+		expected, 0, oper.Write)
+}
+
+// checkVXLANs compares the global vxlan pool and its local vlans with the
+// vxlan networks
+func (c *checker) checkVXLANs() error {
+	cfg := &resources.AutoVXLANCfgResource{}
+	cfg.StateDriver = c.stateDriver
+	oper := &resources.AutoVXLANOperResource{}
+	oper.StateDriver = c.stateDriver
+	if cfg.Read("global") != nil || oper.Read("global") != nil || cfg.VXLANs == nil ||
+		cfg.LocalVLANs == nil || oper.FreeVXLANs == nil || oper.FreeLocalVLANs == nil {
+		// no vxlan pool configured
+		return nil
+	}
+
+	vxlans := make(map[uint]string)
+	localVLANs := make(map[uint]string)
+	for id, nw := range c.networks {
+		if nw.PktTagType == "vxlan" {
+			vxlans[uint(nw.ExtPktTag)-cfg.FreeVXLANsStart] = "network " + id
+			localVLANs[uint(nw.PktTag)] = "network " + id
+		}
+	}
+	for id, mig := range c.migrations {
+		if _, found := c.networks[id]; !found || mig.PktTagType != "vxlan" {
+			continue
+		}
+		owner := "pkt tag migration of network " + id
+		for _, tag := range []int{mig.OldExtPktTag, mig.NewExtPktTag} {
+			if _, found := vxlans[uint(tag)-cfg.FreeVXLANsStart]; !found {
+				vxlans[uint(tag)-cfg.FreeVXLANsStart] = owner
+			}
+		}
+		for _, tag := range []int{mig.OldPktTag, mig.NewPktTag} {
+			if _, found := localVLANs[uint(tag)]; !found {
+				localVLANs[uint(tag)] = owner
+			}
+		}
+	}
+
+	inUse := cfg.VXLANs.Difference(oper.FreeVXLANs)
+	if err := c.compareInUse(vxlanKey, "vxlan", inUse, oper.FreeVXLANs, cfg.VXLANs,
+		vxlans, cfg.FreeVXLANsStart, oper.Write); err != nil {
+		return err
+	}
+
+	inUse = cfg.LocalVLANs.Difference(oper.FreeLocalVLANs)
+	return c.compareInUse(vxlanKey, "local vlan", inUse, oper.FreeLocalVLANs, cfg.LocalVLANs,
+		localVLANs, 0, oper.Write)
+}
+
+// checkDocknets removes the docker network state of deleted networks and
+// endpoint groups
+func (c *checker) checkDocknets() error {
+	dnetCfg := &docknet.DnetOperState{}
+	dnetCfg.StateDriver = c.stateDriver
+	dnetList, err := dnetCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+
+	for _, dnetState := range dnetList {
+		dnet := dnetState.(*docknet.DnetOperState)
+		owner := "network " + dnet.NetworkName + "." + dnet.TenantName
+		_, found := c.networks[dnet.NetworkName+"."+dnet.TenantName]
+		if dnet.ServiceName != "" {
+			owner = "endpoint group " + mastercfg.GetEndpointGroupKey(dnet.ServiceName, dnet.TenantName)
+			_, found = c.epgs[mastercfg.GetEndpointGroupKey(dnet.ServiceName, dnet.TenantName)]
+		}
+		if found {
+			continue
+		}
+
+		err := c.report(docknetKeyPrefix+dnet.ID, dnet.Clear,
+			"docker network %s of deleted %s", dnet.DocknetUUID, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readModel reads the contivmodel objects of a type
+func (c *checker) readModel(objType string, newObj func() interface{}) ([]interface{}, error) {
+	strList, err := c.modelDb.ListDir(modelKeyPrefix + objType + "/")
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	objs := []interface{}{}
+	for _, objStr := range strList {
+		obj := newObj()
+		if err := json.Unmarshal([]byte(objStr), obj); err != nil {
+			log.Errorf("Error parsing %s object %s. Err: %v", objType, objStr, err)
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+// checkModel compares the contivmodel networks and endpoint groups with
+// their state, the model is the source of truth so mismatches are only
+// reported
+func (c *checker) checkModel() error {
+	if c.modelDb == nil {
+		return nil
+	}
+
+	networks, err := c.readModel("network", func() interface{} { return &contivModel.Network{} })
+	if err != nil {
+		return err
+	}
+	modelNetworks := make(map[string]bool)
+	for _, obj := range networks {
+		network := obj.(*contivModel.Network)
+		id := network.NetworkName + "." + network.TenantName
+		modelNetworks[id] = true
+		if _, found := c.networks[id]; !found {
+			if err := c.report(modelKeyPrefix+"network/"+network.Key, nil,
+				"network has no state"); err != nil {
+				return err
+			}
+		}
+	}
+	for id, nw := range c.networks {
+		if !modelNetworks[id] && nw.NwType != "infra" {
+			if err := c.report(networkKeyPrefix+id, nil, "network has no model object"); err != nil {
+				return err
+			}
+		}
+	}
+
+	epgs, err := c.readModel("endpointGroup", func() interface{} { return &contivModel.EndpointGroup{} })
+	if err != nil {
+		return err
+	}
+	modelEpgs := make(map[string]bool)
+	for _, obj := range epgs {
+		epg := obj.(*contivModel.EndpointGroup)
+		id := mastercfg.GetEndpointGroupKey(epg.GroupName, epg.TenantName)
+		modelEpgs[id] = true
+		if _, found := c.epgs[id]; !found {
+			if err := c.report(modelKeyPrefix+"endpointGroup/"+epg.Key, nil,
+				"endpoint group has no state"); err != nil {
+				return err
+			}
+		}
+	}
+	for id := range c.epgs {
+		if !modelEpgs[id] {
+			if err := c.report(epgKeyPrefix+id, nil, "endpoint group has no model object"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
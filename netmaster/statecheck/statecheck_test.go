@@ -0,0 +1,112 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statecheck
+
+import (
+	"testing"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/docknet"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/netplugin/utils/netutils"
+)
+
+func TestStateCheck(t *testing.T) {
+	fakeDriver := &state.FakeStateDriver{}
+	fakeDriver.Init(nil)
+	defer fakeDriver.Deinit()
+
+	// network with its gateway, one endpoint and a leaked address
+	nwCfg := &mastercfg.CfgNetworkState{
+		Tenant:      "tenant1",
+		NetworkName: "net1",
+		NwType:      "data",
+		SubnetIP:    "10.1.1.0",
+		SubnetLen:   24,
+		Gateway:     "10.1.1.254",
+		IPAddrRange: "10.1.1.0-10.1.1.255",
+		EpAddrCount: 2,
+		EpCount:     2,
+	}
+	nwCfg.ID = "net1.tenant1"
+	nwCfg.StateDriver = fakeDriver
+	netutils.InitSubnetBitset(&nwCfg.IPAllocMap, nwCfg.SubnetLen)
+	nwCfg.IPAllocMap.Set(254)
+	nwCfg.IPAllocMap.Set(1)
+	nwCfg.IPAllocMap.Set(5)
+	if err := nwCfg.Write(); err != nil {
+		t.Fatalf("error writing network state: %v", err)
+	}
+
+	eps := []*mastercfg.CfgEndpointState{
+		{CommonState: core.CommonState{ID: "net1.tenant1-ep1"}, NetID: "net1.tenant1", IPAddress: "10.1.1.1"},
+		{CommonState: core.CommonState{ID: "net2.tenant1-ep2"}, NetID: "net2.tenant1", IPAddress: "10.1.2.1"},
+	}
+	for _, ep := range eps {
+		ep.StateDriver = fakeDriver
+		if err := ep.Write(); err != nil {
+			t.Fatalf("error writing endpoint state: %v", err)
+		}
+	}
+
+	dnetCfg := &docknet.DnetOperState{
+		TenantName:  "tenant1",
+		NetworkName: "net2",
+		DocknetUUID: "uuid2",
+	}
+	dnetCfg.ID = "tenant1.net2"
+	dnetCfg.StateDriver = fakeDriver
+	if err := dnetCfg.Write(); err != nil {
+		t.Fatalf("error writing docknet state: %v", err)
+	}
+
+	expected := []string{
+		endpointKeyPrefix + "net2.tenant1-ep2",
+		networkKeyPrefix + "net1.tenant1",
+		networkKeyPrefix + "net1.tenant1",
+		docknetKeyPrefix + "tenant1.net2",
+	}
+	for _, repair := range []bool{false, true} {
+		incs, err := Run(fakeDriver, nil, repair)
+		if err != nil {
+			t.Fatalf("error checking state: %v", err)
+		}
+		if len(incs) != len(expected) {
+			t.Fatalf("unexpected inconsistencies: %v", incs)
+		}
+		for i, inc := range incs {
+			if inc.Key != expected[i] || !inc.Repairable || inc.Repaired != repair {
+				t.Fatalf("unexpected inconsistency %d: %v, expected key %s", i, inc, expected[i])
+			}
+		}
+	}
+
+	incs, err := Run(fakeDriver, nil, false)
+	if err != nil {
+		t.Fatalf("error checking state: %v", err)
+	}
+	if len(incs) != 0 {
+		t.Fatalf("inconsistencies left after the repair: %v", incs)
+	}
+
+	if err := nwCfg.Read("net1.tenant1"); err != nil {
+		t.Fatalf("error reading network state: %v", err)
+	}
+	if nwCfg.EpCount != 1 || nwCfg.EpAddrCount != 1 || nwCfg.IPAllocMap.Test(5) {
+		t.Fatalf("network state not repaired: %+v", nwCfg)
+	}
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package agent
 
 import (
+	"encoding/json"
 	"net"
 	"net/http"
 	"time"
@@ -27,14 +28,19 @@ import (
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/cluster"
 	"github.com/contiv/netplugin/netplugin/plugin"
+	"github.com/contiv/netplugin/utils/metrics"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 )
 
+// policyStatsInterval is how often the policy rule counters are published
+const policyStatsInterval = 30 * time.Second
+
 // Agent holds the netplugin agent state
 type Agent struct {
 	netPlugin    *plugin.NetPlugin // driver plugin
@@ -47,7 +53,7 @@ func NewAgent(pluginConfig *plugin.Config) *Agent {
 	netPlugin := &plugin.NetPlugin{}
 
 	// init cluster state
-	err := cluster.Init(opts.DbURL)
+	err := cluster.Init(&opts)
 	if err != nil {
 		log.Fatalf("Error initializing cluster. Err: %v", err)
 	}
@@ -164,6 +170,17 @@ func (ag *Agent) ProcessCurrentState() error {
 		}
 	}
 
+	readRules := &mastercfg.CfgPolicyRule{}
+	readRules.StateDriver = ag.netPlugin.StateDriver
+	ruleCfgs, err := readRules.ReadAll()
+	if err == nil {
+		for idx, ruleCfg := range ruleCfgs {
+			rule := ruleCfg.(*mastercfg.CfgPolicyRule)
+			log.Debugf("read policy rule[%d] %s, populating state \n", idx, rule.RuleId)
+			processPolicyRuleState(ag.netPlugin, opts, rule.RuleId, false)
+		}
+	}
+
 	return nil
 }
 
@@ -180,9 +197,33 @@ func (ag *Agent) PostInit() error {
 	// start service REST requests
 	ag.serveRequests()
 
+	// publish the policy rule counters for netmaster to aggregate
+	go publishPolicyStats(ag.netPlugin, opts.HostLabel)
+
 	return nil
 }
 
+// publishPolicyStats periodically writes the policy rule counters of this
+// node to the state store
+func publishPolicyStats(netPlugin *plugin.NetPlugin, hostLabel string) {
+	for {
+		time.Sleep(policyStatsInterval)
+
+		ruleStats, err := netPlugin.GetPolicyRuleStats()
+		if err != nil {
+			log.Debugf("Error fetching policy rule stats. Err: %v", err)
+			continue
+		}
+
+		statsCfg := &mastercfg.PolicyStatsState{Rules: ruleStats}
+		statsCfg.ID = hostLabel
+		statsCfg.StateDriver = netPlugin.StateDriver
+		if err := statsCfg.Write(); err != nil {
+			log.Errorf("Error writing policy rule stats. Err: %v", err)
+		}
+	}
+}
+
 func (ag *Agent) monitorDockerEvents(de chan error) {
 	// watch for docker events
 	docker, err := dockerclient.NewClient("unix:///var/run/docker.sock", "", nil, nil)
@@ -220,6 +261,8 @@ func (ag *Agent) HandleEvents() error {
 
 	go handlePolicyRuleEvents(ag.netPlugin, opts, recvErr)
 
+	go handleNodeMaintenanceEvents(ag.netPlugin, opts, recvErr)
+
 	if ag.pluginConfig.Instance.PluginMode == "docker" ||
 		ag.pluginConfig.Instance.PluginMode == "swarm-mode" {
 		go ag.monitorDockerEvents(recvErr)
@@ -283,6 +326,26 @@ func (ag *Agent) serveRequests() {
 		w.Write(ns)
 	})
 
+	// prometheus metrics, with the datapath counters read on each scrape
+	prometheus.MustRegister(&datapathCollector{netPlugin: ag.netPlugin})
+	s.Handle("/metrics", metrics.Handler())
+
+	s.HandleFunc("/policylogs", func(w http.ResponseWriter, r *http.Request) {
+		records, err := ag.netPlugin.GetPolicyLogs()
+		if err != nil {
+			log.Errorf("Error fetching policy logs. Err: %v", err)
+			http.Error(w, "Error fetching policy logs", http.StatusInternalServerError)
+			return
+		}
+		content, err := json.Marshal(records)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+	})
+
 	// Create HTTP server and listener
 	server := &http.Server{Handler: router}
 	listener, err := net.Listen("tcp", listenURL)
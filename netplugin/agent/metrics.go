@@ -0,0 +1,123 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/netplugin/plugin"
+	"github.com/contiv/netplugin/utils/metrics"
+	"github.com/contiv/ofnet"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// datapathCollector reports the nameserver and service flow counters of the
+// driver when the metrics are scraped
+type datapathCollector struct {
+	netPlugin *plugin.NetPlugin
+}
+
+// svcKey identifies a service in the service flow metrics
+type svcKey struct {
+	vrf       string
+	serviceIP string
+	protocol  string
+	port      string
+}
+
+// Describe sends the descriptors of the datapath metrics
+func (c *datapathCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.NameserverEvents
+	ch <- metrics.ServicePackets
+	ch <- metrics.ServiceBytes
+}
+
+// Collect reads the counters from the driver
+func (c *datapathCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectNameserver(ch)
+	c.collectServices(ch)
+}
+
+func (c *datapathCollector) collectNameserver(ch chan<- prometheus.Metric) {
+	ns, err := c.netPlugin.NetworkDriver.InspectNameserver()
+	if err != nil {
+		log.Errorf("Error fetching nameserver state. Err: %v", err)
+		return
+	}
+	if len(ns) == 0 {
+		// no nameserver in this driver
+		return
+	}
+
+	nsState := struct {
+		Stats map[string]map[string]uint64 `json:"stats"`
+	}{}
+	if err := json.Unmarshal(ns, &nsState); err != nil {
+		log.Errorf("Error decoding nameserver state. Err: %v", err)
+		return
+	}
+
+	for tenant, stats := range nsState.Stats {
+		for event, count := range stats {
+			ch <- prometheus.MustNewConstMetric(metrics.NameserverEvents, prometheus.CounterValue,
+				float64(count), tenant, event)
+		}
+	}
+}
+
+func (c *datapathCollector) collectServices(ch chan<- prometheus.Metric) {
+	stats, err := c.netPlugin.GetEndpointStats()
+	if err != nil {
+		log.Errorf("Error fetching stats from driver. Err: %v", err)
+		return
+	}
+	if len(stats) == 0 {
+		return
+	}
+
+	epStats := map[string]ofnet.OfnetEndpointStats{}
+	if err := json.Unmarshal(stats, &epStats); err != nil {
+		log.Errorf("Error decoding endpoint stats. Err: %v", err)
+		return
+	}
+
+	// sum the flows of the local endpoints by service
+	svcStats := map[svcKey]ofnet.OfnetDatapathStats{}
+	for _, ep := range epStats {
+		for _, svc := range ep.SvcStats {
+			key := svcKey{svc.ServiceVRF, svc.ServiceIP, svc.Protocol, svc.SvcPort}
+			sum := svcStats[key]
+			sum.PacketsIn += svc.SvcStats.PacketsIn
+			sum.PacketsOut += svc.SvcStats.PacketsOut
+			sum.BytesIn += svc.SvcStats.BytesIn
+			sum.BytesOut += svc.SvcStats.BytesOut
+			svcStats[key] = sum
+		}
+	}
+
+	for key, sum := range svcStats {
+		labels := []string{key.vrf, key.serviceIP, key.protocol, key.port}
+		ch <- prometheus.MustNewConstMetric(metrics.ServicePackets, prometheus.CounterValue,
+			float64(sum.PacketsIn), append(labels, "in")...)
+		ch <- prometheus.MustNewConstMetric(metrics.ServicePackets, prometheus.CounterValue,
+			float64(sum.PacketsOut), append(labels, "out")...)
+		ch <- prometheus.MustNewConstMetric(metrics.ServiceBytes, prometheus.CounterValue,
+			float64(sum.BytesIn), append(labels, "in")...)
+		ch <- prometheus.MustNewConstMetric(metrics.ServiceBytes, prometheus.CounterValue,
+			float64(sum.BytesOut), append(labels, "out")...)
+	}
+}
@@ -236,6 +236,68 @@ func processNetEvent(netPlugin *plugin.NetPlugin, nwCfg *mastercfg.CfgNetworkSta
 	return
 }
 
+// processNetPktTagUpdate moves a network to the pkt tags of a migration and
+// publishes the tags programmed on this node, or the failure, to netmaster
+func processNetPktTagUpdate(netPlugin *plugin.NetPlugin, prevCfg, nwCfg *mastercfg.CfgNetworkState,
+	opts core.InstanceInfo) {
+	log.Infof("Network %s pkt tags changed from %d/%d to %d/%d", nwCfg.ID,
+		prevCfg.PktTag, prevCfg.ExtPktTag, nwCfg.PktTag, nwCfg.ExtPktTag)
+
+	hostState := &mastercfg.PktTagHostState{
+		NetworkID: nwCfg.ID,
+		Hostname:  opts.HostLabel,
+		PktTag:    nwCfg.PktTag,
+		ExtPktTag: nwCfg.ExtPktTag,
+	}
+	hostState.ID = mastercfg.PktTagHostStateID(nwCfg.ID, opts.HostLabel)
+	hostState.StateDriver = netPlugin.StateDriver
+
+	err := netPlugin.UpdateNetworkPktTag(nwCfg.ID, prevCfg.PktTag, prevCfg.ExtPktTag)
+	if err != nil {
+		log.Errorf("Error moving network %s to pkt tags %d/%d. Err: %v", nwCfg.ID,
+			nwCfg.PktTag, nwCfg.ExtPktTag, err)
+		hostState.Error = err.Error()
+	}
+
+	if err := hostState.Write(); err != nil {
+		log.Errorf("Error publishing pkt tags of network %s. Err: %v", nwCfg.ID, err)
+	}
+}
+
+// processNetUpdateEvent applies in-place network updates. Most modify events
+// only carry allocation changes, the pkt tags of a migration and the host
+// routes of vxlan networks are the only things programmed here that depend
+// on the updated parameters.
+func processNetUpdateEvent(netPlugin *plugin.NetPlugin, prevCfg, nwCfg *mastercfg.CfgNetworkState,
+	opts core.InstanceInfo) {
+	if prevCfg.PktTag != nwCfg.PktTag || prevCfg.ExtPktTag != nwCfg.ExtPktTag {
+		processNetPktTagUpdate(netPlugin, prevCfg, nwCfg, opts)
+	}
+
+	if prevCfg.SubnetIP == nwCfg.SubnetIP && prevCfg.SubnetLen == nwCfg.SubnetLen &&
+		prevCfg.Gateway == nwCfg.Gateway && prevCfg.IPv6Subnet == nwCfg.IPv6Subnet &&
+		prevCfg.IPv6Gateway == nwCfg.IPv6Gateway && prevCfg.NetworkTag == nwCfg.NetworkTag {
+		log.Debugf("Received a modify event on network %q, no config change", nwCfg.ID)
+		return
+	}
+
+	log.Infof("Network %s updated: subnet %s/%d gateway %s ipv6 subnet %s/%d gateway %s tag %s",
+		nwCfg.ID, nwCfg.SubnetIP, nwCfg.SubnetLen, nwCfg.Gateway, nwCfg.IPv6Subnet,
+		nwCfg.IPv6SubnetLen, nwCfg.IPv6Gateway, nwCfg.NetworkTag)
+
+	if prevCfg.SubnetLen == nwCfg.SubnetLen || nwCfg.NwType == "infra" ||
+		nwCfg.PktTagType != "vxlan" {
+		return
+	}
+
+	gwIP, err := getVxGWIP(netPlugin, nwCfg.Tenant, opts.HostLabel)
+	if err != nil {
+		return
+	}
+	netutils.DelIPRoute(fmt.Sprintf("%s/%d", prevCfg.SubnetIP, prevCfg.SubnetLen), gwIP)
+	netutils.AddIPRoute(fmt.Sprintf("%s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen), gwIP)
+}
+
 // processEpState restores endpoint state
 func processEpState(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, epID string) error {
 	// take a lock in netplugin to ensure we are programming one event at a time.
@@ -327,6 +389,28 @@ func processBgpEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, hostID
 	return err
 }
 
+// processNodeMaintenanceEvent removes the VTEP of a decommissioned peer
+func processNodeMaintenanceEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo,
+	maintCfg *mastercfg.NodeMaintenanceState, isDelete bool) error {
+	if isDelete || !maintCfg.Decommissioned || maintCfg.VtepIP == "" ||
+		maintCfg.Hostname == opts.HostLabel {
+		return nil
+	}
+
+	err := netPlugin.DeletePeerHost(core.ServiceInfo{
+		HostAddr: maintCfg.VtepIP,
+		Port:     opts.VxlanUDPPort,
+	})
+	if err != nil {
+		log.Errorf("Error removing VTEP %s of decommissioned node %s. Err: %v",
+			maintCfg.VtepIP, maintCfg.Hostname, err)
+	} else {
+		log.Infof("Removed VTEP %s of decommissioned node %s", maintCfg.VtepIP, maintCfg.Hostname)
+	}
+
+	return err
+}
+
 func processEpgEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, ID string, isDelete bool) error {
 	log.Infof("Received processEpgEvent")
 	var err error
@@ -499,15 +583,7 @@ func processSvcProviderUpdEvent(netPlugin *plugin.NetPlugin, svcProvider *master
 
 // processPolicyRuleState updates policy rule state
 func processPolicyRuleState(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, ruleID string, isDelete bool) error {
-	// read policy config
-	ruleCfg := &mastercfg.CfgPolicyRule{}
-	ruleCfg.StateDriver = netPlugin.StateDriver
-
-	err := ruleCfg.Read(ruleID)
-	if err != nil {
-		log.Errorf("Failed to read config for policy rule '%s' \n", ruleID)
-		return err
-	}
+	var err error
 	if isDelete {
 		// Delete endpoint
 		err = netPlugin.DelPolicyRule(ruleID)
@@ -568,9 +644,9 @@ func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps
 				processGlobalConfigUpdEvent(netPlugin, opts, prevCfg, gCfg)
 			}
 
-			// Ignore modify event on network state
 			if nwCfg, ok := currentState.(*mastercfg.CfgNetworkState); ok {
-				log.Debugf("Received a modify event on network %q, ignoring it", nwCfg.ID)
+				prevCfg := rsp.Prev.(*mastercfg.CfgNetworkState)
+				processNetUpdateEvent(netPlugin, prevCfg, nwCfg, opts)
 				continue
 			}
 
@@ -617,6 +693,10 @@ func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps
 			log.Infof("Received %q for PolicyRule: %q", eventStr, ruleCfg.RuleId)
 			processPolicyRuleState(netPlugin, opts, ruleCfg.RuleId, isDelete)
 		}
+		if maintCfg, ok := currentState.(*mastercfg.NodeMaintenanceState); ok {
+			log.Infof("Received %q for node maintenance: %q", eventStr, maintCfg.Hostname)
+			processNodeMaintenanceEvent(netPlugin, opts, maintCfg, isDelete)
+		}
 	}
 }
 
@@ -687,6 +767,15 @@ func handleGlobalCfgEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo,
 	log.Errorf("Error from handleGlobalCfgEvents")
 }
 
+func handleNodeMaintenanceEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, retErr chan error) {
+	rsps := make(chan core.WatchState)
+	go processStateEvent(netPlugin, opts, rsps)
+	cfg := mastercfg.NodeMaintenanceState{}
+	cfg.StateDriver = netPlugin.StateDriver
+	retErr <- cfg.WatchAll(rsps)
+	log.Errorf("Error from handleNodeMaintenanceEvents")
+}
+
 func handlePolicyRuleEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, retErr chan error) {
 	rsps := make(chan core.WatchState)
 	go processStateEvent(netPlugin, opts, rsps)
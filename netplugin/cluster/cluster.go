@@ -26,8 +26,10 @@ import (
 	"time"
 
 	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/plugin"
 	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/netplugin/version"
 	"github.com/contiv/objdb"
 
 	log "github.com/Sirupsen/logrus"
@@ -248,6 +250,7 @@ func registerService(objClient objdb.API, ctrlIP, vtepIP, hostname string, vxlan
 	// netplugin service info
 	srvInfo := objdb.ServiceInfo{
 		ServiceName: "netplugin",
+		Version:     version.Get().Version,
 		TTL:         10,
 		HostAddr:    ctrlIP,
 		Port:        netpluginRPCPort1,
@@ -263,6 +266,7 @@ func registerService(objClient objdb.API, ctrlIP, vtepIP, hostname string, vxlan
 
 	srvInfo = objdb.ServiceInfo{
 		ServiceName: "netplugin",
+		Version:     version.Get().Version,
 		TTL:         10,
 		HostAddr:    ctrlIP,
 		Port:        netpluginRPCPort2,
@@ -295,6 +299,43 @@ func registerService(objClient objdb.API, ctrlIP, vtepIP, hostname string, vxlan
 	return nil
 }
 
+// publishNodeState periodically writes the inventory of this node to the
+// state store, netmaster derives the node health from the heartbeats
+func publishNodeState(netplugin *plugin.NetPlugin, ctrlIP, vtepIP, hostname string) {
+	instInfo := netplugin.PluginConfig.Instance
+	for {
+		nodeState := &mastercfg.NodeState{
+			Hostname:      hostname,
+			CtrlIP:        ctrlIP,
+			VtepIP:        vtepIP,
+			Version:       version.Get().Version,
+			FwdMode:       instInfo.FwdMode,
+			PluginMode:    instInfo.PluginMode,
+			Uplinks:       instInfo.UplinkIntf,
+			NumEndpoints:  countLocalEndpoints(netplugin),
+			LastHeartbeat: time.Now(),
+		}
+		nodeState.ID = hostname
+		nodeState.StateDriver = netplugin.StateDriver
+		if err := nodeState.Write(); err != nil {
+			log.Errorf("Error writing node state. Err: %v", err)
+		}
+
+		time.Sleep(mastercfg.NodeHeartbeatInterval)
+	}
+}
+
+// countLocalEndpoints returns the number of endpoints homed on this node
+func countLocalEndpoints(netplugin *plugin.NetPlugin) int {
+	count, err := netplugin.GetLocalEndpointCount()
+	if err != nil {
+		log.Debugf("Error getting local endpoint count. Err: %v", err)
+		return 0
+	}
+
+	return count
+}
+
 // Main loop to discover peer hosts and masters
 func peerDiscoveryLoop(netplugin *plugin.NetPlugin, objClient objdb.API, ctrlIP, vtepIP string) {
 	// Create channels for watch thread
@@ -396,11 +437,16 @@ func GetLocalAddr() (string, error) {
 }
 
 // Init initializes the cluster module
-func Init(storeURL string) error {
+func Init(instInfo *core.InstanceInfo) error {
 	var err error
 
 	// Create an objdb client
-	ObjdbClient, err = objdb.NewClient(storeURL)
+	ObjdbClient, err = objdb.NewClientWithConfig(&objdb.ClientConfig{
+		DbURL:  instInfo.DbURL,
+		CACert: instInfo.DbCACert,
+		Cert:   instInfo.DbCert,
+		Key:    instInfo.DbKey,
+	})
 
 	return err
 }
@@ -410,6 +456,9 @@ func RunLoop(netplugin *plugin.NetPlugin, ctrlIP, vtepIP, hostname string) error
 	// Register ourselves
 	err := registerService(ObjdbClient, ctrlIP, vtepIP, hostname, netplugin.PluginConfig.Instance.VxlanUDPPort)
 
+	// Publish our inventory
+	go publishNodeState(netplugin, ctrlIP, vtepIP, hostname)
+
 	// Start peer discovery loop
 	go peerDiscoveryLoop(netplugin, ObjdbClient, ctrlIP, vtepIP)
 
@@ -24,6 +24,7 @@ import (
 	cmap "github.com/streamrail/concurrent-map"
 	"hash/fnv"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +38,20 @@ const maxNameRecordsInResp = 10
 
 const nameServerMaxTTL = 120
 
+// timeout of the upstream queries for names matched by fqdn policies
+const fqdnUpstreamTimeout = 2 * time.Second
+
+// upstream queries in progress, the queries over it are forwarded to the
+// container name servers
+const fqdnMaxPending = 64
+
+// resolv.conf listing the upstream name servers of the host
+const fqdnResolvConf = "/etc/resolv.conf"
+
+// nameServerDomain is the search domain of the name records, names can be
+// looked up as <name>, <name>.<tenant>.contiv or <name>.contiv
+const nameServerDomain = "contiv"
+
 type tenantBucket struct {
 	sync.RWMutex
 	tenantTables map[string]*dnsTables
@@ -57,16 +72,36 @@ type NetpluginNameServer struct {
 	bucketSize  uint
 	buckets     []tenantBucket
 	k8sService  cmap.ConcurrentMap // for non-multi tenant LB service
+	fqdnHandler FqdnHandler        // learns the addresses of fqdn policies
+	fqdnPending chan bool          // upstream queries in progress
+	upstreams   []string           // upstream name servers, host:port
 	stats       struct {
 		sync.RWMutex
 		tenantStats map[string]map[string]uint64
 	}
 }
 
+// FqdnHandler learns the addresses of the names matched by policy rules on a
+// domain name
+type FqdnHandler interface {
+	// FqdnMatch checks if a name is matched by a policy rule of the tenant
+	FqdnMatch(tenant string, name string) bool
+	// FqdnResolved is called with the ipv4 addresses a name resolved to
+	FqdnResolved(tenant string, name string, ips []net.IP, ttl uint32)
+}
+
 // DNS name record, ipv4 & ipv6 address
 type nameRecord struct {
 	v4Record net.IP
 	v6Record net.IP
+	ports    []svcPort // service ports for SRV records
+	ptrName  string    // name returned in PTR records
+}
+
+// service port, svc-port & protocol
+type svcPort struct {
+	port     uint16
+	protocol string
 }
 
 // dns records per tenant
@@ -75,6 +110,121 @@ type dnsTables struct {
 	endpointTbl map[string]nameRecord      // endpoint-id records
 	epgTbl      map[string]map[string]bool // endpoint group records
 	nameTbl     map[string]map[string]bool // container-name records
+	ptrTbl      map[string]string          // ip address to name records
+}
+
+// fqdn returns the fully qualified name of a record in the tenant domain
+func fqdn(tenant string, name string) string {
+	return fmt.Sprintf("%s.%s.%s.", name, tenant, nameServerDomain)
+}
+
+// trimSearchDomain removes the tenant or contiv search domain from a name
+func trimSearchDomain(tenant string, name string) string {
+	if n := strings.TrimSuffix(name, "."+tenant+"."+nameServerDomain); n != name {
+		return n
+	}
+	return strings.TrimSuffix(name, "."+nameServerDomain)
+}
+
+// parseServicePorts parses svc-port:prov-port:protocol service ports
+func parseServicePorts(ports []string) []svcPort {
+	sp := []svcPort{}
+	for _, p := range ports {
+		f := strings.Split(p, ":")
+		if len(f) != 3 {
+			continue
+		}
+		port, err := strconv.ParseUint(f[0], 10, 16)
+		if err != nil {
+			continue
+		}
+		sp = append(sp, svcPort{port: uint16(port), protocol: strings.ToLower(f[2])})
+	}
+	return sp
+}
+
+// parseSrvName splits a SRV name, _<port>._<protocol>.<service> or
+// _<protocol>.<service>, port is empty when all ports are looked up. The
+// port is a number or the name of a well known service such as http.
+func parseSrvName(name string) (string, string, string, bool) {
+	l := strings.SplitN(name, ".", 3)
+	if len(l) == 3 && strings.HasPrefix(l[0], "_") && strings.HasPrefix(l[1], "_") {
+		return l[0][1:], strings.ToLower(l[1][1:]), l[2], true
+	}
+	if len(l) >= 2 && strings.HasPrefix(l[0], "_") {
+		return "", strings.ToLower(l[0][1:]), strings.Join(l[1:], "."), true
+	}
+	return "", "", "", false
+}
+
+// srvPort returns the port number of a SRV name port. Contiv service ports
+// are not named, so a named port is the standard port of the service, as
+// listed in /etc/services.
+func srvPort(port string, protocol string) (uint16, bool) {
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		return uint16(p), true
+	}
+	if p, err := net.LookupPort(protocol, port); err == nil {
+		return uint16(p), true
+	}
+	return 0, false
+}
+
+// reverseAddr returns the ip address of a in-addr.arpa or ip6.arpa name
+func reverseAddr(name string) net.IP {
+	name = strings.ToLower(name)
+	if n := strings.TrimSuffix(name, ".in-addr.arpa"); n != name {
+		l := strings.Split(n, ".")
+		if len(l) != net.IPv4len {
+			return nil
+		}
+		for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+			l[i], l[j] = l[j], l[i]
+		}
+		return net.ParseIP(strings.Join(l, "."))
+	}
+
+	if n := strings.TrimSuffix(name, ".ip6.arpa"); n != name {
+		l := strings.Split(n, ".")
+		if len(l) != 2*net.IPv6len {
+			return nil
+		}
+		addr := ""
+		for i := len(l) - 1; i >= 0; i-- {
+			if len(l[i]) != 1 {
+				return nil
+			}
+			addr += l[i]
+			if i%4 == 0 && i > 0 {
+				addr += ":"
+			}
+		}
+		return net.ParseIP(addr)
+	}
+	return nil
+}
+
+func (dt *dnsTables) addPtrRecord(record nameRecord) {
+	if dt.ptrTbl == nil {
+		dt.ptrTbl = make(map[string]string)
+	}
+	for _, ip := range []net.IP{record.v4Record, record.v6Record} {
+		if ip != nil && ip.IsUnspecified() != true {
+			dt.ptrTbl[ip.String()] = record.ptrName
+		}
+	}
+}
+
+func (dt *dnsTables) delPtrRecord(record nameRecord) {
+	for _, ip := range []net.IP{record.v4Record, record.v6Record} {
+		if ip == nil {
+			continue
+		}
+		// the address may have been taken over by another record
+		if name, ok := dt.ptrTbl[ip.String()]; ok && name == record.ptrName {
+			delete(dt.ptrTbl, ip.String())
+		}
+	}
 }
 
 func lookUpServiceV4Record(record nameRecord, name string) ([]dns.RR, int) {
@@ -203,8 +353,16 @@ func (ens *NetpluginNameServer) addService(s core.State) {
 	if tenantTables.svcTbl == nil {
 		tenantTables.svcTbl = make(map[string]nameRecord)
 	}
-	nr := nameRecord{v4Record: net.ParseIP(svc.IPAddress)}
+	if old, ok := tenantTables.svcTbl[svc.ServiceName]; ok {
+		tenantTables.delPtrRecord(old)
+	}
+	nr := nameRecord{
+		v4Record: net.ParseIP(svc.IPAddress),
+		ports:    parseServicePorts(svc.Ports),
+		ptrName:  fqdn(tenant, svc.ServiceName),
+	}
 	tenantTables.svcTbl[svc.ServiceName] = nr
+	tenantTables.addPtrRecord(nr)
 
 }
 
@@ -224,6 +382,9 @@ func (ens *NetpluginNameServer) delService(s core.State) {
 	defer tenMap.Unlock()
 	tenantTables, ok := tenMap.tenantTables[tenant]
 	if ok && tenantTables.svcTbl != nil {
+		if nr, ok := tenantTables.svcTbl[svc.ServiceName]; ok {
+			tenantTables.delPtrRecord(nr)
+		}
 		delete(tenantTables.svcTbl, svc.ServiceName)
 	}
 }
@@ -257,17 +418,25 @@ func (ens *NetpluginNameServer) addEndpoint(s core.State) {
 	epEntry := nameRecord{
 		v4Record: net.ParseIP(eps.IPAddress),
 		v6Record: net.ParseIP(eps.IPv6Address),
+		ptrName:  fqdn(tenant, eps.EndpointID),
 	}
 
+	containerName := eps.EPCommonName
+	if len(containerName) > 0 && containerName[:1] == "/" {
+		containerName = containerName[1:]
+	}
+	if len(containerName) > 0 {
+		epEntry.ptrName = fqdn(tenant, containerName)
+	}
+
+	if old, ok := tenantTables.endpointTbl[eps.EndpointID]; ok {
+		tenantTables.delPtrRecord(old)
+	}
 	tenantTables.endpointTbl[eps.EndpointID] = epEntry
+	tenantTables.addPtrRecord(epEntry)
 
 	//update name
-	if len(eps.EPCommonName) > 0 {
-		containerName := eps.EPCommonName
-		if eps.EPCommonName[:1] == "/" {
-			containerName = eps.EPCommonName[1:]
-		}
-
+	if len(containerName) > 0 {
 		if tenantTables.nameTbl == nil {
 			tenantTables.nameTbl = make(map[string]map[string]bool)
 		}
@@ -336,6 +505,9 @@ func (ens *NetpluginNameServer) delEndpoint(s core.State) {
 			}
 		}
 		if tenantTables.endpointTbl != nil {
+			if nr, ok := tenantTables.endpointTbl[eps.EndpointID]; ok {
+				tenantTables.delPtrRecord(nr)
+			}
 			delete(tenantTables.endpointTbl, eps.EndpointID)
 		}
 	}
@@ -370,9 +542,10 @@ func (ens *NetpluginNameServer) incTenantErrStats(tenant string, inName string)
 }
 
 func (ens *NetpluginNameServer) serveTypeA(tenant string, name string) ([]dns.RR, int) {
+	key := trimSearchDomain(tenant, name)
 
 	// check non-multi tenant services for k8s
-	if k8sSvc, svcOk := ens.k8sService.Get(name); svcOk {
+	if k8sSvc, svcOk := ens.k8sService.Get(key); svcOk {
 		if sr, nrOk := k8sSvc.(nameRecord); nrOk {
 			if rr, l := lookUpServiceV4Record(sr, name); l > 0 {
 				return rr, l
@@ -386,14 +559,14 @@ func (ens *NetpluginNameServer) serveTypeA(tenant string, name string) ([]dns.RR
 
 	if dh, ok := tenMap.tenantTables[tenant]; ok {
 		// service
-		if svc, ok := dh.svcTbl[name]; ok {
+		if svc, ok := dh.svcTbl[key]; ok {
 			if rr, l := lookUpServiceV4Record(svc, name); l > 0 {
 				return rr, l
 			}
 		}
 
 		// epg
-		if ep, ok := dh.epgTbl[name]; ok {
+		if ep, ok := dh.epgTbl[key]; ok {
 			if ep != nil {
 				if rr, l := dh.lookUpEndPointV4Record(ep, name); l > 0 {
 					return rr, l
@@ -402,7 +575,7 @@ func (ens *NetpluginNameServer) serveTypeA(tenant string, name string) ([]dns.RR
 		}
 
 		// name
-		if nm, ok := dh.nameTbl[name]; ok {
+		if nm, ok := dh.nameTbl[key]; ok {
 			if nm != nil {
 				if rr, l := dh.lookUpEndPointV4Record(nm, name); l > 0 {
 					return rr, l
@@ -415,20 +588,21 @@ func (ens *NetpluginNameServer) serveTypeA(tenant string, name string) ([]dns.RR
 }
 
 func (ens *NetpluginNameServer) serveTypeAAAA(tenant string, name string) ([]dns.RR, int) {
+	key := trimSearchDomain(tenant, name)
 	tenMap := ens.getBucket(tenant)
 	tenMap.RLock()
 	defer tenMap.RUnlock()
 
 	if dh, ok := tenMap.tenantTables[tenant]; ok {
 		// epg
-		if ep, ok := dh.epgTbl[name]; ok {
+		if ep, ok := dh.epgTbl[key]; ok {
 			if rr, l := dh.lookUpEndPointV6Record(ep, name); l > 0 {
 				return rr, l
 			}
 		}
 
 		// name
-		if nm, ok := dh.nameTbl[name]; ok {
+		if nm, ok := dh.nameTbl[key]; ok {
 			if rr, l := dh.lookUpEndPointV6Record(nm, name); l > 0 {
 				return rr, l
 			}
@@ -438,9 +612,85 @@ func (ens *NetpluginNameServer) serveTypeAAAA(tenant string, name string) ([]dns
 	return nil, 0
 }
 
+// serveTypeSRV returns the SRV records of a service and the address
+// records of their target
+func (ens *NetpluginNameServer) serveTypeSRV(tenant string, name string) ([]dns.RR, []dns.RR, int) {
+	port, protocol, svcName, ok := parseSrvName(trimSearchDomain(tenant, name))
+	if !ok {
+		return nil, nil, 0
+	}
+	portNum := uint16(0)
+	if len(port) > 0 {
+		if portNum, ok = srvPort(port, protocol); !ok {
+			return nil, nil, 0
+		}
+	}
+
+	tenMap := ens.getBucket(tenant)
+	tenMap.RLock()
+	defer tenMap.RUnlock()
+
+	dh, ok := tenMap.tenantTables[tenant]
+	if !ok {
+		return nil, nil, 0
+	}
+	svc, ok := dh.svcTbl[svcName]
+	if !ok {
+		return nil, nil, 0
+	}
+
+	target := fqdn(tenant, svcName)
+	rr := []dns.RR{}
+	for _, p := range svc.ports {
+		if p.protocol != protocol || (len(port) > 0 && p.port != portNum) {
+			continue
+		}
+		r := new(dns.SRV)
+		r.Port = p.port
+		r.Target = target
+		r.Hdr = dns.RR_Header{Name: name + ".", Rrtype: dns.TypeSRV,
+			Class: dns.ClassINET, Ttl: nameServerMaxTTL}
+		rr = append(rr, r)
+		if len(rr) >= maxNameRecordsInResp {
+			break
+		}
+	}
+	if len(rr) == 0 {
+		return nil, nil, 0
+	}
+
+	extra, _ := lookUpServiceV4Record(svc, strings.TrimSuffix(target, "."))
+	return rr, extra, len(rr)
+}
+
+// serveTypePTR returns the name of a service or endpoint address
+func (ens *NetpluginNameServer) serveTypePTR(tenant string, name string) ([]dns.RR, int) {
+	ip := reverseAddr(name)
+	if ip == nil {
+		return nil, 0
+	}
+
+	tenMap := ens.getBucket(tenant)
+	tenMap.RLock()
+	defer tenMap.RUnlock()
+
+	if dh, ok := tenMap.tenantTables[tenant]; ok {
+		if ptrName, ok := dh.ptrTbl[ip.String()]; ok {
+			r := new(dns.PTR)
+			r.Ptr = ptrName
+			r.Hdr = dns.RR_Header{Name: name + ".", Rrtype: dns.TypePTR,
+				Class: dns.ClassINET, Ttl: nameServerMaxTTL}
+			return []dns.RR{r}, 1
+		}
+	}
+
+	return nil, 0
+}
+
 func (ens *NetpluginNameServer) serveNameRecord(tenant string, r *dns.Msg) ([]byte, error) {
 
 	ansRR := []dns.RR{}
+	extraRR := []dns.RR{}
 	for _, q1 := range r.Question {
 		name := strings.TrimSuffix(q1.Name, ".")
 		dnsLog.Infof("lookup name-record: %s ", q1.String())
@@ -457,6 +707,17 @@ func (ens *NetpluginNameServer) serveNameRecord(tenant string, r *dns.Msg) ([]by
 				ansRR = append(ansRR, rr...)
 			}
 
+		case dns.TypeSRV:
+			if rr, extra, l := ens.serveTypeSRV(tenant, name); l > 0 {
+				ansRR = append(ansRR, rr...)
+				extraRR = append(extraRR, extra...)
+			}
+
+		case dns.TypePTR:
+			if rr, l := ens.serveTypePTR(tenant, name); l > 0 {
+				ansRR = append(ansRR, rr...)
+			}
+
 		case dns.TypeANY:
 
 			if rr, l := ens.serveTypeA(tenant, name); l > 0 {
@@ -474,6 +735,7 @@ func (ens *NetpluginNameServer) serveNameRecord(tenant string, r *dns.Msg) ([]by
 		m := &dns.Msg{}
 		m.SetReply(r)
 		m.Answer = ansRR
+		m.Extra = extraRR
 		m.Authoritative = true
 		m.RecursionAvailable = true
 		dnsLog.Infof("namerserver response: %s", m.String())
@@ -539,6 +801,12 @@ func (ens *NetpluginNameServer) inspectNameRecord() map[string]map[string]map[st
 			}
 			inspectMap[tk]["endpoints"] = endpointMap
 
+			ptrMap := make(map[string][]string)
+			for ip, name := range tv.ptrTbl {
+				ptrMap[ip] = append(ptrMap[ip], name)
+			}
+			inspectMap[tk]["reverseRecords"] = ptrMap
+
 		}
 	}
 
@@ -581,25 +849,28 @@ func (ens *NetpluginNameServer) InspectState() (interface{}, error) {
 	return &s, nil
 }
 
-// NsLookup returns name record,called from ofnet agent
-func (ens *NetpluginNameServer) NsLookup(nsq []byte, vrfPtr *string) ([]byte, error) {
-	tenant := *vrfPtr
+// unpackQuery unpacks a dns query
+func unpackQuery(nsq []byte) (*dns.Msg, error) {
 	req := new(dns.Msg)
-	err := req.Unpack(nsq)
-	if err != nil {
-		ens.incTenantStats(tenant, "invalidQuery")
+	if err := req.Unpack(nsq); err != nil {
 		return nil, err
 	}
 
 	// no fancy requests
-	if req.Response {
-		ens.incTenantStats(tenant, "invalidQuery")
+	if req.Response || req.IsTsig() != nil {
 		return nil, errors.New("")
 	}
 
-	if req.IsTsig() != nil {
+	return req, nil
+}
+
+// NsLookup returns name record,called from ofnet agent
+func (ens *NetpluginNameServer) NsLookup(nsq []byte, vrfPtr *string) ([]byte, error) {
+	tenant := *vrfPtr
+	req, err := unpackQuery(nsq)
+	if err != nil {
 		ens.incTenantStats(tenant, "invalidQuery")
-		return nil, errors.New("")
+		return nil, err
 	}
 
 	d, err := ens.serveNameRecord(tenant, req)
@@ -614,6 +885,122 @@ func (ens *NetpluginNameServer) NsLookup(nsq []byte, vrfPtr *string) ([]byte, er
 
 }
 
+// NsLookupDeferred resolves the names matched by fqdn policies off the
+// packet-in path, called from ofnet agent. It returns false when the query
+// is left to NsLookup, else the response is passed to reply once the upstream
+// name servers answered, nil if they didn't.
+func (ens *NetpluginNameServer) NsLookupDeferred(nsq []byte, vrfPtr *string, reply func([]byte)) bool {
+	tenant := *vrfPtr
+	req, err := unpackQuery(nsq)
+	if err != nil {
+		return false
+	}
+
+	name, ok := ens.fqdnQuery(tenant, req)
+	if !ok {
+		return false
+	}
+
+	// name records of the tenant take precedence
+	if _, err := ens.serveNameRecord(tenant, req); err == nil {
+		return false
+	}
+
+	select {
+	case ens.fqdnPending <- true:
+	default:
+		ens.incTenantErrStats(tenant, "fqdnPendingOverflow")
+		return false
+	}
+
+	go func() {
+		defer func() { <-ens.fqdnPending }()
+
+		resp, err := ens.resolveFqdn(tenant, name, req)
+		if err != nil {
+			reply(nil)
+			return
+		}
+		ens.incTenantStats(tenant, "fqdnResolved")
+		reply(resp)
+	}()
+
+	return true
+}
+
+// AddFqdnHandler registers the handler learning the addresses of the names
+// matched by policy rules
+func (ens *NetpluginNameServer) AddFqdnHandler(h FqdnHandler) {
+	ens.fqdnPending = make(chan bool, fqdnMaxPending)
+	ens.fqdnHandler = h
+}
+
+// fqdnQuery returns the name of a query matched by a policy rule
+func (ens *NetpluginNameServer) fqdnQuery(tenant string, req *dns.Msg) (string, bool) {
+	if ens.fqdnHandler == nil || len(ens.upstreams) == 0 || len(req.Question) != 1 {
+		return "", false
+	}
+
+	q := req.Question[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+	if (q.Qtype != dns.TypeA && q.Qtype != dns.TypeANY) || !ens.fqdnHandler.FqdnMatch(tenant, name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// resolveFqdn resolves a name matched by a policy rule with the upstream name
+// servers of the host. The addresses are learned before the reply is sent so
+// that the policy flows are in place when the first packet is sent.
+func (ens *NetpluginNameServer) resolveFqdn(tenant string, name string, req *dns.Msg) ([]byte, error) {
+	client := &dns.Client{Timeout: fqdnUpstreamTimeout}
+	var resp *dns.Msg
+	var err error
+	for _, upstream := range ens.upstreams {
+		resp, _, err = client.Exchange(req, upstream)
+		if err == nil {
+			break
+		}
+		dnsLog.Warnf("failed to resolve %s with %s: %s", name, upstream, err)
+	}
+	if err != nil {
+		ens.incTenantErrStats(tenant, "fqdnUpstreamFailure")
+		return nil, err
+	}
+
+	// answers may include the cname chain, keep the lowest ttl
+	ips := []net.IP{}
+	ttl := uint32(0)
+	for _, rr := range resp.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A)
+			if ttl == 0 || a.Hdr.Ttl < ttl {
+				ttl = a.Hdr.Ttl
+			}
+		}
+	}
+	if len(ips) > 0 {
+		ens.fqdnHandler.FqdnResolved(tenant, name, ips, ttl)
+	}
+
+	return resp.Pack()
+}
+
+// readUpstreams reads the upstream name servers of the host
+func (ens *NetpluginNameServer) readUpstreams(resolvConf string) {
+	cfg, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil {
+		dnsLog.Warnf("no upstream name servers, fqdn policies disabled: %s", err)
+		return
+	}
+
+	ens.upstreams = []string{}
+	for _, server := range cfg.Servers {
+		ens.upstreams = append(ens.upstreams, net.JoinHostPort(server, cfg.Port))
+	}
+}
+
 func (ens *NetpluginNameServer) readStateStore() {
 	svc := mastercfg.CfgServiceLBState{}
 	if st, err := ens.stateDriver.ReadAllState(ens.svcKeyPath, &svc, json.Unmarshal); err == nil {
@@ -709,6 +1096,7 @@ func (ens *NetpluginNameServer) Init(sd core.StateDriver) error {
 	}
 	ens.epKeyPath = mastercfg.StateConfigPath + "eps/"
 	ens.svcKeyPath = mastercfg.StateConfigPath + "serviceLB/"
+	ens.readUpstreams(fqdnResolvConf)
 	go ens.processStateEvent()
 	go ens.startSvcWatch()
 	go ens.startEndpointWatch()
@@ -18,6 +18,7 @@ import (
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/miekg/dns"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -871,6 +872,270 @@ func TestK8sMultiTenantServiceLookup(t *testing.T) {
 	assertOnTrue(t, s == true, fmt.Sprintf("service exist, %+v", ns.inspectNameRecord()))
 }
 
+func nsQuery(t *testing.T, ns *NetpluginNameServer, vrf string, name string, qtype uint16) *dns.Msg {
+	q1 := new(dns.Msg)
+	q1.SetQuestion(name, qtype)
+	dmsg, err := q1.Pack()
+	assertOnErr(t, err, "failed to pack query")
+	br, err1 := ns.NsLookup(dmsg, &vrf)
+	assertOnErr(t, err1, fmt.Sprintf("lookup of %s failed", name))
+	resp := new(dns.Msg)
+	err = resp.Unpack(br)
+	assertOnErr(t, err, "failed to unpack response")
+	assertOnTrue(t, resp.Response != true, fmt.Sprintf("not a valid resp %+v", resp))
+	return resp
+}
+
+func TestSRVLookup(t *testing.T) {
+	ns := new(NetpluginNameServer)
+	ds := new(dummyState)
+	err := ns.Init(ds)
+	assertOnErr(t, err, "namespace init")
+
+	vrf := "tenant1"
+	svc := mastercfg.CfgServiceLBState{
+		ServiceName: "web",
+		IPAddress:   "10.36.28.100",
+		Tenant:      vrf,
+		Network:     "net1",
+		Ports:       []string{"80:8080:TCP", "443:8443:TCP", "53:53:UDP"},
+	}
+	ns.addService(&svc)
+
+	resp := nsQuery(t, ns, vrf, "_tcp.web.", dns.TypeSRV)
+	assertOnTrue(t, len(resp.Answer) != 2, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+	ports := map[uint16]bool{}
+	for _, rr := range resp.Answer {
+		srv, ok := rr.(*dns.SRV)
+		assertOnTrue(t, ok != true, fmt.Sprintf("expected SRV record, %+v", resp.Answer))
+		assertOnTrue(t, srv.Target != "web.tenant1.contiv.", fmt.Sprintf("invalid target %+v", srv))
+		assertOnTrue(t, srv.Hdr.Name != "_tcp.web.", fmt.Sprintf("not a valid name: %+v", srv.Hdr))
+		assertOnTrue(t, srv.Hdr.Ttl != nameServerMaxTTL, fmt.Sprintf("not a valid ttl: %+v", srv.Hdr))
+		ports[srv.Port] = true
+	}
+	assertOnTrue(t, !ports[80] || !ports[443], fmt.Sprintf("invalid ports %+v", resp.Answer))
+	assertOnTrue(t, len(resp.Extra) != 1, fmt.Sprintf("not a valid extra %+v", resp.Extra))
+	a1, ok := resp.Extra[0].(*dns.A)
+	assertOnTrue(t, ok != true, fmt.Sprintf("expected A record, %+v", resp.Extra))
+	assertOnTrue(t, a1.A.String() != svc.IPAddress, fmt.Sprintf("invalid ip address, %+v", a1.A))
+	assertOnTrue(t, a1.Hdr.Name != "web.tenant1.contiv.", fmt.Sprintf("not a valid name: %+v", a1.Hdr))
+
+	resp = nsQuery(t, ns, vrf, "_53._udp.web.tenant1.contiv.", dns.TypeSRV)
+	assertOnTrue(t, len(resp.Answer) != 1, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+	srv := resp.Answer[0].(*dns.SRV)
+	assertOnTrue(t, srv.Port != 53, fmt.Sprintf("invalid port %+v", srv))
+
+	// named ports are the standard ports of the services
+	for _, q := range []struct {
+		name string
+		port uint16
+	}{
+		{"_http._tcp.web.", 80},
+		{"_https._tcp.web.tenant1.contiv.", 443},
+		{"_domain._udp.web.", 53},
+	} {
+		resp = nsQuery(t, ns, vrf, q.name, dns.TypeSRV)
+		assertOnTrue(t, len(resp.Answer) != 1, fmt.Sprintf("not a valid answer for %s %+v", q.name, resp.Answer))
+		srv = resp.Answer[0].(*dns.SRV)
+		assertOnTrue(t, srv.Port != q.port, fmt.Sprintf("invalid port for %s %+v", q.name, srv))
+	}
+
+	for _, name := range []string{"_8080._tcp.web.", "_tcp.db.", "web.", "_ssh._tcp.web.", "_nosuchsvc._tcp.web."} {
+		q1 := new(dns.Msg)
+		q1.SetQuestion(name, dns.TypeSRV)
+		dmsg, err := q1.Pack()
+		assertOnErr(t, err, "failed to pack query")
+		_, err = ns.NsLookup(dmsg, &vrf)
+		assertOnTrue(t, err == nil, fmt.Sprintf("found SRV record for %s", name))
+	}
+
+	// other tenants don't see the service
+	q1 := new(dns.Msg)
+	q1.SetQuestion("_tcp.web.", dns.TypeSRV)
+	dmsg, err := q1.Pack()
+	assertOnErr(t, err, "failed to pack query")
+	otherVrf := "tenant2"
+	_, err = ns.NsLookup(dmsg, &otherVrf)
+	assertOnTrue(t, err == nil, "found SRV record in another tenant")
+	ns.delService(&svc)
+}
+
+func TestPTRLookup(t *testing.T) {
+	ns := new(NetpluginNameServer)
+	ds := new(dummyState)
+	err := ns.Init(ds)
+	assertOnErr(t, err, "namespace init")
+
+	vrf := "tenant1"
+	nw := "net1"
+	endPointEvent("add", ns, vrf, nw, true, "epg1", 2)
+	svc := mastercfg.CfgServiceLBState{
+		ServiceName: "web",
+		IPAddress:   "10.36.28.100",
+		Tenant:      vrf,
+	}
+	ns.addService(&svc)
+
+	ptrs := map[string]string{
+		"1.28.36.10.in-addr.arpa.":   "testendpoint-1.tenant1.contiv.",
+		"2.28.36.10.in-addr.arpa.":   "testendpoint-2.tenant1.contiv.",
+		"100.28.36.10.in-addr.arpa.": "web.tenant1.contiv.",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1.0.0.2.0.0.0.0.0.6.8.4.1.0.0.2.ip6.arpa.": "testendpoint-1.tenant1.contiv.",
+	}
+	for name, ptrName := range ptrs {
+		resp := nsQuery(t, ns, vrf, name, dns.TypePTR)
+		assertOnTrue(t, len(resp.Answer) != 1, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+		p1, ok := resp.Answer[0].(*dns.PTR)
+		assertOnTrue(t, ok != true, fmt.Sprintf("expected PTR record, %+v", resp.Answer))
+		assertOnTrue(t, p1.Ptr != ptrName, fmt.Sprintf("invalid ptr %+v", p1))
+		assertOnTrue(t, p1.Hdr.Name != name, fmt.Sprintf("not a valid name: %+v", p1.Hdr))
+		assertOnTrue(t, p1.Hdr.Ttl != nameServerMaxTTL, fmt.Sprintf("not a valid ttl: %+v", p1.Hdr))
+	}
+
+	endPointEvent("del", ns, vrf, nw, true, "epg1", 2)
+	ns.delService(&svc)
+	for name := range ptrs {
+		q1 := new(dns.Msg)
+		q1.SetQuestion(name, dns.TypePTR)
+		dmsg, err := q1.Pack()
+		assertOnErr(t, err, "failed to pack query")
+		_, err = ns.NsLookup(dmsg, &vrf)
+		assertOnTrue(t, err == nil, fmt.Sprintf("found PTR record for %s", name))
+	}
+}
+
+func TestSearchDomainLookup(t *testing.T) {
+	ns := new(NetpluginNameServer)
+	ds := new(dummyState)
+	err := ns.Init(ds)
+	assertOnErr(t, err, "namespace init")
+
+	vrf := "tenant1"
+	nw := "net1"
+	serviceEvent("add", ns, vrf, nw, 1)
+	endPointEvent("add", ns, vrf, nw, true, "epg1", 1)
+
+	names := map[string]string{
+		"testservice-1.tenant1.contiv.":  "10.36.28.1",
+		"testservice-1.contiv.":          "10.36.28.1",
+		"testendpoint-1.tenant1.contiv.": "10.36.28.1",
+		"epg1.tenant1.contiv.":           "10.36.28.1",
+	}
+	for name, IPAddr := range names {
+		resp := nsQuery(t, ns, vrf, name, dns.TypeA)
+		assertOnTrue(t, len(resp.Answer) != 1, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+		a1, ok := resp.Answer[0].(*dns.A)
+		assertOnTrue(t, ok != true, fmt.Sprintf("expected A record, %+v", resp.Answer))
+		assertOnTrue(t, a1.A.String() != IPAddr, fmt.Sprintf("invalid ip address, %+v", a1.A))
+		assertOnTrue(t, a1.Hdr.Name != name, fmt.Sprintf("not a valid name: %+v", a1.Hdr))
+	}
+
+	resp := nsQuery(t, ns, vrf, "epg1.tenant1.contiv.", dns.TypeAAAA)
+	assertOnTrue(t, len(resp.Answer) != 1, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+
+	// the domain of another tenant is not searched
+	q1 := new(dns.Msg)
+	q1.SetQuestion("testservice-1.tenant2.contiv.", dns.TypeA)
+	dmsg, err := q1.Pack()
+	assertOnErr(t, err, "failed to pack query")
+	_, err = ns.NsLookup(dmsg, &vrf)
+	assertOnTrue(t, err == nil, "found record in another tenant domain")
+
+	serviceEvent("del", ns, vrf, nw, 1)
+	endPointEvent("del", ns, vrf, nw, true, "epg1", 1)
+}
+
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
+
+type fqdnHandlerStub struct {
+	tenant string
+	name   string
+	ips    []net.IP
+	ttl    uint32
+}
+
+func (h *fqdnHandlerStub) FqdnMatch(tenant string, name string) bool {
+	return tenant == "tenant1" && name == "api.example.com"
+}
+
+func (h *fqdnHandlerStub) FqdnResolved(tenant string, name string, ips []net.IP, ttl uint32) {
+	h.tenant, h.name, h.ips, h.ttl = tenant, name, ips, ttl
+}
+
+func TestFqdnLookup(t *testing.T) {
+	// upstream name server answering with a cname chain
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assertOnErr(t, err, "upstream listen")
+	started := make(chan bool)
+	upstream := &dns.Server{PacketConn: pc, NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			cname, _ := dns.NewRR("api.example.com. 300 IN CNAME lb.example.com.")
+			a, _ := dns.NewRR("lb.example.com. 30 IN A 192.0.2.10")
+			m.Answer = []dns.RR{cname, a}
+			w.WriteMsg(m)
+		})}
+	go upstream.ActivateAndServe()
+	defer upstream.Shutdown()
+	<-started
+
+	ns := new(NetpluginNameServer)
+	ds := new(dummyState)
+	err = ns.Init(ds)
+	assertOnErr(t, err, "namespace init")
+	ns.upstreams = []string{pc.LocalAddr().String()}
+	h := &fqdnHandlerStub{}
+	ns.AddFqdnHandler(h)
+
+	query := func(name string) []byte {
+		q1 := new(dns.Msg)
+		q1.SetQuestion(name+".", dns.TypeA)
+		dmsg, err := q1.Pack()
+		assertOnErr(t, err, "failed to pack query")
+		return dmsg
+	}
+	lookup := func(name string, vrf string) ([]byte, bool) {
+		replies := make(chan []byte, 1)
+		if !ns.NsLookupDeferred(query(name), &vrf, func(b []byte) { replies <- b }) {
+			return nil, false
+		}
+		select {
+		case br := <-replies:
+			return br, true
+		case <-time.After(2 * fqdnUpstreamTimeout):
+			t.Fatalf("no reply for %s", name)
+		}
+		return nil, true
+	}
+
+	br, deferred := lookup("api.example.com", "tenant1")
+	assertOnTrue(t, !deferred || br == nil, "fqdn lookup failed")
+	resp := new(dns.Msg)
+	err = resp.Unpack(br)
+	assertOnErr(t, err, "failed to unpack response")
+	assertOnTrue(t, len(resp.Answer) != 2, fmt.Sprintf("not a valid answer %+v", resp.Answer))
+	assertOnTrue(t, h.tenant != "tenant1" || h.name != "api.example.com",
+		fmt.Sprintf("invalid fqdn learned %+v", h))
+	assertOnTrue(t, len(h.ips) != 1 || h.ips[0].String() != "192.0.2.10" || h.ttl != 30,
+		fmt.Sprintf("invalid addresses learned %+v", h))
+
+	// inline lookups don't block on the upstream name servers
+	vrf := "tenant1"
+	_, err = ns.NsLookup(query("api.example.com"), &vrf)
+	assertOnTrue(t, err == nil, "fqdn resolved inline")
+
+	// names not matched by a policy are left to the container name servers
+	h.name = ""
+	_, deferred = lookup("www.example.com", "tenant1")
+	assertOnTrue(t, deferred || h.name != "", "unmatched name was resolved")
+	_, deferred = lookup("api.example.com", "tenant2")
+	assertOnTrue(t, deferred || h.name != "", "name of another tenant was resolved")
+
+	// without an upstream answer the query is forwarded
+	ns.upstreams = []string{"127.0.0.1:1"}
+	br, deferred = lookup("api.example.com", "tenant1")
+	assertOnTrue(t, !deferred || br != nil, "reply without an upstream answer")
+}
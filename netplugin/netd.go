@@ -65,19 +65,24 @@ func (s *StringSlice) Value() []string {
 // network provisioning interfaces
 
 type cliOpts struct {
-	hostLabel    string
-	pluginMode   string // plugin could be docker | kubernetes
-	cfgFile      string
-	debug        bool
-	syslog       string
-	jsonLog      bool
-	ctrlIP       string      // IP address to be used by control protocols
-	vtepIP       string      // IP address to be used by the VTEP
-	vlanIntf     StringSlice // Uplink interface for VLAN switching
-	version      bool
-	dbURL        string // state store URL
-	nwDriver     string // network driver implementation (ovs/vpp)
-	vxlanUDPPort int    // Vxlan UDP port, default: 4789
+	hostLabel         string
+	pluginMode        string // plugin could be docker | kubernetes
+	cfgFile           string
+	debug             bool
+	syslog            string
+	jsonLog           bool
+	ctrlIP            string      // IP address to be used by control protocols
+	vtepIP            string      // IP address to be used by the VTEP
+	vlanIntf          StringSlice // Uplink interface for VLAN switching
+	version           bool
+	dbURL             string // state store URL
+	dbCACert          string // CA certificate of the state store
+	dbCert            string // client certificate for the state store
+	dbKey             string // client key for the state store
+	nwDriver          string // network driver implementation (ovs/vpp)
+	vxlanUDPPort      int    // Vxlan UDP port, default: 4789
+	policyLog         string // policy log file or syslog
+	localhostPortMaps bool   // published ports are reached on localhost
 }
 
 func configureSyslog(syslogParam string) {
@@ -160,7 +165,19 @@ func main() {
 	flagSet.StringVar(&opts.dbURL,
 		"cluster-store",
 		"etcd://127.0.0.1:2379",
-		"state store url")
+		"state store url, comma separated for multiple members")
+	flagSet.StringVar(&opts.dbCACert,
+		"cluster-store-cacert",
+		"",
+		"CA certificate of the state store, enables TLS")
+	flagSet.StringVar(&opts.dbCert,
+		"cluster-store-cert",
+		"",
+		"client certificate for the state store")
+	flagSet.StringVar(&opts.dbKey,
+		"cluster-store-key",
+		"",
+		"client key for the state store")
 	flagSet.StringVar(&opts.nwDriver,
 		"net-driver",
 		"ovs",
@@ -169,6 +186,14 @@ func main() {
 		"vxlan-port",
 		4789,
 		"VxLAN UDP port number")
+	flagSet.StringVar(&opts.policyLog,
+		"policy-log",
+		"",
+		"Write the packets logged by policy rules to a file -- use 'syslog' to log via local syslog")
+	flagSet.BoolVar(&opts.localhostPortMaps,
+		"localhost-port-maps",
+		false,
+		"Reach published ports on localhost too -- enables route_localnet on the interfaces of the published endpoints")
 
 	err = flagSet.Parse(os.Args[1:])
 	if err != nil {
@@ -234,13 +259,18 @@ func main() {
 			State:   stateStore,
 		},
 		Instance: core.InstanceInfo{
-			HostLabel:    opts.hostLabel,
-			CtrlIP:       opts.ctrlIP,
-			VtepIP:       opts.vtepIP,
-			UplinkIntf:   opts.vlanIntf,
-			DbURL:        opts.dbURL,
-			PluginMode:   opts.pluginMode,
-			VxlanUDPPort: opts.vxlanUDPPort,
+			HostLabel:         opts.hostLabel,
+			CtrlIP:            opts.ctrlIP,
+			VtepIP:            opts.vtepIP,
+			UplinkIntf:        opts.vlanIntf,
+			DbURL:             opts.dbURL,
+			DbCACert:          opts.dbCACert,
+			DbCert:            opts.dbCert,
+			DbKey:             opts.dbKey,
+			PluginMode:        opts.pluginMode,
+			VxlanUDPPort:      opts.vxlanUDPPort,
+			PolicyLog:         opts.policyLog,
+			LocalhostPortMaps: opts.localhostPortMaps,
 		},
 	}
 
@@ -116,6 +116,14 @@ func (p *NetPlugin) CreateNetwork(id string) error {
 	return p.NetworkDriver.CreateNetwork(id)
 }
 
+// UpdateNetworkPktTag moves a network from its old pkt tags to the ones
+// in its config.
+func (p *NetPlugin) UpdateNetworkPktTag(id string, oldPktTag, oldExtPktTag int) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.UpdateNetworkPktTag(id, oldPktTag, oldExtPktTag)
+}
+
 // DeleteNetwork deletes a network provided by the ID.
 func (p *NetPlugin) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, Gw string, tenant string) error {
 	p.Lock()
@@ -252,6 +260,27 @@ func (p *NetPlugin) GetEndpointStats() ([]byte, error) {
 	return p.NetworkDriver.GetEndpointStats()
 }
 
+// GetPolicyRuleStats returns the hit counters of the policy rules
+func (p *NetPlugin) GetPolicyRuleStats() (map[string]core.PolicyRuleStats, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.GetPolicyRuleStats()
+}
+
+// GetPolicyLogs returns the recent packets logged by policy rules
+func (p *NetPlugin) GetPolicyLogs() ([]core.PolicyLogRecord, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.GetPolicyLogs()
+}
+
+// GetLocalEndpointCount returns the number of endpoints homed on this node
+func (p *NetPlugin) GetLocalEndpointCount() (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.GetLocalEndpointCount()
+}
+
 // InspectState returns current state of the plugin
 func (p *NetPlugin) InspectState() ([]byte, error) {
 	p.Lock()
@@ -353,3 +382,17 @@ func (p *NetPlugin) DelPolicyRule(id string) error {
 	defer p.Unlock()
 	return p.NetworkDriver.DelPolicyRule(id)
 }
+
+// AddPortMaps publishes host ports to an endpoint
+func (p *NetPlugin) AddPortMaps(id string, maps []core.PortMapSpec) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.AddPortMaps(id, maps)
+}
+
+// DelPortMaps removes the host ports published to an endpoint
+func (p *NetPlugin) DelPortMaps(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.DelPortMaps(id)
+}
@@ -0,0 +1,190 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/objdb"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// BoltStateDriverConfig encapsulates the path of the bolt db file
+type BoltStateDriverConfig struct {
+	Bolt struct {
+		Path string
+	}
+}
+
+// BoltStateDriver implements the StateDriver interface for a bolt db file
+// on the local disk, for single node deployments without an etcd or consul
+// cluster. netmaster and netplugin on the node share the file.
+type BoltStateDriver struct {
+	Store *objdb.BoltStore
+}
+
+// Init the driver with a core.Config.
+func (d *BoltStateDriver) Init(instInfo *core.InstanceInfo) error {
+	var err error
+
+	if instInfo == nil || !strings.HasPrefix(instInfo.DbURL, "bolt://") {
+		return errors.New("Invalid bolt config")
+	}
+
+	_, paths, err := objdb.ParseDbURL(instInfo.DbURL)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		return errors.New("Bolt store needs a single db file")
+	}
+
+	d.Store, err = objdb.OpenBoltStore(paths[0])
+
+	return err
+}
+
+// Deinit is currently a no-op.
+func (d *BoltStateDriver) Deinit() {}
+
+// Write state to key with value.
+func (d *BoltStateDriver) Write(key string, value []byte) (err error) {
+	defer observeStateOp("bolt", "write", time.Now(), &err)
+
+	return d.Store.Put(key, value)
+}
+
+// Read state from key.
+func (d *BoltStateDriver) Read(key string) (value []byte, err error) {
+	defer observeStateOp("bolt", "read", time.Now(), &err)
+
+	value, err = d.Store.Get(key)
+	if err == objdb.ErrBoltKeyNotFound {
+		return []byte{}, core.Errorf("Key not found")
+	}
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return value, nil
+}
+
+// ReadAll state from baseKey.
+func (d *BoltStateDriver) ReadAll(baseKey string) (values [][]byte, err error) {
+	defer observeStateOp("bolt", "readAll", time.Now(), &err)
+
+	_, values, err = d.Store.List(baseKey)
+	if err != nil {
+		return [][]byte{}, err
+	}
+	if len(values) == 0 {
+		return [][]byte{}, core.Errorf("Key not found")
+	}
+
+	return values, nil
+}
+
+func (d *BoltStateDriver) channelBoltEvents(events chan objdb.BoltEvent, rsps chan [2][]byte) {
+	for event := range events {
+		rsp := [2][]byte{event.Value, event.PrevValue}
+		eventStr := "create"
+		if event.PrevValue != nil {
+			if event.Value != nil {
+				eventStr = "modify"
+			} else {
+				eventStr = "delete"
+			}
+		}
+
+		log.Debugf("Received %q for key: %s", eventStr, event.Key)
+		//channel the translated response
+		rsps <- rsp
+	}
+}
+
+// WatchAll state transitions from baseKey
+func (d *BoltStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
+	events := make(chan objdb.BoltEvent, 1)
+	err := d.Store.Watch(baseKey, events, make(chan bool))
+	if err != nil {
+		log.Errorf("bolt watch failed. Err: %v", err)
+		return err
+	}
+
+	go d.channelBoltEvents(events, rsps)
+
+	return nil
+}
+
+// ClearState removes key from the store
+func (d *BoltStateDriver) ClearState(key string) (err error) {
+	defer observeStateOp("bolt", "clear", time.Now(), &err)
+
+	return d.Store.Delete(key)
+}
+
+// ReadState reads key into a core.State with the unmarshaling function.
+func (d *BoltStateDriver) ReadState(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) error {
+	encodedState, err := d.Read(key)
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(encodedState, value)
+}
+
+// ReadAllState Reads all the state from baseKey and returns a list of core.State.
+func (d *BoltStateDriver) ReadAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error) ([]core.State, error) {
+	return readAllStateCommon(d, baseKey, sType, unmarshal)
+}
+
+// WatchAllState watches all state from the baseKey.
+func (d *BoltStateDriver) WatchAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error, rsps chan core.WatchState) error {
+	byteRsps := make(chan [2][]byte, 1)
+	recvErr := make(chan error, 1)
+
+	err := d.WatchAll(baseKey, byteRsps)
+	if err != nil {
+		log.Errorf("WatchAll returned %v", err)
+		return err
+	}
+
+	for {
+		go channelStateEvents(d, sType, unmarshal, byteRsps, rsps, recvErr)
+
+		err = <-recvErr
+		log.Errorf("Err from channelStateEvents %v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// WriteState writes a value of core.State into a key with a given marshaling function.
+func (d *BoltStateDriver) WriteState(key string, value core.State,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return d.Write(key, encodedState)
+}
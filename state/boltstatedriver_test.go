@@ -0,0 +1,156 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+)
+
+var boltTestDir string
+
+func setupBoltDriver(t *testing.T) *BoltStateDriver {
+	if boltTestDir == "" {
+		dir, err := ioutil.TempDir("", "boltstate")
+		if err != nil {
+			t.Fatalf("error creating temp dir. Err: %v", err)
+		}
+		boltTestDir = dir
+	}
+	instInfo := core.InstanceInfo{DbURL: "bolt://" + filepath.Join(boltTestDir, "state.db")}
+
+	driver := &BoltStateDriver{}
+
+	err := driver.Init(&instInfo)
+	if err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+		return nil
+	}
+
+	return driver
+}
+
+func TestBoltStateDriverInit(t *testing.T) {
+	setupBoltDriver(t)
+}
+
+func TestBoltStateDriverInitInvalidConfig(t *testing.T) {
+	driver := &BoltStateDriver{}
+	commonTestStateDriverInitInvalidConfig(t, driver)
+}
+
+func TestBoltStateDriverWrite(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWrite(t, driver)
+}
+
+func TestBoltStateDriverRead(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverRead(t, driver)
+}
+
+func TestBoltStateDriverWriteState(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWriteState(t, driver)
+}
+
+func TestBoltStateDriverWriteStateForUpdate(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWriteStateForUpdate(t, driver)
+}
+
+func TestBoltStateDriverClearState(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverClearState(t, driver)
+}
+
+func TestBoltStateDriverReadState(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverReadState(t, driver)
+}
+
+func TestBoltStateDriverReadStateAfterUpdate(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverReadStateAfterUpdate(t, driver)
+}
+
+func TestBoltStateDriverReadStateAfterClear(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverReadStateAfterClear(t, driver)
+}
+
+func TestBoltStateDriverWatchAllStateCreate(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWatchAllStateCreate(t, driver)
+}
+
+func TestBoltStateDriverWatchAllStateModify(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWatchAllStateModify(t, driver)
+}
+
+func TestBoltStateDriverWatchAllStateDelete(t *testing.T) {
+	driver := setupBoltDriver(t)
+	commonTestStateDriverWatchAllStateDelete(t, driver)
+}
+
+// TestBoltStateDriverSharedFile writes the db file from another process while
+// this one watches it, the way a netplugin shares the file with netmaster
+func TestBoltStateDriverSharedFile(t *testing.T) {
+	if os.Getenv("BOLT_TEST_WRITER") != "" {
+		driver := &BoltStateDriver{}
+		if err := driver.Init(&core.InstanceInfo{DbURL: os.Getenv("BOLT_TEST_WRITER")}); err != nil {
+			t.Fatalf("driver init failed. Error: %s", err)
+		}
+		if err := driver.Write("shared/key", []byte("fromWriter")); err != nil {
+			t.Fatalf("failed to write bytes. Error: %s", err)
+		}
+		return
+	}
+
+	driver := setupBoltDriver(t)
+	rsps := make(chan [2][]byte, 1)
+	if err := driver.WatchAll("shared/", rsps); err != nil {
+		t.Fatalf("watch failed. Error: %s", err)
+	}
+
+	writer := exec.Command(os.Args[0], "-test.run", "TestBoltStateDriverSharedFile")
+	writer.Env = append(os.Environ(), "BOLT_TEST_WRITER=bolt://"+filepath.Join(boltTestDir, "state.db"))
+	if out, err := writer.CombinedOutput(); err != nil {
+		t.Fatalf("writer process failed. Error: %s, Output: %s", err, out)
+	}
+
+	value, err := driver.Read("shared/key")
+	if err != nil || !bytes.Equal(value, []byte("fromWriter")) {
+		t.Fatalf("failed to read the value written by the other process. Got %q. Error: %v", value, err)
+	}
+
+	select {
+	case rsp := <-rsps:
+		if !bytes.Equal(rsp[0], []byte("fromWriter")) || rsp[1] != nil {
+			t.Fatalf("unexpected watch event %q", rsp)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("timed out waiting for events")
+	}
+}
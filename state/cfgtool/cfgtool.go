@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"strconv"
@@ -27,19 +28,51 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/drivers/ovsd"
+	"github.com/contiv/netplugin/netmaster/backup"
 	"github.com/contiv/netplugin/netmaster/docknet"
 	"github.com/contiv/netplugin/netmaster/gstate"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netmaster/resources"
+	"github.com/contiv/netplugin/netmaster/statecheck"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/objdb"
 )
 
+// storeOpts are the options to reach the cluster store
+type storeOpts struct {
+	clusterStore string
+	storeCACert  string
+	storeCert    string
+	storeKey     string
+}
+
+// addStoreFlags adds the cluster store options to a flag set
+func addStoreFlags(flagSet *flag.FlagSet, opts *storeOpts) {
+	flagSet.StringVar(&opts.clusterStore,
+		"cluster-store",
+		"etcd://127.0.0.1:2379",
+		"Etcd or Consul cluster store url.")
+	flagSet.StringVar(&opts.storeCACert,
+		"cluster-store-cacert",
+		"",
+		"CA certificate of the cluster store, enables TLS")
+	flagSet.StringVar(&opts.storeCert,
+		"cluster-store-cert",
+		"",
+		"Client certificate for the cluster store")
+	flagSet.StringVar(&opts.storeKey,
+		"cluster-store-key",
+		"",
+		"Client key for the cluster store")
+}
+
 // initStateDriver creates a state driver based on the cluster store URL
-func initStateDriver(clusterStore string) (core.StateDriver, error) {
+func initStateDriver(opts *storeOpts) (core.StateDriver, error) {
 	// parse the state store URL
-	parts := strings.Split(clusterStore, "://")
+	parts := strings.Split(opts.clusterStore, "://")
 	if len(parts) < 2 {
-		return nil, core.Errorf("Invalid state-store URL %q", clusterStore)
+		return nil, core.Errorf("Invalid state-store URL %q", opts.clusterStore)
 	}
 	stateStore := parts[0]
 
@@ -47,13 +80,17 @@ func initStateDriver(clusterStore string) (core.StateDriver, error) {
 	switch stateStore {
 	case utils.EtcdNameStr:
 	case utils.ConsulNameStr:
+	case utils.BoltNameStr:
 	default:
 		return nil, core.Errorf("Unsupported state-store %q", stateStore)
 	}
 
 	// Setup instance info
 	instInfo := core.InstanceInfo{
-		DbURL: clusterStore,
+		DbURL:    opts.clusterStore,
+		DbCACert: opts.storeCACert,
+		DbCert:   opts.storeCert,
+		DbKey:    opts.storeKey,
 	}
 
 	return utils.NewStateDriver(stateStore, &instInfo)
@@ -192,7 +229,7 @@ func processState(stateDriver core.StateDriver, stateName, stateID, fieldName, s
 	typeRegistry[reflect.TypeOf(resources.AutoVLANOperResource{}).Name()] = &resources.AutoVLANOperResource{}
 	typeRegistry[reflect.TypeOf(resources.AutoVXLANCfgResource{}).Name()] = &resources.AutoVXLANCfgResource{}
 	typeRegistry[reflect.TypeOf(resources.AutoVXLANOperResource{}).Name()] = &resources.AutoVXLANOperResource{}
-	typeRegistry[reflect.TypeOf(drivers.OvsDriverOperState{}).Name()] = &drivers.OvsDriverOperState{}
+	typeRegistry[reflect.TypeOf(ovsd.OvsDriverOperState{}).Name()] = &ovsd.OvsDriverOperState{}
 	typeRegistry[reflect.TypeOf(drivers.OperEndpointState{}).Name()] = &drivers.OperEndpointState{}
 	typeRegistry[reflect.TypeOf(docknet.DnetOperState{}).Name()] = &docknet.DnetOperState{}
 
@@ -298,14 +335,189 @@ func processState(stateDriver core.StateDriver, stateName, stateID, fieldName, s
 	return nil
 }
 
+// processCheck handles the `check` command, it returns the number of
+// inconsistencies left in the state store
+func processCheck(stateDriver core.StateDriver, opts *storeOpts, repair bool) (int, error) {
+	// the model objects are read through objdb
+	modelDb, err := objdb.NewClientWithConfig(&objdb.ClientConfig{
+		DbURL:  opts.clusterStore,
+		CACert: opts.storeCACert,
+		Cert:   opts.storeCert,
+		Key:    opts.storeKey,
+	})
+	if err != nil {
+		log.Errorf("Error connecting to state store %s. Err: %v", opts.clusterStore, err)
+		return 0, err
+	}
+
+	incs, err := statecheck.Run(stateDriver, modelDb, repair)
+	for _, inc := range incs {
+		fmt.Println(inc)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	left := 0
+	for _, inc := range incs {
+		if !inc.Repaired {
+			left++
+		}
+	}
+	fmt.Printf("%d inconsistencies found, %d repaired\n", len(incs), len(incs)-left)
+
+	return left, nil
+}
+
+// checkMain runs the `check` command
+func checkMain(args []string) {
+	var opts storeOpts
+	var repair bool
+
+	flagSet := flag.NewFlagSet("check", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s check:\n", os.Args[0])
+		flagSet.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nRepair only while netmaster is stopped, no network, group or endpoint may change during the repair.\n")
+	}
+
+	addStoreFlags(flagSet, &opts)
+	flagSet.BoolVar(&repair,
+		"repair",
+		false,
+		"Repair the inconsistencies found")
+	if err := flagSet.Parse(args); err != nil {
+		log.Errorf("Error parsing commandline args: %v", err)
+		return
+	}
+
+	// initialize state driver
+	stateDriver, err := initStateDriver(&opts)
+	if err != nil {
+		log.Fatalf("Failed to init state-store. Error: %s", err)
+	}
+
+	left, err := processCheck(stateDriver, &opts, repair)
+	if err != nil {
+		log.Fatalf("Error checking state store. Err: %v", err)
+	}
+	if left != 0 {
+		os.Exit(1)
+	}
+}
+
+// processBackup writes an archive of the state store to a file
+func processBackup(stateDriver core.StateDriver, fileName string) error {
+	archive, err := backup.Backup(stateDriver)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(fileName, content, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved %d objects to %s\n", len(archive.Objects), fileName)
+	return nil
+}
+
+// processRestore restores an archive into an empty state store
+func processRestore(stateDriver core.StateDriver, fileName string, endpoints bool) error {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	archive := &backup.Archive{}
+	if err := json.Unmarshal(content, archive); err != nil {
+		return err
+	}
+
+	if err := backup.Restore(stateDriver, archive, endpoints); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored the backup of %s taken with version %s\n", archive.Time, archive.NetpluginVersion)
+	return nil
+}
+
+// archiveMain runs the `backup` and `restore` commands
+func archiveMain(cmd string, args []string) {
+	var opts storeOpts
+	var fileName string
+	var endpoints bool
+
+	flagSet := flag.NewFlagSet(cmd, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], cmd)
+		flagSet.PrintDefaults()
+		if cmd == "restore" {
+			fmt.Fprintf(os.Stderr, "\nRestore into an empty state store, before netmaster is started.\n")
+		}
+	}
+
+	addStoreFlags(flagSet, &opts)
+	flagSet.StringVar(&fileName,
+		"file",
+		"",
+		"Backup archive file")
+	if cmd == "restore" {
+		flagSet.BoolVar(&endpoints,
+			"endpoints",
+			false,
+			"Restore the runtime endpoint state")
+	}
+	if err := flagSet.Parse(args); err != nil {
+		log.Errorf("Error parsing commandline args: %v", err)
+		return
+	}
+
+	if fileName == "" {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+
+	// initialize state driver
+	stateDriver, err := initStateDriver(&opts)
+	if err != nil {
+		log.Fatalf("Failed to init state-store. Error: %s", err)
+	}
+
+	if cmd == "backup" {
+		err = processBackup(stateDriver, fileName)
+	} else {
+		err = processRestore(stateDriver, fileName, endpoints)
+	}
+	if err != nil {
+		log.Fatalf("Error processing %s of %s. Err: %v", cmd, fileName, err)
+	}
+}
+
 func main() {
 	var rsrcName string
-	var clusterStore string
+	var opts storeOpts
 	var setVal string
 	var stateName string
 	var stateID string
 	var fieldName string
 
+	// commands with their own options
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			checkMain(os.Args[2:])
+			return
+		case "backup", "restore":
+			archiveMain(os.Args[1], os.Args[2:])
+			return
+		}
+	}
+
 	// parse all commandline args
 	flagSet := flag.NewFlagSet("cfgtool", flag.ExitOnError)
 	flagSet.Usage = func() {
@@ -316,6 +528,9 @@ func main() {
 		fmt.Fprintf(os.Stderr, "	%s -state GlobConfig -id global -field FwdMode -set routing\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "%s -resource <vlan|vxlan> -set <new-range>\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "	%s -resource vlan -set 1-10\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s check [-repair]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s backup -file <archive>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s restore -file <archive> [-endpoints]\n", os.Args[0])
 	}
 
 	flagSet.StringVar(&rsrcName,
@@ -326,10 +541,7 @@ func main() {
 		"set",
 		"",
 		"Resource value")
-	flagSet.StringVar(&clusterStore,
-		"cluster-store",
-		"etcd://127.0.0.1:2379",
-		"Etcd or Consul cluster store url.")
+	addStoreFlags(flagSet, &opts)
 	flagSet.StringVar(&stateName,
 		"state",
 		"",
@@ -356,7 +568,7 @@ func main() {
 	}
 
 	// initialize state driver
-	stateDriver, err := initStateDriver(clusterStore)
+	stateDriver, err := initStateDriver(&opts)
 	if err != nil {
 		log.Fatalf("Failed to init state-store. Error: %s", err)
 	}
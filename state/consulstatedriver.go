@@ -17,10 +17,12 @@ package state
 
 import (
 	"errors"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/contiv/netplugin/core"
+	"github.com/contiv/objdb"
 	"github.com/hashicorp/consul/api"
 
 	log "github.com/Sirupsen/logrus"
@@ -45,12 +47,30 @@ type ConsulStateDriver struct {
 func (d *ConsulStateDriver) Init(instInfo *core.InstanceInfo) error {
 	var err error
 
-	if instInfo == nil || !strings.Contains(instInfo.DbURL, "consul://") {
+	if instInfo == nil || !strings.HasPrefix(instInfo.DbURL, "consul://") {
 		return errors.New("Invalid consul config")
 	}
 
+	_, hosts, err := objdb.ParseDbURL(instInfo.DbURL)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := objdb.NewTLSConfig(instInfo.DbCACert, instInfo.DbCert, instInfo.DbKey)
+	if err != nil {
+		log.Errorf("Error loading consul certificates. Err: %v", err)
+		return err
+	}
+
+	// consul client talks to a single address, the transport fails over
+	// between the members
 	cfg := api.Config{
-		Address: strings.TrimPrefix(instInfo.DbURL, "consul://"),
+		Address:    hosts[0],
+		Scheme:     "http",
+		HttpClient: &http.Client{Transport: objdb.NewFailoverTransport(hosts, tlsConfig)},
+	}
+	if tlsConfig != nil {
+		cfg.Scheme = "https"
 	}
 
 	d.Client, err = api.NewClient(&cfg)
@@ -68,10 +88,10 @@ func processKey(inKey string) string {
 }
 
 // Write state to key with value.
-func (d *ConsulStateDriver) Write(key string, value []byte) error {
-	key = processKey(key)
+func (d *ConsulStateDriver) Write(key string, value []byte) (err error) {
+	defer observeStateOp("consul", "write", time.Now(), &err)
 
-	var err error
+	key = processKey(key)
 
 	for i := 0; i < maxConsulRetries; i++ {
 		_, err = d.Client.KV().Put(&api.KVPair{Key: key, Value: value}, nil)
@@ -90,10 +110,11 @@ func (d *ConsulStateDriver) Write(key string, value []byte) error {
 }
 
 // Read state from key.
-func (d *ConsulStateDriver) Read(key string) ([]byte, error) {
+func (d *ConsulStateDriver) Read(key string) (value []byte, err error) {
+	defer observeStateOp("consul", "read", time.Now(), &err)
+
 	key = processKey(key)
 
-	var err error
 	var kv *api.KVPair
 
 	for i := 0; i < maxConsulRetries; i++ {
@@ -119,10 +140,11 @@ func (d *ConsulStateDriver) Read(key string) ([]byte, error) {
 }
 
 // ReadAll state from baseKey.
-func (d *ConsulStateDriver) ReadAll(baseKey string) ([][]byte, error) {
+func (d *ConsulStateDriver) ReadAll(baseKey string) (values [][]byte, err error) {
+	defer observeStateOp("consul", "readAll", time.Now(), &err)
+
 	baseKey = processKey(baseKey)
 
-	var err error
 	var kvs api.KVPairs
 
 	for i := 0; i < maxConsulRetries; i++ {
@@ -143,7 +165,7 @@ func (d *ConsulStateDriver) ReadAll(baseKey string) ([][]byte, error) {
 			return nil, core.Errorf("Key not found")
 		}
 
-		values := [][]byte{}
+		values = [][]byte{}
 		for _, kv := range kvs {
 			values = append(values, kv.Value)
 		}
@@ -269,9 +291,11 @@ func (d *ConsulStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error
 }
 
 // ClearState removes key from etcd.
-func (d *ConsulStateDriver) ClearState(key string) error {
+func (d *ConsulStateDriver) ClearState(key string) (err error) {
+	defer observeStateOp("consul", "clear", time.Now(), &err)
+
 	key = processKey(key)
-	_, err := d.Client.KV().Delete(key, nil)
+	_, err = d.Client.KV().Delete(key, nil)
 	return err
 }
 
@@ -98,3 +98,16 @@ func TestConsulStateDriverWatchAllStateDelete(t *testing.T) {
 	driver := setupConsulDriver(t)
 	commonTestStateDriverWatchAllStateDelete(t, driver)
 }
+
+func TestConsulStateDriverFailover(t *testing.T) {
+	// the first member is down, requests fail over to the second one
+	instInfo := core.InstanceInfo{DbURL: "consul://127.0.0.1:8499,127.0.0.1:8500"}
+
+	driver := &ConsulStateDriver{}
+	err := driver.Init(&instInfo)
+	if err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+	}
+
+	commonTestStateDriverRead(t, driver)
+}
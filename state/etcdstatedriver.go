@@ -25,6 +25,8 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/utils/metrics"
+	"github.com/contiv/objdb"
 	"github.com/coreos/etcd/client"
 
 	log "github.com/Sirupsen/logrus"
@@ -54,13 +56,25 @@ type EtcdStateDriver struct {
 func (d *EtcdStateDriver) Init(instInfo *core.InstanceInfo) error {
 	var err error
 
-	if instInfo == nil || !strings.Contains(instInfo.DbURL, "etcd://") {
+	if instInfo == nil || !strings.HasPrefix(instInfo.DbURL, "etcd://") {
 		return errors.New("Invalid etcd config")
 	}
 
-	etcdURL := strings.Replace(instInfo.DbURL, "etcd://", "http://", 1)
+	_, hosts, err := objdb.ParseDbURL(instInfo.DbURL)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := objdb.NewTLSConfig(instInfo.DbCACert, instInfo.DbCert, instInfo.DbKey)
+	if err != nil {
+		log.Errorf("Error loading etcd certificates. Err: %v", err)
+		return err
+	}
+
+	// the client fails over between the endpoints by itself
 	etcdConfig := client.Config{
-		Endpoints: []string{etcdURL},
+		Endpoints: objdb.Endpoints(hosts, tlsConfig),
+		Transport: objdb.NewTransport(tlsConfig),
 	}
 
 	d.Client, err = client.New(etcdConfig)
@@ -78,12 +92,12 @@ func (d *EtcdStateDriver) Init(instInfo *core.InstanceInfo) error {
 func (d *EtcdStateDriver) Deinit() {}
 
 // Write state to key with value.
-func (d *EtcdStateDriver) Write(key string, value []byte) error {
+func (d *EtcdStateDriver) Write(key string, value []byte) (err error) {
+	defer observeStateOp("etcd", "write", time.Now(), &err)
+
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	var err error
-
 	for i := 0; i < maxEtcdRetries; i++ {
 		_, err = d.KeysAPI.Set(ctx, key, string(value[:]), nil)
 		if err != nil && err.Error() == client.ErrClusterUnavailable.Error() {
@@ -100,11 +114,12 @@ func (d *EtcdStateDriver) Write(key string, value []byte) error {
 }
 
 // Read state from key.
-func (d *EtcdStateDriver) Read(key string) ([]byte, error) {
+func (d *EtcdStateDriver) Read(key string) (value []byte, err error) {
+	defer observeStateOp("etcd", "read", time.Now(), &err)
+
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	var err error
 	var resp *client.Response
 
 	for i := 0; i < maxEtcdRetries; i++ {
@@ -134,17 +149,18 @@ func (d *EtcdStateDriver) Read(key string) ([]byte, error) {
 }
 
 // ReadAll state from baseKey.
-func (d *EtcdStateDriver) ReadAll(baseKey string) ([][]byte, error) {
+func (d *EtcdStateDriver) ReadAll(baseKey string) (values [][]byte, err error) {
+	defer observeStateOp("etcd", "readAll", time.Now(), &err)
+
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	var err error
 	var resp *client.Response
 
 	for i := 0; i < maxEtcdRetries; i++ {
 		resp, err = d.KeysAPI.Get(ctx, baseKey, &client.GetOptions{Recursive: true, Quorum: true})
 		if err == nil {
-			values := [][]byte{}
+			values = [][]byte{}
 			for _, node := range resp.Node.Nodes {
 				values = append(values, []byte(node.Value))
 			}
@@ -217,11 +233,13 @@ func (d *EtcdStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
 }
 
 // ClearState removes key from etcd
-func (d *EtcdStateDriver) ClearState(key string) error {
+func (d *EtcdStateDriver) ClearState(key string) (err error) {
+	defer observeStateOp("etcd", "clear", time.Now(), &err)
+
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
 
-	_, err := d.KeysAPI.Delete(ctx, key, nil)
+	_, err = d.KeysAPI.Delete(ctx, key, nil)
 	return err
 }
 
@@ -279,6 +297,12 @@ func (d *EtcdStateDriver) ReadAllState(baseKey string, sType core.State,
 	return readAllStateCommon(d, baseKey, sType, unmarshal)
 }
 
+// observeStateOp records the metrics of a state store operation, it is
+// deferred with the error result of the operation
+func observeStateOp(driver, op string, start time.Time, err *error) {
+	metrics.ObserveStateOp(driver, op, start, *err)
+}
+
 // channelStateEvents watches for updates(created, modify, delete) to a state of
 // specified type and unmarshals (given a function) all changes and puts then on
 // channel of core.WatchState objects.
@@ -289,6 +313,7 @@ func channelStateEvents(d core.StateDriver, sType core.State,
 	for {
 		// block on change notifications
 		byteRsp := <-byteRsps
+		received := time.Now()
 
 		rsp := core.WatchState{Curr: nil, Prev: nil}
 		for i := 0; i < 2; i++ {
@@ -320,6 +345,7 @@ func channelStateEvents(d core.StateDriver, sType core.State,
 
 		//channel the translated response
 		rsps <- rsp
+		metrics.StateWatchEventLag.Observe(time.Since(received).Seconds())
 	}
 }
 
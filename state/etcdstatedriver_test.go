@@ -17,7 +17,19 @@ package state
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -451,3 +463,145 @@ func TestEtcdStateDriverWatchAllStateDelete(t *testing.T) {
 	driver := setupEtcdDriver(t)
 	commonTestStateDriverWatchAllStateDelete(t, driver)
 }
+
+// writeTestCert creates a key pair signed by the parent certificate, self
+// signed when parent is nil, and writes them in dir
+func writeTestCert(t *testing.T, dir, name string, template, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key. Err: %v", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("error creating certificate. Err: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate. Err: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key. Err: %v", err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPem, 0600); err != nil {
+		t.Fatalf("error writing certificate. Err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPem, 0600); err != nil {
+		t.Fatalf("error writing key. Err: %v", err)
+	}
+
+	return cert, key
+}
+
+// writeTestCerts creates a CA, a server certificate for 127.0.0.1 and a
+// client certificate
+func writeTestCerts(t *testing.T, dir string) {
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := writeTestCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "contiv test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, nil, nil)
+	writeTestCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeTestCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "netplugin"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+}
+
+func TestEtcdStateDriverTLS(t *testing.T) {
+	etcdBin, err := exec.LookPath("etcd")
+	if err != nil {
+		t.Skip("etcd binary not found")
+	}
+
+	dir, err := ioutil.TempDir("", "etcdtls")
+	if err != nil {
+		t.Fatalf("error creating temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestCerts(t, dir)
+
+	// etcd requiring client certificates
+	clientURL := "https://127.0.0.1:23791"
+	peerURL := "http://127.0.0.1:23792"
+	etcd := exec.Command(etcdBin,
+		"--name", "tlstest",
+		"--data-dir", filepath.Join(dir, "data"),
+		"--listen-client-urls", clientURL,
+		"--advertise-client-urls", clientURL,
+		"--listen-peer-urls", peerURL,
+		"--initial-advertise-peer-urls", peerURL,
+		"--initial-cluster", "tlstest="+peerURL,
+		"--cert-file", filepath.Join(dir, "server.pem"),
+		"--key-file", filepath.Join(dir, "server-key.pem"),
+		"--trusted-ca-file", filepath.Join(dir, "ca.pem"),
+		"--client-cert-auth")
+	if err := etcd.Start(); err != nil {
+		t.Fatalf("error starting etcd. Err: %v", err)
+	}
+	defer etcd.Process.Kill()
+
+	// the first member is down, requests fail over to the second one
+	instInfo := core.InstanceInfo{
+		DbURL:    "etcd://127.0.0.1:23790,127.0.0.1:23791",
+		DbCACert: filepath.Join(dir, "ca.pem"),
+		DbCert:   filepath.Join(dir, "client.pem"),
+		DbKey:    filepath.Join(dir, "client-key.pem"),
+	}
+	driver := &EtcdStateDriver{}
+	if err := driver.Init(&instInfo); err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+	}
+
+	testBytes := []byte("tls")
+	for i := 0; ; i++ {
+		err = driver.Write("/contiv.io/tlstest", testBytes)
+		if err == nil {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("failed to write over tls. Error: %s", err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	readBytes, err := driver.Read("/contiv.io/tlstest")
+	if err != nil || !bytes.Equal(readBytes, testBytes) {
+		t.Fatalf("failed to read over tls. Got %q. Error: %v", readBytes, err)
+	}
+
+	// without a client certificate the connection is refused
+	instInfo.DbCert, instInfo.DbKey = "", ""
+	driver = &EtcdStateDriver{}
+	if err := driver.Init(&instInfo); err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+	}
+	if _, err := driver.Read("/contiv.io/tlstest"); err == nil {
+		t.Fatalf("read without client certificate succeeded")
+	}
+}
@@ -43,6 +43,10 @@ var stateDriverRegistry = map[string]driverConfigTypes{
 		DriverType: reflect.TypeOf(state.ConsulStateDriver{}),
 		ConfigType: reflect.TypeOf(state.ConsulStateDriverConfig{}),
 	},
+	BoltNameStr: {
+		DriverType: reflect.TypeOf(state.BoltStateDriver{}),
+		ConfigType: reflect.TypeOf(state.BoltStateDriverConfig{}),
+	},
 	// fakestate-driver is used for tests, so not exposing a public name for it.
 	"fakedriver": {
 		DriverType: reflect.TypeOf(state.FakeStateDriver{}),
@@ -55,6 +59,8 @@ const (
 	EtcdNameStr = "etcd"
 	// ConsulNameStr is a string constant for consul state-store
 	ConsulNameStr = "consul"
+	// BoltNameStr is a string constant for the local bolt state-store
+	BoltNameStr = "bolt"
 	// OvsNameStr is a string constant for ovs driver
 	OvsNameStr = "ovs"
 	// VppNameStr is a string constant for vpp driver
@@ -18,12 +18,27 @@ type ContivConfig struct {
 	K8sCert      string `json:"K8S_CERT,omitempty"`
 	K8sToken     string `json:"K8S_TOKEN,omitempty"`
 	SvcSubnet    string `json:"SVC_SUBNET,omitempty"`
+	NsMapping    string `json:"NS_MAPPING,omitempty"`
+	NsNetwork    string `json:"NS_NETWORK,omitempty"`
+	NsSubnet     string `json:"NS_SUBNET,omitempty"`
 }
 
+// namespace mapping modes, a pod without contiv labels is placed in an epg
+// named after its namespace, either in the default tenant or in a tenant
+// named after its namespace
+const (
+	NsMappingNone   = ""
+	NsMappingEpg    = "epg"
+	NsMappingTenant = "tenant"
+)
+
 // contivKubeCfgFile holds credentials to access k8s api server
 const (
 	contivKubeCfgFile = "/opt/contiv/config/contiv.json"
 	defSvcSubnet      = "10.254.0.0/16"
+	defNsNetwork      = "default-net"
+	defNsSubnet       = "10.36.0.0/16"
+	defTenantName     = "default"
 	tokenFile         = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
@@ -35,11 +50,19 @@ func GetK8SConfig(pCfg *ContivConfig) error {
 	}
 
 	pCfg.SvcSubnet = defSvcSubnet
+	pCfg.NsNetwork = defNsNetwork
+	pCfg.NsSubnet = defNsSubnet
 	err = json.Unmarshal(bytes, pCfg)
 	if err != nil {
 		return fmt.Errorf("Error parsing config file: %s", err)
 	}
 
+	switch pCfg.NsMapping {
+	case NsMappingNone, NsMappingEpg, NsMappingTenant:
+	default:
+		return fmt.Errorf("Invalid namespace mapping %q in config file", pCfg.NsMapping)
+	}
+
 	// If no client certs or token is specified, get the default token
 	if len(strings.TrimSpace(pCfg.K8sCert)) == 0 && len(strings.TrimSpace(pCfg.K8sToken)) == 0 {
 		pCfg.K8sToken, err = getDefaultToken()
@@ -52,6 +75,23 @@ func GetK8SConfig(pCfg *ContivConfig) error {
 	return nil
 }
 
+// GetNamespaceTarget returns the tenant, network and epg a namespace maps to,
+// ok is false when namespace mapping is disabled or does not apply
+func GetNamespaceTarget(pCfg *ContivConfig, ns string) (tenant, network, epg string, ok bool) {
+	if ns == "" || ns == "kube-system" {
+		return "", "", "", false
+	}
+
+	switch pCfg.NsMapping {
+	case NsMappingEpg:
+		return defTenantName, pCfg.NsNetwork, ns, true
+	case NsMappingTenant:
+		return ns, pCfg.NsNetwork, ns, true
+	}
+
+	return "", "", "", false
+}
+
 // SetUpK8SClient init K8S client
 func SetUpK8SClient() (*kubernetes.Clientset, error) {
 	var contivK8sCfg ContivConfig
@@ -67,6 +107,12 @@ func SetUpK8SClient() (*kubernetes.Clientset, error) {
 		BearerToken:     contivK8sCfg.K8sToken,
 		TLSClientConfig: k8sRest.TLSClientConfig{CAFile: contivK8sCfg.K8sCa},
 	}
+	// use client certs when both cert and key are configured
+	if len(strings.TrimSpace(contivK8sCfg.K8sCert)) > 0 &&
+		len(strings.TrimSpace(contivK8sCfg.K8sKey)) > 0 {
+		restCfg.TLSClientConfig.CertFile = contivK8sCfg.K8sCert
+		restCfg.TLSClientConfig.KeyFile = contivK8sCfg.K8sKey
+	}
 	clientSet, err := kubernetes.NewForConfig(restCfg)
 	if err != nil {
 		log.Errorf("failed to create kubernetes client instance %s, %+v", err, restCfg)
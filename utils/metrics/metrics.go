@@ -0,0 +1,203 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the prometheus metrics served on /metrics by
+// netmaster and netplugin. The metric names and labels are part of the
+// monitoring interface of contiv, change them with care.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "contiv"
+
+// Results of an operation, value of the "result" label
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// Operations and events, updated by the daemons as they happen
+var (
+	// EndpointOperations counts the endpoint create and delete requests of
+	// the plugin apis.
+	// Labels: plugin (docker, k8s, mesos, netmaster), op (create, delete),
+	// result (success, error)
+	EndpointOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "endpoint_operations_total",
+		Help:      "Endpoint create and delete requests of the plugin apis.",
+	}, []string{"plugin", "op", "result"})
+
+	// EndpointOperationDuration is the latency of the endpoint create and
+	// delete requests of the plugin apis.
+	// Labels: plugin (docker, k8s, mesos, netmaster), op (create, delete)
+	EndpointOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "endpoint_operation_duration_seconds",
+		Help:      "Latency of the endpoint create and delete requests of the plugin apis.",
+	}, []string{"plugin", "op"})
+
+	// StateOperationDuration is the latency of the state store operations.
+	// Labels: driver (etcd, consul, bolt), op (read, readAll, write, clear)
+	StateOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "state_operation_duration_seconds",
+		Help:      "Latency of the state store operations.",
+	}, []string{"driver", "op"})
+
+	// StateOperationErrors counts the failed state store operations, reads
+	// of missing keys are not failures.
+	// Labels: driver (etcd, consul, bolt), op (read, readAll, write, clear)
+	StateOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "state_operation_errors_total",
+		Help:      "Failed state store operations.",
+	}, []string{"driver", "op"})
+
+	// StateWatchEventLag is the time between a watched change being received
+	// from the state store and its delivery to the watcher.
+	StateWatchEventLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "state_watch_event_lag_seconds",
+		Help:      "Time between a state store change being received and its delivery to the watcher.",
+	})
+
+	// NetmasterLeader is 1 when the netmaster holds the leader lock, 0 when
+	// it is a follower.
+	NetmasterLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "netmaster_leader",
+		Help:      "1 when the netmaster is the leader, 0 when it is a follower.",
+	})
+
+	// NetmasterLeaderChanges counts the leader lock acquisitions and losses
+	// of the netmaster.
+	NetmasterLeaderChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "netmaster_leader_changes_total",
+		Help:      "Leader lock acquisitions and losses of the netmaster.",
+	})
+)
+
+// Resource usage and datapath counters, read from the state or the driver
+// when /metrics is scraped
+var (
+	// VLANPoolSize is the number of vlans in the global vlan pool
+	VLANPoolSize = prometheus.NewDesc(namespace+"_vlan_pool_size",
+		"Vlans in the global vlan pool.", nil, nil)
+
+	// VLANPoolAllocated is the number of vlans allocated from the global
+	// vlan pool
+	VLANPoolAllocated = prometheus.NewDesc(namespace+"_vlan_pool_allocated",
+		"Vlans allocated from the global vlan pool.", nil, nil)
+
+	// VXLANPoolSize is the number of vxlans in the global vxlan pool
+	VXLANPoolSize = prometheus.NewDesc(namespace+"_vxlan_pool_size",
+		"Vxlans in the global vxlan pool.", nil, nil)
+
+	// VXLANPoolAllocated is the number of vxlans allocated from the global
+	// vxlan pool
+	VXLANPoolAllocated = prometheus.NewDesc(namespace+"_vxlan_pool_allocated",
+		"Vxlans allocated from the global vxlan pool.", nil, nil)
+
+	// NetworkIPPoolSize is the number of addresses of a network available
+	// to endpoints, allocated or not.
+	// Labels: tenant, network
+	NetworkIPPoolSize = prometheus.NewDesc(namespace+"_network_ip_pool_size",
+		"Addresses of a network available to endpoints.", []string{"tenant", "network"}, nil)
+
+	// NetworkIPPoolAllocated is the number of addresses of a network
+	// allocated to endpoints.
+	// Labels: tenant, network
+	NetworkIPPoolAllocated = prometheus.NewDesc(namespace+"_network_ip_pool_allocated",
+		"Addresses of a network allocated to endpoints.", []string{"tenant", "network"}, nil)
+
+	// NameserverEvents counts the events of the netplugin nameserver, the
+	// queries (foundNameRecord, noNameRecord, invalidQuery, ...) per tenant
+	// and the errors, suffixed with Error, with an empty tenant for the
+	// errors not specific to a tenant.
+	// Labels: tenant, event
+	NameserverEvents = prometheus.NewDesc(namespace+"_nameserver_events_total",
+		"Queries and errors of the netplugin nameserver.", []string{"tenant", "event"}, nil)
+
+	// ServicePackets counts the packets of the service flows of the local
+	// endpoints.
+	// Labels: vrf, service_ip, protocol, port, direction (in, out)
+	ServicePackets = prometheus.NewDesc(namespace+"_service_packets_total",
+		"Packets of the service flows of the local endpoints.",
+		[]string{"vrf", "service_ip", "protocol", "port", "direction"}, nil)
+
+	// ServiceBytes counts the bytes of the service flows of the local
+	// endpoints.
+	// Labels: vrf, service_ip, protocol, port, direction (in, out)
+	ServiceBytes = prometheus.NewDesc(namespace+"_service_bytes_total",
+		"Bytes of the service flows of the local endpoints.",
+		[]string{"vrf", "service_ip", "protocol", "port", "direction"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(EndpointOperations)
+	prometheus.MustRegister(EndpointOperationDuration)
+	prometheus.MustRegister(StateOperationDuration)
+	prometheus.MustRegister(StateOperationErrors)
+	prometheus.MustRegister(StateWatchEventLag)
+	prometheus.MustRegister(NetmasterLeader)
+	prometheus.MustRegister(NetmasterLeaderChanges)
+}
+
+// result returns the result label of an operation
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+// ObserveEndpointOp records an endpoint operation of a plugin api started
+// at start
+func ObserveEndpointOp(plugin, op string, start time.Time, err error) {
+	EndpointOperations.WithLabelValues(plugin, op, result(err)).Inc()
+	EndpointOperationDuration.WithLabelValues(plugin, op).Observe(time.Since(start).Seconds())
+}
+
+// ObserveStateOp records a state store operation started at start
+func ObserveStateOp(driver, op string, start time.Time, err error) {
+	StateOperationDuration.WithLabelValues(driver, op).Observe(time.Since(start).Seconds())
+	if core.ErrIfKeyExists(err) != nil {
+		StateOperationErrors.WithLabelValues(driver, op).Inc()
+	}
+}
+
+// SetLeader records the leader state of the netmaster
+func SetLeader(isLeader bool) {
+	NetmasterLeaderChanges.Inc()
+	if isLeader {
+		NetmasterLeader.Set(1)
+	} else {
+		NetmasterLeader.Set(0)
+	}
+}
+
+// Handler returns the handler of /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
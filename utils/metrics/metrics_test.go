@@ -0,0 +1,94 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterValue returns the value of a counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatalf("Error reading counter. Err: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestObserveStateOp(t *testing.T) {
+	errCount := StateOperationErrors.WithLabelValues("test", "read")
+
+	ObserveStateOp("test", "read", time.Now(), nil)
+	ObserveStateOp("test", "read", time.Now(), core.Errorf("Key not found"))
+	if v := counterValue(t, errCount); v != 0 {
+		t.Fatalf("missing keys counted as errors: %v", v)
+	}
+
+	ObserveStateOp("test", "read", time.Now(), core.Errorf("connection refused"))
+	if v := counterValue(t, errCount); v != 1 {
+		t.Fatalf("expected 1 error, got %v", v)
+	}
+}
+
+func TestObserveEndpointOp(t *testing.T) {
+	ObserveEndpointOp("test", "create", time.Now(), nil)
+	ObserveEndpointOp("test", "create", time.Now(), nil)
+	ObserveEndpointOp("test", "create", time.Now(), errors.New("failed"))
+
+	if v := counterValue(t, EndpointOperations.WithLabelValues("test", "create", ResultSuccess)); v != 2 {
+		t.Fatalf("expected 2 successful creates, got %v", v)
+	}
+	if v := counterValue(t, EndpointOperations.WithLabelValues("test", "create", ResultError)); v != 1 {
+		t.Fatalf("expected 1 failed create, got %v", v)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	SetLeader(true)
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error getting metrics. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading metrics. Err: %v", err)
+	}
+
+	for _, line := range []string{
+		"contiv_netmaster_leader 1",
+		"contiv_netmaster_leader_changes_total 1",
+		"# TYPE contiv_state_watch_event_lag_seconds histogram",
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Fatalf("metrics have no %q:\n%s", line, body)
+		}
+	}
+}